@@ -0,0 +1,218 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DefaultAccrualWindow is how far back the accrual rate of a venue is estimated from by default.
+const DefaultAccrualWindow = 30 * 24 * time.Hour
+
+// Units the accrual of a venue is measured in.
+const (
+	AccrualUnitAtom = "atom" // venues holding only ATOM and its liquid staking tokens
+	AccrualUnitUSD  = "usd"
+)
+
+// AccrualInterval is the growth of a venue between two snapshots.
+type AccrualInterval struct {
+	From             time.Time `json:"from"`
+	To               time.Time `json:"to"`
+	DailyRatePercent float64   `json:"daily_rate_percent"`
+}
+
+// VenueAccrual is the estimated daily yield of a venue, from the growth of its principal and
+// rewards between consecutive snapshots.
+type VenueAccrual struct {
+	VenueID   string            `json:"venue_id"`
+	Unit      string            `json:"unit"`
+	Intervals []AccrualInterval `json:"intervals"`
+	// ExcludedIntervals had cash flows of the bid, like withdrawals or reward claims, which would
+	// pass for yield.
+	ExcludedIntervals int `json:"excluded_intervals"`
+	// DailyRatePercent weights the intervals by their value and duration, nil without intervals.
+	DailyRatePercent  *float64 `json:"daily_rate_percent"`
+	AnnualizedPercent *float64 `json:"annualized_percent"` // the daily rate times 365
+}
+
+type accrualPoint struct {
+	timestamp time.Time
+	valueAtom float64
+	valueUSD  float64
+	atomOnly  bool
+}
+
+// venueAccrual estimates the daily yield of a venue from its snapshots, keeping the last point of
+// every interval. ATOM venues are measured in ATOM, so that moves of the ATOM price don't pass
+// for yield, the others in USD.
+func venueAccrual(bidId int, id string, from time.Time, to time.Time, interval time.Duration) (*VenueAccrual, error) {
+	var points []accrualPoint
+	err := snapshotStore.Range(from, to, func(snapshot BidSnapshot) error {
+		for _, venueHoldings := range snapshot.Holdings {
+			if venueHoldings.VenueID != id || venueHoldings.AddressPrincipal == nil || venueHoldings.Stale {
+				continue
+			}
+
+			point := accrualPoint{timestamp: snapshot.Timestamp}
+			point.valueAtom, point.valueUSD, point.atomOnly = venueValue(venueHoldings)
+
+			last := len(points) - 1
+			if interval > 0 && last >= 0 && points[last].timestamp.Truncate(interval).Equal(point.timestamp.Truncate(interval)) {
+				points[last] = point
+			} else {
+				points = append(points, point)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	accrual := &VenueAccrual{VenueID: id, Unit: AccrualUnitUSD, Intervals: []AccrualInterval{}}
+	if len(points) > 0 && points[len(points)-1].atomOnly {
+		accrual.Unit = AccrualUnitAtom
+	}
+	value := func(point accrualPoint) float64 {
+		if accrual.Unit == AccrualUnitAtom {
+			return point.valueAtom
+		}
+		return point.valueUSD
+	}
+
+	flows := bidCashFlowDates(bidId, id)
+	growth, capitalDays := 0.0, 0.0
+	for i := 1; i < len(points); i++ {
+		start, end := points[i-1], points[i]
+		days := end.timestamp.Sub(start.timestamp).Hours() / 24
+		if days <= 0 || value(start) <= 0 {
+			continue
+		}
+		if hasDateBetween(flows, start.timestamp, end.timestamp) {
+			accrual.ExcludedIntervals++
+			continue
+		}
+
+		accrual.Intervals = append(accrual.Intervals, AccrualInterval{
+			From:             start.timestamp,
+			To:               end.timestamp,
+			DailyRatePercent: (value(end) - value(start)) / value(start) / days * 100,
+		})
+		growth += value(end) - value(start)
+		capitalDays += value(start) * days
+	}
+
+	if capitalDays > 0 {
+		daily := growth / capitalDays * 100
+		annualized := daily * 365
+		accrual.DailyRatePercent = &daily
+		accrual.AnnualizedPercent = &annualized
+	}
+
+	return accrual, nil
+}
+
+// venueValue sums the principal and rewards of a venue in ATOM and USD, and reports whether its
+// principal holds only ATOM and its liquid staking tokens.
+func venueValue(venueHoldings VenueHoldings) (float64, float64, bool) {
+	valueAtom, valueUSD, atomOnly := 0.0, 0.0, true
+	for _, holdings := range []*Holdings{venueHoldings.AddressPrincipal, venueHoldings.AddressRewards} {
+		if holdings == nil {
+			continue
+		}
+		valueAtom += holdings.TotalAtom
+		valueUSD += holdings.TotalUSDC
+	}
+	if venueHoldings.AddressPrincipal != nil {
+		for _, asset := range venueHoldings.AddressPrincipal.Balances {
+			if rewardCategory(asset.DisplayName) != RewardCategoryAtom {
+				atomOnly = false
+			}
+		}
+	}
+	return valueAtom, valueUSD, atomOnly
+}
+
+// bidCashFlowDates returns the dates funds entered or left a bid, or the rewards of one of its
+// venues were claimed: of the given venue, or of any venue if venueId is empty.
+func bidCashFlowDates(bidId int, venueId string) []time.Time {
+	bidConfig := activeBids()[bidId]
+
+	var dates []time.Time
+	for _, withdrawal := range bidConfig.Withdrawals {
+		dates = append(dates, withdrawal.Date)
+	}
+	for _, allocation := range bidConfig.Allocations {
+		dates = append(dates, allocation.Date)
+	}
+	for _, transfer := range bidTransfers(bidId) {
+		dates = append(dates, transfer.Date)
+	}
+	for _, claim := range bidConfig.RewardClaims {
+		if venueId == "" || claim.VenueID == venueId {
+			dates = append(dates, claim.Date)
+		}
+	}
+	return dates
+}
+
+// hasDateBetween reports whether one of the dates is in (from, to].
+func hasDateBetween(dates []time.Time, from time.Time, to time.Time) bool {
+	for _, date := range dates {
+		if date.After(from) && !date.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// venueAccrualHandler serves the estimated daily yield of a venue. The time window can be
+// restricted with the RFC 3339 from and to query parameters, and defaults to the last 30 days.
+// The snapshots are thinned out to one per interval, 24h by default.
+func venueAccrualHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["venue_id"]
+	bidId, _, ok := findVenue(id)
+	if !ok {
+		http.Error(w, "venue not found: "+id, http.StatusNotFound)
+		return
+	}
+
+	if snapshotStore == nil {
+		http.Error(w, "snapshots are disabled", http.StatusNotFound)
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.IsZero() {
+		from = time.Now().Add(-DefaultAccrualWindow)
+	}
+
+	interval := 24 * time.Hour
+	if intervalStr := r.URL.Query().Get("interval"); intervalStr != "" {
+		if interval, err = time.ParseDuration(intervalStr); err != nil || interval < 0 {
+			http.Error(w, "invalid interval", http.StatusBadRequest)
+			return
+		}
+	}
+
+	accrual, err := venueAccrual(bidId, id, from, to, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := marshalResponse(r, accrual)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// bidConfigMu serializes admin changes of bid configs, so that checking the version a change was
+// based on and applying it are atomic.
+var bidConfigMu sync.Mutex
+
+// bidConfigVersion identifies the content of a bid config, as its ETag. Any change of the config,
+// through the admin API or otherwise, changes the version.
+func bidConfigVersion(bidId int, bidConfig BidPositionConfig) (string, error) {
+	bid, err := encodeBidConfig(bidId, bidConfig)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(bid)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:8]) + `"`, nil
+}
+
+// checkBidVersion implements optimistic concurrency for changes of a bid config, so that two admins
+// editing the same bid can't silently overwrite each other's changes. Changes of an existing bid
+// must send the ETag they were based on in If-Match, and creations can send "If-None-Match: *" to
+// fail if the bid was created in the meantime. It writes an error response and returns false if
+// the precondition fails. Call it with bidConfigMu held, until the change is applied.
+func checkBidVersion(w http.ResponseWriter, r *http.Request, bidId int) bool {
+	bidConfig, exists := activeBids()[bidId]
+
+	if !exists {
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != "*" {
+			http.Error(w, fmt.Sprintf("bid %d doesn't exist anymore", bidId), http.StatusPreconditionFailed)
+			return false
+		}
+		return true
+	}
+
+	version, err := bidConfigVersion(bidId, bidConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	w.Header().Set("ETag", version)
+
+	if r.Header.Get("If-None-Match") == "*" {
+		http.Error(w, fmt.Sprintf("bid %d already exists", bidId), http.StatusPreconditionFailed)
+		return false
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match with the ETag of the bid config is required", http.StatusPreconditionRequired)
+		return false
+	}
+	if ifMatch == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == version {
+			return true
+		}
+	}
+	http.Error(w, fmt.Sprintf("bid %d was changed in the meantime, its version is now %s", bidId, version), http.StatusPreconditionFailed)
+	return false
+}
+
+// adminConfigStore persists the changes of bid configs through the admin API. Without it,
+// the admin API can't change bids.
+var adminConfigStore ConfigStore
+
+var (
+	errBidNotFound = errors.New("bid not found")
+	errBidExists   = errors.New("bid already exists")
+)
+
+// adminBidHandler serves the config of a bid in the config store format, with its version as ETag.
+func adminBidHandler(w http.ResponseWriter, r *http.Request) {
+	bidId, err := strconv.Atoi(mux.Vars(r)["bid_id"])
+	if err != nil {
+		http.Error(w, "invalid bid ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bidConfigMu.Lock()
+	bidConfig, ok := activeBids()[bidId]
+	bidConfigMu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("bid not found: %d", bidId), http.StatusNotFound)
+		return
+	}
+
+	writeAdminBid(w, r, http.StatusOK, bidId, bidConfig)
+}
+
+// createBidHandler adds a bid, given in the config store format.
+func createBidHandler(w http.ResponseWriter, r *http.Request) {
+	var bid storedBid
+	if err := decodeAdminRequest(r, &bid); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changeBid(w, r, "create", bid.BidId, http.StatusCreated, func(current *BidPositionConfig) (*BidPositionConfig, error) {
+		if current != nil {
+			return nil, errBidExists
+		}
+		bidConfig, err := decodeBidConfig(bid)
+		return &bidConfig, err
+	})
+}
+
+// updateBidHandler replaces the config of a bid, given in the config store format, or deletes the bid.
+func updateBidHandler(w http.ResponseWriter, r *http.Request) {
+	bidId, err := strconv.Atoi(mux.Vars(r)["bid_id"])
+	if err != nil {
+		http.Error(w, "invalid bid ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		changeBid(w, r, "delete", bidId, http.StatusNoContent, func(current *BidPositionConfig) (*BidPositionConfig, error) {
+			if current == nil {
+				return nil, errBidNotFound
+			}
+			return nil, nil
+		})
+		return
+	}
+
+	var bid storedBid
+	if err := decodeAdminRequest(r, &bid); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if bid.BidId != 0 && bid.BidId != bidId {
+		http.Error(w, fmt.Sprintf("the body is for bid %d, not %d", bid.BidId, bidId), http.StatusBadRequest)
+		return
+	}
+	bid.BidId = bidId
+
+	changeBid(w, r, "update", bidId, http.StatusOK, func(current *BidPositionConfig) (*BidPositionConfig, error) {
+		bidConfig, err := decodeBidConfig(bid)
+		return &bidConfig, err
+	})
+}
+
+// addWithdrawalHandler appends a withdrawal to a bid.
+func addWithdrawalHandler(w http.ResponseWriter, r *http.Request) {
+	bidId, err := strconv.Atoi(mux.Vars(r)["bid_id"])
+	if err != nil {
+		http.Error(w, "invalid bid ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var withdrawal Withdrawal
+	if err := decodeAdminRequest(r, &withdrawal); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changeBid(w, r, "add_withdrawal", bidId, http.StatusOK, func(current *BidPositionConfig) (*BidPositionConfig, error) {
+		if current == nil {
+			return nil, errBidNotFound
+		}
+		next := *current
+		next.Withdrawals = append(append([]Withdrawal(nil), current.Withdrawals...), withdrawal)
+		return &next, nil
+	})
+}
+
+// updateVenueHandler changes fields of a venue config, e.g. {"ActiveShares": 1000}. The changed
+// config is validated against the config type of the venue's kind, and can't change the venue ID.
+func updateVenueHandler(w http.ResponseWriter, r *http.Request) {
+	bidId, err := strconv.Atoi(mux.Vars(r)["bid_id"])
+	if err != nil {
+		http.Error(w, "invalid bid ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	id := mux.Vars(r)["venue_id"]
+
+	var fields map[string]json.RawMessage
+	if err := decodeAdminRequest(r, &fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	changeBid(w, r, "update_venue", bidId, http.StatusOK, func(current *BidPositionConfig) (*BidPositionConfig, error) {
+		if current == nil {
+			return nil, errBidNotFound
+		}
+
+		next := *current
+		next.Venues = append([]VenuePositionConfig(nil), current.Venues...)
+		for i, venueConfig := range next.Venues {
+			if venueID(bidId, venueConfig) != id {
+				continue
+			}
+
+			kind, err := venueConfigKind(venueConfig)
+			if err != nil {
+				return nil, err
+			}
+			merged, err := mergeJSONFields(venueConfig, fields)
+			if err != nil {
+				return nil, err
+			}
+			updated, err := venueConfigKinds[kind](merged)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s venue config: %v", kind, err)
+			}
+			if venueID(bidId, updated) != id {
+				return nil, fmt.Errorf("the change would change the venue ID to %s, add a new venue superseding it instead", venueID(bidId, updated))
+			}
+			next.Venues[i] = updated
+			return &next, nil
+		}
+		return nil, fmt.Errorf("venue %s is not a venue of bid %d", id, bidId)
+	})
+}
+
+// changeBid applies a change of a bid config through the admin API: it checks the version the
+// change is based on, validates the result together with all other bids, records it in the audit
+// log, saves it to the config store and serves it. change returns the new config of the bid, or
+// nil to delete it; its errors are the client's.
+func changeBid(w http.ResponseWriter, r *http.Request, action string, bidId int, status int, change func(current *BidPositionConfig) (*BidPositionConfig, error)) {
+	if adminConfigStore == nil {
+		http.Error(w, "changing bids requires a JSON --config file to save them to", http.StatusForbidden)
+		return
+	}
+
+	bidConfigMu.Lock()
+	defer bidConfigMu.Unlock()
+
+	// a created bid isn't based on an earlier version
+	if action != "create" && !checkBidVersion(w, r, bidId) {
+		return
+	}
+
+	previous := activeBids()
+	var current *BidPositionConfig
+	if bidConfig, ok := previous[bidId]; ok {
+		current = &bidConfig
+	}
+
+	next, err := change(current)
+	if errors.Is(err, errBidNotFound) {
+		http.Error(w, fmt.Sprintf("bid not found: %d", bidId), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errBidExists) {
+		http.Error(w, fmt.Sprintf("bid %d already exists", bidId), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bids := withBidConfig(previous, bidId, next)
+	if errs := validateBidConfigs(bids); len(errs) > 0 {
+		messages := make([]string, 0, len(errs))
+		for _, err := range errs {
+			messages = append(messages, err.Error())
+		}
+		http.Error(w, "invalid bid config:\n"+strings.Join(messages, "\n"), http.StatusBadRequest)
+		return
+	}
+
+	// the change must not be applied if it can't be recorded
+	if err := auditBidChange(r, action, bidId, current, next); err != nil {
+		http.Error(w, "recording the change: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the store may have bids that a profile leaves out
+	stored, err := adminConfigStore.LoadBids()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := adminConfigStore.SaveBids(withBidConfig(stored, bidId, next)); errors.Is(err, errConfigConflict) {
+		http.Error(w, "saving the change: "+err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, "saving the change: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	setActiveBids(bids)
+	logBidConfigWarnings(bids)
+	if current != nil {
+		var nextConfig BidPositionConfig
+		if next != nil {
+			nextConfig = *next
+		}
+		invalidateBid(bidId, *current, nextConfig)
+	}
+
+	if next == nil {
+		w.WriteHeader(status)
+		return
+	}
+	writeAdminBid(w, r, status, bidId, *next)
+}
+
+// withBidConfig returns a copy of the bid configs with the config of a bid replaced, or removed if nil.
+func withBidConfig(bids map[int]BidPositionConfig, bidId int, bidConfig *BidPositionConfig) map[int]BidPositionConfig {
+	result := make(map[int]BidPositionConfig, len(bids)+1)
+	for otherBidId, otherConfig := range bids {
+		result[otherBidId] = otherConfig
+	}
+	if bidConfig == nil {
+		delete(result, bidId)
+	} else {
+		result[bidId] = *bidConfig
+	}
+	return result
+}
+
+// mergeJSONFields overrides fields of the JSON encoding of a value.
+func mergeJSONFields(v interface{}, fields map[string]json.RawMessage) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(data, &merged); err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		merged[name] = value
+	}
+	return json.Marshal(merged)
+}
+
+// decodeAdminRequest decodes the body of an admin request, rejecting unknown fields to catch typos.
+func decodeAdminRequest(r *http.Request, v interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request: %v", err)
+	}
+	return nil
+}
+
+// writeAdminBid serves the config of a bid in the config store format, with its version as ETag.
+func writeAdminBid(w http.ResponseWriter, r *http.Request, status int, bidId int, bidConfig BidPositionConfig) {
+	bid, err := encodeBidConfig(bidId, bidConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	version, err := bidConfigVersion(bidId, bidConfig)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", version)
+	if r.Method == http.MethodGet && r.Header.Get("If-None-Match") == version {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(bid, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/admin/bids/"+strconv.Itoa(bidId))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
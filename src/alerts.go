@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// AlertCooldown suppresses repeated alerts of the same kind for the same subject.
+	AlertCooldown = time.Hour
+	// AlertWebhookTimeout bounds the delivery of a single alert.
+	AlertWebhookTimeout = 10 * time.Second
+)
+
+// alertWebhookURL receives alerts as JSON POST requests, e.g. a Slack incoming webhook.
+// If it is not set, alerts are only logged and counted.
+func alertWebhookURL() string {
+	return getSecret("ALERT_WEBHOOK_URL")
+}
+
+var alertsMetric = newCounter("alerts_total", "Number of alerts fired by kind.")
+
+// Alert kinds.
+const (
+	AlertSlowUpstream   = "slow_upstream"
+	AlertDeadUpstream   = "dead_upstream"
+	AlertPoolShare      = "pool_share"
+	AlertRefreshStall   = "refresh_stalled"
+	AlertConfigError    = "config_error"
+	AlertPoolDeprecated = "pool_deprecated"
+)
+
+// Alert is the payload posted to the alert webhook.
+type Alert struct {
+	Kind      string    `json:"kind"`
+	Subject   string    `json:"subject"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+	// Text duplicates the message for chat webhooks that only display a text field.
+	Text string `json:"text"`
+}
+
+var (
+	alertsMu    sync.Mutex
+	lastAlertAt = make(map[string]time.Time)
+)
+
+// fireAlert logs an alert and delivers it to the webhook, unless the same alert
+// fired within the cooldown.
+func fireAlert(kind string, subject string, message string) {
+	now := time.Now()
+	key := kind + "/" + subject
+
+	alertsMu.Lock()
+	if last, ok := lastAlertAt[key]; ok && now.Sub(last) < AlertCooldown {
+		alertsMu.Unlock()
+		return
+	}
+	lastAlertAt[key] = now
+	alertsMu.Unlock()
+
+	log.Printf("ALERT [%s] %s: %s", kind, subject, message)
+	alertsMetric.Add(1, "kind", kind)
+
+	if alertWebhookURL() == "" {
+		return
+	}
+
+	alert := Alert{
+		Kind:      kind,
+		Subject:   subject,
+		Message:   message,
+		Timestamp: now.UTC(),
+		Text:      "[" + kind + "] " + subject + ": " + message,
+	}
+	go deliverAlert(alert)
+}
+
+func deliverAlert(alert Alert) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Encoding alert failed: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), AlertWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", alertWebhookURL(), bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Creating alert request failed: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Printf("Delivering alert failed: %v", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("Delivering alert failed: status %d", resp.StatusCode)
+	}
+}
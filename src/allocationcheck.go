@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// NewBidWindow is how long after its deployment a bid counts as new, so that its principal is
+// compared to its allocation. Older bids have drifted away from it with their yield.
+const NewBidWindow = 7 * 24 * time.Hour
+
+// allocationTolerancePercent is how far the principal of a new bid may be from its allocation
+// before a config_error alert fires. Zero disables the check.
+var allocationTolerancePercent = 10.0
+
+var allocationDeviationMetric = newGauge("bid_allocation_deviation_percent",
+	"Deviation of the principal of a new bid from its allocation, in percent.")
+
+var (
+	allocationChecksMu sync.Mutex
+	// allocationChecks are the venues of the bids at the time they were checked, so that a bid is
+	// checked again once its venues are fixed.
+	allocationChecks = make(map[int]string)
+)
+
+// checkBidAllocation alerts if the principal of a new bid is far from its allocation, which
+// hints at wrong shares or position IDs in its config. Every bid is checked once, on its first
+// computation with all venues valued, and again whenever its venues change. Bids with
+// withdrawals or transfers, or deployed before the NewBidWindow, are not checked.
+func checkBidAllocation(bidId int, bidHoldings []VenueHoldings) {
+	if allocationTolerancePercent <= 0 {
+		return
+	}
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return
+	}
+
+	venues := fmt.Sprintf("%+v", bidConfig.Venues)
+	allocationChecksMu.Lock()
+	checked := allocationChecks[bidId] == venues
+	allocationChecksMu.Unlock()
+	if checked {
+		return
+	}
+	markChecked := func() {
+		allocationChecksMu.Lock()
+		allocationChecks[bidId] = venues
+		allocationChecksMu.Unlock()
+	}
+
+	now := time.Now()
+	deployedAt, _, ok := bidDeploymentDate(bidId, bidConfig)
+	if !ok || now.Sub(deployedAt) > NewBidWindow || len(bidConfig.Withdrawals) > 0 || len(bidTransfers(bidId)) > 0 {
+		markChecked()
+		return
+	}
+
+	principalAtom := 0.0
+	for _, venueHoldings := range bidHoldings {
+		if venueHoldings.InfoMissing {
+			// venues without an integration are never valued
+			markChecked()
+			return
+		}
+		if venueHoldings.Pending || venueHoldings.Stale || venueHoldings.AddressPrincipal == nil {
+			// check again once all venues are valued
+			return
+		}
+		principalAtom += venueHoldings.AddressPrincipal.TotalAtom
+	}
+	markChecked()
+
+	allocated := allocatedAtom(bidConfig, now)
+	if allocated <= 0 {
+		return
+	}
+
+	deviation := math.Abs(principalAtom-allocated) / allocated * 100
+	allocationDeviationMetric.Set(deviation, "bid", strconv.Itoa(bidId))
+
+	if deviation > allocationTolerancePercent {
+		fireAlert(AlertConfigError, fmt.Sprintf("bid %d", bidId), fmt.Sprintf("the principal of %.0f ATOM is %.1f%% off the allocation of %.0f ATOM, over the tolerance of %.1f%%; check the shares and position IDs of its venues",
+			principalAtom, deviation, allocated, allocationTolerancePercent))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Allocation is an installment of the funds of a bid, in ATOM.
+type Allocation struct {
+	Date    time.Time `json:"date"`
+	Amount  float64   `json:"amount"`
+	VenueID string    `json:"venue_id,omitempty"` // the venue the funds went to, if a single one
+}
+
+// allocationTranches returns the allocations of a bid in chronological order. A bid with only an
+// InitialAllocation received all of it at deployedAt.
+func allocationTranches(bidConfig BidPositionConfig, deployedAt time.Time) []Allocation {
+	if len(bidConfig.Allocations) == 0 {
+		if bidConfig.InitialAllocation <= 0 {
+			return nil
+		}
+		return []Allocation{{Date: deployedAt, Amount: float64(bidConfig.InitialAllocation)}}
+	}
+
+	tranches := append([]Allocation(nil), bidConfig.Allocations...)
+	sort.SliceStable(tranches, func(i, j int) bool { return tranches[i].Date.Before(tranches[j].Date) })
+	return tranches
+}
+
+// totalAllocatedAtom sums all allocations of a bid.
+func totalAllocatedAtom(bidConfig BidPositionConfig) float64 {
+	total := float64(bidConfig.InitialAllocation)
+	for _, allocation := range bidConfig.Allocations {
+		total += allocation.Amount
+	}
+	return total
+}
+
+// allocatedAtom sums the allocations of a bid until the given time. An InitialAllocation counts
+// from the start.
+func allocatedAtom(bidConfig BidPositionConfig, until time.Time) float64 {
+	allocated := float64(bidConfig.InitialAllocation)
+	for _, allocation := range bidConfig.Allocations {
+		if !allocation.Date.After(until) {
+			allocated += allocation.Amount
+		}
+	}
+	return allocated
+}
+
+// timeWeightedAllocationAtom averages the allocations of a bid from deployedAt to end, weighting
+// each by the share of the period it was deployed for. It is the total allocation if all of it was
+// deployed at deployedAt.
+func timeWeightedAllocationAtom(bidConfig BidPositionConfig, deployedAt time.Time, end time.Time) float64 {
+	period := end.Sub(deployedAt)
+	if period <= 0 {
+		return allocatedAtom(bidConfig, end)
+	}
+
+	weighted := 0.0
+	for _, tranche := range allocationTranches(bidConfig, deployedAt) {
+		if tranche.Date.After(end) {
+			continue
+		}
+		start := tranche.Date
+		if start.Before(deployedAt) {
+			start = deployedAt
+		}
+		weighted += tranche.Amount * end.Sub(start).Seconds() / period.Seconds()
+	}
+	return weighted
+}
+
+// validateAllocations checks that the allocation tranches of a bid replace its InitialAllocation,
+// are dated and positive, go to venues of the bid and precede its withdrawals.
+func validateAllocations(bidId int, bidConfig BidPositionConfig) []error {
+	if len(bidConfig.Allocations) == 0 {
+		return nil
+	}
+
+	var errs []error
+	if bidConfig.InitialAllocation != 0 {
+		errs = append(errs, fmt.Errorf("bid %d: set either the initial allocation or allocations, not both", bidId))
+	}
+
+	venues := make(map[string]bool)
+	for _, venueConfig := range bidConfig.Venues {
+		venues[venueID(bidId, venueConfig)] = true
+	}
+
+	tranches := allocationTranches(bidConfig, time.Time{})
+	for _, tranche := range tranches {
+		if tranche.Date.IsZero() {
+			errs = append(errs, fmt.Errorf("bid %d: allocation of %.2f has no date", bidId, tranche.Amount))
+		}
+		if tranche.Amount <= 0 {
+			errs = append(errs, fmt.Errorf("bid %d: allocation on %s has a non-positive amount", bidId, tranche.Date.Format("2006-01-02")))
+		}
+		if tranche.VenueID != "" && !venues[tranche.VenueID] {
+			errs = append(errs, fmt.Errorf("bid %d: allocation on %s goes to %s, which is not a venue of the bid",
+				bidId, tranche.Date.Format("2006-01-02"), tranche.VenueID))
+		}
+	}
+
+	for _, withdrawal := range bidConfig.Withdrawals {
+		if !tranches[0].Date.Before(withdrawal.Date) {
+			errs = append(errs, fmt.Errorf("bid %d: first allocation on %s is not before the withdrawal on %s",
+				bidId, tranches[0].Date.Format("2006-01-02"), withdrawal.Date.Format("2006-01-02")))
+		}
+	}
+
+	return errs
+}
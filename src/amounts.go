@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Errors of parseAmount, to be checked with errors.Is.
+var (
+	errMalformedAmount  = errors.New("malformed amount")
+	errNegativeAmount   = errors.New("negative amount")
+	errAmountOutOfRange = errors.New("amount out of range")
+)
+
+// parseAmount parses an integer amount in the base unit of a token, as returned by the chain
+// APIs. Amounts of tokens with 18 decimals overflow int64, so they are kept as big integers, which
+// are only converted to float64 to value them.
+func parseAmount(s string) (*big.Int, error) {
+	amount, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", errMalformedAmount, s)
+	}
+	if amount.Sign() < 0 {
+		return nil, fmt.Errorf("%w: %s", errNegativeAmount, s)
+	}
+	if math.IsInf(amountFloat(amount), 0) {
+		return nil, fmt.Errorf("%w: %s", errAmountOutOfRange, s)
+	}
+	return amount, nil
+}
+
+// parseAmountValue parses an amount of decoded JSON, which the chain APIs encode as a string.
+func parseAmountValue(v interface{}) (*big.Int, error) {
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: %v is not a string", errMalformedAmount, v)
+	}
+	return parseAmount(s)
+}
+
+// amountFloat converts an amount to float64, e.g. to take a share of it. It keeps the magnitude of
+// the amount, but only 15 to 17 significant digits.
+func amountFloat(amount *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(amount).Float64()
+	return f
+}
+
+// tokenAmount converts an amount in the base unit of a token to whole tokens, to value it.
+func tokenAmount(amount *big.Int, decimals int) float64 {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil)
+	f, _ := new(big.Rat).SetFrac(amount, scale).Float64()
+	return f
+}
+
+// exactTokenAmount formats an amount in the base unit of a token as an exact decimal of whole
+// tokens, without trailing zeros.
+func exactTokenAmount(amount *big.Int, decimals int) string {
+	digits := amount.String()
+	if decimals <= 0 {
+		return digits
+	}
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	whole, fraction := digits[:len(digits)-decimals], strings.TrimRight(digits[len(digits)-decimals:], "0")
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
+
+// addAmount adds an amount to the one of a denom.
+func addAmount(amounts map[string]*big.Int, denom string, amount *big.Int) {
+	if amounts[denom] == nil {
+		amounts[denom] = new(big.Int)
+	}
+	amounts[denom].Add(amounts[denom], amount)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// AnalyticsQueueSize is the number of snapshots buffered for the analytics sink.
+	// Snapshots are dropped when the sink falls that far behind.
+	AnalyticsQueueSize = 256
+	// AnalyticsInsertTimeout bounds a single insert into the analytics sink.
+	AnalyticsInsertTimeout = 30 * time.Second
+)
+
+// Kinds of holdings in analytics rows.
+const (
+	HoldingsKindVenueTotal = "venue_total"
+	HoldingsKindPrincipal  = "principal"
+	HoldingsKindRewards    = "rewards"
+)
+
+var (
+	analyticsRowsMetric = newCounter("analytics_rows_inserted_total",
+		"Number of snapshot rows inserted into the analytics sink.")
+	analyticsFailuresMetric = newCounter("analytics_insert_failures_total",
+		"Number of snapshot inserts into the analytics sink that failed or were dropped.")
+)
+
+// AnalyticsRow is a single asset balance of a venue in a snapshot, flattened for SQL analysis.
+type AnalyticsRow struct {
+	Timestamp time.Time `json:"ts"`
+	BidId     int       `json:"bid_id"`
+	VenueID   string    `json:"venue_id"`
+	Protocol  Protocol  `json:"protocol"`
+	Kind      string    `json:"kind"`
+	Denom     string    `json:"denom"`
+	Amount    float64   `json:"amount"`
+	USD       float64   `json:"usd"`
+	Atom      float64   `json:"atom"`
+}
+
+// AnalyticsSink receives snapshot rows, e.g. a table in an analytics warehouse.
+type AnalyticsSink interface {
+	Insert(ctx context.Context, rows []AnalyticsRow) error
+}
+
+// analyticsRows flattens a snapshot into one row per venue, holdings kind and asset.
+func analyticsRows(snapshot BidSnapshot) []AnalyticsRow {
+	var rows []AnalyticsRow
+	for _, venueHoldings := range snapshot.Holdings {
+		kinds := []struct {
+			kind     string
+			holdings *Holdings
+		}{
+			{HoldingsKindVenueTotal, venueHoldings.VenueTotal},
+			{HoldingsKindPrincipal, venueHoldings.AddressPrincipal},
+			{HoldingsKindRewards, venueHoldings.AddressRewards},
+		}
+
+		for _, k := range kinds {
+			if k.holdings == nil {
+				continue
+			}
+
+			// liabilities are rows with negative amounts
+			for _, asset := range k.holdings.allAssets() {
+				rows = append(rows, AnalyticsRow{
+					Timestamp: snapshot.Timestamp,
+					BidId:     snapshot.BidId,
+					VenueID:   venueHoldings.VenueID,
+					Protocol:  venueHoldings.Protocol,
+					Kind:      k.kind,
+					Denom:     asset.Denom,
+					Amount:    asset.Amount,
+					USD:       asset.USDValue,
+					Atom:      asset.AtomValue,
+				})
+			}
+		}
+	}
+	return rows
+}
+
+// ClickHouseSink inserts rows into a ClickHouse table via the HTTP interface.
+type ClickHouseSink struct {
+	URL      string
+	Table    string
+	User     string
+	Password string
+}
+
+// analyticsSinkFromEnv configures the analytics sink from the environment.
+// It returns nil if ANALYTICS_CLICKHOUSE_URL is not set.
+func analyticsSinkFromEnv() AnalyticsSink {
+	chURL := os.Getenv("ANALYTICS_CLICKHOUSE_URL")
+	if chURL == "" {
+		return nil
+	}
+
+	sink := &ClickHouseSink{
+		URL:      strings.TrimSuffix(chURL, "/"),
+		Table:    os.Getenv("ANALYTICS_CLICKHOUSE_TABLE"),
+		User:     os.Getenv("ANALYTICS_CLICKHOUSE_USER"),
+		Password: getSecret("ANALYTICS_CLICKHOUSE_PASSWORD"),
+	}
+	if sink.Table == "" {
+		sink.Table = "deployment_snapshots"
+	}
+
+	return sink
+}
+
+func (s *ClickHouseSink) Insert(ctx context.Context, rows []AnalyticsRow) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("encoding row: %v", err)
+		}
+	}
+
+	params := url.Values{}
+	params.Set("query", "INSERT INTO "+s.Table+" FORMAT JSONEachRow")
+	params.Set("date_time_input_format", "best_effort")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL+"/?"+params.Encode(), &body)
+	if err != nil {
+		return err
+	}
+	if s.User != "" {
+		req.SetBasicAuth(s.User, s.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("inserting into ClickHouse: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("inserting into ClickHouse: status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// analyticsQueue is nil if the analytics sink is disabled.
+var analyticsQueue chan BidSnapshot
+
+// startAnalyticsSink streams recorded snapshots to the sink in the background,
+// so that slow inserts don't hold up the computation of holdings.
+func startAnalyticsSink(sink AnalyticsSink) {
+	analyticsQueue = make(chan BidSnapshot, AnalyticsQueueSize)
+
+	go func() {
+		for snapshot := range analyticsQueue {
+			rows := analyticsRows(snapshot)
+			if len(rows) == 0 {
+				continue
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), AnalyticsInsertTimeout)
+			err := sink.Insert(ctx, rows)
+			cancel()
+
+			if err != nil {
+				log.Printf("Inserting snapshot of bid %d into the analytics sink failed: %v", snapshot.BidId, err)
+				analyticsFailuresMetric.Add(1)
+				continue
+			}
+			analyticsRowsMetric.Add(float64(len(rows)))
+		}
+	}()
+}
+
+// queueAnalytics hands a snapshot to the analytics sink without blocking.
+func queueAnalytics(snapshot BidSnapshot) {
+	if analyticsQueue == nil {
+		return
+	}
+
+	select {
+	case analyticsQueue <- snapshot:
+	default:
+		log.Printf("Analytics queue is full, dropping snapshot of bid %d", snapshot.BidId)
+		analyticsFailuresMetric.Add(1)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveCheckInterval is how often the archiver checks for completed days to upload.
+const ArchiveCheckInterval = time.Hour
+
+var (
+	lastArchivedDayMetric = newGauge("last_archived_day_timestamp",
+		"Unix time of the start of the last day archived to object storage.")
+	archiveFailuresMetric = newCounter("archive_failures_total",
+		"Number of daily archive uploads that failed.")
+)
+
+// ObjectStore stores archive objects, e.g. in an S3 or GCS bucket.
+type ObjectStore interface {
+	Put(ctx context.Context, key string, contentType string, data []byte) error
+}
+
+// S3ObjectStore writes objects to an S3-compatible bucket using path-style URLs.
+// GCS can be used through its S3 interoperability endpoint with HMAC keys.
+type S3ObjectStore struct {
+	Endpoint    string
+	Region      string
+	Bucket      string
+	Prefix      string
+	Credentials AWSCredentials
+}
+
+// archiveStoreFromEnv configures the archive bucket from the environment.
+// It returns nil if ARCHIVE_BUCKET is not set.
+func archiveStoreFromEnv() (*S3ObjectStore, error) {
+	bucket := os.Getenv("ARCHIVE_BUCKET")
+	if bucket == "" {
+		return nil, nil
+	}
+
+	store := &S3ObjectStore{
+		Endpoint: os.Getenv("ARCHIVE_ENDPOINT"),
+		Region:   os.Getenv("ARCHIVE_REGION"),
+		Bucket:   bucket,
+		Prefix:   os.Getenv("ARCHIVE_PREFIX"),
+		Credentials: AWSCredentials{
+			AccessKeyID:     getSecret("ARCHIVE_ACCESS_KEY_ID"),
+			SecretAccessKey: getSecret("ARCHIVE_SECRET_ACCESS_KEY"),
+		},
+	}
+	if store.Region == "" {
+		store.Region = "us-east-1"
+	}
+	if store.Endpoint == "" {
+		store.Endpoint = "https://s3." + store.Region + ".amazonaws.com"
+	}
+	store.Endpoint = strings.TrimSuffix(store.Endpoint, "/")
+
+	if store.Credentials.AccessKeyID == "" || store.Credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("ARCHIVE_ACCESS_KEY_ID and ARCHIVE_SECRET_ACCESS_KEY must be set")
+	}
+
+	return store, nil
+}
+
+func (s *S3ObjectStore) Put(ctx context.Context, key string, contentType string, data []byte) error {
+	url := s.Endpoint + "/" + s.Bucket + "/" + awsURIEncode(s.Prefix+key, false)
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	signV4(req, data, s.Region, "s3", s.Credentials, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uploading %s: %v", key, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("uploading %s: status %d: %s", key, resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// DailyArchive is the full state of all bids at the end of a day,
+// i.e. the last snapshot of every bid taken that day.
+type DailyArchive struct {
+	Date        string        `json:"date"`
+	GeneratedAt time.Time     `json:"generated_at"`
+	Bids        []BidSnapshot `json:"bids"`
+}
+
+// buildDailyArchive collects the last snapshot of every bid taken on the given UTC day.
+func buildDailyArchive(day time.Time) (*DailyArchive, error) {
+	start := day.UTC().Truncate(24 * time.Hour)
+	end := start.Add(24*time.Hour - time.Nanosecond)
+
+	latest := make(map[int]BidSnapshot)
+	err := snapshotStore.Range(start, end, func(snapshot BidSnapshot) error {
+		latest[snapshot.BidId] = snapshot
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	archive := &DailyArchive{
+		Date:        start.Format(snapshotFileDateFormat),
+		GeneratedAt: time.Now().UTC(),
+		Bids:        make([]BidSnapshot, 0, len(latest)),
+	}
+	for _, snapshot := range latest {
+		archive.Bids = append(archive.Bids, snapshot)
+	}
+	sort.Slice(archive.Bids, func(i, j int) bool { return archive.Bids[i].BidId < archive.Bids[j].BidId })
+
+	return archive, nil
+}
+
+// archiveDay uploads the archive of the given day as <prefix>YYYY-MM-DD.json.
+// Days without snapshots are skipped.
+func archiveDay(ctx context.Context, store ObjectStore, day time.Time) error {
+	archive, err := buildDailyArchive(day)
+	if err != nil {
+		return fmt.Errorf("building archive: %v", err)
+	}
+	if len(archive.Bids) == 0 {
+		debugLog("No snapshots to archive", map[string]string{"date": archive.Date})
+		return nil
+	}
+
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return fmt.Errorf("encoding archive: %v", err)
+	}
+
+	return store.Put(ctx, archive.Date+".json", "application/json", sealJSON(data))
+}
+
+var (
+	archiveMu       sync.Mutex
+	lastArchivedDay time.Time
+)
+
+// archiveCompletedDays uploads every completed day since the last archived one.
+// After a restart, only the previous day is uploaded again.
+func archiveCompletedDays(ctx context.Context, store ObjectStore) {
+	archiveMu.Lock()
+	defer archiveMu.Unlock()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	day := today.Add(-24 * time.Hour)
+	if !lastArchivedDay.IsZero() {
+		day = lastArchivedDay.Add(24 * time.Hour)
+	}
+
+	for ; day.Before(today); day = day.Add(24 * time.Hour) {
+		if err := archiveDay(ctx, store, day); err != nil {
+			log.Printf("Archiving %s failed: %v", day.Format(snapshotFileDateFormat), err)
+			archiveFailuresMetric.Add(1)
+			return
+		}
+
+		lastArchivedDay = day
+		lastArchivedDayMetric.Set(float64(day.Unix()))
+	}
+}
+
+// startArchiver uploads the daily archives in the background.
+func startArchiver(store ObjectStore) {
+	go func() {
+		ticker := time.NewTicker(ArchiveCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if isLeader() {
+				archiveCompletedDays(context.Background(), store)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetListMaxStale is how long a cached asset list is still used after it expired, while its
+// upstream fails.
+const AssetListMaxStale = 24 * time.Hour
+
+var assetListRequestsMetric = newCounter("asset_list_requests_total",
+	"Number of asset list lookups by outcome: fresh (served from memory), revalidated (not modified), fetched or stale (upstream failed).")
+
+// cachedAssetList is a parsed asset list with the validators and freshness of its response.
+type cachedAssetList struct {
+	chainID      string
+	tokens       map[string]ChainTokenInfo // never modified once cached
+	etag         string
+	lastModified string
+	expires      time.Time
+	noStore      bool // the response forbids keeping it
+}
+
+var (
+	assetListCacheMu sync.Mutex
+	assetListCache   = make(map[string]*cachedAssetList)
+)
+
+// getAssetList returns the chain ID and tokens of an asset list. Lists are kept in memory for as
+// long as their Cache-Control allows, then revalidated with their ETag or Last-Modified, so that
+// an unchanged list costs a 304. If the upstream fails, an expired list is used for up to
+// AssetListMaxStale.
+func getAssetList(ctx context.Context, assetListUrl string) (string, map[string]ChainTokenInfo, error) {
+	assetListCacheMu.Lock()
+	cached := assetListCache[assetListUrl]
+	assetListCacheMu.Unlock()
+
+	now := time.Now()
+	if cached != nil && now.Before(cached.expires) {
+		assetListRequestsMetric.Add(1, "outcome", "fresh")
+		return cached.chainID, cached.tokens, nil
+	}
+
+	fetched, notModified, err := fetchAssetListResponse(ctx, assetListUrl, cached)
+	if err != nil {
+		if cached != nil && now.Sub(cached.expires) < AssetListMaxStale {
+			debugLog("Using a stale asset list", map[string]string{"url": assetListUrl, "error": err.Error()})
+			assetListRequestsMetric.Add(1, "outcome", "stale")
+			return cached.chainID, cached.tokens, nil
+		}
+		return "", nil, err
+	}
+
+	if notModified {
+		assetListRequestsMetric.Add(1, "outcome", "revalidated")
+		// the cached entry is shared with concurrent readers, so it is replaced rather than updated
+		revalidated := *cached
+		revalidated.expires = fetched.expires
+		revalidated.noStore = fetched.noStore
+		fetched = &revalidated
+	} else {
+		assetListRequestsMetric.Add(1, "outcome", "fetched")
+	}
+
+	assetListCacheMu.Lock()
+	if fetched.noStore {
+		delete(assetListCache, assetListUrl)
+	} else {
+		assetListCache[assetListUrl] = fetched
+	}
+	assetListCacheMu.Unlock()
+
+	return fetched.chainID, fetched.tokens, nil
+}
+
+// fetchAssetListResponse requests an asset list, conditionally if a cached one can be
+// revalidated. If the list wasn't modified, only the expiry of the returned entry is set.
+func fetchAssetListResponse(ctx context.Context, assetListUrl string, cached *cachedAssetList) (*cachedAssetList, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", assetListUrl, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if cached != nil {
+		if cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	maxAge, store := cacheControlMaxAge(resp.Header.Get("Cache-Control"))
+	expires := time.Now().Add(maxAge)
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return &cachedAssetList{expires: expires, noStore: !store}, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("fetching asset list: %d", resp.StatusCode)
+	}
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, err
+	}
+	chainID, tokens, err := parseAssetList(result)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &cachedAssetList{
+		chainID:      chainID,
+		tokens:       tokens,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		expires:      expires,
+		noStore:      !store,
+	}, false, nil
+}
+
+// cacheControlMaxAge returns how long a response may be reused without revalidation, its max-age
+// or 0 if it has none or has no-cache, and whether it may be kept at all, which no-store forbids.
+func cacheControlMaxAge(cacheControl string) (time.Duration, bool) {
+	var maxAge time.Duration
+	noCache := false
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			if seconds, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil && seconds > 0 {
+				maxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	if noCache {
+		return 0, true
+	}
+	return maxAge, true
+}
+
+// parseAssetList reads the chain ID and tokens of a chains.cosmos.directory asset list.
+func parseAssetList(result map[string]interface{}) (string, map[string]ChainTokenInfo, error) {
+	chain, ok := result["chain"].(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("invalid asset data structure")
+	}
+
+	chainID, ok := chain["chain_id"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("missing chain_id")
+	}
+
+	assets, ok := chain["assets"].([]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("invalid assets structure")
+	}
+
+	tokens := make(map[string]ChainTokenInfo)
+	for _, asset := range assets {
+		assetMap, ok := asset.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		denom, ok := assetMap["denom"].(string)
+		if !ok {
+			continue
+		}
+
+		token := ChainTokenInfo{
+			Denom: denom,
+		}
+
+		if symbol, ok := assetMap["symbol"].(string); ok {
+			token.Display = symbol
+		}
+
+		if decimals, ok := assetMap["decimals"].(float64); ok {
+			token.Decimals = int(decimals)
+		}
+
+		if coingeckoID, ok := assetMap["coingecko_id"].(string); ok {
+			token.CoingeckoID = coingeckoID
+		}
+
+		tokens[denom] = token
+	}
+
+	return chainID, tokens, nil
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math"
 	"strconv"
 	"strings"
 )
 
 type AstroportVenuePositionConfig struct {
+	VenueMetadata
+
 	PoolAddress      string // Contract address of the pool
 	Address          string
 	IncentiveAddress string
@@ -27,6 +29,10 @@ func (venueConfig AstroportVenuePositionConfig) GetAddress() string {
 	return venueConfig.Address
 }
 
+func (venueConfig AstroportVenuePositionConfig) GetActiveShares() float64 {
+	return float64(venueConfig.ActiveShares)
+}
+
 type AstroportPosition struct {
 	protocolConfig      ProtocolConfig
 	venuePositionConfig AstroportVenuePositionConfig
@@ -44,13 +50,13 @@ func NewAstroportPosition(config ProtocolConfig, venuePositionConfig VenuePositi
 	}, nil
 }
 
-func (p AstroportPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
+func (p AstroportPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	// Query pool info
 	queryMsg := map[string]interface{}{
 		"pool": map[string]interface{}{},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.PoolAddress, queryMsg)
 	if err != nil {
 		return nil, fmt.Errorf("querying pool data: %s", err)
@@ -68,7 +74,10 @@ func (p AstroportPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		info := assetMap["info"].(map[string]interface{})
 		nativeToken := info["native_token"].(map[string]interface{})
 		denom := nativeToken["denom"].(string)
-		amount, _ := strconv.ParseInt(assetMap["amount"].(string), 10, 64)
+		amount, err := parseAmountValue(assetMap["amount"])
+		if err != nil {
+			return nil, fmt.Errorf("parsing amount of %s: %w", denom, err)
+		}
 
 		tokenInfo, err := assetData.GetTokenInfo(denom)
 		if err != nil {
@@ -76,8 +85,8 @@ func (p AstroportPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 			continue
 		}
 
-		adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			debugLog("Error getting token values", map[string]string{"denom": denom})
 			continue
@@ -89,6 +98,7 @@ func (p AstroportPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		poolAssets = append(poolAssets, Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		})
@@ -101,7 +111,7 @@ func (p AstroportPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 	}, nil
 }
 
-func (p AstroportPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p AstroportPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -117,7 +127,7 @@ func (p AstroportPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo,
 		},
 	}
 
-	withdrawData, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	withdrawData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.PoolAddress, withdrawQuery)
 	if err != nil {
 		return nil, fmt.Errorf("simulating withdrawal: %s", err)
@@ -133,7 +143,10 @@ func (p AstroportPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo,
 		info := assetMap["info"].(map[string]interface{})
 		nativeToken := info["native_token"].(map[string]interface{})
 		denom := nativeToken["denom"].(string)
-		amount, _ := strconv.ParseInt(assetMap["amount"].(string), 10, 64)
+		amount, err := parseAmountValue(assetMap["amount"])
+		if err != nil {
+			return nil, fmt.Errorf("parsing amount of %s: %w", denom, err)
+		}
 
 		tokenInfo, err := assetData.GetTokenInfo(denom)
 		if err != nil {
@@ -141,8 +154,8 @@ func (p AstroportPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo,
 			continue
 		}
 
-		adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			debugLog("Error getting token values", map[string]string{"denom": denom})
 			continue
@@ -154,6 +167,7 @@ func (p AstroportPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo,
 		holdingAssets = append(holdingAssets, Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		})
@@ -166,12 +180,12 @@ func (p AstroportPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo,
 	}, nil
 }
 
-func GetLPToken(p AstroportPosition) (string, error) {
+func GetLPToken(ctx context.Context, p AstroportPosition) (string, error) {
 	pairQuery := map[string]interface{}{
 		"pair": map[string]interface{}{},
 	}
 
-	pairData, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	pairData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.PoolAddress, pairQuery)
 	if err != nil {
 		return "", fmt.Errorf("querying pair info: %s", err)
@@ -181,8 +195,12 @@ func GetLPToken(p AstroportPosition) (string, error) {
 	return lpToken, nil
 }
 
+func (p AstroportPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
 // We can only calculate rewards per address, not per bid.
-func (p AstroportPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p AstroportPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -192,7 +210,7 @@ func (p AstroportPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, ad
 	}
 
 	// First get LP token info
-	lpToken, err := GetLPToken(p)
+	lpToken, err := GetLPToken(ctx, p)
 	if err != nil {
 		return nil, err
 	}
@@ -204,7 +222,7 @@ func (p AstroportPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, ad
 		},
 	}
 
-	rewardsData, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	rewardsData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.IncentiveAddress, rewardsQuery)
 	if err != nil {
 		// Check if error is "user doesn't have position"
@@ -228,7 +246,10 @@ func (p AstroportPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, ad
 		info := rewardMap["info"].(map[string]interface{})
 		nativeToken := info["native_token"].(map[string]interface{})
 		denom := nativeToken["denom"].(string)
-		amount, _ := strconv.ParseInt(rewardMap["amount"].(string), 10, 64)
+		amount, err := parseAmountValue(rewardMap["amount"])
+		if err != nil {
+			return nil, fmt.Errorf("parsing reward amount of %s: %w", denom, err)
+		}
 
 		tokenInfo, err := assetData.GetTokenInfo(denom)
 		if err != nil {
@@ -236,8 +257,8 @@ func (p AstroportPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, ad
 			continue
 		}
 
-		adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			debugLog("Error getting token values", map[string]string{"denom": denom})
 			continue
@@ -249,6 +270,7 @@ func (p AstroportPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, ad
 		rewardAssets = append(rewardAssets, Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		})
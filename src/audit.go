@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuditEntry records a change of a bid config or an experimental deployment through the admin API.
+type AuditEntry struct {
+	Time           time.Time  `json:"time"`
+	Actor          string     `json:"actor"`
+	Action         string     `json:"action"`
+	BidId          *int       `json:"bid_id,omitempty"`
+	ExperimentalId *int       `json:"experimental_id,omitempty"`
+	Diff           []JSONDiff `json:"diff"`
+}
+
+// AuditLog persists audit entries as JSON lines. Entries are only ever appended.
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// auditLog is nil if the audit log is disabled, in which case changes are only logged.
+var auditLog *AuditLog
+
+func NewAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating audit log directory: %v", err)
+	}
+
+	return &AuditLog{path: path}, nil
+}
+
+func (l *AuditLog) Append(entry AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding audit entry: %v", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening audit log: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing audit entry: %v", err)
+	}
+	// the change is only applied once its entry is durable
+	return f.Sync()
+}
+
+// Entries returns the entries matching a filter, oldest first.
+func (l *AuditLog) Entries(filter func(AuditEntry) bool) ([]AuditEntry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %v", err)
+	}
+	defer f.Close()
+
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("decoding audit entry: %v", err)
+		}
+		if filter(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+// adminActor identifies who sent an admin request by the admin token it carries,
+// see adminTokens. The shared token is recorded with the client IP.
+func adminActor(r *http.Request) string {
+	identity, ok := adminIdentity(r)
+	if !ok || identity == "" {
+		return "admin@" + clientIP(r)
+	}
+	return identity
+}
+
+// auditBidChange records a change of a bid config, before it is applied. before is nil for
+// created bids and after is nil for deleted ones. The change must not be applied if it
+// can't be recorded.
+func auditBidChange(r *http.Request, action string, bidId int, before *BidPositionConfig, after *BidPositionConfig) error {
+	return auditBidChangeBy(adminActor(r), action, bidId, before, after)
+}
+
+// auditBidChangeBy records a change of a bid config by the given actor, e.g. an automatic one.
+func auditBidChangeBy(actor string, action string, bidId int, before *BidPositionConfig, after *BidPositionConfig) error {
+	// a missing bid is diffed as an empty object, so that every field shows up in the diff
+	var genericBefore, genericAfter interface{} = map[string]interface{}{}, map[string]interface{}{}
+	var err error
+	if before != nil {
+		if genericBefore, err = genericBidConfig(bidId, *before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if genericAfter, err = genericBidConfig(bidId, *after); err != nil {
+			return err
+		}
+	}
+
+	entry := AuditEntry{
+		Time:   time.Now().UTC(),
+		Actor:  actor,
+		Action: action,
+		BidId:  &bidId,
+		Diff:   diffJSON("", genericBefore, genericAfter),
+	}
+	log.Printf("Config change by %s: %s bid %d, %d changed values", entry.Actor, action, bidId, len(entry.Diff))
+
+	if auditLog == nil {
+		return nil
+	}
+	return auditLog.Append(entry)
+}
+
+// auditExperimentalChange records a change of an experimental deployment like auditBidChange.
+func auditExperimentalChange(r *http.Request, action string, id int, before *ExperimentalDeployment, after *ExperimentalDeployment) error {
+	var genericBefore, genericAfter interface{} = map[string]interface{}{}, map[string]interface{}{}
+	var err error
+	if before != nil {
+		if genericBefore, err = genericExperimental(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if genericAfter, err = genericExperimental(after); err != nil {
+			return err
+		}
+	}
+
+	entry := AuditEntry{
+		Time:           time.Now().UTC(),
+		Actor:          adminActor(r),
+		Action:         action,
+		ExperimentalId: &id,
+		Diff:           diffJSON("", genericBefore, genericAfter),
+	}
+	log.Printf("Config change by %s: %s %d, %d changed values", entry.Actor, action, id, len(entry.Diff))
+
+	if auditLog == nil {
+		return nil
+	}
+	return auditLog.Append(entry)
+}
+
+// auditHandler lists the recorded config changes, optionally filtered by bid_id, actor and
+// the from/to time window.
+func auditHandler(w http.ResponseWriter, r *http.Request) {
+	if auditLog == nil {
+		http.Error(w, "the audit log is disabled", http.StatusNotFound)
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bidId := -1
+	if bidIdStr := r.URL.Query().Get("bid_id"); bidIdStr != "" {
+		if bidId, err = strconv.Atoi(bidIdStr); err != nil {
+			http.Error(w, "invalid bid_id", http.StatusBadRequest)
+			return
+		}
+	}
+	actor := r.URL.Query().Get("actor")
+
+	entries, err := auditLog.Entries(func(entry AuditEntry) bool {
+		return (bidId < 0 || (entry.BidId != nil && *entry.BidId == bidId)) &&
+			(actor == "" || entry.Actor == actor) &&
+			(from.IsZero() || !entry.Time.Before(from)) &&
+			(to.IsZero() || !entry.Time.After(to))
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// adminAPIToken is the admin token shared by all admins. If neither it nor ADMIN_API_TOKENS
+// is set, the admin API is disabled.
+func adminAPIToken() string {
+	return getSecret("ADMIN_API_TOKEN")
+}
+
+// adminTokens maps the admin tokens to the identities recorded in the audit log. Every admin
+// has their own token in ADMIN_API_TOKENS, as comma-separated <name>:<token> pairs; malformed
+// pairs are ignored. The shared ADMIN_API_TOKEN can't tell admins apart and has an empty identity.
+func adminTokens() map[string]string {
+	tokens := map[string]string{}
+	if token := adminAPIToken(); token != "" {
+		tokens[token] = ""
+	}
+	for _, pair := range strings.Split(getSecret("ADMIN_API_TOKENS"), ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && name != "" && token != "" {
+			tokens[token] = name
+		}
+	}
+	return tokens
+}
+
+// requireAdmin rejects requests that don't carry the admin bearer token.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !checkAdmin(w, r) {
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// checkAdmin writes an error response and returns false if the request doesn't carry the admin bearer token.
+// It is used directly by public endpoints with admin-only options.
+func checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if len(adminTokens()) == 0 {
+		http.Error(w, "admin API is disabled", http.StatusForbidden)
+		return false
+	}
+
+	if !hasAdminToken(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// hasAdminToken reports whether the request carries an admin bearer token.
+func hasAdminToken(r *http.Request) bool {
+	_, ok := adminIdentity(r)
+	return ok
+}
+
+// adminIdentity returns the identity of the admin token the request carries.
+func adminIdentity(r *http.Request) (string, bool) {
+	authorization := []byte(r.Header.Get("Authorization"))
+	identity, found := "", false
+	// compare against every token, so that the timing doesn't tell which one matched
+	for token, name := range adminTokens() {
+		if subtle.ConstantTimeCompare(authorization, []byte("Bearer "+token)) == 1 {
+			identity, found = name, true
+		}
+	}
+	return identity, found
+}
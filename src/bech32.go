@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// decodeBech32 checks a bech32 address, e.g. osmo1..., and returns its human-readable prefix.
+func decodeBech32(address string) (string, error) {
+	if len(address) < 8 || len(address) > 90 {
+		return "", fmt.Errorf("invalid length %d", len(address))
+	}
+	if strings.ToLower(address) != address && strings.ToUpper(address) != address {
+		return "", fmt.Errorf("mixed case")
+	}
+	address = strings.ToLower(address)
+
+	sep := strings.LastIndexByte(address, '1')
+	if sep < 1 || sep+7 > len(address) {
+		return "", fmt.Errorf("missing separator or checksum")
+	}
+	hrp, data := address[:sep], address[sep+1:]
+	for _, c := range hrp {
+		if c < 33 || c > 126 {
+			return "", fmt.Errorf("invalid character in prefix")
+		}
+	}
+
+	values := make([]int, len(data))
+	for i, c := range data {
+		values[i] = strings.IndexRune(bech32Charset, c)
+		if values[i] < 0 {
+			return "", fmt.Errorf("invalid character %q", c)
+		}
+	}
+
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != 1 {
+		return "", fmt.Errorf("invalid checksum")
+	}
+	return hrp, nil
+}
+
+func bech32Polymod(values []int) int {
+	generator := []int{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := 1
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ v
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32ExpandHRP(hrp string) []int {
+	expanded := make([]int, 0, len(hrp)*2+1)
+	for _, c := range hrp {
+		expanded = append(expanded, int(c)>>5)
+	}
+	expanded = append(expanded, 0)
+	for _, c := range hrp {
+		expanded = append(expanded, int(c)&31)
+	}
+	return expanded
+}
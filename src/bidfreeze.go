@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DefaultFreezeAfter is how long after its last withdrawal a fully withdrawn bid is frozen.
+const DefaultFreezeAfter = 30 * 24 * time.Hour
+
+// FreezeCheckInterval is how often the freezer checks for bids to freeze.
+const FreezeCheckInterval = time.Hour
+
+// FreezeActor is the actor automatic freezes are recorded under in the audit trail.
+const FreezeActor = "freezer"
+
+var bidsFrozenMetric = newCounter("bids_frozen_total", "Number of fully withdrawn bids whose final summary was frozen")
+
+// FrozenBid is the final summary of a fully withdrawn bid, computed once and stored with its
+// config. Frozen bids are served from it and their venues are no longer queried.
+type FrozenBid struct {
+	FrozenAt        time.Time        `json:"frozen_at"`
+	Status          string           `json:"status"` // withdrawn or compounded
+	CompoundedInto  []int            `json:"compounded_into,omitempty"`
+	Holdings        []VenueHoldings  `json:"holdings"`
+	Performance     *BidPerformance  `json:"performance"`
+	LifetimeRewards *LifetimeRewards `json:"lifetime_rewards"`
+}
+
+// bidExitDate returns the date of the last withdrawal or transfer out of a bid, or the zero time.
+func bidExitDate(bidConfig BidPositionConfig) time.Time {
+	var exit time.Time
+	for _, withdrawal := range bidConfig.Withdrawals {
+		if withdrawal.Date.After(exit) {
+			exit = withdrawal.Date
+		}
+	}
+	for _, transfer := range bidConfig.Transfers {
+		if transfer.Date.After(exit) {
+			exit = transfer.Date
+		}
+	}
+	return exit
+}
+
+// computeFrozenBid computes the final summary of a bid. It returns nil if the bid still holds
+// funds or its holdings aren't final, e.g. because a venue failed.
+func computeFrozenBid(ctx context.Context, bidId int, bidConfig BidPositionConfig, now time.Time) (*FrozenBid, error) {
+	holdings, err := computeHoldings(ctx, bidId)
+	if err != nil {
+		return nil, err
+	}
+	for _, venueHoldings := range holdings {
+		if venueHoldings.Stale || venueHoldings.Pending {
+			return nil, nil
+		}
+	}
+
+	status, compoundedInto := bidStatus(bidId, bidConfig, holdings)
+	if status == BidStatusActive {
+		return nil, nil
+	}
+
+	performance := computeBidPerformance(bidId, bidConfig, holdings, now)
+	if performance != nil {
+		performance.USD = computeUSDPerformance(ctx, bidId, bidConfig, holdings, performance)
+	}
+
+	return &FrozenBid{
+		FrozenAt:        now.UTC(),
+		Status:          status,
+		CompoundedInto:  compoundedInto,
+		Holdings:        holdings,
+		Performance:     performance,
+		LifetimeRewards: computeLifetimeRewards(bidConfig, holdings),
+	}, nil
+}
+
+// freezeDueBids freezes the fully withdrawn bids whose last withdrawal is older than after.
+func freezeDueBids(ctx context.Context, after time.Duration) {
+	now := time.Now()
+	for _, bidId := range sortedBidIds() {
+		bidConfig, ok := activeBids()[bidId]
+		if !ok || bidConfig.Frozen != nil {
+			continue
+		}
+		exit := bidExitDate(bidConfig)
+		if exit.IsZero() || now.Sub(exit) < after {
+			continue
+		}
+
+		version, err := bidConfigVersion(bidId, bidConfig)
+		if err != nil {
+			log.Printf("Freezing bid %d failed: %v", bidId, err)
+			continue
+		}
+		frozen, err := computeFrozenBid(ctx, bidId, bidConfig, now)
+		if err != nil {
+			log.Printf("Freezing bid %d failed: %v", bidId, err)
+			continue
+		}
+		if frozen == nil {
+			continue
+		}
+
+		if err := freezeBid(bidId, version, frozen); err != nil {
+			log.Printf("Freezing bid %d failed: %v", bidId, err)
+			continue
+		}
+		bidsFrozenMetric.Add(1)
+		log.Printf("Froze bid %d, %s since %s", bidId, frozen.Status, exit.Format(time.RFC3339))
+	}
+}
+
+// freezeBid stores the frozen summary with the config of a bid, like a change through the admin
+// API. It fails if the bid changed since version, as the summary may be outdated.
+func freezeBid(bidId int, version string, frozen *FrozenBid) error {
+	bidConfigMu.Lock()
+	defer bidConfigMu.Unlock()
+
+	previous := activeBids()
+	current, ok := previous[bidId]
+	if !ok {
+		return errBidNotFound
+	}
+	if currentVersion, err := bidConfigVersion(bidId, current); err != nil || currentVersion != version {
+		return fmt.Errorf("the bid changed while its summary was computed")
+	}
+
+	next := current
+	next.Frozen = frozen
+
+	if err := auditBidChangeBy(FreezeActor, "freeze", bidId, &current, &next); err != nil {
+		return fmt.Errorf("recording the change: %v", err)
+	}
+
+	// the store may have bids that a profile leaves out
+	stored, err := adminConfigStore.LoadBids()
+	if err != nil {
+		return err
+	}
+	if err := adminConfigStore.SaveBids(withBidConfig(stored, bidId, &next)); err != nil {
+		return fmt.Errorf("saving the change: %v", err)
+	}
+
+	setActiveBids(withBidConfig(previous, bidId, &next))
+	invalidateBid(bidId, current, next)
+	return nil
+}
+
+// startFreezer freezes fully withdrawn bids in the background, after they have been withdrawn for
+// the given time. The frozen summaries are saved to the config store.
+func startFreezer(after time.Duration) {
+	go func() {
+		ticker := time.NewTicker(FreezeCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			if isLeader() {
+				freezeDueBids(context.Background(), after)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+const chainRegistryImages = "https://raw.githubusercontent.com/cosmos/chain-registry/master/"
+
+// protocolLogos are the logo URLs of the protocols, served with the protocols and the venues.
+var protocolLogos = map[Protocol]string{
+	Osmosis: chainRegistryImages + "osmosis/images/osmo.svg",
+	Nolus:   chainRegistryImages + "nolus/images/nolus.svg",
+	Ux:      chainRegistryImages + "umee/images/umee.svg",
+}
+
+// assetLogos are the logo URLs of the assets by denom or display name, served with the assets.
+var assetLogos = map[string]string{
+	"ATOM":   chainRegistryImages + "cosmoshub/images/atom.svg",
+	"stATOM": chainRegistryImages + "stride/images/statom.svg",
+	"OSMO":   chainRegistryImages + "osmosis/images/osmo.svg",
+	"NTRN":   chainRegistryImages + "neutron/images/ntrn.svg",
+	"USDC":   chainRegistryImages + "noble/images/USDCoin.svg",
+}
+
+// Branding is the format of the --branding file.
+type Branding struct {
+	Protocols map[Protocol]string `json:"protocols"`
+	Assets    map[string]string   `json:"assets"`
+}
+
+// loadBranding adds the logos of a branding file to the built-in ones, replacing them. An empty
+// URL drops a logo.
+func loadBranding(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading branding file: %v", err)
+	}
+
+	var branding Branding
+	if err := decodeStrictJSON(data, &branding); err != nil {
+		return fmt.Errorf("decoding branding file: %v", err)
+	}
+
+	for protocol, logo := range branding.Protocols {
+		if _, ok := protocolConfigMap[protocol]; !ok {
+			return fmt.Errorf("logo of unknown protocol %s", protocol)
+		}
+		setLogo(protocolLogos, protocol, logo)
+	}
+	for asset, logo := range branding.Assets {
+		setLogo(assetLogos, asset, logo)
+	}
+	return nil
+}
+
+func setLogo[K comparable](logos map[K]string, key K, logo string) {
+	if logo == "" {
+		delete(logos, key)
+		return
+	}
+	logos[key] = logo
+}
+
+// assetLogo returns the logo of an asset by its denom or, failing that, its display name.
+func assetLogo(denom string, displayName string) string {
+	if logo, ok := assetLogos[denom]; ok {
+		return logo
+	}
+	return assetLogos[displayName]
+}
+
+// brandingHandler serves all logos, e.g. for the legend of a dashboard.
+func brandingHandler(w http.ResponseWriter, r *http.Request) {
+	jsonData, err := json.MarshalIndent(Branding{Protocols: protocolLogos, Assets: assetLogos}, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
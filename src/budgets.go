@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultVenueTimeout bounds the computation of a venue if its protocol doesn't set a timeout.
+	DefaultVenueTimeout = 60 * time.Second
+	// DefaultVenueLatencyBudget is the expected computation time of a venue if its protocol doesn't set one.
+	DefaultVenueLatencyBudget = 20 * time.Second
+)
+
+var (
+	venueDurationMetric = newGauge("venue_compute_duration_seconds",
+		"Duration of the last computation of a venue by protocol.")
+	slowUpstreamMetric = newCounter("slow_upstream_total",
+		"Number of venue computations that exceeded the latency budget of their protocol.")
+	venueTimeoutsMetric = newCounter("venue_timeouts_total",
+		"Number of venue computations that exceeded the timeout of their protocol.")
+	staleVenuesServedMetric = newCounter("stale_venues_served_total",
+		"Number of times a venue was served from the last successful computation after a timeout.")
+)
+
+// timeout returns the time after which the computation of a venue on the protocol is abandoned.
+func (c ProtocolConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultVenueTimeout
+}
+
+// latencyBudget returns the computation time above which the protocol's upstreams are considered slow.
+func (c ProtocolConfig) latencyBudget() time.Duration {
+	if c.LatencyBudget > 0 {
+		return c.LatencyBudget
+	}
+	return DefaultVenueLatencyBudget
+}
+
+// parseProtocolBudgets applies overrides like "osmosis=30s/10s,mars=45s" to the protocol configs.
+// Protocols are identified by their slug, and the latency budget after the slash is optional.
+func parseProtocolBudgets(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		slug, durations, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid protocol budget %q, expected <protocol>=<timeout>[/<budget>]", entry)
+		}
+
+		var protocol Protocol
+		for p := range protocolConfigMap {
+			if protocolSlug(p) == slug {
+				protocol = p
+			}
+		}
+		if protocol == "" {
+			return fmt.Errorf("unknown protocol: %s", slug)
+		}
+
+		timeoutStr, budgetStr, hasBudget := strings.Cut(durations, "/")
+		config := protocolConfigMap[protocol]
+
+		timeout, err := time.ParseDuration(timeoutStr)
+		if err != nil {
+			return fmt.Errorf("invalid timeout for %s: %v", slug, err)
+		}
+		config.Timeout = timeout
+
+		if hasBudget {
+			budget, err := time.ParseDuration(budgetStr)
+			if err != nil {
+				return fmt.Errorf("invalid latency budget for %s: %v", slug, err)
+			}
+			config.LatencyBudget = budget
+		}
+
+		protocolConfigMap[protocol] = config
+	}
+
+	return nil
+}
+
+// computeVenueHoldingsWithBudget computes a venue within the timeout of its protocol.
+// If the timeout is exceeded, the last successful result is served instead, marked as stale.
+// Computations exceeding the latency budget fire a slow-upstream alert.
+func computeVenueHoldingsWithBudget(ctx context.Context, bidId int, venueConfig VenuePositionConfig) (*VenueHoldings, error) {
+	protocolConfig := protocolConfigMap[venueConfig.GetProtocol()]
+	id := venueID(bidId, venueConfig)
+	protocol := string(venueConfig.GetProtocol())
+
+	venueCtx, cancel := context.WithTimeout(withPriceSnapshot(ctx, priceSnapshotFor(ctx)), protocolConfig.timeout())
+	defer cancel()
+
+	start := time.Now()
+	venueHoldings, err := computeVenueHoldings(venueCtx, bidId, venueConfig)
+	duration := time.Since(start)
+	venueDurationMetric.Set(duration.Seconds(), "protocol", protocol)
+
+	if duration > protocolConfig.latencyBudget() {
+		slowUpstreamMetric.Add(1, "protocol", protocol)
+		fireAlert(AlertSlowUpstream, protocol, fmt.Sprintf("venue %s took %s, over the latency budget of %s",
+			id, duration.Round(time.Millisecond), protocolConfig.latencyBudget()))
+	}
+
+	if err == nil {
+		storeVenue(id, *venueHoldings, start)
+		if !venueConfig.GetMetadata().Testnet {
+			checkPoolShare(id, venueHoldings)
+		}
+		return venueHoldings, nil
+	}
+
+	// only fall back to the cache if the venue's own timeout was hit, not if the caller gave up
+	if errors.Is(venueCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		venueTimeoutsMetric.Add(1, "protocol", protocol)
+
+		if cached, ok := markVenueStale(id); ok {
+			staleVenuesServedMetric.Add(1, "protocol", protocol)
+			return &cached.Holdings, nil
+		}
+
+		return nil, fmt.Errorf("computing venue %s timed out after %s", id, protocolConfig.timeout())
+	}
+
+	if isPoolDeprecatedError(err) {
+		return serveDeprecatedVenue(id, venueConfig, err)
+	}
+
+	return nil, err
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// LeaderLeaseTTL is how long the refresher lease is held without renewal,
+	// i.e. how long the refresher pauses if the leader dies.
+	LeaderLeaseTTL = 30 * time.Second
+	// SharedVenueTTL keeps venues in the shared cache long after they expire,
+	// so that replicas can still serve them as stale if the leader is down.
+	SharedVenueTTL = 24 * time.Hour
+)
+
+// renewLeaseScript extends the lease only if it is still held by this instance.
+const renewLeaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+
+var (
+	// sharedRedis is set if REDIS_URL is configured, in which case the replicas share
+	// their venue computations and elect a single instance to run the refresher.
+	sharedRedis    *redisClient
+	redisKeyPrefix = "deployment-tracking:"
+	instanceID     string
+
+	leading atomic.Bool
+)
+
+var (
+	refresherLeaderMetric = newGauge("refresher_leader",
+		"1 if this instance holds the refresher lease.")
+	sharedCacheErrorsMetric = newCounter("shared_cache_errors_total",
+		"Number of failed reads and writes of the shared venue cache.")
+)
+
+// clusterFromEnv connects to REDIS_URL, if set. REDIS_KEY_PREFIX namespaces the keys.
+func clusterFromEnv() error {
+	redisURL := getSecret("REDIS_URL")
+	if redisURL == "" {
+		return nil
+	}
+
+	client, err := newRedisClient(redisURL)
+	if err != nil {
+		return err
+	}
+	if _, err := client.Do(context.Background(), "PING"); err != nil {
+		return fmt.Errorf("connecting to Redis: %v", err)
+	}
+
+	if prefix := os.Getenv("REDIS_KEY_PREFIX"); prefix != "" {
+		redisKeyPrefix = prefix
+	}
+
+	hostname, _ := os.Hostname()
+	instanceID = hostname + "-" + strconv.Itoa(os.Getpid())
+	sharedRedis = client
+	return nil
+}
+
+// isLeader reports whether this instance runs the refresher and records snapshots.
+// Without Redis, every instance is on its own and thus the leader.
+func isLeader() bool {
+	return sharedRedis == nil || leading.Load()
+}
+
+func setLeading(l bool) {
+	if leading.Swap(l) != l {
+		if l {
+			log.Printf("Instance %s acquired the refresher lease", instanceID)
+		} else {
+			log.Printf("Instance %s lost the refresher lease", instanceID)
+		}
+	}
+
+	if l {
+		refresherLeaderMetric.Set(1)
+	} else {
+		refresherLeaderMetric.Set(0)
+	}
+}
+
+// startLeaderElection competes for the refresher lease, renewing it while held.
+func startLeaderElection() {
+	if sharedRedis == nil {
+		return
+	}
+
+	// try once right away, so that the refresher of a single instance starts immediately
+	var lastRenewal time.Time
+	updateLease(&lastRenewal)
+
+	go func() {
+		ticker := time.NewTicker(LeaderLeaseTTL / 3)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			updateLease(&lastRenewal)
+		}
+	}()
+}
+
+// updateLease renews the refresher lease if this instance holds it, and tries to acquire it otherwise.
+func updateLease(lastRenewal *time.Time) {
+	key := redisKeyPrefix + "refresher-leader"
+	ttl := strconv.FormatInt(LeaderLeaseTTL.Milliseconds(), 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), LeaderLeaseTTL/3)
+	defer cancel()
+
+	var reply interface{}
+	var err error
+	if leading.Load() {
+		reply, err = sharedRedis.Do(ctx, "EVAL", renewLeaseScript, "1", key, instanceID, ttl)
+	} else {
+		reply, err = sharedRedis.Do(ctx, "SET", key, instanceID, "NX", "PX", ttl)
+	}
+
+	switch {
+	case err != nil:
+		log.Printf("Refresher lease update failed: %v", err)
+		// without renewal, another instance may take over once the lease expires
+		if leading.Load() && time.Since(*lastRenewal) > LeaderLeaseTTL*2/3 {
+			setLeading(false)
+		}
+	case reply == "OK" || reply == int64(1):
+		*lastRenewal = time.Now()
+		setLeading(true)
+	default:
+		setLeading(false)
+	}
+}
+
+// sharedVenueKey is the Redis key of a venue in the shared cache.
+func sharedVenueKey(id string) string {
+	return redisKeyPrefix + "venue:" + id
+}
+
+// loadSharedVenue reads the computation of a venue from the shared cache.
+func loadSharedVenue(id string) (cachedVenue, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	reply, err := sharedRedis.Do(ctx, "GET", sharedVenueKey(id))
+	if err != nil {
+		sharedCacheErrorsMetric.Add(1)
+		debugLog("Reading the shared venue cache failed", map[string]string{"venue": id, "error": err.Error()})
+		return cachedVenue{}, false
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return cachedVenue{}, false
+	}
+
+	var cached cachedVenue
+	if err := json.Unmarshal([]byte(data), &cached); err != nil {
+		sharedCacheErrorsMetric.Add(1)
+		return cachedVenue{}, false
+	}
+	return cached, true
+}
+
+// storeSharedVenue writes the computation of a venue to the shared cache.
+func storeSharedVenue(id string, cached cachedVenue) {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		sharedCacheErrorsMetric.Add(1)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ttl := strconv.FormatInt(SharedVenueTTL.Milliseconds(), 10)
+	if _, err := sharedRedis.Do(ctx, "SET", sharedVenueKey(id), string(data), "PX", ttl); err != nil {
+		sharedCacheErrorsMetric.Add(1)
+		log.Printf("Writing venue %s to the shared cache failed: %v", id, err)
+	}
+}
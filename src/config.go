@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// activeProfileName is the name of the profile the server runs with.
+var activeProfileName = DefaultProfile
+
+// configSource tells where the served bid configs come from: "code", "file" or "database".
+var configSource = "code"
+
+// ConfigResponse is the effective configuration of the server, in the config store format where
+// there is one.
+type ConfigResponse struct {
+	Profile          string                   `json:"profile"`
+	Source           string                   `json:"source"`
+	Bids             []storedBid              `json:"bids"`
+	Experimental     []storedExperimental     `json:"experimental"`
+	Protocols        []ProtocolConfigResponse `json:"protocols"`
+	TestnetProtocols []ProtocolConfigResponse `json:"testnet_protocols"`
+	Rounds           []HydroRound             `json:"rounds"`
+}
+
+// ProtocolConfigResponse is the effective config of a protocol, after the profile and the latency
+// budgets were applied.
+type ProtocolConfigResponse struct {
+	Protocol             Protocol `json:"protocol"`
+	PoolInfoURL          string   `json:"pool_info_url"`
+	AddressBalanceURL    string   `json:"address_balance_url"`
+	AssetListURL         string   `json:"asset_list_url"`
+	TimeoutSeconds       float64  `json:"timeout_seconds"`
+	LatencyBudgetSeconds float64  `json:"latency_budget_seconds"`
+}
+
+func protocolConfigResponses(configs map[Protocol]ProtocolConfig) []ProtocolConfigResponse {
+	responses := make([]ProtocolConfigResponse, 0, len(configs))
+	for protocol, config := range configs {
+		// testnet configs use the timeouts of their protocol
+		timing := protocolConfigMap[protocol]
+		responses = append(responses, ProtocolConfigResponse{
+			Protocol:             protocol,
+			PoolInfoURL:          config.PoolInfoUrl,
+			AddressBalanceURL:    config.AddressBalanceUrl,
+			AssetListURL:         config.AssetListURL,
+			TimeoutSeconds:       timing.timeout().Seconds(),
+			LatencyBudgetSeconds: timing.latencyBudget().Seconds(),
+		})
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].Protocol < responses[j].Protocol })
+	return responses
+}
+
+// configHandler serves the configuration the server is running with: the bids and experimental
+// deployments it serves, after the profile was applied, and the endpoints and timeouts of every
+// protocol.
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	bidConfigMu.Lock()
+	bids, bidsErr := encodeBidConfigs(activeBids())
+	experimental, experimentalErr := encodeExperimentals(activeExperimental())
+	bidConfigMu.Unlock()
+	for _, err := range []error{bidsErr, experimentalErr} {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response := ConfigResponse{
+		Profile:          activeProfileName,
+		Source:           configSource,
+		Bids:             bids.Bids,
+		Experimental:     experimental,
+		Protocols:        protocolConfigResponses(protocolConfigMap),
+		TestnetProtocols: protocolConfigResponses(testnetProtocolConfigMap),
+		Rounds:           roundCalendar,
+	}
+
+	jsonData, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,670 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigStoreVersion is the format version of stored configs.
+const ConfigStoreVersion = 1
+
+// ConfigStore persists the bid configs outside of the code.
+type ConfigStore interface {
+	LoadBids() (map[int]BidPositionConfig, error)
+	SaveBids(bids map[int]BidPositionConfig) error
+	// LoadExperimental returns nil if the store has no experimental deployments, as opposed to an
+	// empty set of them, in which case the ones in the code are served.
+	LoadExperimental() (map[int]*ExperimentalDeployment, error)
+	SaveExperimental(deployments map[int]*ExperimentalDeployment) error
+	// Revision changes whenever the stored configs may have changed.
+	Revision() (string, error)
+}
+
+// storedConfig is the serialized form of the bid configs.
+type storedConfig struct {
+	Version int         `json:"version"`
+	Bids    []storedBid `json:"bids"`
+	// a pointer, so that a config without experimental deployments can be told from one without the section
+	Experimental *[]storedExperimental `json:"experimental,omitempty"`
+}
+
+type storedBid struct {
+	BidId             int           `json:"bid_id"`
+	Name              string        `json:"name,omitempty"`
+	Description       string        `json:"description,omitempty"`
+	Round             int           `json:"round,omitempty"`
+	InitialAllocation int           `json:"initial_allocation"`
+	Allocations       []Allocation  `json:"allocations,omitempty"`
+	Venues            []storedVenue `json:"venues"`
+	Withdrawals       []Withdrawal  `json:"withdrawals"`
+	RewardClaims      []RewardClaim `json:"reward_claims,omitempty"`
+	Transfers         []Transfer    `json:"transfers,omitempty"`
+	Frozen            *FrozenBid    `json:"frozen,omitempty"`
+}
+
+// storedVenue tags a venue config with its kind, so that it can be decoded into the right type.
+type storedVenue struct {
+	Kind   string          `json:"kind"`
+	Config json.RawMessage `json:"config"`
+}
+
+// venueConfigKinds decodes the venue configs of every kind.
+var venueConfigKinds = map[string]func(json.RawMessage) (VenuePositionConfig, error){
+	"astroport":  decodeVenueConfig[AstroportVenuePositionConfig],
+	"cosmwasm":   decodeVenueConfig[GenericCosmWasmVenuePositionConfig],
+	"demex":      decodeVenueConfig[DemexVenuePositionConfig],
+	"drop":       decodeVenueConfig[DropVenuePositionConfig],
+	"duality":    decodeVenueConfig[DualityVenuePositionConfig],
+	"elys":       decodeVenueConfig[ElysVenuePositionConfig],
+	"inter":      decodeVenueConfig[InterVenuePositionConfig],
+	"levana":     decodeVenueConfig[LevanaVenuePositionConfig],
+	"magma":      decodeVenueConfig[MagmaVenuePositionConfig],
+	"margined":   decodeVenueConfig[MarginedVenuePositionConfig],
+	"mars":       decodeVenueConfig[MarsVenuePositionConfig],
+	"missing":    decodeVenueConfig[MissingVenuePositionConfig],
+	"mito":       decodeVenueConfig[MitoVenuePositionConfig],
+	"neptune":    decodeVenueConfig[NeptuneVenuePositionConfig],
+	"nolus":      decodeVenueConfig[NolusVenuePositionConfig],
+	"osmosis":    decodeVenueConfig[OsmosisVenuePositionConfig],
+	"pryzm":      decodeVenueConfig[PryzmVenuePositionConfig],
+	"quasar":     decodeVenueConfig[QuasarVenuePositionConfig],
+	"shade":      decodeVenueConfig[ShadeVenuePositionConfig],
+	"staking":    decodeVenueConfig[StakingVenuePositionConfig],
+	"uniswapv3":  decodeVenueConfig[UniswapV3VenuePositionConfig],
+	"ux":         decodeVenueConfig[UxVenuePositionConfig],
+	"whitewhale": decodeVenueConfig[WhiteWhaleVenuePositionConfig],
+}
+
+// venueConfigKind returns the kind a venue config is stored as.
+func venueConfigKind(venueConfig VenuePositionConfig) (string, error) {
+	switch venueConfig.(type) {
+	case AstroportVenuePositionConfig:
+		return "astroport", nil
+	case GenericCosmWasmVenuePositionConfig:
+		return "cosmwasm", nil
+	case DemexVenuePositionConfig:
+		return "demex", nil
+	case DropVenuePositionConfig:
+		return "drop", nil
+	case DualityVenuePositionConfig:
+		return "duality", nil
+	case ElysVenuePositionConfig:
+		return "elys", nil
+	case InterVenuePositionConfig:
+		return "inter", nil
+	case LevanaVenuePositionConfig:
+		return "levana", nil
+	case MagmaVenuePositionConfig:
+		return "magma", nil
+	case MarginedVenuePositionConfig:
+		return "margined", nil
+	case MarsVenuePositionConfig:
+		return "mars", nil
+	case MissingVenuePositionConfig:
+		return "missing", nil
+	case MitoVenuePositionConfig:
+		return "mito", nil
+	case NeptuneVenuePositionConfig:
+		return "neptune", nil
+	case NolusVenuePositionConfig:
+		return "nolus", nil
+	case OsmosisVenuePositionConfig:
+		return "osmosis", nil
+	case PryzmVenuePositionConfig:
+		return "pryzm", nil
+	case QuasarVenuePositionConfig:
+		return "quasar", nil
+	case ShadeVenuePositionConfig:
+		return "shade", nil
+	case StakingVenuePositionConfig:
+		return "staking", nil
+	case UniswapV3VenuePositionConfig:
+		return "uniswapv3", nil
+	case UxVenuePositionConfig:
+		return "ux", nil
+	case WhiteWhaleVenuePositionConfig:
+		return "whitewhale", nil
+	}
+	return "", fmt.Errorf("unsupported venue config type: %T", venueConfig)
+}
+
+// decodeVenueConfig decodes a venue config of type T, rejecting unknown fields to catch typos.
+func decodeVenueConfig[T VenuePositionConfig](raw json.RawMessage) (VenuePositionConfig, error) {
+	var config T
+	if err := decodeStrictJSON(raw, &config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// encodeBidConfigs converts the bid configs to their serialized form, ordered by bid ID.
+func encodeBidConfigs(bids map[int]BidPositionConfig) (*storedConfig, error) {
+	config := &storedConfig{Version: ConfigStoreVersion, Bids: []storedBid{}}
+
+	bidIds := make([]int, 0, len(bids))
+	for bidId := range bids {
+		bidIds = append(bidIds, bidId)
+	}
+	sort.Ints(bidIds)
+
+	for _, bidId := range bidIds {
+		bid, err := encodeBidConfig(bidId, bids[bidId])
+		if err != nil {
+			return nil, err
+		}
+		config.Bids = append(config.Bids, bid)
+	}
+
+	return config, nil
+}
+
+func encodeBidConfig(bidId int, bidConfig BidPositionConfig) (storedBid, error) {
+	bid := storedBid{
+		BidId:             bidId,
+		Name:              bidConfig.Name,
+		Description:       bidConfig.Description,
+		Round:             bidConfig.Round,
+		InitialAllocation: bidConfig.InitialAllocation,
+		Allocations:       bidConfig.Allocations,
+		Venues:            make([]storedVenue, 0, len(bidConfig.Venues)),
+		Withdrawals:       bidConfig.Withdrawals,
+		RewardClaims:      bidConfig.RewardClaims,
+		Transfers:         bidConfig.Transfers,
+		Frozen:            bidConfig.Frozen,
+	}
+	if bid.Withdrawals == nil {
+		bid.Withdrawals = []Withdrawal{}
+	}
+
+	for _, venueConfig := range bidConfig.Venues {
+		kind, err := venueConfigKind(venueConfig)
+		if err != nil {
+			return bid, fmt.Errorf("bid %d: %v", bidId, err)
+		}
+		raw, err := json.Marshal(venueConfig)
+		if err != nil {
+			return bid, fmt.Errorf("bid %d: encoding venue: %v", bidId, err)
+		}
+		bid.Venues = append(bid.Venues, storedVenue{Kind: kind, Config: raw})
+	}
+
+	return bid, nil
+}
+
+// decodeBidConfigs converts serialized bid configs back to the bid map.
+func decodeBidConfigs(config *storedConfig) (map[int]BidPositionConfig, error) {
+	if config.Version != ConfigStoreVersion {
+		return nil, fmt.Errorf("unsupported config version %d", config.Version)
+	}
+
+	bids := make(map[int]BidPositionConfig, len(config.Bids))
+	for _, bid := range config.Bids {
+		if _, ok := bids[bid.BidId]; ok {
+			return nil, fmt.Errorf("duplicate bid %d", bid.BidId)
+		}
+
+		bidConfig, err := decodeBidConfig(bid)
+		if err != nil {
+			return nil, err
+		}
+		bids[bid.BidId] = bidConfig
+	}
+
+	return bids, nil
+}
+
+func decodeBidConfig(bid storedBid) (BidPositionConfig, error) {
+	bidConfig := BidPositionConfig{
+		Name:              bid.Name,
+		Description:       bid.Description,
+		Round:             bid.Round,
+		InitialAllocation: bid.InitialAllocation,
+		Allocations:       bid.Allocations,
+		Withdrawals:       bid.Withdrawals,
+		RewardClaims:      bid.RewardClaims,
+		Transfers:         bid.Transfers,
+		Frozen:            bid.Frozen,
+	}
+
+	for i, venue := range bid.Venues {
+		decode, ok := venueConfigKinds[venue.Kind]
+		if !ok {
+			return bidConfig, fmt.Errorf("bid %d: venue %d has unknown kind %q", bid.BidId, i, venue.Kind)
+		}
+		venueConfig, err := decode(venue.Config)
+		if err != nil {
+			return bidConfig, fmt.Errorf("bid %d: decoding venue %d: %v", bid.BidId, i, err)
+		}
+		bidConfig.Venues = append(bidConfig.Venues, venueConfig)
+	}
+
+	return bidConfig, nil
+}
+
+// FileConfigStore keeps the bid configs in a JSON file, or reads them from a YAML file with a
+// .yaml or .yml extension.
+type FileConfigStore struct {
+	path string
+}
+
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func NewFileConfigStore(path string) *FileConfigStore {
+	return &FileConfigStore{path: path}
+}
+
+func (s *FileConfigStore) LoadBids() (map[int]BidPositionConfig, error) {
+	config, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	return decodeBidConfigs(config)
+}
+
+// SaveBids replaces the stored bid configs, keeping the experimental deployments.
+func (s *FileConfigStore) SaveBids(bids map[int]BidPositionConfig) error {
+	config, err := encodeBidConfigs(bids)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.loadExisting()
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		config.Experimental = existing.Experimental
+	}
+
+	return s.save(config)
+}
+
+func (s *FileConfigStore) LoadExperimental() (map[int]*ExperimentalDeployment, error) {
+	config, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	if config.Experimental == nil {
+		return nil, nil
+	}
+	return decodeExperimentals(*config.Experimental)
+}
+
+// SaveExperimental replaces the stored experimental deployments, keeping the bid configs.
+func (s *FileConfigStore) SaveExperimental(deployments map[int]*ExperimentalDeployment) error {
+	experimental, err := encodeExperimentals(deployments)
+	if err != nil {
+		return err
+	}
+
+	config, err := s.loadExisting()
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		config = &storedConfig{Version: ConfigStoreVersion, Bids: []storedBid{}}
+	}
+	config.Experimental = &experimental
+
+	return s.save(config)
+}
+
+func (s *FileConfigStore) load() (*storedConfig, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config store: %v", err)
+	}
+
+	if isYAMLPath(s.path) {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, fmt.Errorf("decoding config store: %v", err)
+		}
+	}
+
+	// unknown fields are rejected, so that typos don't silently drop values
+	var config storedConfig
+	if err := decodeStrictJSON(data, &config); err != nil {
+		return nil, fmt.Errorf("decoding config store: %v", err)
+	}
+	if config.Version != ConfigStoreVersion {
+		return nil, fmt.Errorf("unsupported config version %d", config.Version)
+	}
+	return &config, nil
+}
+
+// loadExisting loads the stored configs, or returns nil if the file doesn't exist yet.
+func (s *FileConfigStore) loadExisting() (*storedConfig, error) {
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return s.load()
+}
+
+// save replaces the file atomically, so that readers never see a partial write.
+func (s *FileConfigStore) save(config *storedConfig) error {
+	if isYAMLPath(s.path) {
+		return fmt.Errorf("YAML configs are written by hand, save to a .json file instead")
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding config store: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing config store: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing config store: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing config store: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Revision returns the modification time of the file.
+func (s *FileConfigStore) Revision() (string, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return "", err
+	}
+	return info.ModTime().Format(time.RFC3339Nano), nil
+}
+
+// diffBidConfigs lists the differences between two sets of bid configs, one per line.
+func diffBidConfigs(a map[int]BidPositionConfig, b map[int]BidPositionConfig, aName string, bName string) ([]string, error) {
+	var diffs []string
+
+	bidIds := make(map[int]bool)
+	for bidId := range a {
+		bidIds[bidId] = true
+	}
+	for bidId := range b {
+		bidIds[bidId] = true
+	}
+	sorted := make([]int, 0, len(bidIds))
+	for bidId := range bidIds {
+		sorted = append(sorted, bidId)
+	}
+	sort.Ints(sorted)
+
+	for _, bidId := range sorted {
+		bidA, inA := a[bidId]
+		bidB, inB := b[bidId]
+		if !inA {
+			diffs = append(diffs, fmt.Sprintf("bid %d: only in the %s", bidId, bName))
+			continue
+		}
+		if !inB {
+			diffs = append(diffs, fmt.Sprintf("bid %d: only in the %s", bidId, aName))
+			continue
+		}
+
+		genericA, err := genericBidConfig(bidId, bidA)
+		if err != nil {
+			return nil, err
+		}
+		genericB, err := genericBidConfig(bidId, bidB)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, diff := range diffJSON("", genericA, genericB) {
+			diffs = append(diffs, fmt.Sprintf("bid %d: %s: %s %s, %s %s", bidId, diff.Path, aName, diff.A, bName, diff.B))
+		}
+	}
+
+	return diffs, nil
+}
+
+// genericBidConfig converts a bid config to plain JSON values for diffing.
+func genericBidConfig(bidId int, bidConfig BidPositionConfig) (interface{}, error) {
+	bid, err := encodeBidConfig(bidId, bidConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(bid)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
+
+// JSONDiff is a value that differs between two JSON documents, formatted as JSON.
+// A missing value is formatted as "(none)".
+type JSONDiff struct {
+	Path string `json:"path"`
+	A    string `json:"old"`
+	B    string `json:"new"`
+}
+
+// diffJSON compares two decoded JSON values and returns the paths at which they differ.
+func diffJSON(path string, a interface{}, b interface{}) []JSONDiff {
+	mapA, aIsMap := a.(map[string]interface{})
+	mapB, bIsMap := b.(map[string]interface{})
+	if aIsMap && bIsMap {
+		keys := make(map[string]bool)
+		for key := range mapA {
+			keys[key] = true
+		}
+		for key := range mapB {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			sorted = append(sorted, key)
+		}
+		sort.Strings(sorted)
+
+		var diffs []JSONDiff
+		for _, key := range sorted {
+			valueA, inA := mapA[key]
+			valueB, inB := mapB[key]
+			switch {
+			case !inA:
+				diffs = append(diffs, JSONDiff{Path: joinJSONPath(path, key), A: "(none)", B: formatJSONValue(valueB)})
+			case !inB:
+				diffs = append(diffs, JSONDiff{Path: joinJSONPath(path, key), A: formatJSONValue(valueA), B: "(none)"})
+			default:
+				diffs = append(diffs, diffJSON(joinJSONPath(path, key), valueA, valueB)...)
+			}
+		}
+		return diffs
+	}
+
+	sliceA, aIsSlice := a.([]interface{})
+	sliceB, bIsSlice := b.([]interface{})
+	if aIsSlice && bIsSlice {
+		var diffs []JSONDiff
+		for i := 0; i < len(sliceA) || i < len(sliceB); i++ {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(sliceA):
+				diffs = append(diffs, JSONDiff{Path: elementPath, A: "(none)", B: formatJSONValue(sliceB[i])})
+			case i >= len(sliceB):
+				diffs = append(diffs, JSONDiff{Path: elementPath, A: formatJSONValue(sliceA[i]), B: "(none)"})
+			default:
+				diffs = append(diffs, diffJSON(elementPath, sliceA[i], sliceB[i])...)
+			}
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return []JSONDiff{{Path: path, A: formatJSONValue(a), B: formatJSONValue(b)}}
+}
+
+func joinJSONPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func formatJSONValue(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// runConfig implements the config command, which moves the bid configs from the code to a config store:
+//
+//	deployment_tracking config migrate --store bids.json
+//	deployment_tracking config verify --store postgres://tracker@db/deployments
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("expected a subcommand: migrate or verify")
+	}
+
+	flags := flag.NewFlagSet("config "+args[0], flag.ExitOnError)
+	storePath := flags.String("store", "", "Path of the config store file, or a postgres:// or sqlite: database URL")
+	force := flags.Bool("force", false, "Overwrite an existing config store (migrate only)")
+	flags.Parse(args[1:])
+
+	if *storePath == "" {
+		return fmt.Errorf("--store is required")
+	}
+	store, err := newConfigStore(*storePath)
+	if err != nil {
+		return err
+	}
+	// the URL may contain a password
+	storeName := *storePath
+	if isDatabaseURL(storeName) {
+		storeName = "the database"
+	}
+
+	switch args[0] {
+	case "migrate":
+		if !*force {
+			if err := checkStoreEmpty(store, storeName); err != nil {
+				return err
+			}
+		}
+		if err := store.SaveBids(bidMap); err != nil {
+			return err
+		}
+		if err := store.SaveExperimental(experimentalMap); err != nil {
+			return err
+		}
+
+		// read the store back, so that a lossy encoding is caught right away
+		stored, err := store.LoadBids()
+		if err != nil {
+			return fmt.Errorf("reading back the migrated configs: %v", err)
+		}
+		diffs, err := diffBidConfigs(bidMap, stored, "code", "store")
+		if err != nil {
+			return err
+		}
+		storedExperimental, err := store.LoadExperimental()
+		if err != nil {
+			return fmt.Errorf("reading back the migrated experimental deployments: %v", err)
+		}
+		experimentalDiffs, err := diffExperimentals(experimentalMap, storedExperimental, "code", "store")
+		if err != nil {
+			return err
+		}
+		diffs = append(diffs, experimentalDiffs...)
+		if len(diffs) > 0 {
+			return fmt.Errorf("the migrated configs differ from the code:\n%s", strings.Join(diffs, "\n"))
+		}
+
+		log.Printf("Migrated %d bids and %d experimental deployments to %s", len(stored), len(storedExperimental), storeName)
+		return nil
+	case "verify":
+		stored, err := store.LoadBids()
+		if err != nil {
+			return err
+		}
+		diffs, err := diffBidConfigs(bidMap, stored, "code", "store")
+		if err != nil {
+			return err
+		}
+		// a store without experimental deployments serves the ones of the code
+		storedExperimental, err := store.LoadExperimental()
+		if err != nil {
+			return err
+		}
+		if storedExperimental != nil {
+			experimentalDiffs, err := diffExperimentals(experimentalMap, storedExperimental, "code", "store")
+			if err != nil {
+				return err
+			}
+			diffs = append(diffs, experimentalDiffs...)
+		}
+		for _, diff := range diffs {
+			fmt.Println(diff)
+		}
+		if len(diffs) > 0 {
+			return fmt.Errorf("%d differences between the code and %s", len(diffs), storeName)
+		}
+
+		log.Printf("The %d bids in %s match the code", len(stored), storeName)
+		return nil
+	default:
+		return fmt.Errorf("unknown subcommand: %s", args[0])
+	}
+}
+
+// checkStoreEmpty refuses to migrate into a store that already holds configs.
+func checkStoreEmpty(store ConfigStore, storeName string) error {
+	switch store := store.(type) {
+	case *FileConfigStore:
+		if _, err := os.Stat(store.path); err == nil {
+			return fmt.Errorf("%s already exists, pass --force to overwrite it", storeName)
+		}
+	case *DatabaseConfigStore:
+		empty, err := store.IsEmpty()
+		if err != nil {
+			return err
+		}
+		if !empty {
+			return fmt.Errorf("%s already holds bids, pass --force to overwrite them", storeName)
+		}
+	}
+	return nil
+}
+
+// loadBidsFromStore replaces the bid configs of the code with the stored ones.
+// Differences to the code are logged, as they are expected only while the transition is in progress.
+func loadBidsFromStore(store ConfigStore) error {
+	stored, err := store.LoadBids()
+	if err != nil {
+		return err
+	}
+
+	diffs, err := diffBidConfigs(bidMap, stored, "code", "store")
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		log.Printf("Config store differs from the code: %s", diff)
+	}
+
+	setActiveBids(stored)
+	log.Printf("Loaded %d bids from the config store", len(stored))
+	return nil
+}
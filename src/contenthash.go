@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// contentHash returns a short hash identifying the content of data.
+func contentHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:8])
+}
+
+// venueContentHash hashes the holdings of a venue, without its hash and trace. The display fields
+// of the response are derived from the hashed values, so they are left out too.
+func venueContentHash(venueHoldings VenueHoldings) string {
+	venueHoldings.ContentHash = ""
+	venueHoldings.Trace = nil
+	data, _ := json.Marshal(venueHoldings)
+	return contentHash(data)
+}
+
+// withContentHashes returns a copy of the holdings of a bid with the content hash of each venue,
+// and the hash of all of them, which is the content hash of the bid's holdings response. Missing
+// holdings stay nil.
+func withContentHashes(holdings []VenueHoldings) ([]VenueHoldings, string) {
+	if holdings == nil {
+		return nil, contentHash(nil)
+	}
+	hashed := make([]VenueHoldings, len(holdings))
+	leaves := make([]byte, 0, len(holdings)*17)
+	for i, venueHoldings := range holdings {
+		venueHoldings.ContentHash = venueContentHash(venueHoldings)
+		hashed[i] = venueHoldings
+		leaves = append(leaves, venueHoldings.ContentHash...)
+		leaves = append(leaves, '\n')
+	}
+	return hashed, contentHash(leaves)
+}
+
+// bidContentHash hashes a bid of the listing from the hash of its holdings and its fields that
+// only change with its config: the name, status, allocations, withdrawals and transfers. The
+// performance is left out, as it moves with time even if nothing else changes.
+func bidContentHash(bidHoldings BidHoldings) string {
+	data, _ := json.Marshal(struct {
+		HoldingsHash   string
+		Name           string
+		Description    string
+		Round          int
+		Status         string
+		CompoundedInto []int
+		Allocation     float64
+		Allocations    []Allocation
+		Withdrawals    []Withdrawal
+		Transfers      []Transfer
+	}{
+		bidHoldings.HoldingsHash, bidHoldings.Name, bidHoldings.Description, bidHoldings.Round, bidHoldings.Status,
+		bidHoldings.CompoundedInto, bidHoldings.InitialAllocation, bidHoldings.Allocations,
+		bidHoldings.Withdrawals, bidHoldings.Transfers,
+	})
+	return contentHash(data)
+}
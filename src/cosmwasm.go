@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GenericCosmWasmVenuePositionConfig is a position in a CosmWasm contract whose amounts are read
+// with queries given in the config, so that simple protocols can be onboarded without an adapter:
+// the amounts of the principal, rewards and TVL are read from the responses of their queries, and
+// multiplied by the redemption rate of RateQuery if set.
+type GenericCosmWasmVenuePositionConfig struct {
+	VenueMetadata
+
+	ContractAddress string // Contract the queries go to unless they name another
+	Address         string
+	Protocol        Protocol // CosmWasm for the contracts on Osmosis, CosmWasmNeutron for the ones on Neutron
+	Denom           string   // Token the queried amounts are of
+	// RedemptionDenom is the token the amounts redeem for at the rate of RateQuery, which values
+	// them, e.g. ATOM for a liquid staking token. If empty, the amounts are valued as Denom.
+	RedemptionDenom string
+	// DisplayUnitAmounts is set if the contract returns amounts in whole tokens rather than in the
+	// base unit of the token.
+	DisplayUnitAmounts bool
+
+	PrincipalQuery CosmWasmQuery
+	RewardsQuery   *CosmWasmQuery // nil if the rewards are included in the principal
+	TVLQuery       *CosmWasmQuery // nil if the TVL isn't tracked
+	RateQuery      *CosmWasmQuery // nil for a rate of 1
+}
+
+// CosmWasmQuery is a smart query of a generic CosmWasm venue and where its response holds the
+// amount.
+type CosmWasmQuery struct {
+	Contract string // Defaults to the ContractAddress of the venue
+	// Message is the JSON query, in which {address} is replaced by the address of the venue, e.g.
+	// {"balance": {"address": "{address}"}}.
+	Message string
+	// Path is the dot-separated path to the amount in the response, with array indices as numbers,
+	// e.g. "balance" or "assets.0.amount". The amount can be a JSON number or string.
+	Path string
+}
+
+func (venueConfig GenericCosmWasmVenuePositionConfig) GetProtocol() Protocol {
+	if venueConfig.Protocol == "" {
+		return CosmWasm
+	}
+	return venueConfig.Protocol
+}
+
+func (venueConfig GenericCosmWasmVenuePositionConfig) GetPoolID() string {
+	return venueConfig.ContractAddress
+}
+
+func (venueConfig GenericCosmWasmVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type GenericCosmWasmPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig GenericCosmWasmVenuePositionConfig
+}
+
+func NewGenericCosmWasmPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*GenericCosmWasmPosition, error) {
+	genericVenuePositionConfig, ok := venuePositionConfig.(GenericCosmWasmVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of GenericCosmWasmVenuePositionConfig type")
+	}
+
+	return &GenericCosmWasmPosition{protocolConfig: config, venuePositionConfig: genericVenuePositionConfig}, nil
+}
+
+func (p GenericCosmWasmPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{
+		SupportsTVL:                p.venuePositionConfig.TVLQuery != nil,
+		SupportsPrincipal:          true,
+		SupportsRewards:            p.venuePositionConfig.RewardsQuery != nil,
+		RewardsIncludedInPrincipal: p.venuePositionConfig.RewardsQuery == nil,
+	}
+}
+
+func (p GenericCosmWasmPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	if p.venuePositionConfig.TVLQuery == nil {
+		return nil, fmt.Errorf("no TVL query is configured")
+	}
+	return p.queryHoldings(ctx, assetData, *p.venuePositionConfig.TVLQuery, "")
+}
+
+func (p GenericCosmWasmPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return p.queryHoldings(ctx, assetData, p.venuePositionConfig.PrincipalQuery, address)
+}
+
+func (p GenericCosmWasmPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	if p.venuePositionConfig.RewardsQuery == nil {
+		return nil, fmt.Errorf("rewards are included in the principal for %s", p.venuePositionConfig.ContractAddress)
+	}
+	return p.queryHoldings(ctx, assetData, *p.venuePositionConfig.RewardsQuery, address)
+}
+
+// queryHoldings reads an amount with the query and values it at the redemption rate.
+func (p GenericCosmWasmPosition) queryHoldings(ctx context.Context, assetData *ChainInfo, query CosmWasmQuery, address string) (*Holdings, error) {
+	amount, err := p.queryAmount(ctx, query, address)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	rate := 1.0
+	if p.venuePositionConfig.RateQuery != nil {
+		if rate, err = p.queryAmount(ctx, *p.venuePositionConfig.RateQuery, address); err != nil {
+			return nil, fmt.Errorf("querying redemption rate: %s", err)
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("invalid redemption rate %v", rate)
+		}
+	}
+
+	valueDenom := p.venuePositionConfig.RedemptionDenom
+	if valueDenom == "" {
+		valueDenom = p.venuePositionConfig.Denom
+	}
+	tokenInfo, err := assetData.GetTokenInfo(valueDenom)
+	if err != nil {
+		return nil, err
+	}
+
+	// the held token has the decimals of the one it redeems for
+	if !p.venuePositionConfig.DisplayUnitAmounts {
+		amount /= math.Pow(10, float64(tokenInfo.Decimals))
+	}
+	usdValue, atomValue, err := getTokenValues(ctx, amount*rate, *tokenInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute token values: %s", err)
+	}
+
+	displayName := tokenInfo.Display
+	if heldInfo, err := assetData.GetTokenInfo(p.venuePositionConfig.Denom); err == nil {
+		displayName = heldInfo.Display
+	}
+
+	return &Holdings{
+		Balances: []Asset{
+			{
+				Denom:       p.venuePositionConfig.Denom,
+				Amount:      amount,
+				USDValue:    usdValue,
+				DisplayName: displayName,
+			},
+		},
+		TotalUSDC: usdValue,
+		TotalAtom: atomValue,
+	}, nil
+}
+
+// queryAmount runs the query for the address and returns the amount at its path.
+func (p GenericCosmWasmPosition) queryAmount(ctx context.Context, query CosmWasmQuery, address string) (float64, error) {
+	message, err := parseCosmWasmMessage(query.Message, address)
+	if err != nil {
+		return 0, err
+	}
+
+	contract := query.Contract
+	if contract == "" {
+		contract = p.venuePositionConfig.ContractAddress
+	}
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, contract, message)
+	if err != nil {
+		return 0, fmt.Errorf("querying %s: %s", contract, err)
+	}
+
+	value, err := jsonPathValue(data, query.Path)
+	if err != nil {
+		return 0, err
+	}
+	return parseCosmWasmNumber(value)
+}
+
+// parseCosmWasmMessage fills the address into a query message and decodes it.
+func parseCosmWasmMessage(message string, address string) (map[string]interface{}, error) {
+	var query map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.ReplaceAll(message, "{address}", address)), &query); err != nil {
+		return nil, fmt.Errorf("invalid query message %q: %v", message, err)
+	}
+	return query, nil
+}
+
+// jsonPathValue returns the value at a dot-separated path in decoded JSON.
+func jsonPathValue(data interface{}, path string) (interface{}, error) {
+	if path == "" {
+		return data, nil
+	}
+	value := data
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			child, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("no %q in the response at %s", key, path)
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("no index %q in the response at %s", key, path)
+			}
+			value = node[index]
+		default:
+			return nil, fmt.Errorf("no %q in the response at %s", key, path)
+		}
+	}
+	return value, nil
+}
+
+// parseCosmWasmNumber parses an amount or rate, which contracts return as a JSON string or number.
+func parseCosmWasmNumber(value interface{}) (float64, error) {
+	var number float64
+	switch value := value.(type) {
+	case float64:
+		number = value
+	case string:
+		var err error
+		if number, err = strconv.ParseFloat(value, 64); err != nil {
+			return 0, fmt.Errorf("%w: %q", errMalformedAmount, value)
+		}
+	default:
+		return 0, fmt.Errorf("%w: %v is not a number", errMalformedAmount, value)
+	}
+	if number < 0 {
+		return 0, fmt.Errorf("%w: %v", errNegativeAmount, number)
+	}
+	return number, nil
+}
+
+// validateGenericCosmWasmQueries checks the denom and queries of a generic CosmWasm venue, so that
+// a broken query template fails the startup rather than every request for the venue.
+func validateGenericCosmWasmQueries(bidId int, venueConfig VenuePositionConfig) []error {
+	genericConfig, ok := venueConfig.(GenericCosmWasmVenuePositionConfig)
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	if genericConfig.Denom == "" {
+		errs = append(errs, fmt.Errorf("venue %s has no Denom", venueID(bidId, venueConfig)))
+	}
+	queries := map[string]*CosmWasmQuery{
+		"PrincipalQuery": &genericConfig.PrincipalQuery,
+		"RewardsQuery":   genericConfig.RewardsQuery,
+		"TVLQuery":       genericConfig.TVLQuery,
+		"RateQuery":      genericConfig.RateQuery,
+	}
+	for _, name := range []string{"PrincipalQuery", "RewardsQuery", "TVLQuery", "RateQuery"} {
+		query := queries[name]
+		if query == nil {
+			continue
+		}
+		if _, err := parseCosmWasmMessage(query.Message, genericConfig.Address); err != nil {
+			errs = append(errs, fmt.Errorf("venue %s has an invalid %s: %v", venueID(bidId, venueConfig), name, err))
+		}
+		if query.Contract != "" {
+			if _, err := decodeBech32(query.Contract); err != nil {
+				errs = append(errs, fmt.Errorf("venue %s has a malformed %s contract %q: %v", venueID(bidId, venueConfig), name, query.Contract, err))
+			}
+		}
+	}
+	return errs
+}
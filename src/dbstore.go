@@ -0,0 +1,613 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// DatabaseTimeout bounds every operation of the database config store.
+const DatabaseTimeout = 10 * time.Second
+
+// errConfigConflict is returned when saving bids that another instance saved over since they were
+// loaded.
+var errConfigConflict = errors.New("the stored bid configs changed since they were loaded, try again")
+
+// databaseDrivers are the database/sql drivers tried for each URL scheme, in order. pgx and the
+// pure Go modernc.org/sqlite are compiled in; another one can be registered by a blank import.
+var databaseDrivers = map[string][]string{
+	"postgres":   {"postgres", "pgx"},
+	"postgresql": {"postgres", "pgx"},
+	"sqlite":     {"sqlite", "sqlite3"},
+}
+
+// databaseColumn is a column added to an existing table by a migration.
+type databaseColumn struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+// databaseMigration is a step of the schema of the database config store, applied in one
+// transaction. Its columns are only added if they are missing: stores created before the schema
+// was versioned already have some of them.
+type databaseMigration struct {
+	Statements []string
+	AddColumns []databaseColumn
+}
+
+// databaseMigrations are the steps of the schema, in order: the schema_version table records how
+// many are applied. New columns and tables get a new migration, the applied ones never change. They
+// only use types that both Postgres and SQLite understand.
+var databaseMigrations = []databaseMigration{
+	{Statements: []string{
+		`CREATE TABLE IF NOT EXISTS bids (
+			bid_id INTEGER PRIMARY KEY,
+			initial_allocation BIGINT NOT NULL,
+			reward_claims TEXT NOT NULL,
+			transfers TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS venues (
+			bid_id INTEGER NOT NULL REFERENCES bids (bid_id),
+			position INTEGER NOT NULL,
+			kind TEXT NOT NULL,
+			config TEXT NOT NULL,
+			PRIMARY KEY (bid_id, position)
+		)`,
+		`CREATE TABLE IF NOT EXISTS withdrawals (
+			bid_id INTEGER NOT NULL REFERENCES bids (bid_id),
+			position INTEGER NOT NULL,
+			date TEXT NOT NULL,
+			withdrawn_amount DOUBLE PRECISION NOT NULL,
+			withdrawn_shares DOUBLE PRECISION NOT NULL,
+			compounded_bid_id INTEGER NOT NULL,
+			compounded_into TEXT NOT NULL,
+			PRIMARY KEY (bid_id, position)
+		)`,
+		// experimental deployments are only ever read and written as a whole
+		`CREATE TABLE IF NOT EXISTS experimental_deployments (
+			experimental_id INTEGER PRIMARY KEY,
+			config TEXT NOT NULL
+		)`,
+		// a single row, bumped on every save, so that other instances notice changes cheaply
+		`CREATE TABLE IF NOT EXISTS config_revision (
+			id INTEGER PRIMARY KEY,
+			revision BIGINT NOT NULL
+		)`,
+		`INSERT INTO config_revision (id, revision) SELECT 1, 0 WHERE NOT EXISTS (SELECT 1 FROM config_revision WHERE id = 1)`,
+	}},
+	// the round of each bid
+	{AddColumns: []databaseColumn{{"bids", "round", "INTEGER NOT NULL DEFAULT 0"}}},
+	// the names and descriptions of bids
+	{AddColumns: []databaseColumn{
+		{"bids", "name", "TEXT NOT NULL DEFAULT ''"},
+		{"bids", "description", "TEXT NOT NULL DEFAULT ''"},
+	}},
+	// the allocation tranches of bids
+	{AddColumns: []databaseColumn{{"bids", "allocations", "TEXT NOT NULL DEFAULT ''"}}},
+	// the frozen summaries of fully withdrawn bids
+	{AddColumns: []databaseColumn{{"bids", "frozen", "TEXT NOT NULL DEFAULT ''"}}},
+}
+
+// isDatabaseURL reports whether a config store location is a database rather than a file.
+func isDatabaseURL(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	if !ok {
+		scheme, _, ok = strings.Cut(location, ":")
+	}
+	_, known := databaseDrivers[strings.ToLower(scheme)]
+	return ok && known
+}
+
+// newConfigStore opens the config store at a location: a postgres:// or sqlite: URL, or else the
+// path of a file.
+func newConfigStore(location string) (ConfigStore, error) {
+	if isDatabaseURL(location) {
+		return NewDatabaseConfigStore(location)
+	}
+	return NewFileConfigStore(location), nil
+}
+
+// DatabaseConfigStore keeps the bid configs in a Postgres or SQLite database, one row per bid,
+// venue and withdrawal, so that several instances can share them.
+type DatabaseConfigStore struct {
+	db       *sql.DB
+	postgres bool // Postgres takes $1 placeholders, SQLite ?
+
+	mu       sync.Mutex
+	revision int64 // the revision the bids were last loaded at, -1 before they are
+}
+
+// NewDatabaseConfigStore connects to the database at a postgres:// or sqlite: URL and migrates its
+// schema to the one of this build. SQLite URLs take a file path, e.g. sqlite:///var/lib/bids.db.
+func NewDatabaseConfigStore(rawURL string) (*DatabaseConfigStore, error) {
+	scheme, rest, ok := strings.Cut(rawURL, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid database URL")
+	}
+	scheme = strings.ToLower(scheme)
+
+	driver := ""
+	for _, name := range databaseDrivers[scheme] {
+		if isDriverRegistered(name) {
+			driver = name
+			break
+		}
+	}
+	if driver == "" {
+		return nil, fmt.Errorf("no %s driver is compiled in, register one of %s", scheme, strings.Join(databaseDrivers[scheme], ", "))
+	}
+
+	dsn := rawURL
+	if scheme == "sqlite" {
+		dsn = strings.TrimPrefix(rest, "//")
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening config database: %v", err)
+	}
+
+	s := &DatabaseConfigStore{db: db, postgres: scheme != "sqlite", revision: -1}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// migrate applies the migrations that the database hasn't had yet.
+func (s *DatabaseConfigStore) migrate() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("creating config database schema: %v", err)
+	}
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if err == sql.ErrNoRows {
+		if _, err := s.db.ExecContext(ctx, `INSERT INTO schema_version (version) VALUES (0)`); err != nil {
+			return fmt.Errorf("creating config database schema: %v", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("reading the config database schema version: %v", err)
+	}
+	if version > len(databaseMigrations) {
+		return fmt.Errorf("config database schema version %d is newer than this build (%d)", version, len(databaseMigrations))
+	}
+
+	for i := version; i < len(databaseMigrations); i++ {
+		if err := s.applyMigration(ctx, i+1, databaseMigrations[i]); err != nil {
+			return fmt.Errorf("migrating config database to schema version %d: %v", i+1, err)
+		}
+		debugLog("Migrated config database", map[string]string{"version": strconv.Itoa(i + 1)})
+	}
+	return nil
+}
+
+func (s *DatabaseConfigStore) applyMigration(ctx context.Context, version int, migration databaseMigration) error {
+	// checked outside the transaction, as a failed query aborts a Postgres transaction
+	var missing []databaseColumn
+	for _, column := range migration.AddColumns {
+		if !s.hasColumn(ctx, column.Table, column.Name) {
+			missing = append(missing, column)
+		}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range migration.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	for _, column := range missing {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", column.Table, column.Name, column.Definition)); err != nil {
+			return fmt.Errorf("adding %s.%s: %v", column.Table, column.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, s.query(`UPDATE schema_version SET version = ?`), version); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// hasColumn reports whether a table has a column.
+func (s *DatabaseConfigStore) hasColumn(ctx context.Context, table string, column string) bool {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 0", column, table))
+	if err != nil {
+		return false
+	}
+	rows.Close()
+	return true
+}
+
+func isDriverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// query rewrites the ? placeholders of a query to $1, $2... for Postgres.
+func (s *DatabaseConfigStore) query(query string) string {
+	if !s.postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// LoadBids reads the stored configs and records their revision, which the next SaveBids expects.
+func (s *DatabaseConfigStore) LoadBids() (map[int]BidPositionConfig, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	// read in one transaction, so that a concurrent save isn't seen halfway
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading config database: %v", err)
+	}
+	defer tx.Rollback()
+
+	var revision int64
+	if err := tx.QueryRowContext(ctx, `SELECT revision FROM config_revision WHERE id = 1`).Scan(&revision); err != nil {
+		return nil, fmt.Errorf("reading the config revision: %v", err)
+	}
+
+	config := &storedConfig{Version: ConfigStoreVersion, Bids: []storedBid{}}
+	bidIndex := make(map[int]int)
+
+	rows, err := tx.QueryContext(ctx, s.query(`SELECT bid_id, name, description, round, initial_allocation, allocations, reward_claims, transfers, frozen FROM bids ORDER BY bid_id`))
+	if err != nil {
+		return nil, fmt.Errorf("reading bids: %v", err)
+	}
+	for rows.Next() {
+		var bid storedBid
+		var allocations, rewardClaims, transfers, frozen string
+		if err := rows.Scan(&bid.BidId, &bid.Name, &bid.Description, &bid.Round, &bid.InitialAllocation, &allocations, &rewardClaims, &transfers, &frozen); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading bids: %v", err)
+		}
+		if err := decodeDatabaseJSON(allocations, &bid.Allocations); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("bid %d: decoding allocations: %v", bid.BidId, err)
+		}
+		if err := decodeDatabaseJSON(rewardClaims, &bid.RewardClaims); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("bid %d: decoding reward claims: %v", bid.BidId, err)
+		}
+		if err := decodeDatabaseJSON(transfers, &bid.Transfers); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("bid %d: decoding transfers: %v", bid.BidId, err)
+		}
+		if err := decodeDatabaseJSON(frozen, &bid.Frozen); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("bid %d: decoding frozen summary: %v", bid.BidId, err)
+		}
+		bid.Venues = []storedVenue{}
+		bid.Withdrawals = []Withdrawal{}
+		bidIndex[bid.BidId] = len(config.Bids)
+		config.Bids = append(config.Bids, bid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading bids: %v", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, s.query(`SELECT bid_id, kind, config FROM venues ORDER BY bid_id, position`))
+	if err != nil {
+		return nil, fmt.Errorf("reading venues: %v", err)
+	}
+	for rows.Next() {
+		var bidId int
+		var venue storedVenue
+		var venueConfig string
+		if err := rows.Scan(&bidId, &venue.Kind, &venueConfig); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading venues: %v", err)
+		}
+		i, ok := bidIndex[bidId]
+		if !ok {
+			rows.Close()
+			return nil, fmt.Errorf("venue of unknown bid %d", bidId)
+		}
+		venue.Config = json.RawMessage(venueConfig)
+		config.Bids[i].Venues = append(config.Bids[i].Venues, venue)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading venues: %v", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, s.query(`SELECT bid_id, date, withdrawn_amount, withdrawn_shares, compounded_bid_id, compounded_into FROM withdrawals ORDER BY bid_id, position`))
+	if err != nil {
+		return nil, fmt.Errorf("reading withdrawals: %v", err)
+	}
+	for rows.Next() {
+		var bidId int
+		var withdrawal Withdrawal
+		var date, compoundedInto string
+		if err := rows.Scan(&bidId, &date, &withdrawal.WithdrawnAmount, &withdrawal.WithdrawnShares, &withdrawal.CompoundedBidId, &compoundedInto); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reading withdrawals: %v", err)
+		}
+		i, ok := bidIndex[bidId]
+		if !ok {
+			rows.Close()
+			return nil, fmt.Errorf("withdrawal of unknown bid %d", bidId)
+		}
+		if withdrawal.Date, err = time.Parse(time.RFC3339Nano, date); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("bid %d: invalid withdrawal date %q", bidId, date)
+		}
+		if err := decodeDatabaseJSON(compoundedInto, &withdrawal.CompoundedInto); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("bid %d: decoding withdrawal targets: %v", bidId, err)
+		}
+		config.Bids[i].Withdrawals = append(config.Bids[i].Withdrawals, withdrawal)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading withdrawals: %v", err)
+	}
+
+	bids, err := decodeBidConfigs(config)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.revision = revision
+	s.mu.Unlock()
+	return bids, nil
+}
+
+// decodeDatabaseJSON decodes a JSON column, where an empty string stands for no value.
+func decodeDatabaseJSON(data string, v interface{}) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+// encodeDatabaseJSON encodes a JSON column, storing empty slices as an empty string.
+func encodeDatabaseJSON[T any](values []T) (string, error) {
+	if len(values) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(values)
+	return string(data), err
+}
+
+// SaveBids replaces the stored configs in one transaction and bumps the revision. Once the bids
+// were loaded, it only saves over the revision they were loaded at, and otherwise fails with
+// errConfigConflict: another instance saved in between, and its change would be lost.
+func (s *DatabaseConfigStore) SaveBids(bids map[int]BidPositionConfig) error {
+	config, err := encodeBidConfigs(bids)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("writing config database: %v", err)
+	}
+	defer tx.Rollback()
+
+	// bumped first, so that a concurrent save waits on the row lock and then fails the check
+	s.mu.Lock()
+	expected := s.revision
+	s.mu.Unlock()
+	if err := bumpConfigRevision(ctx, tx, expected); err != nil {
+		return err
+	}
+
+	for _, table := range []string{"withdrawals", "venues", "bids"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return fmt.Errorf("clearing %s: %v", table, err)
+		}
+	}
+
+	for _, bid := range config.Bids {
+		allocations, err := encodeDatabaseJSON(bid.Allocations)
+		if err != nil {
+			return fmt.Errorf("bid %d: encoding allocations: %v", bid.BidId, err)
+		}
+		rewardClaims, err := encodeDatabaseJSON(bid.RewardClaims)
+		if err != nil {
+			return fmt.Errorf("bid %d: encoding reward claims: %v", bid.BidId, err)
+		}
+		transfers, err := encodeDatabaseJSON(bid.Transfers)
+		if err != nil {
+			return fmt.Errorf("bid %d: encoding transfers: %v", bid.BidId, err)
+		}
+		frozen := ""
+		if bid.Frozen != nil {
+			data, err := json.Marshal(bid.Frozen)
+			if err != nil {
+				return fmt.Errorf("bid %d: encoding frozen summary: %v", bid.BidId, err)
+			}
+			frozen = string(data)
+		}
+		if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO bids (bid_id, name, description, round, initial_allocation, allocations, reward_claims, transfers, frozen) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			bid.BidId, bid.Name, bid.Description, bid.Round, bid.InitialAllocation, allocations, rewardClaims, transfers, frozen); err != nil {
+			return fmt.Errorf("bid %d: %v", bid.BidId, err)
+		}
+
+		for i, venue := range bid.Venues {
+			if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO venues (bid_id, position, kind, config) VALUES (?, ?, ?, ?)`),
+				bid.BidId, i, venue.Kind, string(venue.Config)); err != nil {
+				return fmt.Errorf("bid %d: venue %d: %v", bid.BidId, i, err)
+			}
+		}
+
+		for i, withdrawal := range bid.Withdrawals {
+			compoundedInto, err := encodeDatabaseJSON(withdrawal.CompoundedInto)
+			if err != nil {
+				return fmt.Errorf("bid %d: encoding withdrawal targets: %v", bid.BidId, err)
+			}
+			if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO withdrawals (bid_id, position, date, withdrawn_amount, withdrawn_shares, compounded_bid_id, compounded_into) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+				bid.BidId, i, withdrawal.Date.Format(time.RFC3339Nano), withdrawal.WithdrawnAmount, withdrawal.WithdrawnShares, withdrawal.CompoundedBidId, compoundedInto); err != nil {
+				return fmt.Errorf("bid %d: withdrawal %d: %v", bid.BidId, i, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("writing config database: %v", err)
+	}
+	if expected >= 0 {
+		s.mu.Lock()
+		s.revision = expected + 1
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// bumpConfigRevision bumps the revision in a save, if it still is the expected one. A negative
+// expected revision bumps any.
+func bumpConfigRevision(ctx context.Context, tx *sql.Tx, expected int64) error {
+	query := `UPDATE config_revision SET revision = revision + 1 WHERE id = 1`
+	if expected >= 0 {
+		query += ` AND revision = ` + strconv.FormatInt(expected, 10)
+	}
+	result, err := tx.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("bumping the config revision: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return errConfigConflict
+	}
+	return nil
+}
+
+// LoadExperimental never returns nil: the database is created with the experimental deployments
+// by the config command.
+func (s *DatabaseConfigStore) LoadExperimental() (map[int]*ExperimentalDeployment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT config FROM experimental_deployments ORDER BY experimental_id`)
+	if err != nil {
+		return nil, fmt.Errorf("reading experimental deployments: %v", err)
+	}
+	defer rows.Close()
+
+	stored := []storedExperimental{}
+	for rows.Next() {
+		var config string
+		if err := rows.Scan(&config); err != nil {
+			return nil, fmt.Errorf("reading experimental deployments: %v", err)
+		}
+		var deployment storedExperimental
+		if err := decodeStrictJSON([]byte(config), &deployment); err != nil {
+			return nil, fmt.Errorf("decoding experimental deployment: %v", err)
+		}
+		stored = append(stored, deployment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading experimental deployments: %v", err)
+	}
+
+	return decodeExperimentals(stored)
+}
+
+func (s *DatabaseConfigStore) SaveExperimental(deployments map[int]*ExperimentalDeployment) error {
+	stored, err := encodeExperimentals(deployments)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("writing config database: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM experimental_deployments"); err != nil {
+		return fmt.Errorf("clearing experimental_deployments: %v", err)
+	}
+	for _, deployment := range stored {
+		config, err := json.Marshal(deployment)
+		if err != nil {
+			return fmt.Errorf("experimental deployment %d: %v", deployment.ExperimentalId, err)
+		}
+		if _, err := tx.ExecContext(ctx, s.query(`INSERT INTO experimental_deployments (experimental_id, config) VALUES (?, ?)`),
+			deployment.ExperimentalId, string(config)); err != nil {
+			return fmt.Errorf("experimental deployment %d: %v", deployment.ExperimentalId, err)
+		}
+	}
+
+	if err := bumpConfigRevision(ctx, tx, -1); err != nil {
+		return err
+	}
+	var revision int64
+	if err := tx.QueryRowContext(ctx, `SELECT revision FROM config_revision WHERE id = 1`).Scan(&revision); err != nil {
+		return fmt.Errorf("reading the config revision: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("writing config database: %v", err)
+	}
+
+	// the bids didn't change, so they may still be saved over this revision
+	s.mu.Lock()
+	if s.revision == revision-1 {
+		s.revision = revision
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Revision returns the revision of the stored configs, bumped on every save.
+func (s *DatabaseConfigStore) Revision() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	var revision int64
+	if err := s.db.QueryRowContext(ctx, `SELECT revision FROM config_revision WHERE id = 1`).Scan(&revision); err != nil {
+		return "", fmt.Errorf("reading the config revision: %v", err)
+	}
+	return strconv.FormatInt(revision, 10), nil
+}
+
+// IsEmpty reports whether no bids are stored yet.
+func (s *DatabaseConfigStore) IsEmpty() (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DatabaseTimeout)
+	defer cancel()
+
+	var count int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM bids`).Scan(&count); err != nil {
+		return false, fmt.Errorf("counting bids: %v", err)
+	}
+	return count == 0, nil
+}
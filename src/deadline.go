@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DeadlineHeader asks for a response within the given number of milliseconds.
+const DeadlineHeader = "X-Deadline-Ms"
+
+// maxRequestDeadline bounds the deadlines requested by clients.
+var maxRequestDeadline = 30 * time.Second
+
+type requestDeadlineKey struct{}
+
+// withRequestDeadline applies the deadline requested in the header of a request to its context.
+// Unlike a context deadline, it doesn't cancel the computations, which complete in the background.
+func withRequestDeadline(r *http.Request) (*http.Request, error) {
+	header := r.Header.Get(DeadlineHeader)
+	if header == "" {
+		return r, nil
+	}
+
+	ms, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || ms < 0 {
+		return nil, fmt.Errorf("invalid %s header: %q", DeadlineHeader, header)
+	}
+	timeout := time.Duration(ms) * time.Millisecond
+	if timeout > maxRequestDeadline {
+		timeout = maxRequestDeadline
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), requestDeadlineKey{}, time.Now().Add(timeout))), nil
+}
+
+func requestDeadline(ctx context.Context) (time.Time, bool) {
+	deadline, ok := ctx.Value(requestDeadlineKey{}).(time.Time)
+	return deadline, ok
+}
+
+// refreshHoldingsUntil recomputes the expired venues of a bid like refreshHoldings, but only waits
+// for them until the deadline. Venues that aren't done by then are served from the cache, marked as
+// stale, or as pending if they were never computed, and finish in the background. Such partial
+// results are not cached.
+func refreshHoldingsUntil(ctx context.Context, bidId int, deadline time.Time) ([]VenueHoldings, error) {
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return nil, fmt.Errorf("bid not found: %d", bidId)
+	}
+
+	// the computations outlive the request
+	background := context.WithoutCancel(ctx)
+
+	var wg sync.WaitGroup
+	var errMu sync.Mutex
+	var firstErr error
+	now := time.Now()
+	for _, venueConfig := range productionVenues(bidConfig) {
+		if cached, ok := getCachedVenue(venueID(bidId, venueConfig)); ok && !cached.Stale && now.Before(cached.expiresAt()) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(venueConfig VenuePositionConfig) {
+			defer wg.Done()
+			if _, err := refreshVenue(background, bidId, venueConfig); err != nil {
+				errMu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMu.Unlock()
+			}
+		}(venueConfig)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return publishBidHoldings(bidId)
+	case <-time.After(time.Until(deadline)):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	return assemblePartialBidHoldings(bidId, time.Now())
+}
+
+// assemblePartialBidHoldings collects the holdings of a bid from the venue cache like
+// assembleBidHoldings, with placeholders for the venues that haven't been computed yet.
+func assemblePartialBidHoldings(bidId int, now time.Time) ([]VenueHoldings, error) {
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return nil, fmt.Errorf("bid not found: %d", bidId)
+	}
+
+	bidHoldings := make([]VenueHoldings, 0, len(bidConfig.Venues))
+	for _, venueConfig := range productionVenues(bidConfig) {
+		id := venueID(bidId, venueConfig)
+		cached, ok := getCachedVenue(id)
+		if !ok {
+			bidHoldings = append(bidHoldings, VenueHoldings{
+				VenueID:      id,
+				Name:         venueConfig.GetMetadata().Name,
+				Description:  venueConfig.GetMetadata().Description,
+				DisplayName:  venueDisplayName(venueConfig, nil),
+				Links:        venueLinks(venueConfig),
+				ProtocolLogo: protocolLogos[venueConfig.GetProtocol()],
+				Pool:         venuePoolComposition(venueConfig),
+				Supersedes:   venueConfig.GetMetadata().Supersedes,
+				SupersededBy: venueConfig.GetMetadata().SupersededBy,
+				Protocol:     venueConfig.GetProtocol(),
+				Pending:      true,
+			})
+			continue
+		}
+
+		holdings := cached.Holdings
+		holdings.Stale = cached.Stale || !now.Before(cached.expiresAt())
+		bidHoldings = append(bidHoldings, holdings)
+	}
+
+	return revalueAtom(bidHoldings, currentPrices.Load()), nil
+}
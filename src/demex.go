@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+// DemexVenuePositionConfig is a deposit into a Demex perp liquidity pool on Carbon.
+type DemexVenuePositionConfig struct {
+	VenueMetadata
+
+	PoolID  string
+	Address string
+	// ActiveShares is the pool share token amount of the bid, if the address holds shares of
+	// several bids. If 0, all shares held by the address count.
+	ActiveShares float64
+}
+
+func (venueConfig DemexVenuePositionConfig) GetProtocol() Protocol {
+	return Demex
+}
+
+func (venueConfig DemexVenuePositionConfig) GetPoolID() string {
+	return venueConfig.PoolID
+}
+
+func (venueConfig DemexVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type DemexPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig DemexVenuePositionConfig
+}
+
+func NewDemexPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*DemexPosition, error) {
+	demexVenuePositionConfig, ok := venuePositionConfig.(DemexVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of DemexVenuePositionConfig type")
+	}
+
+	return &DemexPosition{protocolConfig: config, venuePositionConfig: demexVenuePositionConfig}, nil
+}
+
+// The pool's trading fees and funding accrue to its NAV per share.
+func (p DemexPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+func (p DemexPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	pool, err := p.getPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.poolHoldings(ctx, assetData, pool, 1)
+}
+
+func (p DemexPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	pool, err := p.getPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := p.venuePositionConfig.ActiveShares
+	if shares == 0 {
+		if shares, err = p.getShareBalance(ctx, address, pool.ShareDenom); err != nil {
+			return nil, err
+		}
+	}
+	if shares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	if pool.totalShares == 0 {
+		return nil, fmt.Errorf("pool %s has no shares", p.venuePositionConfig.PoolID)
+	}
+	return p.poolHoldings(ctx, assetData, pool, shares/pool.totalShares)
+}
+
+func (p DemexPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Demex")
+}
+
+// demexPool is a perp liquidity pool with its net asset value, in its deposit denom.
+type demexPool struct {
+	DepositDenom string `json:"deposit_denom"`
+	ShareDenom   string `json:"share_denom"`
+
+	totalShares float64
+	totalNAV    float64
+}
+
+func (p DemexPosition) getPool(ctx context.Context) (*demexPool, error) {
+	poolId := url.PathEscape(p.venuePositionConfig.PoolID)
+
+	var poolResponse struct {
+		Pool *demexPool `json:"pool"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf("%s/pools/%s", p.protocolConfig.PoolInfoUrl, poolId), &poolResponse); err != nil {
+		return nil, fmt.Errorf("querying pool: %s", err)
+	}
+	if poolResponse.Pool == nil || poolResponse.Pool.DepositDenom == "" || poolResponse.Pool.ShareDenom == "" {
+		return nil, fmt.Errorf("invalid pool structure")
+	}
+
+	var infoResponse struct {
+		PoolInfo *struct {
+			TotalShareAmount string `json:"total_share_amount"`
+			TotalNAVAmount   string `json:"total_nav_amount"`
+		} `json:"pool_info"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf("%s/pools/pool_info/%s", p.protocolConfig.PoolInfoUrl, poolId), &infoResponse); err != nil {
+		return nil, fmt.Errorf("querying pool info: %s", err)
+	}
+	if infoResponse.PoolInfo == nil {
+		return nil, fmt.Errorf("invalid pool info structure")
+	}
+
+	pool := poolResponse.Pool
+	var err error
+	if pool.totalShares, err = strconv.ParseFloat(infoResponse.PoolInfo.TotalShareAmount, 64); err != nil {
+		return nil, fmt.Errorf("failed to parse total_share_amount into float64: %s", err)
+	}
+	if pool.totalNAV, err = strconv.ParseFloat(infoResponse.PoolInfo.TotalNAVAmount, 64); err != nil {
+		return nil, fmt.Errorf("failed to parse total_nav_amount into float64: %s", err)
+	}
+	return pool, nil
+}
+
+// getShareBalance returns the amount of pool share tokens held by the address.
+func (p DemexPosition) getShareBalance(ctx context.Context, address string, shareDenom string) (float64, error) {
+	var response struct {
+		Balance *struct {
+			Amount string `json:"amount"`
+		} `json:"balance"`
+	}
+	balanceUrl := fmt.Sprintf("%s/%s/by_denom?denom=%s", p.protocolConfig.AddressBalanceUrl, address, url.QueryEscape(shareDenom))
+	if err := getJSON(ctx, balanceUrl, &response); err != nil {
+		return 0, fmt.Errorf("querying share balance: %s", err)
+	}
+	if response.Balance == nil {
+		return 0, fmt.Errorf("invalid balance structure")
+	}
+
+	shares, err := strconv.ParseFloat(response.Balance.Amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse balance into float64: %s", err)
+	}
+	return shares, nil
+}
+
+// poolHoldings returns the given share of the NAV of the pool.
+func (p DemexPosition) poolHoldings(ctx context.Context, assetData *ChainInfo, pool *demexPool, share float64) (*Holdings, error) {
+	tokenInfo, err := assetData.GetTokenInfo(pool.DepositDenom)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustedAmount := pool.totalNAV * share / math.Pow(10, float64(tokenInfo.Decimals))
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute token values: %s", err)
+	}
+
+	return &Holdings{
+		Balances: []Asset{
+			{
+				Denom:       pool.DepositDenom,
+				Amount:      adjustedAmount,
+				USDValue:    usdValue,
+				DisplayName: tokenInfo.Display,
+			},
+		},
+		TotalUSDC: usdValue,
+		TotalAtom: atomValue,
+	}, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeprecatedPoolLookback is how far back the snapshots are searched for the last holdings of a
+// venue whose pool is deprecated, if it has no cached computation.
+const DeprecatedPoolLookback = 30 * 24 * time.Hour
+
+var deprecatedPoolsMetric = newCounter("pool_deprecated_total",
+	"Number of venue computations that failed because the pool no longer exists or was migrated.")
+
+// deprecatedPoolErrors are the errors of the chain APIs and contracts when a pool or its contract
+// no longer exists, or was migrated to a new one.
+var deprecatedPoolErrors = []string{
+	"no such contract",
+	"contract: not found",
+	"contract not found",
+	"pool not found",
+	"pool does not exist",
+	"migrated",
+	"deprecated",
+}
+
+// isPoolDeprecatedError reports whether a venue computation failed because its pool is gone,
+// rather than because of a failing upstream.
+func isPoolDeprecatedError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, pattern := range deprecatedPoolErrors {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveDeprecatedVenue serves the last known holdings of a venue whose pool is deprecated, from
+// the cache or else the snapshots, flagged as pool_deprecated, and alerts the operators to
+// update its config.
+func serveDeprecatedVenue(id string, venueConfig VenuePositionConfig, err error) (*VenueHoldings, error) {
+	protocol := string(venueConfig.GetProtocol())
+	deprecatedPoolsMetric.Add(1, "protocol", protocol)
+	fireAlert(AlertPoolDeprecated, id, fmt.Sprintf("pool %s of %s looks migrated or deprecated (%v); update the config of the venue",
+		venueConfig.GetPoolID(), protocol, err))
+
+	holdings, computedAt, ok := lastKnownVenue(id)
+	if !ok {
+		return nil, fmt.Errorf("pool of venue %s is deprecated and it has no earlier result: %v", id, err)
+	}
+
+	holdings.Stale = true
+	holdings.PoolDeprecated = true
+	holdings.Trace = nil
+	markVenueDeprecated(id, holdings, computedAt)
+	return &holdings, nil
+}
+
+// lastKnownVenue returns the last computation of a venue and when it was computed.
+func lastKnownVenue(id string) (VenueHoldings, time.Time, bool) {
+	if cached, ok := getCachedVenue(id); ok {
+		return cached.Holdings, cached.ComputedAt, true
+	}
+	if snapshotStore == nil {
+		return VenueHoldings{}, time.Time{}, false
+	}
+
+	now := time.Now()
+	history, err := venueHistory(id, now.Add(-DeprecatedPoolLookback), now)
+	if err != nil || len(history) == 0 {
+		return VenueHoldings{}, time.Time{}, false
+	}
+	last := history[len(history)-1]
+	return last.Holdings, last.Timestamp, true
+}
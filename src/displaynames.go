@@ -0,0 +1,59 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// venueDisplayName returns the name of a venue for clients: its configured Name or, failing that,
+// one generated from its protocol, the assets of its pool and its pool ID, e.g.
+// "Osmosis ATOM/USDC CL (pool 1283)". composition are the holdings of the pool, or of the address
+// for venues without a pool, and may be nil.
+func venueDisplayName(venueConfig VenuePositionConfig, composition *Holdings) string {
+	if name := venueConfig.GetMetadata().Name; name != "" {
+		return name
+	}
+
+	parts := []string{string(venueConfig.GetProtocol())}
+
+	var symbols []string
+	if composition != nil {
+		seen := make(map[string]bool)
+		for _, asset := range composition.Balances {
+			symbol := asset.DisplayName
+			if symbol == "" {
+				symbol = asset.Denom
+			}
+			if !seen[symbol] {
+				seen[symbol] = true
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	if len(symbols) > 0 {
+		parts = append(parts, strings.Join(symbols, "/"))
+	}
+
+	if kind := poolKind(venueConfig); kind != "" {
+		parts = append(parts, kind)
+	}
+
+	// only numeric pool IDs are readable, contract addresses are left to the links
+	if _, err := strconv.Atoi(venueConfig.GetPoolID()); err == nil {
+		parts = append(parts, "(pool "+venueConfig.GetPoolID()+")")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// poolKind names the type of pool of a venue, unless it is the usual constant product pool.
+func poolKind(venueConfig VenuePositionConfig) string {
+	pool := venuePoolComposition(venueConfig)
+	if pool == nil || pool.Type == PoolTypeXYK {
+		return ""
+	}
+	if pool.Type == PoolTypeCL {
+		return "CL"
+	}
+	return pool.Type
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DropVenuePositionConfig is dATOM, the liquid staking token of Drop on Neutron, held by an
+// address. dATOM is redeemable for ATOM at the exchange rate of the Drop core contract, which
+// grows with the staking rewards.
+type DropVenuePositionConfig struct {
+	VenueMetadata
+
+	CoreAddress string // Contract address of the Drop core contract, which sets the exchange rate
+	DAtomDenom  string // Denom of dATOM, e.g. factory/neutron1.../udatom
+	Address     string
+	// ActiveShares is the dATOM amount of the bid, in its base unit, if the address holds the dATOM
+	// of several bids. If 0, all dATOM held by the address counts.
+	ActiveShares float64
+}
+
+func (venueConfig DropVenuePositionConfig) GetProtocol() Protocol {
+	return Drop
+}
+
+func (venueConfig DropVenuePositionConfig) GetPoolID() string {
+	return venueConfig.CoreAddress
+}
+
+func (venueConfig DropVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type DropPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig DropVenuePositionConfig
+}
+
+func NewDropPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*DropPosition, error) {
+	dropVenuePositionConfig, ok := venuePositionConfig.(DropVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of DropVenuePositionConfig type")
+	}
+
+	return &DropPosition{protocolConfig: config, venuePositionConfig: dropVenuePositionConfig}, nil
+}
+
+// The staking rewards accrue to the exchange rate of dATOM.
+func (p DropPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+// ComputeTVL returns all dATOM in circulation, in ATOM terms.
+func (p DropPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	var response struct {
+		Amount *struct {
+			Amount string `json:"amount"`
+		} `json:"amount"`
+	}
+	supplyUrl := fmt.Sprintf("%s/supply/by_denom?denom=%s",
+		strings.TrimSuffix(p.protocolConfig.AddressBalanceUrl, "/balances"), url.QueryEscape(p.venuePositionConfig.DAtomDenom))
+	if err := getJSON(ctx, supplyUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying dATOM supply: %s", err)
+	}
+	if response.Amount == nil {
+		return nil, fmt.Errorf("invalid supply structure")
+	}
+	supply, err := parseAmount(response.Amount.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dATOM supply: %w", err)
+	}
+
+	return p.dAtomHoldings(ctx, assetData, supply)
+}
+
+// ComputeAddressPrincipalHoldings returns the dATOM of the address, valued at the ATOM it
+// redeems for.
+func (p DropPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	var amount *big.Int
+	if shares := p.venuePositionConfig.ActiveShares; shares != 0 {
+		amount, _ = big.NewFloat(shares).Int(nil)
+	} else {
+		var response struct {
+			Balance *struct {
+				Amount string `json:"amount"`
+			} `json:"balance"`
+		}
+		balanceUrl := fmt.Sprintf("%s/%s/by_denom?denom=%s", p.protocolConfig.AddressBalanceUrl, address, url.QueryEscape(p.venuePositionConfig.DAtomDenom))
+		if err := getJSON(ctx, balanceUrl, &response); err != nil {
+			return nil, fmt.Errorf("querying dATOM balance: %s", err)
+		}
+		if response.Balance == nil {
+			return nil, fmt.Errorf("invalid balance structure")
+		}
+
+		var err error
+		if amount, err = parseAmount(response.Balance.Amount); err != nil {
+			return nil, fmt.Errorf("invalid dATOM balance: %w", err)
+		}
+	}
+
+	return p.dAtomHoldings(ctx, assetData, amount)
+}
+
+func (p DropPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Drop")
+}
+
+// getExchangeRate returns the ATOM one dATOM redeems for.
+func (p DropPosition) getExchangeRate(ctx context.Context) (float64, error) {
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.CoreAddress, map[string]interface{}{
+			"exchange_rate": map[string]interface{}{},
+		})
+	if err != nil {
+		return 0, fmt.Errorf("querying exchange rate: %s", err)
+	}
+
+	rateStr, ok := data.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid exchange rate structure")
+	}
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse exchange rate into float64: %s", err)
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("invalid exchange rate %s", rateStr)
+	}
+	return rate, nil
+}
+
+// dAtomHoldings values an amount of dATOM, in its base unit, at the ATOM it redeems for. The
+// amount stays in dATOM, while its values are those of the redeemed ATOM.
+func (p DropPosition) dAtomHoldings(ctx context.Context, assetData *ChainInfo, amount *big.Int) (*Holdings, error) {
+	if amount.Sign() == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	rate, err := p.getExchangeRate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	atomInfo, err := assetData.GetTokenInfo(NEUTRON_ATOM)
+	if err != nil {
+		return nil, err
+	}
+
+	// dATOM has the decimals of ATOM
+	dAtomAmount := tokenAmount(amount, atomInfo.Decimals)
+	usdValue, atomValue, err := getTokenValues(ctx, dAtomAmount*rate, *atomInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute token values: %s", err)
+	}
+
+	return &Holdings{
+		Balances: []Asset{
+			{
+				Denom:       p.venuePositionConfig.DAtomDenom,
+				Amount:      dAtomAmount,
+				ExactAmount: exactTokenAmount(amount, atomInfo.Decimals),
+				USDValue:    usdValue,
+				DisplayName: "dATOM",
+			},
+		},
+		TotalUSDC: usdValue,
+		TotalAtom: atomValue,
+	}, nil
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math"
 	"strconv"
 )
 
 type DualityVenuePositionConfig struct {
+	VenueMetadata
+
 	PoolAddress  string // Contract address of the pool
 	Address      string
 	ActiveShares int64 // LP token amount, this is a way to track the funds deployed per bid
@@ -24,6 +26,10 @@ func (venueConfig DualityVenuePositionConfig) GetAddress() string {
 	return venueConfig.Address
 }
 
+func (venueConfig DualityVenuePositionConfig) GetActiveShares() float64 {
+	return float64(venueConfig.ActiveShares)
+}
+
 type DualityPosition struct {
 	protocolConfig      ProtocolConfig
 	venuePositionConfig DualityVenuePositionConfig
@@ -41,13 +47,13 @@ func NewDualityPosition(config ProtocolConfig, venuePositionConfig VenuePosition
 	}, nil
 }
 
-func (p DualityPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
+func (p DualityPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	// Query pool info
 	queryMsg := map[string]interface{}{
 		"get_balance": map[string]interface{}{},
 	}
 
-	poolData, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	poolData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.PoolAddress, queryMsg)
 	if err != nil {
 		return nil, fmt.Errorf("querying pool data: %w", err)
@@ -84,9 +90,9 @@ func (p DualityPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 			continue
 		}
 
-		amount, err := strconv.ParseInt(amountStr, 10, 64)
+		amount, err := parseAmount(amountStr)
 		if err != nil {
-			debugLog("Error parsing amount", map[string]string{"denom": denom})
+			debugLog("Error parsing amount", map[string]string{"denom": denom, "error": err.Error()})
 			continue
 		}
 
@@ -98,10 +104,10 @@ func (p DualityPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		}
 
 		// Adjust amount by decimals
-		adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
 
 		// Get USD and ATOM value
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			debugLog("Error getting token values", map[string]string{"denom": denom})
 			continue
@@ -114,6 +120,7 @@ func (p DualityPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		poolAssets = append(poolAssets, Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		})
@@ -126,7 +133,7 @@ func (p DualityPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 	}, nil
 }
 
-func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _ string) (*Holdings, error) {
+func (p DualityPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, _ string) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -145,7 +152,7 @@ func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 		},
 	}
 
-	withdrawData, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	withdrawData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.PoolAddress, withdrawQuery)
 	if err != nil {
 		return nil, fmt.Errorf("simulating withdrawal: %s", err)
@@ -162,7 +169,7 @@ func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 	}
 
 	// Get pool assets for token denominations
-	poolAssets, err := getPoolAssets(p)
+	poolAssets, err := getPoolAssets(ctx, p)
 	if err != nil {
 		return nil, fmt.Errorf("getting pool assets: %s", err)
 	}
@@ -172,9 +179,9 @@ func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 	totalValueATOM := 0.0
 
 	for i, amountStr := range amounts {
-		amount, err := strconv.ParseInt(amountStr.(string), 10, 64)
+		amount, err := parseAmountValue(amountStr)
 		if err != nil {
-			debugLog("Error parsing token amount", map[string]string{"index": strconv.Itoa(i)})
+			debugLog("Error parsing token amount", map[string]string{"index": strconv.Itoa(i), "error": err.Error()})
 			continue
 		}
 
@@ -185,8 +192,8 @@ func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 			continue
 		}
 
-		adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			debugLog("Error getting token values", map[string]string{"denom": denom})
 			continue
@@ -198,6 +205,7 @@ func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 		holdingAssets = append(holdingAssets, Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		})
@@ -210,17 +218,21 @@ func (p DualityPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 	}, nil
 }
 
-func (p DualityPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p DualityPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+func (p DualityPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	// Duality protocol doesn't keep track of the initial holdings and yield separately
 	return &Holdings{}, nil
 }
 
-func getPoolAssets(p DualityPosition) ([]Asset, error) {
+func getPoolAssets(ctx context.Context, p DualityPosition) ([]Asset, error) {
 	configQuery := map[string]interface{}{
 		"get_config": map[string]interface{}{},
 	}
 
-	configData, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl,
+	configData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
 		p.venuePositionConfig.PoolAddress, configQuery)
 	if err != nil {
 		return nil, fmt.Errorf("querying pool config: %s", err)
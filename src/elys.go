@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -22,6 +23,8 @@ const (
 )
 
 type ElysVenuePositionConfig struct {
+	VenueMetadata
+
 	PoolId       string
 	Address      string
 	ActiveShares float64  // lp token amount, this is a way to track the funds deployed per bid
@@ -40,6 +43,10 @@ func (venueConfig ElysVenuePositionConfig) GetAddress() string {
 	return venueConfig.Address
 }
 
+func (venueConfig ElysVenuePositionConfig) GetActiveShares() float64 {
+	return venueConfig.ActiveShares
+}
+
 type ElysPosition struct {
 	protocolConfig      ProtocolConfig
 	venuePositionConfig ElysVenuePositionConfig
@@ -57,19 +64,19 @@ func NewElysPosition(config ProtocolConfig, venuePositionConfig VenuePositionCon
 	}, nil
 }
 
-func (p ElysPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
+func (p ElysPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	switch p.venuePositionConfig.PoolType {
 	case Stablestake:
-		return p.computeStablestakeTVL(assetData)
+		return p.computeStablestakeTVL(ctx, assetData)
 	case AMM:
-		return p.computeAMMTVL(assetData)
+		return p.computeAMMTVL(ctx, assetData)
 	default:
 		return nil, fmt.Errorf("unsupported pool type: %s", p.venuePositionConfig.PoolType)
 	}
 }
 
-func (p ElysPosition) computeStablestakeTVL(assetData *ChainInfo) (*Holdings, error) {
-	poolData, err := p.fetchStablestakePoolData()
+func (p ElysPosition) computeStablestakeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	poolData, err := p.fetchStablestakePoolData(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -89,9 +96,9 @@ func (p ElysPosition) computeStablestakeTVL(assetData *ChainInfo) (*Holdings, er
 		return nil, fmt.Errorf("missing or invalid net_amount in pool data")
 	}
 
-	amount, err := strconv.ParseInt(netAmountStr, 10, 64)
+	amount, err := parseAmount(netAmountStr)
 	if err != nil {
-		return nil, fmt.Errorf("parsing net_amount: %v", err)
+		return nil, fmt.Errorf("parsing net_amount: %w", err)
 	}
 
 	tokenInfo, err := assetData.GetTokenInfo(depositDenom)
@@ -99,9 +106,9 @@ func (p ElysPosition) computeStablestakeTVL(assetData *ChainInfo) (*Holdings, er
 		return nil, fmt.Errorf("getting token info: %v", err)
 	}
 
-	adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
+	adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
 
-	usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("calculating token values: %v", err)
 	}
@@ -110,6 +117,7 @@ func (p ElysPosition) computeStablestakeTVL(assetData *ChainInfo) (*Holdings, er
 		{
 			Denom:       depositDenom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		},
@@ -122,9 +130,9 @@ func (p ElysPosition) computeStablestakeTVL(assetData *ChainInfo) (*Holdings, er
 	}, nil
 }
 
-func (p ElysPosition) computeAMMTVL(assetData *ChainInfo) (*Holdings, error) {
+func (p ElysPosition) computeAMMTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	// Fetch AMM pool data
-	poolData, err := p.fetchAMMPoolData()
+	poolData, err := p.fetchAMMPoolData(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("fetching AMM pool data: %v", err)
 	}
@@ -166,9 +174,9 @@ func (p ElysPosition) computeAMMTVL(assetData *ChainInfo) (*Holdings, error) {
 		}
 
 		// Parse the amount
-		amount, err := strconv.ParseInt(amountStr, 10, 64)
+		amount, err := parseAmount(amountStr)
 		if err != nil {
-			return nil, fmt.Errorf("parsing token amount: %v", err)
+			return nil, fmt.Errorf("parsing token amount: %w", err)
 		}
 
 		// Get token info
@@ -178,10 +186,10 @@ func (p ElysPosition) computeAMMTVL(assetData *ChainInfo) (*Holdings, error) {
 		}
 
 		// Calculate adjusted amount
-		adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
 
 		// Calculate USD and ATOM values
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			return nil, fmt.Errorf("calculating token values for denom %s: %v", denom, err)
 		}
@@ -194,6 +202,7 @@ func (p ElysPosition) computeAMMTVL(assetData *ChainInfo) (*Holdings, error) {
 		poolAssets = append(poolAssets, Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		})
@@ -207,7 +216,7 @@ func (p ElysPosition) computeAMMTVL(assetData *ChainInfo) (*Holdings, error) {
 	}, nil
 }
 
-func (p ElysPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p ElysPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -218,18 +227,18 @@ func (p ElysPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, addr
 
 	switch p.venuePositionConfig.PoolType {
 	case Stablestake:
-		return p.computeStablestakePrincipalHoldings(assetData, address)
+		return p.computeStablestakePrincipalHoldings(ctx, assetData, address)
 	case AMM:
-		return p.computeAMMPrincipalHoldings(assetData, address)
+		return p.computeAMMPrincipalHoldings(ctx, assetData, address)
 	default:
 		return nil, fmt.Errorf("unsupported pool type: %s", p.venuePositionConfig.PoolType)
 	}
 }
 
-func (p ElysPosition) computeStablestakePrincipalHoldings(assetData *ChainInfo, _ string) (*Holdings, error) {
+func (p ElysPosition) computeStablestakePrincipalHoldings(ctx context.Context, assetData *ChainInfo, _ string) (*Holdings, error) {
 	amount := p.venuePositionConfig.ActiveShares
 
-	poolData, err := p.fetchStablestakePoolData()
+	poolData, err := p.fetchStablestakePoolData(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -262,7 +271,7 @@ func (p ElysPosition) computeStablestakePrincipalHoldings(assetData *ChainInfo,
 	adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
 	holdings := adjustedAmount * redemptionRate
 
-	usdValue, atomValue, err := getTokenValues(holdings, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, holdings, *tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("calculating token values: %v", err)
 	}
@@ -283,7 +292,7 @@ func (p ElysPosition) computeStablestakePrincipalHoldings(assetData *ChainInfo,
 	}, nil
 }
 
-func (p ElysPosition) computeAMMPrincipalHoldings(assetData *ChainInfo, _ string) (*Holdings, error) {
+func (p ElysPosition) computeAMMPrincipalHoldings(ctx context.Context, assetData *ChainInfo, _ string) (*Holdings, error) {
 	// Use LPAmount from the venue position config
 	amount := p.venuePositionConfig.ActiveShares
 	if amount == 0 {
@@ -291,7 +300,7 @@ func (p ElysPosition) computeAMMPrincipalHoldings(assetData *ChainInfo, _ string
 	}
 
 	// Fetch AMM pool data
-	poolData, err := p.fetchAMMPoolData()
+	poolData, err := p.fetchAMMPoolData(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("fetching AMM pool data: %v", err)
 	}
@@ -328,7 +337,7 @@ func (p ElysPosition) computeAMMPrincipalHoldings(assetData *ChainInfo, _ string
 	holdings := adjustedAmount * lpTokenPrice
 
 	// Calculate USD and ATOM values
-	usdValue, atomValue, err := getTokenValues(holdings, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, holdings, *tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("calculating token values: %v", err)
 	}
@@ -351,8 +360,12 @@ func (p ElysPosition) computeAMMPrincipalHoldings(assetData *ChainInfo, _ string
 	}, nil
 }
 
+func (p ElysPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
 // We can only calculate rewards per address, not per bid.
-func (p ElysPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p ElysPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -371,7 +384,7 @@ func (p ElysPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address
 		rewardURL := fmt.Sprintf("%s/masterchef/user_reward_info?user=%s&pool_id=%s&reward_denom=%s",
 			p.protocolConfig.PoolInfoUrl, address, p.venuePositionConfig.PoolId, queryDenom)
 
-		resp, err := http.Get(rewardURL)
+		resp, err := httpGet(ctx, rewardURL)
 		if err != nil {
 			debugLog("Error fetching reward data", map[string]string{"denom": queryDenom, "error": err.Error()})
 			continue
@@ -423,7 +436,7 @@ func (p ElysPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address
 		}
 
 		adjustedAmount := rewardPending / math.Pow(10, float64(tokenInfo.Decimals))
-		usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 		if err != nil {
 			debugLog("Error getting token values", map[string]string{"denom": rewardDenom})
 			continue
@@ -447,10 +460,10 @@ func (p ElysPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address
 	}, nil
 }
 
-func (p ElysPosition) fetchStablestakePoolData() (map[string]interface{}, error) {
+func (p ElysPosition) fetchStablestakePoolData(ctx context.Context) (map[string]interface{}, error) {
 	poolURL := fmt.Sprintf("%s/stablestake/pool/%s", p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolId)
 
-	resp, err := http.Get(poolURL)
+	resp, err := httpGet(ctx, poolURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetching stablestake pool info: %v", err)
 	}
@@ -468,12 +481,12 @@ func (p ElysPosition) fetchStablestakePoolData() (map[string]interface{}, error)
 	return poolData, nil
 }
 
-func (p ElysPosition) fetchAMMPoolData() (map[string]interface{}, error) {
+func (p ElysPosition) fetchAMMPoolData(ctx context.Context) (map[string]interface{}, error) {
 	// Construct the URL for querying the AMM pool
 	poolURL := fmt.Sprintf("%s/amm/pool/%s/%s", p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolId, "1")
 
 	// Make the HTTP GET request
-	resp, err := http.Get(poolURL)
+	resp, err := httpGet(ctx, poolURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetching AMM pool info: %v", err)
 	}
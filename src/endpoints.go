@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DeadUpstreamAfter is how long an upstream host has to fail every request to be considered dead.
+	DeadUpstreamAfter = 30 * time.Minute
+	// EndpointCheckInterval is the interval of looking for dead hosts and their replacements.
+	EndpointCheckInterval = 5 * time.Minute
+	// DeadUpstreamProbeInterval is how often a dead host that is failed over is retried,
+	// so that the failover ends once it recovers.
+	DeadUpstreamProbeInterval = 5 * time.Minute
+	// MaxEndpointSuggestions bounds the number of replacements suggested per dead host.
+	MaxEndpointSuggestions = 3
+)
+
+// nonRESTHosts serve APIs other than the chain REST API, so the chain registry has no replacements for them.
+var nonRESTHosts = map[string]bool{
+	"sqs.osmosis.zone": true,
+}
+
+// extraRESTHosts are chain REST hosts that are not part of a protocol config.
+var extraRESTHosts = map[string]string{
+	"osmosis-lcd.numia.xyz": "osmosis", // Magma
+}
+
+// upstreamFailoverEnabled reroutes the requests to dead hosts to their first suggested replacement.
+var upstreamFailoverEnabled bool
+
+// DeadUpstream is an upstream host that failed every request for a while, with replacements
+// from the chain registry that answered for the right chain.
+type DeadUpstream struct {
+	Host         string    `json:"host"`
+	Chain        string    `json:"chain"`
+	FailingSince time.Time `json:"failing_since"`
+	Suggestions  []string  `json:"suggestions"`
+	// FailoverTo is set if requests to the host are rerouted.
+	FailoverTo string `json:"failover_to,omitempty"`
+	// BasePath is the path the REST API of the host is served under, which the path of the
+	// replacement takes the place of.
+	BasePath string `json:"base_path,omitempty"`
+}
+
+var (
+	deadUpstreamsMu sync.Mutex
+	deadUpstreams   = make(map[string]*DeadUpstream)
+	// lastDeadProbe is when a request was last let through to a failed-over host.
+	lastDeadProbe = make(map[string]time.Time)
+)
+
+// restAPIRoots are the path segments the modules of a chain REST API start with.
+var restAPIRoots = []string{"/cosmos/", "/cosmwasm/", "/ibc/"}
+
+// restBasePath returns the path a REST API is served under, e.g. /osmosis for
+// https://rest.cosmos.directory/osmosis/cosmos/bank/v1beta1/balances. Paths without a known module
+// are taken to be served at the root.
+func restBasePath(path string) string {
+	for _, root := range restAPIRoots {
+		if i := strings.Index(path, root); i >= 0 {
+			return path[:i]
+		}
+	}
+	return ""
+}
+
+// restHostBasePaths maps the hosts of the chain REST APIs used by the protocols to the path their
+// API is served under.
+func restHostBasePaths() map[string]string {
+	basePaths := make(map[string]string)
+	for _, config := range protocolConfigMap {
+		for _, rawURL := range []string{config.PoolInfoUrl, config.AddressBalanceUrl} {
+			if u, err := url.Parse(rawURL); err == nil && u.Host != "" {
+				if basePath := restBasePath(u.Path); basePath != "" {
+					basePaths[u.Host] = basePath
+				}
+			}
+		}
+	}
+	return basePaths
+}
+
+// restHostChains maps the hosts of the chain REST APIs used by the protocols to their chain registry names.
+func restHostChains() map[string]string {
+	chains := make(map[string]string)
+	for host, chain := range extraRESTHosts {
+		chains[host] = chain
+	}
+
+	for _, config := range protocolConfigMap {
+		chain, ok := strings.CutPrefix(config.AssetListURL, "https://chains.cosmos.directory/")
+		if !ok {
+			continue
+		}
+		for _, rawURL := range []string{config.PoolInfoUrl, config.AddressBalanceUrl} {
+			u, err := url.Parse(rawURL)
+			if err != nil || u.Host == "" || nonRESTHosts[u.Host] {
+				continue
+			}
+			chains[u.Host] = chain
+		}
+	}
+
+	return chains
+}
+
+// isDeadUpstream reports whether a host has failed every request for DeadUpstreamAfter.
+func isDeadUpstream(health UpstreamHealth, now time.Time) bool {
+	return !health.Healthy && !health.FailingSince.IsZero() && now.Sub(health.FailingSince) > DeadUpstreamAfter
+}
+
+// checkUpstreamEndpoints updates the dead hosts and looks up replacements for newly dead ones.
+func checkUpstreamEndpoints(ctx context.Context) {
+	now := time.Now()
+	chains := restHostChains()
+
+	unhealthy := make(map[string]bool)
+	dead := make(map[string]UpstreamHealth)
+	for _, health := range getUpstreamHealth() {
+		if !health.Healthy {
+			unhealthy[health.Host] = true
+		}
+		if _, ok := chains[health.Host]; ok && isDeadUpstream(health, now) {
+			dead[health.Host] = health
+		}
+	}
+
+	deadUpstreamsMu.Lock()
+	for host := range deadUpstreams {
+		if _, ok := dead[host]; !ok {
+			log.Printf("Upstream %s recovered", host)
+			delete(deadUpstreams, host)
+		}
+	}
+	deadUpstreamsMu.Unlock()
+
+	for host, health := range dead {
+		deadUpstreamsMu.Lock()
+		known := deadUpstreams[host]
+		deadUpstreamsMu.Unlock()
+		// suggestions are looked up again while there are none, or the failover target failed as well
+		if known != nil && len(known.Suggestions) > 0 && !unhealthy[hostOf(known.Suggestions[0])] {
+			continue
+		}
+
+		suggestions, err := suggestRESTEndpoints(ctx, chains[host], host, unhealthy)
+		if err != nil {
+			log.Printf("Looking up replacements for %s failed: %v", host, err)
+		}
+		if suggestions == nil {
+			suggestions = []string{}
+		}
+
+		entry := &DeadUpstream{
+			Host:         host,
+			Chain:        chains[host],
+			FailingSince: health.FailingSince,
+			Suggestions:  suggestions,
+			BasePath:     restHostBasePaths()[host],
+		}
+		if upstreamFailoverEnabled && len(suggestions) > 0 {
+			entry.FailoverTo = suggestions[0]
+		}
+
+		deadUpstreamsMu.Lock()
+		deadUpstreams[host] = entry
+		deadUpstreamsMu.Unlock()
+
+		message := fmt.Sprintf("every request failed since %s (%s), suggested replacements: %s",
+			health.FailingSince.UTC().Format(time.RFC3339), health.LastError, strings.Join(suggestions, ", "))
+		if entry.FailoverTo != "" {
+			message += ", failing over to " + entry.FailoverTo
+		}
+		fireAlert(AlertDeadUpstream, host, message)
+	}
+}
+
+// chainRegistryEntry is the part of a cosmos.directory chain entry with the REST endpoints.
+type chainRegistryEntry struct {
+	Chain struct {
+		ChainID  string `json:"chain_id"`
+		BestAPIs struct {
+			REST []struct {
+				Address  string `json:"address"`
+				Provider string `json:"provider"`
+			} `json:"rest"`
+		} `json:"best_apis"`
+	} `json:"chain"`
+}
+
+// suggestRESTEndpoints returns REST endpoints of a chain from the chain registry that answer for the
+// chain, excluding the dead host and other unhealthy hosts.
+func suggestRESTEndpoints(ctx context.Context, chain string, deadHost string, unhealthy map[string]bool) ([]string, error) {
+	resp, err := httpGet(ctx, "https://chains.cosmos.directory/"+chain)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chain registry returned status %d", resp.StatusCode)
+	}
+	var entry chainRegistryEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("decoding chain registry entry: %v", err)
+	}
+
+	var suggestions []string
+	for _, api := range entry.Chain.BestAPIs.REST {
+		address := strings.TrimSuffix(api.Address, "/")
+		host := hostOf(address)
+		if host == "" || host == deadHost || unhealthy[host] {
+			continue
+		}
+		if err := checkRESTEndpoint(ctx, address, entry.Chain.ChainID); err != nil {
+			debugLog("Skipping suggested endpoint", map[string]string{"address": address, "error": err.Error()})
+			continue
+		}
+
+		suggestions = append(suggestions, address)
+		if len(suggestions) == MaxEndpointSuggestions {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
+
+// checkRESTEndpoint checks that a REST endpoint answers and serves the expected chain.
+func checkRESTEndpoint(ctx context.Context, address string, chainID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	resp, err := httpGet(ctx, address+"/cosmos/base/tendermint/v1beta1/node_info")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var nodeInfo struct {
+		DefaultNodeInfo struct {
+			Network string `json:"network"`
+		} `json:"default_node_info"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&nodeInfo); err != nil {
+		return fmt.Errorf("decoding node info: %v", err)
+	}
+	if chainID != "" && nodeInfo.DefaultNodeInfo.Network != chainID {
+		return fmt.Errorf("serves %s instead of %s", nodeInfo.DefaultNodeInfo.Network, chainID)
+	}
+	return nil
+}
+
+func hostOf(address string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// getDeadUpstreams returns the dead hosts, sorted by host.
+func getDeadUpstreams() []DeadUpstream {
+	deadUpstreamsMu.Lock()
+	defer deadUpstreamsMu.Unlock()
+
+	result := make([]DeadUpstream, 0, len(deadUpstreams))
+	for _, dead := range deadUpstreams {
+		result = append(result, *dead)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Host < result[j].Host })
+
+	return result
+}
+
+// failoverURL returns the URL a request to a dead host is rerouted to, if failover is enabled. The
+// base path of the dead host's API is replaced with the path of the replacement.
+// Every DeadUpstreamProbeInterval, a request still goes to the dead host to find out whether it recovered.
+func failoverURL(u *url.URL) (*url.URL, bool) {
+	if !upstreamFailoverEnabled {
+		return nil, false
+	}
+
+	deadUpstreamsMu.Lock()
+	defer deadUpstreamsMu.Unlock()
+
+	dead, ok := deadUpstreams[u.Host]
+	if !ok || dead.FailoverTo == "" {
+		return nil, false
+	}
+	if now := time.Now(); now.Sub(lastDeadProbe[u.Host]) > DeadUpstreamProbeInterval {
+		lastDeadProbe[u.Host] = now
+		return nil, false
+	}
+
+	target, err := url.Parse(dead.FailoverTo)
+	if err != nil {
+		return nil, false
+	}
+	rerouted := *u
+	rerouted.Scheme = target.Scheme
+	rerouted.Host = target.Host
+	rerouted.User = target.User
+	rerouted.Path = strings.TrimSuffix(target.Path, "/") + strings.TrimPrefix(u.Path, dead.BasePath)
+	rerouted.RawPath = ""
+	return &rerouted, true
+}
+
+// startEndpointMonitor periodically looks for dead upstream hosts and suggests replacements.
+func startEndpointMonitor() {
+	go func() {
+		ticker := time.NewTicker(EndpointCheckInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), EndpointCheckInterval)
+			checkUpstreamEndpoints(ctx)
+			cancel()
+		}
+	}()
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Selectors of the EVM contract functions that are called, the first 4 bytes of the keccak256 of
+// their signatures.
+const (
+	selectorDecimals  = "313ce567" // decimals()
+	selectorSymbol    = "95d89b41" // symbol()
+	selectorBalanceOf = "70a08231" // balanceOf(address)
+)
+
+// EVMRevertError is a call that the contract reverted, e.g. for a burned NFT.
+type EVMRevertError struct {
+	Message string
+}
+
+func (e *EVMRevertError) Error() string {
+	return "execution reverted: " + e.Message
+}
+
+// evmCall calls a view function of a contract with eth_call at the latest block and returns the
+// ABI-encoded result. data is the hex selector and arguments, without 0x.
+func evmCall(ctx context.Context, rpcUrl string, to string, data string) ([]byte, error) {
+	request, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "eth_call",
+		"params":  []interface{}{map[string]string{"to": to, "data": "0x" + data}, "latest"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	debugLog("Calling EVM contract", map[string]string{"url": rpcUrl, "to": to, "data": data})
+
+	req, err := http.NewRequestWithContext(ctx, "POST", rpcUrl, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling %s: %v", to, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calling %s: unexpected status code: %d", to, resp.StatusCode)
+	}
+
+	var response struct {
+		Result string `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decoding JSON-RPC response: %v", err)
+	}
+	if response.Error != nil {
+		// nodes report reverts with code 3 (geth) or -32000 and a message starting with "execution reverted"
+		if response.Error.Code == 3 || strings.HasPrefix(response.Error.Message, "execution reverted") {
+			return nil, &EVMRevertError{Message: strings.TrimPrefix(strings.TrimPrefix(response.Error.Message, "execution reverted"), ": ")}
+		}
+		return nil, fmt.Errorf("calling %s: %s", to, response.Error.Message)
+	}
+
+	result, err := hex.DecodeString(strings.TrimPrefix(response.Result, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid result of %s: %v", to, err)
+	}
+	return result, nil
+}
+
+var (
+	evmImmutableMu    sync.Mutex
+	evmImmutableCache = make(map[string][]byte)
+)
+
+// evmCallImmutable is evmCall for results that never change, like the decimals of a token or the
+// address of a pool, which are kept in memory.
+func evmCallImmutable(ctx context.Context, rpcUrl string, to string, data string) ([]byte, error) {
+	key := rpcUrl + "|" + strings.ToLower(to) + "|" + data
+
+	evmImmutableMu.Lock()
+	result, ok := evmImmutableCache[key]
+	evmImmutableMu.Unlock()
+	if ok {
+		return result, nil
+	}
+
+	result, err := evmCall(ctx, rpcUrl, to, data)
+	if err != nil {
+		return nil, err
+	}
+
+	evmImmutableMu.Lock()
+	evmImmutableCache[key] = result
+	evmImmutableMu.Unlock()
+	return result, nil
+}
+
+// abiWord returns the i-th 32-byte word of an ABI-encoded result.
+func abiWord(result []byte, i int) ([]byte, error) {
+	if len(result) < (i+1)*32 {
+		return nil, fmt.Errorf("result of %d bytes has no word %d", len(result), i)
+	}
+	return result[i*32 : (i+1)*32], nil
+}
+
+// abiUint decodes the i-th word of a result as an unsigned integer.
+func abiUint(result []byte, i int) (*big.Int, error) {
+	word, err := abiWord(result, i)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(word), nil
+}
+
+// abiInt decodes the i-th word of a result as a signed integer, e.g. an int24 tick.
+func abiInt(result []byte, i int) (*big.Int, error) {
+	value, err := abiUint(result, i)
+	if err != nil {
+		return nil, err
+	}
+	if value.Bit(255) == 1 {
+		value.Sub(value, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return value, nil
+}
+
+// abiAddress decodes the i-th word of a result as an address, in lowercase hex with 0x.
+func abiAddress(result []byte, i int) (string, error) {
+	word, err := abiWord(result, i)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(word[12:]), nil
+}
+
+// abiEncodeUint encodes an unsigned or signed integer argument.
+func abiEncodeUint(value *big.Int) string {
+	word := new(big.Int).Set(value)
+	if word.Sign() < 0 {
+		word.Add(word, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return fmt.Sprintf("%064x", word)
+}
+
+// abiEncodeAddress encodes an address argument.
+func abiEncodeAddress(address string) string {
+	return strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// isEVMAddress returns whether s is a hex address with 0x.
+func isEVMAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// abiString decodes the result of a function returning a string, or a bytes32 like the symbol of
+// some early tokens.
+func abiString(result []byte) (string, error) {
+	if len(result) == 32 {
+		return strings.TrimRight(string(result), "\x00"), nil
+	}
+	offset, err := abiUint(result, 0)
+	if err != nil {
+		return "", err
+	}
+	if !offset.IsInt64() || offset.Int64()%32 != 0 {
+		return "", fmt.Errorf("invalid string offset")
+	}
+	start := int(offset.Int64() / 32)
+	length, err := abiUint(result, start)
+	if err != nil {
+		return "", err
+	}
+	if !length.IsInt64() || (start+1)*32+int(length.Int64()) > len(result) {
+		return "", fmt.Errorf("invalid string length")
+	}
+	end := (start+1)*32 + int(length.Int64())
+	s := string(result[(start+1)*32 : end])
+	if !utf8.ValidString(s) {
+		return "", fmt.Errorf("invalid string")
+	}
+	return s, nil
+}
+
+// fetchERC20Token resolves the token info of an ERC-20 token: its decimals and symbol from the
+// chain, and its CoinGecko ID from coingeckoIDs by address, or else from the Skip asset list of the
+// chain.
+func fetchERC20Token(ctx context.Context, rpcUrl string, chainID string, address string, coingeckoIDs map[string]string) (ChainTokenInfo, error) {
+	token := ChainTokenInfo{Denom: strings.ToLower(address)}
+
+	result, err := evmCallImmutable(ctx, rpcUrl, address, selectorDecimals)
+	if err != nil {
+		return token, fmt.Errorf("querying decimals of %s: %v", address, err)
+	}
+	decimals, err := abiUint(result, 0)
+	if err != nil || decimals.Cmp(big.NewInt(77)) > 0 {
+		return token, fmt.Errorf("invalid decimals of %s", address)
+	}
+	token.Decimals = int(decimals.Int64())
+
+	result, err = evmCallImmutable(ctx, rpcUrl, address, selectorSymbol)
+	if err != nil {
+		return token, fmt.Errorf("querying symbol of %s: %v", address, err)
+	}
+	if token.Display, err = abiString(result); err != nil {
+		return token, fmt.Errorf("invalid symbol of %s: %v", address, err)
+	}
+
+	for tokenAddress, coingeckoID := range coingeckoIDs {
+		if strings.EqualFold(tokenAddress, address) {
+			token.CoingeckoID = coingeckoID
+			return token, nil
+		}
+	}
+
+	if err := fetchSkipAssets(ctx); err != nil {
+		debugLog("Failed to fetch skip assets", map[string]string{"error": err.Error()})
+	}
+	// the Skip asset list has ERC-20 tokens by checksummed address
+	for denom, asset := range getSkipAssets()[chainID] {
+		if strings.EqualFold(denom, address) {
+			token.CoingeckoID = asset.CoingeckoID
+			break
+		}
+	}
+	if token.CoingeckoID == "" {
+		return token, fmt.Errorf("no CoinGecko ID of %s (%s), set one in CoingeckoIDs", token.Display, address)
+	}
+	return token, nil
+}
+
+// fetchERC20Balance returns the balance of an address in an ERC-20 token, in its base unit.
+func fetchERC20Balance(ctx context.Context, rpcUrl string, token string, address string) (*big.Int, error) {
+	result, err := evmCall(ctx, rpcUrl, token, selectorBalanceOf+abiEncodeAddress(address))
+	if err != nil {
+		return nil, fmt.Errorf("querying balance in %s: %v", token, err)
+	}
+	return abiUint(result, 0)
+}
@@ -0,0 +1,599 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ExperimentalDeploymentQueryInterface defines the methods required for experimental deployments
+type ExperimentalDeploymentQueryInterface interface {
+	GetCurrentAddressHoldings(ctx context.Context, assetData *ChainInfo) (*Holdings, error)
+}
+
+type ExperimentalDeployment struct {
+	ExperimentalId         int       `json:"experimental_id"`
+	Name                   string    `json:"name"`
+	Description            string    `json:"description"`
+	Logo                   string    `json:"logo"`
+	StartTimestamp         int64     `json:"start_timestamp"`
+	EndTimestamp           int64     `json:"end_timestamp"`
+	InitialAddressHoldings *Holdings `json:"initial_address_holdings"`
+	CurrentAddressHoldings *Holdings `json:"current_address_holdings"`
+	Querier                ExperimentalDeploymentQueryInterface
+	// InitialValuedAt is when the initial holdings were valued at the prices of the start of the
+	// deployment, as unix seconds, or 0 if they weren't and are valued on every request.
+	InitialValuedAt int64 `json:"initial_valued_at,omitempty"`
+	// Deposits and Withdrawals are the flows into and out of the deployment after its initial
+	// holdings, by date.
+	Deposits    []ExperimentalFlow `json:"deposits,omitempty"`
+	Withdrawals []ExperimentalFlow `json:"withdrawals,omitempty"`
+}
+
+// ExperimentalDeploymentResponse represents the response structure for experimental deployments
+type ExperimentalDeploymentResponse struct {
+	ExperimentalId         int                `json:"experimental_id"`
+	Name                   string             `json:"name"`
+	Description            string             `json:"description"`
+	Logo                   string             `json:"logo"`
+	StartTimestamp         int64              `json:"start_timestamp"`
+	EndTimestamp           int64              `json:"end_timestamp"`
+	InitialAddressHoldings *Holdings          `json:"initial_address_holdings"`
+	CurrentAddressHoldings *Holdings          `json:"current_address_holdings"`
+	Deposits               []ExperimentalFlow `json:"deposits,omitempty"`
+	Withdrawals            []ExperimentalFlow `json:"withdrawals,omitempty"`
+	// Return is unset if the holdings or the flows can't be valued.
+	Return *ExperimentalReturn `json:"return,omitempty"`
+	// VaultFees are only set for deployments in vaults whose manager charges fees.
+	VaultFees *VaultFees `json:"vault_fees,omitempty"`
+}
+
+// experimentalMap holds the configurations for experimental deployments that are served unless
+// they are loaded from a config store, like bidMap.
+var experimentalMap = map[int]*ExperimentalDeployment{
+	1: {
+		ExperimentalId: 1,
+		Name:           "Magma: ATOM<>stATOM vault managed by RoboMcGobo",
+		Description:    "This is a first experimental deployment to test the Magma vaults integration. The Hydro committee has allocated 10,000 ATOM to this test deployment, which are managed by committee member RoboMcGobo in a [0 fee vault](https://app.magma.eco/vault/osmo1ssm5lqgrxcp9lqvr33zcafyd6unme0q4kq2fpqzgwznnjwujts6sfmfass).",
+		Logo:           "https://pbs.twimg.com/profile_images/1830561644285714433/ImSkbXR0_400x400.jpg",
+		StartTimestamp: 1742325420,
+		EndTimestamp:   0,
+		Querier: NewMagmaQuerier(MagmaDeploymentConfig{
+			VaultAddress:  "osmo1ssm5lqgrxcp9lqvr33zcafyd6unme0q4kq2fpqzgwznnjwujts6sfmfass",
+			HolderAddress: "osmo1cuwe7dzgpemwxqzpkhyjwfeev2hcgd9de8xp566hrly6wtpcrc7qgp9jdx",
+			Token0Denom:   "ibc/C140AFD542AE77BD7DCC83F13FDD8C5E5BB8C4929785E6EC2F4C636F98F17901",
+			Token1Denom:   "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2",
+		}),
+		InitialAddressHoldings: &Holdings{
+			Balances: []Asset{
+				{
+					Denom:       "ibc/C140AFD542AE77BD7DCC83F13FDD8C5E5BB8C4929785E6EC2F4C636F98F17901",
+					Amount:      1968.1,
+					DisplayName: "stATOM",
+				},
+				{
+					Denom:       "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2",
+					Amount:      6976.354,
+					DisplayName: "ATOM",
+				},
+			},
+			TotalUSDC: 0, // Will be computed at runtime
+			TotalAtom: 0, // Will be computed at runtime
+		},
+	},
+}
+
+// currentExperimental holds the experimental deployments being served once they were loaded from a
+// config store, replaced as a whole like currentBids.
+var currentExperimental atomic.Pointer[map[int]*ExperimentalDeployment]
+
+// activeExperimental returns the experimental deployments being served: the ones in the code, unless
+// they were loaded from a config store. The map must not be modified.
+func activeExperimental() map[int]*ExperimentalDeployment {
+	if deployments := currentExperimental.Load(); deployments != nil {
+		return *deployments
+	}
+	return experimentalMap
+}
+
+func setActiveExperimental(deployments map[int]*ExperimentalDeployment) {
+	currentExperimental.Store(&deployments)
+	// the response of /experimental is assembled from all deployments
+	resultCache.Delete(experimentalCacheKey)
+}
+
+// storedExperimental is the serialized form of an experimental deployment. Its querier is stored
+// with its kind, like a venue config.
+type storedExperimental struct {
+	ExperimentalId         int                `json:"experimental_id"`
+	Name                   string             `json:"name"`
+	Description            string             `json:"description"`
+	Logo                   string             `json:"logo"`
+	StartTimestamp         int64              `json:"start_timestamp"`
+	EndTimestamp           int64              `json:"end_timestamp"`
+	InitialAddressHoldings []storedAsset      `json:"initial_address_holdings"`
+	InitialValuedAt        int64              `json:"initial_valued_at,omitempty"`
+	Deposits               []ExperimentalFlow `json:"deposits,omitempty"`
+	Withdrawals            []ExperimentalFlow `json:"withdrawals,omitempty"`
+	Querier                storedVenue        `json:"querier"`
+}
+
+// storedAsset is an initial holding of an experimental deployment. Its values are the ones at the
+// prices of the start of the deployment, stored once the deployment was valued when registered.
+type storedAsset struct {
+	Denom       string  `json:"denom"`
+	Amount      float64 `json:"amount"`
+	DisplayName string  `json:"display_name,omitempty"`
+	USDValue    float64 `json:"usd_value,omitempty"`
+	AtomValue   float64 `json:"atom_value,omitempty"`
+}
+
+// experimentalQuerierKinds decodes the querier configs of every kind.
+var experimentalQuerierKinds = map[string]func(json.RawMessage) (ExperimentalDeploymentQueryInterface, error){
+	"magma": func(raw json.RawMessage) (ExperimentalDeploymentQueryInterface, error) {
+		var config MagmaDeploymentConfig
+		if err := decodeStrictJSON(raw, &config); err != nil {
+			return nil, err
+		}
+		if config.HolderAddress == "" || config.VaultAddress == "" || config.Token0Denom == "" || config.Token1Denom == "" {
+			return nil, fmt.Errorf("HolderAddress, VaultAddress, Token0Denom and Token1Denom are required")
+		}
+		return NewMagmaQuerier(config), nil
+	},
+}
+
+// experimentalQuerierConfig returns the kind and config a querier is stored as.
+func experimentalQuerierConfig(querier ExperimentalDeploymentQueryInterface) (string, interface{}, error) {
+	switch querier := querier.(type) {
+	case *MagmaQuerier:
+		return "magma", querier.config, nil
+	}
+	return "", nil, fmt.Errorf("unsupported querier type: %T", querier)
+}
+
+// decodeStrictJSON decodes JSON, rejecting unknown fields to catch typos.
+func decodeStrictJSON(raw json.RawMessage, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func encodeExperimental(deployment *ExperimentalDeployment) (storedExperimental, error) {
+	stored := storedExperimental{
+		ExperimentalId:         deployment.ExperimentalId,
+		Name:                   deployment.Name,
+		Description:            deployment.Description,
+		Logo:                   deployment.Logo,
+		StartTimestamp:         deployment.StartTimestamp,
+		EndTimestamp:           deployment.EndTimestamp,
+		InitialAddressHoldings: []storedAsset{},
+		InitialValuedAt:        deployment.InitialValuedAt,
+		Deposits:               deployment.Deposits,
+		Withdrawals:            deployment.Withdrawals,
+	}
+	if deployment.InitialAddressHoldings != nil {
+		for _, asset := range deployment.InitialAddressHoldings.Balances {
+			storedAsset := storedAsset{Denom: asset.Denom, Amount: asset.Amount, DisplayName: asset.DisplayName}
+			if deployment.InitialValuedAt != 0 {
+				storedAsset.USDValue, storedAsset.AtomValue = asset.USDValue, asset.AtomValue
+			}
+			stored.InitialAddressHoldings = append(stored.InitialAddressHoldings, storedAsset)
+		}
+	}
+
+	kind, config, err := experimentalQuerierConfig(deployment.Querier)
+	if err != nil {
+		return stored, fmt.Errorf("experimental deployment %d: %v", deployment.ExperimentalId, err)
+	}
+	raw, err := json.Marshal(config)
+	if err != nil {
+		return stored, fmt.Errorf("experimental deployment %d: encoding querier: %v", deployment.ExperimentalId, err)
+	}
+	stored.Querier = storedVenue{Kind: kind, Config: raw}
+	return stored, nil
+}
+
+func decodeExperimental(stored storedExperimental) (*ExperimentalDeployment, error) {
+	if stored.ExperimentalId <= 0 {
+		return nil, fmt.Errorf("experimental deployment ID must be positive, got %d", stored.ExperimentalId)
+	}
+	if stored.Name == "" {
+		return nil, fmt.Errorf("experimental deployment %d: name is required", stored.ExperimentalId)
+	}
+	if len(stored.InitialAddressHoldings) == 0 {
+		return nil, fmt.Errorf("experimental deployment %d: initial_address_holdings are required", stored.ExperimentalId)
+	}
+	initialHoldings := &Holdings{Balances: make([]Asset, 0, len(stored.InitialAddressHoldings))}
+	for _, asset := range stored.InitialAddressHoldings {
+		if asset.Denom == "" || asset.Amount <= 0 {
+			return nil, fmt.Errorf("experimental deployment %d: initial holdings need a denom and a positive amount", stored.ExperimentalId)
+		}
+		if asset.USDValue < 0 || asset.AtomValue < 0 {
+			return nil, fmt.Errorf("experimental deployment %d: initial holdings can't have negative values", stored.ExperimentalId)
+		}
+		initialAsset := Asset{Denom: asset.Denom, Amount: asset.Amount, DisplayName: asset.DisplayName}
+		if stored.InitialValuedAt != 0 {
+			initialAsset.USDValue, initialAsset.AtomValue = asset.USDValue, asset.AtomValue
+			initialHoldings.TotalUSDC += asset.USDValue
+			initialHoldings.TotalAtom += asset.AtomValue
+		}
+		initialHoldings.Balances = append(initialHoldings.Balances, initialAsset)
+	}
+	if stored.EndTimestamp != 0 && stored.EndTimestamp < stored.StartTimestamp {
+		return nil, fmt.Errorf("experimental deployment %d: ends before it starts", stored.ExperimentalId)
+	}
+	if err := validateExperimentalFlows(stored.ExperimentalId, "deposits", stored.Deposits, stored.StartTimestamp); err != nil {
+		return nil, err
+	}
+	if err := validateExperimentalFlows(stored.ExperimentalId, "withdrawals", stored.Withdrawals, stored.StartTimestamp); err != nil {
+		return nil, err
+	}
+
+	decode, ok := experimentalQuerierKinds[stored.Querier.Kind]
+	if !ok {
+		return nil, fmt.Errorf("experimental deployment %d: unknown querier kind %q", stored.ExperimentalId, stored.Querier.Kind)
+	}
+	querier, err := decode(stored.Querier.Config)
+	if err != nil {
+		return nil, fmt.Errorf("experimental deployment %d: decoding querier: %v", stored.ExperimentalId, err)
+	}
+
+	return &ExperimentalDeployment{
+		ExperimentalId:         stored.ExperimentalId,
+		Name:                   stored.Name,
+		Description:            stored.Description,
+		Logo:                   stored.Logo,
+		StartTimestamp:         stored.StartTimestamp,
+		EndTimestamp:           stored.EndTimestamp,
+		InitialAddressHoldings: initialHoldings,
+		Querier:                querier,
+		InitialValuedAt:        stored.InitialValuedAt,
+		Deposits:               stored.Deposits,
+		Withdrawals:            stored.Withdrawals,
+	}, nil
+}
+
+// valueExperimental values the initial holdings of a deployment at the prices of its start, so
+// that they are stored with it rather than looked up on every request. If the prices can't be
+// looked up, the deployment is left unvalued.
+func valueExperimental(ctx context.Context, deployment *ExperimentalDeployment) {
+	deployment.InitialValuedAt = 0
+
+	assetData, err := fetchAssetList(ctx, ExperimentalAssetListURL)
+	if err == nil {
+		var valued *Holdings
+		if valued, err = ComputeInitialHoldingsWithPrices(ctx, deployment.InitialAddressHoldings, assetData, deployment.StartTimestamp); err == nil {
+			deployment.InitialAddressHoldings = mergeInitialValues(deployment.InitialAddressHoldings, valued)
+			deployment.InitialValuedAt = time.Now().Unix()
+			return
+		}
+	}
+	log.Printf("Failed to value the initial holdings of experimental deployment %d, they will be valued on request: %v", deployment.ExperimentalId, err)
+
+	// values given with the deployment aren't trusted
+	unvalued := &Holdings{Balances: make([]Asset, 0, len(deployment.InitialAddressHoldings.Balances))}
+	for _, asset := range deployment.InitialAddressHoldings.Balances {
+		unvalued.Balances = append(unvalued.Balances, Asset{Denom: asset.Denom, Amount: asset.Amount, DisplayName: asset.DisplayName})
+	}
+	deployment.InitialAddressHoldings = unvalued
+}
+
+// mergeInitialValues returns the initial holdings with the values of the valued ones. Assets
+// without a price are kept, without values.
+func mergeInitialValues(initial *Holdings, valued *Holdings) *Holdings {
+	values := make(map[string]Asset, len(valued.Balances))
+	for _, asset := range valued.Balances {
+		values[asset.Denom] = asset
+	}
+
+	merged := &Holdings{Balances: make([]Asset, 0, len(initial.Balances)), TotalUSDC: valued.TotalUSDC, TotalAtom: valued.TotalAtom}
+	for _, asset := range initial.Balances {
+		mergedAsset := Asset{Denom: asset.Denom, Amount: asset.Amount, DisplayName: asset.DisplayName}
+		if value, ok := values[asset.Denom]; ok {
+			mergedAsset.USDValue, mergedAsset.AtomValue = value.USDValue, value.AtomValue
+		}
+		merged.Balances = append(merged.Balances, mergedAsset)
+	}
+	return merged
+}
+
+// sameInitialHoldings reports whether two deployments started with the same holdings at the same
+// time, so that the valuation of one applies to the other.
+func sameInitialHoldings(a *ExperimentalDeployment, b *ExperimentalDeployment) bool {
+	if a.StartTimestamp != b.StartTimestamp || len(a.InitialAddressHoldings.Balances) != len(b.InitialAddressHoldings.Balances) {
+		return false
+	}
+	for i, asset := range a.InitialAddressHoldings.Balances {
+		other := b.InitialAddressHoldings.Balances[i]
+		if asset.Denom != other.Denom || asset.Amount != other.Amount {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeExperimentals converts experimental deployments to their serialized form, ordered by ID.
+func encodeExperimentals(deployments map[int]*ExperimentalDeployment) ([]storedExperimental, error) {
+	ids := make([]int, 0, len(deployments))
+	for id := range deployments {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	stored := make([]storedExperimental, 0, len(ids))
+	for _, id := range ids {
+		deployment, err := encodeExperimental(deployments[id])
+		if err != nil {
+			return nil, err
+		}
+		stored = append(stored, deployment)
+	}
+	return stored, nil
+}
+
+func decodeExperimentals(stored []storedExperimental) (map[int]*ExperimentalDeployment, error) {
+	deployments := make(map[int]*ExperimentalDeployment, len(stored))
+	for _, s := range stored {
+		if _, ok := deployments[s.ExperimentalId]; ok {
+			return nil, fmt.Errorf("duplicate experimental deployment %d", s.ExperimentalId)
+		}
+		deployment, err := decodeExperimental(s)
+		if err != nil {
+			return nil, err
+		}
+		deployments[s.ExperimentalId] = deployment
+	}
+	return deployments, nil
+}
+
+// diffExperimentals lists the differences between two sets of experimental deployments, one per line.
+func diffExperimentals(a map[int]*ExperimentalDeployment, b map[int]*ExperimentalDeployment, aName string, bName string) ([]string, error) {
+	genericA, err := genericExperimentals(a)
+	if err != nil {
+		return nil, err
+	}
+	genericB, err := genericExperimentals(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []string
+	for _, diff := range diffJSON("", genericA, genericB) {
+		diffs = append(diffs, fmt.Sprintf("experimental deployment %s: %s %s, %s %s", diff.Path, aName, diff.A, bName, diff.B))
+	}
+	return diffs, nil
+}
+
+// genericExperimentals converts experimental deployments to plain JSON values keyed by ID for diffing.
+func genericExperimentals(deployments map[int]*ExperimentalDeployment) (map[string]interface{}, error) {
+	generic := make(map[string]interface{}, len(deployments))
+	for id, deployment := range deployments {
+		value, err := genericExperimental(deployment)
+		if err != nil {
+			return nil, err
+		}
+		generic[strconv.Itoa(id)] = value
+	}
+	return generic, nil
+}
+
+// genericExperimental converts an experimental deployment to plain JSON values for diffing.
+func genericExperimental(deployment *ExperimentalDeployment) (interface{}, error) {
+	stored, err := encodeExperimental(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	return generic, err
+}
+
+// loadExperimentalFromStore replaces the experimental deployments of the code with the stored ones,
+// if the store has any section for them.
+func loadExperimentalFromStore(store ConfigStore) error {
+	stored, err := store.LoadExperimental()
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		log.Printf("The config store has no experimental deployments, serving the %d of the code", len(experimentalMap))
+		return nil
+	}
+
+	setActiveExperimental(stored)
+	log.Printf("Loaded %d experimental deployments from the config store", len(stored))
+	return nil
+}
+
+var (
+	errExperimentalNotFound = errors.New("experimental deployment not found")
+	errExperimentalExists   = errors.New("experimental deployment already exists")
+)
+
+// adminExperimentalHandler serves the config of an experimental deployment in the config store format.
+func adminExperimentalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["experimental_id"])
+	if err != nil {
+		http.Error(w, "invalid experimental deployment ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	bidConfigMu.Lock()
+	deployment, ok := activeExperimental()[id]
+	bidConfigMu.Unlock()
+	if !ok {
+		http.Error(w, fmt.Sprintf("experimental deployment not found: %d", id), http.StatusNotFound)
+		return
+	}
+
+	writeAdminExperimental(w, http.StatusOK, deployment)
+}
+
+// createExperimentalHandler registers an experimental deployment, given in the config store format.
+func createExperimentalHandler(w http.ResponseWriter, r *http.Request) {
+	var stored storedExperimental
+	if err := decodeAdminRequest(r, &stored); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the deployment is valued before taking the lock, as the price lookups are slow
+	deployment, decodeErr := decodeExperimental(stored)
+	if decodeErr == nil {
+		valueExperimental(r.Context(), deployment)
+	}
+
+	changeExperimental(w, r, "create_experimental", stored.ExperimentalId, http.StatusCreated, func(current *ExperimentalDeployment) (*ExperimentalDeployment, error) {
+		if current != nil {
+			return nil, errExperimentalExists
+		}
+		return deployment, decodeErr
+	})
+}
+
+// updateExperimentalHandler replaces an experimental deployment, given in the config store format,
+// or deletes it.
+func updateExperimentalHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["experimental_id"])
+	if err != nil {
+		http.Error(w, "invalid experimental deployment ID: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		changeExperimental(w, r, "delete_experimental", id, http.StatusNoContent, func(current *ExperimentalDeployment) (*ExperimentalDeployment, error) {
+			if current == nil {
+				return nil, errExperimentalNotFound
+			}
+			return nil, nil
+		})
+		return
+	}
+
+	var stored storedExperimental
+	if err := decodeAdminRequest(r, &stored); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if stored.ExperimentalId != 0 && stored.ExperimentalId != id {
+		http.Error(w, fmt.Sprintf("the body is for experimental deployment %d, not %d", stored.ExperimentalId, id), http.StatusBadRequest)
+		return
+	}
+	stored.ExperimentalId = id
+
+	// the valuation is kept if the initial holdings didn't change
+	bidConfigMu.Lock()
+	previous := activeExperimental()[id]
+	bidConfigMu.Unlock()
+	deployment, decodeErr := decodeExperimental(stored)
+	if decodeErr == nil {
+		if previous != nil && previous.InitialValuedAt != 0 && sameInitialHoldings(previous, deployment) {
+			deployment.InitialAddressHoldings = previous.InitialAddressHoldings
+			deployment.InitialValuedAt = previous.InitialValuedAt
+		} else {
+			valueExperimental(r.Context(), deployment)
+		}
+	}
+
+	changeExperimental(w, r, "update_experimental", id, http.StatusOK, func(current *ExperimentalDeployment) (*ExperimentalDeployment, error) {
+		if current == nil {
+			return nil, errExperimentalNotFound
+		}
+		return deployment, decodeErr
+	})
+}
+
+// changeExperimental applies a change of an experimental deployment through the admin API, like
+// changeBid: it records the change in the audit log, saves all deployments to the config store and
+// serves the result. change returns the new deployment, or nil to delete it; its errors are the
+// client's.
+func changeExperimental(w http.ResponseWriter, r *http.Request, action string, id int, status int, change func(current *ExperimentalDeployment) (*ExperimentalDeployment, error)) {
+	if adminConfigStore == nil {
+		http.Error(w, "changing experimental deployments requires a JSON --config file or a database to save them to", http.StatusForbidden)
+		return
+	}
+
+	bidConfigMu.Lock()
+	defer bidConfigMu.Unlock()
+
+	previous := activeExperimental()
+	current := previous[id]
+
+	next, err := change(current)
+	if errors.Is(err, errExperimentalNotFound) {
+		http.Error(w, fmt.Sprintf("experimental deployment not found: %d", id), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errExperimentalExists) {
+		http.Error(w, fmt.Sprintf("experimental deployment %d already exists", id), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// the change must not be applied if it can't be recorded
+	if err := auditExperimentalChange(r, action, id, current, next); err != nil {
+		http.Error(w, "recording the change: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deployments := make(map[int]*ExperimentalDeployment, len(previous)+1)
+	for otherId, deployment := range previous {
+		deployments[otherId] = deployment
+	}
+	if next == nil {
+		delete(deployments, id)
+	} else {
+		deployments[id] = next
+	}
+
+	if err := adminConfigStore.SaveExperimental(deployments); err != nil {
+		http.Error(w, "saving the change: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setActiveExperimental(deployments)
+
+	if next == nil {
+		w.WriteHeader(status)
+		return
+	}
+	if status == http.StatusCreated {
+		w.Header().Set("Location", "/admin/experimental/"+strconv.Itoa(id))
+	}
+	writeAdminExperimental(w, status, next)
+}
+
+func writeAdminExperimental(w http.ResponseWriter, status int, deployment *ExperimentalDeployment) {
+	stored, err := encodeExperimental(deployment)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonData, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
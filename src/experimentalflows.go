@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ExperimentalFlow is a deposit into or a withdrawal from an experimental deployment after its
+// initial holdings, in the display unit of the token like the initial holdings.
+type ExperimentalFlow struct {
+	Date        time.Time `json:"date"`
+	Denom       string    `json:"denom"`
+	Amount      float64   `json:"amount"`
+	DisplayName string    `json:"display_name,omitempty"`
+}
+
+// ExperimentalReturn compares the current holdings of an experimental deployment to what went
+// into it, in ATOM: the initial holdings and the deposits, net of the withdrawals. The flows are
+// valued at the prices of their dates.
+type ExperimentalReturn struct {
+	InitialValueAtom float64 `json:"initial_value_atom"`
+	DepositedAtom    float64 `json:"deposited_atom"`
+	WithdrawnAtom    float64 `json:"withdrawn_atom"`
+	CurrentValueAtom float64 `json:"current_value_atom"`
+	ReturnAtom       float64 `json:"return_atom"`
+	// ReturnPercent is relative to the initial holdings and the deposits.
+	ReturnPercent float64 `json:"return_percent"`
+}
+
+// validateExperimentalFlows checks the deposits or withdrawals of an experimental deployment and
+// sorts them by date.
+func validateExperimentalFlows(id int, kind string, flows []ExperimentalFlow, startTimestamp int64) error {
+	for _, flow := range flows {
+		if flow.Denom == "" || flow.Amount <= 0 {
+			return fmt.Errorf("experimental deployment %d: %s need a denom and a positive amount", id, kind)
+		}
+		if flow.Date.Unix() < startTimestamp {
+			return fmt.Errorf("experimental deployment %d: %s of %s predate the start of the deployment", id, kind, flow.Date.Format(time.RFC3339))
+		}
+	}
+	sort.SliceStable(flows, func(i, j int) bool { return flows[i].Date.Before(flows[j].Date) })
+	return nil
+}
+
+// valueExperimentalFlows returns the ATOM value of flows at the prices of their dates. Unlike the
+// initial holdings, flows without a price fail the valuation, as the return would be off.
+func valueExperimentalFlows(ctx context.Context, assetData *ChainInfo, flows []ExperimentalFlow) (float64, error) {
+	total := 0.0
+	for _, flow := range flows {
+		timestamp := flow.Date.Unix()
+		atomPrice, err := getHistoricalPrice(ctx, OsmosisAtomDenom, "cosmos", timestamp)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get historical ATOM price: %v", err)
+		}
+		if atomPrice <= 0 {
+			return 0, fmt.Errorf("no historical ATOM price at %s", flow.Date.Format(time.RFC3339))
+		}
+
+		coingeckoID := ""
+		if tokenInfo, ok := assetData.Tokens[flow.Denom]; ok {
+			coingeckoID = tokenInfo.CoingeckoID
+		}
+		price, err := getHistoricalPrice(ctx, flow.Denom, coingeckoID, timestamp)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get historical price of %s: %v", flow.Denom, err)
+		}
+
+		total += flow.Amount * price / atomPrice
+	}
+	return total, nil
+}
+
+// computeExperimentalReturn returns the return of an experimental deployment, accounting for its
+// deposits and withdrawals. It returns nil if the holdings or the flows can't be valued.
+func computeExperimentalReturn(ctx context.Context, assetData *ChainInfo, deployment *ExperimentalDeployment, initial *Holdings, current *Holdings) *ExperimentalReturn {
+	if initial == nil || current == nil || initial.TotalAtom <= 0 {
+		return nil
+	}
+
+	deposited, err := valueExperimentalFlows(ctx, assetData, deployment.Deposits)
+	if err != nil {
+		debugLog(fmt.Sprintf("Error valuing the deposits of experimental deployment %d: %v", deployment.ExperimentalId, err), nil)
+		return nil
+	}
+	withdrawn, err := valueExperimentalFlows(ctx, assetData, deployment.Withdrawals)
+	if err != nil {
+		debugLog(fmt.Sprintf("Error valuing the withdrawals of experimental deployment %d: %v", deployment.ExperimentalId, err), nil)
+		return nil
+	}
+
+	ret := &ExperimentalReturn{
+		InitialValueAtom: initial.TotalAtom,
+		DepositedAtom:    deposited,
+		WithdrawnAtom:    withdrawn,
+		CurrentValueAtom: current.TotalAtom,
+	}
+	ret.ReturnAtom = ret.CurrentValueAtom + ret.WithdrawnAtom - ret.InitialValueAtom - ret.DepositedAtom
+	ret.ReturnPercent = ret.ReturnAtom / (ret.InitialValueAtom + ret.DepositedAtom) * 100
+	return ret
+}
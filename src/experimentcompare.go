@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ComparisonLeg is the return of one side of a comparison over its window, in ATOM. Flows are the
+// funds that entered (deposited) or left (withdrawn) it within the window.
+type ComparisonLeg struct {
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	StartValueAtom float64   `json:"start_value_atom"`
+	EndValueAtom   float64   `json:"end_value_atom"`
+	DepositedAtom  float64   `json:"deposited_atom"`
+	WithdrawnAtom  float64   `json:"withdrawn_atom"`
+	ReturnAtom     float64   `json:"return_atom"`
+	// ReturnPercent is relative to the start value and the deposits.
+	ReturnPercent     float64  `json:"return_percent"`
+	AnnualizedPercent *float64 `json:"annualized_percent"` // nil for windows shorter than a day
+}
+
+// ExperimentalComparison lines up an experimental deployment against a bid over the window of
+// the deployment, e.g. a managed vault against a manual position in the same pair.
+type ExperimentalComparison struct {
+	ExperimentalId int            `json:"experimental_id"`
+	BidId          int            `json:"bid_id"`
+	Experiment     *ComparisonLeg `json:"experiment"`
+	Bid            *ComparisonLeg `json:"bid"`
+	// DifferencePercent is the annualized return of the experiment minus the one of the bid, nil
+	// if either is unknown.
+	DifferencePercent *float64 `json:"difference_percent"`
+	// Notes explain why a side is missing or why the windows differ.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// newComparisonLeg computes the return of a leg from its values and flows.
+func newComparisonLeg(from time.Time, to time.Time, startValue float64, endValue float64, deposited float64, withdrawn float64) *ComparisonLeg {
+	leg := &ComparisonLeg{
+		From:           from,
+		To:             to,
+		StartValueAtom: startValue,
+		EndValueAtom:   endValue,
+		DepositedAtom:  deposited,
+		WithdrawnAtom:  withdrawn,
+		ReturnAtom:     endValue + withdrawn - startValue - deposited,
+	}
+	if invested := startValue + deposited; invested > 0 {
+		leg.ReturnPercent = leg.ReturnAtom / invested * 100
+		if days := to.Sub(from).Hours() / 24; days >= 1 {
+			annualized := leg.ReturnPercent * 365 / days
+			leg.AnnualizedPercent = &annualized
+		}
+	}
+	return leg
+}
+
+// experimentalWindow returns the window of an experimental deployment: from its start to its end,
+// or to now while it runs.
+func experimentalWindow(deployment *ExperimentalDeployment, now time.Time) (time.Time, time.Time) {
+	to := now
+	if deployment.EndTimestamp != 0 && deployment.EndTimestamp < now.Unix() {
+		to = time.Unix(deployment.EndTimestamp, 0)
+	}
+	return time.Unix(deployment.StartTimestamp, 0), to
+}
+
+// bidComparisonLeg computes the return of a bid between its first and last snapshots within the
+// window, with the bid's allocations, compounded withdrawals of other bids and transfers in as
+// deposits, and its withdrawals and transfers out as withdrawals. It returns nil if the bid has
+// no snapshot in the window.
+func bidComparisonLeg(bidId int, bidConfig BidPositionConfig, from time.Time, to time.Time) (*ComparisonLeg, error) {
+	var first, last *BidSnapshot
+	err := snapshotStore.Range(from, to, func(snapshot BidSnapshot) error {
+		if snapshot.BidId != bidId {
+			return nil
+		}
+		if first == nil {
+			first = &snapshot
+		}
+		last = &snapshot
+		return nil
+	})
+	if err != nil || first == nil {
+		return nil, err
+	}
+
+	value := func(snapshot *BidSnapshot) float64 {
+		total := 0.0
+		for _, venueHoldings := range snapshot.Holdings {
+			valueAtom, _, _ := venueValue(venueHoldings)
+			total += valueAtom
+		}
+		return total
+	}
+	inflows := func(at time.Time) float64 {
+		compoundedIn, _ := compoundedIntoBid(bidId, at)
+		return allocatedAtom(bidConfig, at) + compoundedIn + transferredIn(bidId, at)
+	}
+	outflows := func(at time.Time) float64 {
+		return bidWithdrawnAtom(bidConfig, at) + transferredOut(bidConfig, at)
+	}
+
+	start, end := first.Timestamp, last.Timestamp
+	return newComparisonLeg(start, end, value(first), value(last),
+		inflows(end)-inflows(start), outflows(end)-outflows(start)), nil
+}
+
+// experimentalCompareHandler compares an experimental deployment to the bid given by the bid_id
+// query parameter over the window of the deployment. The experiment's return is the one of
+// /experimental, the bid's is taken from its snapshots.
+func experimentalCompareHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	id, err := strconv.Atoi(mux.Vars(r)["experimental_id"])
+	if err != nil {
+		http.Error(w, "invalid experimental deployment ID", http.StatusBadRequest)
+		return
+	}
+	deployment, ok := activeExperimental()[id]
+	if !ok {
+		http.Error(w, fmt.Sprintf("experimental deployment %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	bidId, err := strconv.Atoi(r.URL.Query().Get("bid_id"))
+	if err != nil {
+		http.Error(w, "bid_id is required", http.StatusBadRequest)
+		return
+	}
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		http.Error(w, fmt.Sprintf("bid %d not found", bidId), http.StatusNotFound)
+		return
+	}
+
+	if snapshotStore == nil {
+		http.Error(w, "snapshots are disabled", http.StatusNotFound)
+		return
+	}
+
+	var experiment *ExperimentalDeploymentResponse
+	if isCachedOnly(ctx) {
+		cached, found := resultCache.Get(experimentalCacheKey)
+		if !found {
+			writeComputeError(w, errNotCached)
+			return
+		}
+		for _, response := range cached.([]ExperimentalDeploymentResponse) {
+			if response.ExperimentalId == id {
+				response := response
+				experiment = &response
+			}
+		}
+		if experiment == nil {
+			writeComputeError(w, errNotCached)
+			return
+		}
+	} else {
+		assetData, err := fetchAssetList(ctx, ExperimentalAssetListURL)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching asset list: %v", err), http.StatusInternalServerError)
+			return
+		}
+		response := computeExperimentalResponse(ctx, assetData, deployment)
+		experiment = &response
+	}
+
+	from, to := experimentalWindow(deployment, time.Now())
+	comparison := ExperimentalComparison{ExperimentalId: id, BidId: bidId}
+	if ret := experiment.Return; ret != nil {
+		comparison.Experiment = newComparisonLeg(from, to, ret.InitialValueAtom, ret.CurrentValueAtom, ret.DepositedAtom, ret.WithdrawnAtom)
+	} else {
+		comparison.Notes = append(comparison.Notes, "the return of the experimental deployment can't be valued")
+	}
+
+	comparison.Bid, err = bidComparisonLeg(bidId, bidConfig, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if comparison.Bid == nil {
+		comparison.Notes = append(comparison.Notes, fmt.Sprintf("bid %d has no snapshots in the window of the experimental deployment", bidId))
+	} else if comparison.Bid.From.Sub(from) > 24*time.Hour || to.Sub(comparison.Bid.To) > 24*time.Hour {
+		comparison.Notes = append(comparison.Notes, fmt.Sprintf("the snapshots of bid %d only cover part of the window of the experimental deployment", bidId))
+	}
+
+	if comparison.Experiment != nil && comparison.Experiment.AnnualizedPercent != nil &&
+		comparison.Bid != nil && comparison.Bid.AnnualizedPercent != nil {
+		difference := *comparison.Experiment.AnnualizedPercent - *comparison.Bid.AnnualizedPercent
+		comparison.DifferencePercent = &difference
+	}
+
+	jsonData, err := marshalResponse(r, comparison)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+)
+
+// runExport implements the export command, which writes the snapshot history
+// as analytics rows to a file for offline analysis:
+//
+//	deployment_tracking export --format parquet --from 2025-01-01T00:00:00Z --to 2025-02-01T00:00:00Z --output jan.parquet
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "parquet", "Output format: parquet or csv")
+	decimalSeparator := flags.String("decimal-separator", ".", "Decimal separator of numbers in CSV: . or , (which also separates the fields with semicolons)")
+	dateFormat := flags.String("date-format", DateFormatISO, "Format of timestamps in CSV: iso (RFC 3339), dmy (31.08.2025 12:00:00) or mdy (08/31/2025 12:00:00)")
+	fromStr := flags.String("from", "", "Start of the exported window (RFC 3339), open if empty")
+	toStr := flags.String("to", "", "End of the exported window (RFC 3339), open if empty")
+	snapshotDir := flags.String("snapshot-dir", "snapshots", "Directory the snapshots are stored in")
+	output := flags.String("output", "", "File to write to")
+	flags.Parse(args)
+
+	if *output == "" {
+		return fmt.Errorf("--output is required")
+	}
+
+	locale, err := parseExportLocale(*decimalSeparator, *dateFormat)
+	if err != nil {
+		return err
+	}
+
+	if err := loadSigningKey(); err != nil {
+		return err
+	}
+
+	var from, to time.Time
+	if *fromStr != "" {
+		if from, err = time.Parse(time.RFC3339, *fromStr); err != nil {
+			return fmt.Errorf("invalid --from: %v", err)
+		}
+	}
+	if *toStr != "" {
+		if to, err = time.Parse(time.RFC3339, *toStr); err != nil {
+			return fmt.Errorf("invalid --to: %v", err)
+		}
+	}
+
+	var write func(io.Writer, []AnalyticsRow) error
+	switch *format {
+	case "parquet":
+		write = writeRowsParquet
+	case "csv":
+		write = func(w io.Writer, rows []AnalyticsRow) error {
+			return writeRowsCSV(w, rows, locale)
+		}
+	default:
+		return fmt.Errorf("unknown format: %s", *format)
+	}
+
+	store, err := NewSnapshotStore(*snapshotDir)
+	if err != nil {
+		return err
+	}
+
+	var rows []AnalyticsRow
+	err = store.Range(from, to, func(snapshot BidSnapshot) error {
+		rows = append(rows, analyticsRows(snapshot)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf, rows); err != nil {
+		return err
+	}
+
+	if err := writeSealedFile(*output, buf.Bytes()); err != nil {
+		return err
+	}
+
+	log.Printf("Exported %d rows to %s", len(rows), *output)
+	return nil
+}
+
+// writeRowsParquet writes the rows as a Parquet file with the same columns as the analytics sink.
+func writeRowsParquet(w io.Writer, rows []AnalyticsRow) error {
+	ts := newParquetColumn("ts", parquetInt64, parquetTimestampMillis)
+	bidId := newParquetColumn("bid_id", parquetInt32, parquetNoConvertedType)
+	venueID := newParquetColumn("venue_id", parquetByteArray, parquetUTF8)
+	protocol := newParquetColumn("protocol", parquetByteArray, parquetUTF8)
+	kind := newParquetColumn("kind", parquetByteArray, parquetUTF8)
+	denom := newParquetColumn("denom", parquetByteArray, parquetUTF8)
+	amount := newParquetColumn("amount", parquetDouble, parquetNoConvertedType)
+	usd := newParquetColumn("usd", parquetDouble, parquetNoConvertedType)
+	atom := newParquetColumn("atom", parquetDouble, parquetNoConvertedType)
+
+	for _, row := range rows {
+		ts.AppendInt64(row.Timestamp.UnixMilli())
+		bidId.AppendInt32(int32(row.BidId))
+		venueID.AppendString(row.VenueID)
+		protocol.AppendString(string(row.Protocol))
+		kind.AppendString(row.Kind)
+		denom.AppendString(row.Denom)
+		amount.AppendDouble(row.Amount)
+		usd.AppendDouble(row.USD)
+		atom.AppendDouble(row.Atom)
+	}
+
+	return writeParquet(w, []*parquetColumn{ts, bidId, venueID, protocol, kind, denom, amount, usd, atom})
+}
+
+// writeRowsCSV writes the rows as CSV with the same columns as the analytics sink, with numbers and
+// timestamps in the format of the locale.
+func writeRowsCSV(w io.Writer, rows []AnalyticsRow, locale ExportLocale) error {
+	writer := csv.NewWriter(w)
+	writer.Comma = locale.CSVSeparator()
+	writer.Write([]string{"ts", "bid_id", "venue_id", "protocol", "kind", "denom", "amount", "usd", "atom"})
+
+	for _, row := range rows {
+		writer.Write([]string{
+			locale.FormatTimestamp(row.Timestamp),
+			strconv.Itoa(row.BidId),
+			row.VenueID,
+			string(row.Protocol),
+			row.Kind,
+			row.Denom,
+			locale.FormatNumber(row.Amount),
+			locale.FormatNumber(row.USD),
+			locale.FormatNumber(row.Atom),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
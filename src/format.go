@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AssetClass groups assets that are displayed with the same precision.
+type AssetClass string
+
+const (
+	AssetClassStable AssetClass = "stable"
+	AssetClassMajor  AssetClass = "major"
+	AssetClassOther  AssetClass = "other"
+)
+
+// DisplayRule rounds a value to SignificantDigits, but to no more than MaxDecimals decimals.
+// Fixed rules always show MaxDecimals decimals, e.g. "12.50".
+type DisplayRule struct {
+	SignificantDigits int
+	MaxDecimals       int
+	Fixed             bool
+}
+
+var (
+	assetDisplayRules = map[AssetClass]DisplayRule{
+		AssetClassStable: {MaxDecimals: 2, Fixed: true},
+		AssetClassMajor:  {SignificantDigits: 8, MaxDecimals: 6},
+		AssetClassOther:  {SignificantDigits: 6, MaxDecimals: 6},
+	}
+	usdDisplayRule  = DisplayRule{MaxDecimals: 2, Fixed: true}
+	atomDisplayRule = DisplayRule{SignificantDigits: 8, MaxDecimals: 4}
+)
+
+// majorAssetSymbols are displayed with more precision than long-tail tokens, as small amounts of them are worth a lot.
+var majorAssetSymbols = map[string]bool{
+	"ATOM": true, "STATOM": true, "STKATOM": true, "DATOM": true,
+	"BTC": true, "WBTC": true, "ETH": true, "WETH": true,
+	"OSMO": true, "NTRN": true, "TIA": true, "INJ": true,
+}
+
+// assetClass classifies an asset by its display name.
+func assetClass(displayName string) AssetClass {
+	symbol := strings.ToUpper(displayName)
+	switch {
+	case strings.Contains(symbol, "USD") || symbol == "DAI":
+		return AssetClassStable
+	case majorAssetSymbols[symbol]:
+		return AssetClassMajor
+	default:
+		return AssetClassOther
+	}
+}
+
+// cleanFloat rounds away the representation artifacts of float arithmetic, e.g. 6976.354000000001 becomes 6976.354.
+func cleanFloat(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	cleaned, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 15, 64), 64)
+	return cleaned
+}
+
+// formatRaw formats a value with its full precision, without an exponent.
+func formatRaw(v float64) string {
+	return strconv.FormatFloat(cleanFloat(v), 'f', -1, 64)
+}
+
+// formatDisplay formats a value for display according to the rule.
+func formatDisplay(v float64, rule DisplayRule) string {
+	if rule.Fixed {
+		return strconv.FormatFloat(v, 'f', rule.MaxDecimals, 64)
+	}
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	decimals := rule.SignificantDigits - 1 - int(math.Floor(math.Log10(math.Abs(v))))
+	if decimals > rule.MaxDecimals {
+		decimals = rule.MaxDecimals
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// emptyIfNil returns an empty slice for a nil one, so that lists serialize as arrays: null is
+// reserved for data that is missing.
+func emptyIfNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
+
+// MarshalJSON serializes the balances and liabilities as arrays, even if they are empty, as null
+// is reserved for holdings that are unknown or don't apply.
+func (h Holdings) MarshalJSON() ([]byte, error) {
+	type plainHoldings Holdings
+
+	h.Balances = emptyIfNil(h.Balances)
+	h.Liabilities = emptyIfNil(h.Liabilities)
+	return json.Marshal(plainHoldings(h))
+}
+
+// MarshalJSON serializes the lists of the venue as arrays, even if they are empty.
+func (v VenueHoldings) MarshalJSON() ([]byte, error) {
+	type plainVenueHoldings VenueHoldings
+
+	v.Links = emptyIfNil(v.Links)
+	v.RewardBreakdown = emptyIfNil(v.RewardBreakdown)
+	return json.Marshal(plainVenueHoldings(v))
+}
+
+// MarshalJSON serializes the lists of the bid as arrays, even if they are empty. Only the
+// holdings are null, if they couldn't be computed.
+func (b BidHoldings) MarshalJSON() ([]byte, error) {
+	type plainBidHoldings BidHoldings
+
+	b.CompoundedInto = emptyIfNil(b.CompoundedInto)
+	b.Allocations = emptyIfNil(b.Allocations)
+	b.Withdrawals = emptyIfNil(b.Withdrawals)
+	b.Transfers = emptyIfNil(b.Transfers)
+	return json.Marshal(plainBidHoldings(b))
+}
+
+// stringAmountKeys are the fields that ?numbers=string serializes as decimal strings.
+var stringAmountKeys = map[string]bool{
+	"amount":               true,
+	"usd_value":            true,
+	"atom_value":           true,
+	"total_usdc":           true,
+	"total_atom":           true,
+	"withdrawn_amount":     true,
+	"withdrawn_shares":     true,
+	"current_value_atom":   true,
+	"withdrawn_atom":       true,
+	"compounded_in_atom":   true,
+	"net_deployed":         true,
+	"transferred_in_atom":  true,
+	"transferred_out_atom": true,
+	"return_atom":          true,
+	"initial_usd":          true,
+	"current_value_usd":    true,
+	"withdrawn_usd":        true,
+	"return_usd":           true,
+}
+
+// marshalResponse marshals a response body, with the display fields of its amounts and with amounts
+// as decimal strings if the request asks for ?numbers=string. The display fields are only added to
+// responses, snapshots and caches store the plain values.
+func marshalResponse(r *http.Request, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	stringNumbers := r.URL.Query().Get("numbers") == "string"
+	if data, err = decorateAmounts(data, stringNumbers); err != nil {
+		return nil, err
+	}
+	if stringNumbers {
+		if data, err = stringifyAmounts(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, data, "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// jsonField is a field of a JSON object, in the order of the document.
+type jsonField struct {
+	key   string
+	value json.RawMessage
+}
+
+// decorateAmounts rounds the amounts of the assets and holdings in a JSON document to 15
+// significant digits and adds their precise and display-rounded values as strings, keeping the
+// order of the fields. Assets and holdings are recognized by their fields. With exact, asset
+// amounts read from the chain keep all their digits instead, for stringifyAmounts.
+func decorateAmounts(data []byte, exact bool) ([]byte, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || (data[0] != '{' && data[0] != '[') {
+		return data, nil
+	}
+
+	var out bytes.Buffer
+	if data[0] == '[' {
+		var elements []json.RawMessage
+		if err := json.Unmarshal(data, &elements); err != nil {
+			return nil, err
+		}
+		out.WriteByte('[')
+		for i, element := range elements {
+			decorated, err := decorateAmounts(element, exact)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.Write(decorated)
+		}
+		out.WriteByte(']')
+		return out.Bytes(), nil
+	}
+
+	fields, err := decodeJSONFields(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := range fields {
+		if fields[i].value, err = decorateAmounts(fields[i].value, exact); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case hasJSONFields(fields, "denom", "amount", "usd_value", "atom_value"):
+		fields, err = decorateAsset(fields, exact)
+	case hasJSONFields(fields, "balances", "liabilities", "total_usdc", "total_atom"):
+		fields, err = decorateHoldings(fields)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	out.WriteByte('{')
+	for i, field := range fields {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		key, _ := json.Marshal(field.key)
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(field.value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// decodeJSONFields decodes the fields of a JSON object in their order.
+func decodeJSONFields(data []byte) ([]jsonField, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	var fields []jsonField
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		field := jsonField{key: key.(string)}
+		if err := dec.Decode(&field.value); err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// hasJSONFields reports whether an object has all the keys.
+func hasJSONFields(fields []jsonField, keys ...string) bool {
+	for _, key := range keys {
+		if jsonFieldValue(fields, key) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonFieldValue returns the value of a field, or nil if the object doesn't have it.
+func jsonFieldValue(fields []jsonField, key string) json.RawMessage {
+	for _, field := range fields {
+		if field.key == key {
+			return field.value
+		}
+	}
+	return nil
+}
+
+// amountFields returns the rounded value of an amount with its precise and display-rounded values.
+// The precise value is the exact amount, if there is one.
+func amountFields(key string, value json.RawMessage, exact string, rule DisplayRule) ([]jsonField, error) {
+	var v float64
+	if err := json.Unmarshal(value, &v); err != nil {
+		return nil, fmt.Errorf("%s: %v", key, err)
+	}
+	rounded, err := json.Marshal(cleanFloat(v))
+	if err != nil {
+		return nil, err
+	}
+	if exact == "" {
+		exact = formatRaw(v)
+	}
+	raw, _ := json.Marshal(exact)
+	display, _ := json.Marshal(formatDisplay(v, rule))
+	return []jsonField{{key, rounded}, {key + "_raw", raw}, {key + "_display", display}}, nil
+}
+
+// decorateAsset moves the amount and values of an asset after its other fields, each with its
+// precise and display-rounded value, and adds its asset class, logo and display precision. Its
+// exact_amount is its precise amount, and only goes into the amount itself with exact.
+func decorateAsset(fields []jsonField, exact bool) ([]jsonField, error) {
+	var denom, displayName, exactAmount string
+	if err := json.Unmarshal(jsonFieldValue(fields, "denom"), &denom); err != nil {
+		return nil, fmt.Errorf("denom: %v", err)
+	}
+	if value := jsonFieldValue(fields, "display_name"); value != nil {
+		if err := json.Unmarshal(value, &displayName); err != nil {
+			return nil, fmt.Errorf("display_name: %v", err)
+		}
+	}
+	if value := jsonFieldValue(fields, "exact_amount"); value != nil {
+		if err := json.Unmarshal(value, &exactAmount); err != nil {
+			return nil, fmt.Errorf("exact_amount: %v", err)
+		}
+	}
+	class := assetClass(displayName)
+	rule := assetDisplayRules[class]
+
+	decorated := make([]jsonField, 0, len(fields)+10)
+	for _, field := range fields {
+		if field.key != "amount" && field.key != "exact_amount" && field.key != "usd_value" && field.key != "atom_value" {
+			decorated = append(decorated, field)
+		}
+	}
+	for _, amount := range []struct {
+		key   string
+		exact string
+		rule  DisplayRule
+	}{{"amount", exactAmount, rule}, {"usd_value", "", usdDisplayRule}, {"atom_value", "", atomDisplayRule}} {
+		values, err := amountFields(amount.key, jsonFieldValue(fields, amount.key), amount.exact, amount.rule)
+		if err != nil {
+			return nil, err
+		}
+		if exact && amount.exact != "" {
+			values[0].value = json.RawMessage(amount.exact)
+		}
+		decorated = append(decorated, values...)
+	}
+
+	classValue, _ := json.Marshal(class)
+	decorated = append(decorated, jsonField{"asset_class", classValue})
+	if logo := assetLogo(denom, displayName); logo != "" {
+		logoValue, _ := json.Marshal(logo)
+		decorated = append(decorated, jsonField{"logo", logoValue})
+	}
+	if rule.SignificantDigits != 0 {
+		decorated = append(decorated, jsonField{"significant_digits", json.RawMessage(strconv.Itoa(rule.SignificantDigits))})
+	}
+	return append(decorated, jsonField{"max_decimals", json.RawMessage(strconv.Itoa(rule.MaxDecimals))}), nil
+}
+
+// decorateHoldings adds the precise and display-rounded totals to holdings.
+func decorateHoldings(fields []jsonField) ([]jsonField, error) {
+	decorated := make([]jsonField, 0, len(fields)+4)
+	for _, field := range fields {
+		var rule DisplayRule
+		switch field.key {
+		case "total_usdc":
+			rule = usdDisplayRule
+		case "total_atom":
+			rule = atomDisplayRule
+		default:
+			decorated = append(decorated, field)
+			continue
+		}
+		totalFields, err := amountFields(field.key, field.value, "", rule)
+		if err != nil {
+			return nil, err
+		}
+		decorated = append(decorated, totalFields...)
+	}
+	return decorated, nil
+}
+
+// jsonFrame is an object or array that stringifyAmounts is inside of.
+type jsonFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+	count     int
+}
+
+// stringifyAmounts quotes the numbers of stringAmountKeys in a JSON document, keeping the order of the fields.
+func stringifyAmounts(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	var stack []*jsonFrame
+
+	// beginValue writes the separator before an array element
+	beginValue := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := stack[len(stack)-1]; !top.object {
+			if top.count > 0 {
+				out.WriteByte(',')
+			}
+			top.count++
+		}
+	}
+	// endValue prepares the enclosing object for its next key
+	endValue := func() {
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top.object && top.expectKey {
+				if key, ok := token.(string); ok {
+					if top.count > 0 {
+						out.WriteByte(',')
+					}
+					top.count++
+					top.key = key
+					top.expectKey = false
+					encoded, _ := json.Marshal(key)
+					out.Write(encoded)
+					out.WriteByte(':')
+					continue
+				}
+			}
+		}
+
+		switch t := token.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				beginValue()
+				out.WriteRune(rune(t))
+				stack = append(stack, &jsonFrame{object: t == '{', expectKey: t == '{'})
+			default:
+				out.WriteRune(rune(t))
+				stack = stack[:len(stack)-1]
+				endValue()
+			}
+		case json.Number:
+			beginValue()
+			if len(stack) > 0 && stack[len(stack)-1].object && stringAmountKeys[stack[len(stack)-1].key] {
+				out.WriteString(strconv.Quote(t.String()))
+			} else {
+				out.WriteString(t.String())
+			}
+			endValue()
+		default:
+			beginValue()
+			encoded, err := json.Marshal(t)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(encoded)
+			endValue()
+		}
+	}
+
+	return out.Bytes(), nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// compactResponse marshals v like a response and compacts it, for comparison with a golden string.
+func compactResponse(t *testing.T, v interface{}) string {
+	t.Helper()
+	data, err := marshalResponse(httptest.NewRequest("GET", "/holdings", nil), v)
+	if err != nil {
+		t.Fatalf("marshalResponse: %v", err)
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		t.Fatalf("compacting %s: %v", data, err)
+	}
+	return compact.String()
+}
+
+func TestResponseListsAreArrays(t *testing.T) {
+	date := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	emptyHoldings := `{"balances":[],"liabilities":[],"total_usdc":0,"total_usdc_raw":"0","total_usdc_display":"0.00","total_atom":0,"total_atom_raw":"0","total_atom_display":"0"}`
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		golden string
+	}{
+		{
+			name:   "nil holdings lists",
+			value:  Holdings{},
+			golden: emptyHoldings,
+		},
+		{
+			name:   "empty holdings lists",
+			value:  Holdings{Balances: []Asset{}, Liabilities: []Asset{}},
+			golden: emptyHoldings,
+		},
+		{
+			name:   "missing venue holdings",
+			value:  VenueHoldings{VenueID: "1:osmosis:1283", InfoMissing: true},
+			golden: `{"venue_id":"1:osmosis:1283","display_name":"","links":[],"info_missing":true,"protocol":"","venue_total":null,"address_holdings":null,"address_rewards":null,"reward_breakdown":[]}`,
+		},
+		{
+			name:   "empty venue holdings",
+			value:  VenueHoldings{VenueID: "1:osmosis:1283", Links: []VenueLink{}, VenueTotal: &Holdings{}, RewardBreakdown: []RewardSubtotal{}},
+			golden: `{"venue_id":"1:osmosis:1283","display_name":"","links":[],"info_missing":false,"protocol":"","venue_total":` + emptyHoldings + `,"address_holdings":null,"address_rewards":null,"reward_breakdown":[]}`,
+		},
+		{
+			name:   "bid whose holdings failed",
+			value:  BidHoldings{BidId: 7, Status: "active"},
+			golden: `{"bid_id":7,"status":"active","compounded_into":[],"initial_allocation":0,"allocations":[],"net_deployed":0,"holdings":null,"withdrawals":[],"transfers":[],"performance":null,"lifetime_rewards":null,"content_hash":"","holdings_hash":""}`,
+		},
+		{
+			name: "bid without venues",
+			value: BidHoldings{BidId: 7, Status: "withdrawn", Holdings: []VenueHoldings{}, CompoundedInto: []int{},
+				Allocations: []Allocation{}, Withdrawals: []Withdrawal{{Date: date, WithdrawnAmount: 10}}, Transfers: []Transfer{}},
+			golden: `{"bid_id":7,"status":"withdrawn","compounded_into":[],"initial_allocation":0,"allocations":[],"net_deployed":0,"holdings":[],"withdrawals":[{"date":"2025-03-01T00:00:00Z","withdrawn_amount":10,"withdrawn_shares":0,"compounded_bid_id":0}],"transfers":[],"performance":null,"lifetime_rewards":null,"content_hash":"","holdings_hash":""}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := compactResponse(t, test.value); got != test.golden {
+				t.Errorf("got\n%s\nwant\n%s", got, test.golden)
+			}
+		})
+	}
+}
+
+func TestNilAndEmptyHoldingsSerializeAlike(t *testing.T) {
+	pairs := []struct {
+		name       string
+		nil, empty interface{}
+	}{
+		{"holdings", Holdings{}, Holdings{Balances: []Asset{}, Liabilities: []Asset{}}},
+		{"venue", VenueHoldings{}, VenueHoldings{Links: []VenueLink{}, RewardBreakdown: []RewardSubtotal{}}},
+		{"bid", BidHoldings{Holdings: []VenueHoldings{}}, BidHoldings{Holdings: []VenueHoldings{}, CompoundedInto: []int{},
+			Allocations: []Allocation{}, Withdrawals: []Withdrawal{}, Transfers: []Transfer{}}},
+	}
+
+	for _, pair := range pairs {
+		if got, want := compactResponse(t, pair.nil), compactResponse(t, pair.empty); got != want {
+			t.Errorf("%s: nil lists serialize as\n%s\nbut empty ones as\n%s", pair.name, got, want)
+		}
+	}
+}
+
+func TestAmountsAreDecoratedOnlyInResponses(t *testing.T) {
+	holdings := Holdings{
+		Balances:  []Asset{{Denom: "uatom", Amount: 6976.354000000001, USDValue: 0.30000000000000004, AtomValue: 6976.354000000001, DisplayName: "ATOM"}},
+		TotalUSDC: 0.30000000000000004,
+		TotalAtom: 6976.354000000001,
+	}
+
+	plain, err := json.Marshal(holdings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"balances":[{"denom":"uatom","amount":6976.354000000001,"usd_value":0.30000000000000004,"atom_value":6976.354000000001,"display_name":"ATOM"}],"liabilities":[],"total_usdc":0.30000000000000004,"total_atom":6976.354000000001}`; string(plain) != want {
+		t.Errorf("plain encoding\n%s\nwant\n%s", plain, want)
+	}
+
+	want := `{"balances":[{"denom":"uatom","display_name":"ATOM",` +
+		`"amount":6976.354,"amount_raw":"6976.354","amount_display":"6976.354",` +
+		`"usd_value":0.3,"usd_value_raw":"0.3","usd_value_display":"0.30",` +
+		`"atom_value":6976.354,"atom_value_raw":"6976.354","atom_value_display":"6976.354",` +
+		`"asset_class":"major","logo":"` + assetLogo("uatom", "ATOM") + `","significant_digits":8,"max_decimals":6}],` +
+		`"liabilities":[],"total_usdc":0.3,"total_usdc_raw":"0.3","total_usdc_display":"0.30",` +
+		`"total_atom":6976.354,"total_atom_raw":"6976.354","total_atom_display":"6976.354"}`
+	if got := compactResponse(t, holdings); got != want {
+		t.Errorf("response\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestStringNumbersKeepExactAmounts(t *testing.T) {
+	amount, err := parseAmount("123456789012345678901234567")
+	if err != nil {
+		t.Fatal(err)
+	}
+	holdings := Holdings{Balances: []Asset{{
+		Denom:       "wei",
+		Amount:      tokenAmount(amount, 18),
+		ExactAmount: exactTokenAmount(amount, 18),
+		DisplayName: "ETH",
+	}}}
+
+	data, err := marshalResponse(httptest.NewRequest("GET", "/holdings?numbers=string", nil), holdings)
+	if err != nil {
+		t.Fatalf("marshalResponse: %v", err)
+	}
+	var response struct {
+		Balances []map[string]interface{} `json:"balances"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		t.Fatal(err)
+	}
+	asset := response.Balances[0]
+	if got, want := asset["amount"], "123456789.012345678901234567"; got != want {
+		t.Errorf("amount %v, want %q", got, want)
+	}
+	if got, want := asset["amount_raw"], "123456789.012345678901234567"; got != want {
+		t.Errorf("amount_raw %v, want %q", got, want)
+	}
+	if _, ok := asset["exact_amount"]; ok {
+		t.Errorf("exact_amount is served: %v", asset)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// OsmosisAtomDenom is ATOM on Osmosis, whose price history Numia provides.
+const OsmosisAtomDenom = "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2"
+
+// AtomPriceChartTTL is how long the ATOM price history is reused before new points are fetched.
+const AtomPriceChartTTL = time.Hour
+
+var (
+	atomPriceChartMu        sync.Mutex
+	atomPriceChart          []NumiaHistoricalPrice
+	atomPriceChartFetchedAt time.Time
+)
+
+// USDPerformance separates the performance of a bid in USD-denominated venues from the moves of the
+// ATOM price: the ATOM return of such a bid is its USD return plus the change of the ATOM price.
+type USDPerformance struct {
+	AtomPriceAtDeployment float64 `json:"atom_price_at_deployment"`
+	AtomPriceNow          float64 `json:"atom_price_now"`
+	InitialUSD            float64 `json:"initial_usd"`       // the initial allocation at the deployment price
+	CurrentValueUSD       float64 `json:"current_value_usd"` // principal and rewards
+	WithdrawnUSD          float64 `json:"withdrawn_usd"`     // at the prices on the withdrawal dates
+	ReturnUSD             float64 `json:"return_usd"`
+	ReturnUSDPercent      float64 `json:"return_usd_percent"`
+	// the current value translated into ATOM at the deployment price, i.e. as if the ATOM price
+	// hadn't moved, and at the current price
+	CurrentValueAtomAtDeploymentPrice float64 `json:"current_value_atom_at_deployment_price"`
+	CurrentValueAtomAtCurrentPrice    float64 `json:"current_value_atom_at_current_price"`
+	// AtomPriceEffectPercent is how much of the ATOM return is due to the ATOM price.
+	AtomPriceEffectPercent float64 `json:"atom_price_effect_percent"`
+}
+
+// historicalAtomPrice returns the USD price of ATOM at a time, as pinned in the price ledger. With
+// cachedOnly, it fails instead of fetching the price history.
+func historicalAtomPrice(ctx context.Context, t time.Time, cachedOnly bool) (float64, error) {
+	return priceLedger.Price(OsmosisAtomDenom, t, func() (float64, error) {
+		atomPriceChartMu.Lock()
+		defer atomPriceChartMu.Unlock()
+
+		if time.Since(atomPriceChartFetchedAt) > AtomPriceChartTTL {
+			if cachedOnly && atomPriceChart == nil {
+				return 0, errNotCached
+			}
+			if !cachedOnly {
+				chart, err := fetchNumiaPriceChart(ctx, OsmosisAtomDenom)
+				if err != nil {
+					return 0, err
+				}
+				atomPriceChart, atomPriceChartFetchedAt = chart, time.Now()
+			}
+		}
+
+		return closestHistoricalPrice(atomPriceChart, t.Unix())
+	})
+}
+
+// isUSDDenominated reports whether all valued venues of a bid hold only stablecoins as principal.
+func isUSDDenominated(holdings []VenueHoldings) bool {
+	found := false
+	for _, venueHoldings := range holdings {
+		if venueHoldings.InfoMissing || venueHoldings.AddressPrincipal == nil {
+			continue
+		}
+		for _, asset := range venueHoldings.AddressPrincipal.allAssets() {
+			if assetClass(asset.DisplayName) != AssetClassStable {
+				return false
+			}
+			found = true
+		}
+	}
+	return found
+}
+
+// computeUSDPerformance computes the USD view of the performance of a bid in USD-denominated venues.
+// It returns nil for other bids and if any of the ATOM prices it needs isn't available, rather than
+// partial figures.
+func computeUSDPerformance(ctx context.Context, bidId int, bidConfig BidPositionConfig, holdings []VenueHoldings, performance *BidPerformance) *USDPerformance {
+	if performance == nil || !isUSDDenominated(holdings) {
+		return nil
+	}
+	cachedOnly := isCachedOnly(ctx)
+	atomPrice := func(t time.Time, what string) (float64, bool) {
+		price, err := historicalAtomPrice(ctx, t, cachedOnly)
+		if err == nil && price > 0 {
+			return price, true
+		}
+		// cached-only requests are expected to miss prices that weren't fetched yet
+		if !errors.Is(err, errNotCached) {
+			log.Printf("Bid %d: no ATOM price for the %s on %s, leaving out the USD performance: %v",
+				bidId, what, t.Format("2006-01-02"), err)
+		}
+		return 0, false
+	}
+
+	usd := &USDPerformance{}
+	currentAtom := 0.0
+	for _, venueHoldings := range holdings {
+		for _, h := range []*Holdings{venueHoldings.AddressPrincipal, venueHoldings.AddressRewards} {
+			if h != nil {
+				usd.CurrentValueUSD += h.TotalUSDC
+				currentAtom += h.TotalAtom
+			}
+		}
+	}
+	if currentAtom <= 0 {
+		return nil
+	}
+	// the price the holdings were valued at, rather than a possibly newer one
+	usd.AtomPriceNow = usd.CurrentValueUSD / currentAtom
+
+	var ok bool
+	if usd.AtomPriceAtDeployment, ok = atomPrice(performance.DeployedAt, "deployment"); !ok {
+		return nil
+	}
+
+	for _, withdrawal := range bidConfig.Withdrawals {
+		amount := withdrawal.WithdrawnAmount
+		if amount == 0 {
+			for _, target := range withdrawal.CompoundingTargets() {
+				amount += target.Amount
+			}
+		}
+		price, ok := atomPrice(withdrawal.Date, "withdrawal")
+		if !ok {
+			return nil
+		}
+		usd.WithdrawnUSD += amount * price
+	}
+
+	// installments are valued at the ATOM price of their date
+	for _, tranche := range allocationTranches(bidConfig, performance.DeployedAt) {
+		price := usd.AtomPriceAtDeployment
+		if !tranche.Date.Equal(performance.DeployedAt) {
+			if price, ok = atomPrice(tranche.Date, "allocation"); !ok {
+				return nil
+			}
+		}
+		usd.InitialUSD += tranche.Amount * price
+	}
+	usd.ReturnUSD = usd.CurrentValueUSD + usd.WithdrawnUSD - usd.InitialUSD
+	usd.ReturnUSDPercent = usd.ReturnUSD / usd.InitialUSD * 100
+	usd.CurrentValueAtomAtDeploymentPrice = usd.CurrentValueUSD / usd.AtomPriceAtDeployment
+	usd.CurrentValueAtomAtCurrentPrice = currentAtom
+	usd.AtomPriceEffectPercent = performance.ReturnPercent - usd.ReturnUSDPercent
+
+	return usd
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader identifies a submission, so that its retries are applied only once.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyRetention is how long the response to a submission is replayed to its retries.
+	IdempotencyRetention = 24 * time.Hour
+	// IdempotencyClaimTTL releases the key of a submission in progress on a replica that died.
+	IdempotencyClaimTTL = 5 * time.Minute
+)
+
+// idempotencyResponses are the responses to completed submissions, by method, path and key. With
+// Redis, they are shared by the replicas instead, so that a retry can go to any of them.
+var idempotencyResponses = newLRUCache("idempotency", 10000, 16<<20, IdempotencyRetention)
+
+var (
+	idempotencyMu       sync.Mutex
+	idempotencyInflight = make(map[string]string) // the body hash of submissions in progress
+)
+
+// idempotencyRecord is a submission, in progress until it is done.
+type idempotencyRecord struct {
+	BodyHash string      `json:"body_hash"` // hex SHA-256 of the request body
+	Done     bool        `json:"done"`
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+}
+
+// claimIdempotencyScript returns the record of a submission, or claims its key for a new one.
+const claimIdempotencyScript = `local record = redis.call("get", KEYS[1]) if record then return record end redis.call("set", KEYS[1], ARGV[1], "PX", ARGV[2]) return false`
+
+var idempotencyErrorsMetric = newCounter("idempotency_store_errors_total",
+	"Number of failed reads and writes of the shared idempotency keys, which fell back to the local ones.")
+
+// sharedIdempotencyKey is the Redis key of a submission.
+func sharedIdempotencyKey(cacheKey string) string {
+	hash := sha256.Sum256([]byte(cacheKey))
+	return redisKeyPrefix + "idempotency:" + hex.EncodeToString(hash[:])
+}
+
+// claimIdempotencyKey returns the record of an earlier submission with the key, or nil if the
+// key is claimed for this one.
+func claimIdempotencyKey(cacheKey string, bodyHash string) *idempotencyRecord {
+	if sharedRedis != nil {
+		record, err := claimSharedIdempotencyKey(cacheKey, bodyHash)
+		if err == nil {
+			return record
+		}
+		idempotencyErrorsMetric.Add(1)
+		log.Printf("Claiming the idempotency key in Redis failed, falling back to this replica: %v", err)
+	}
+
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+	if cached, ok := idempotencyResponses.Get(cacheKey); ok {
+		return cached.(*idempotencyRecord)
+	}
+	if inflightHash, ok := idempotencyInflight[cacheKey]; ok {
+		return &idempotencyRecord{BodyHash: inflightHash}
+	}
+	idempotencyInflight[cacheKey] = bodyHash
+	return nil
+}
+
+func claimSharedIdempotencyKey(cacheKey string, bodyHash string) (*idempotencyRecord, error) {
+	claim, _ := json.Marshal(idempotencyRecord{BodyHash: bodyHash})
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	reply, err := sharedRedis.Do(ctx, "EVAL", claimIdempotencyScript, "1", sharedIdempotencyKey(cacheKey),
+		string(claim), strconv.FormatInt(IdempotencyClaimTTL.Milliseconds(), 10))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, nil
+	}
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("decoding idempotency record: %v", err)
+	}
+	return &record, nil
+}
+
+// completeIdempotencyKey records the response to a submission, or releases its key if the response
+// is nil, so that it can be retried. Both stores are updated, as the claim may have fallen back to
+// the local one.
+func completeIdempotencyKey(cacheKey string, record *idempotencyRecord) {
+	idempotencyMu.Lock()
+	delete(idempotencyInflight, cacheKey)
+	if record != nil {
+		idempotencyResponses.Set(cacheKey, record, int64(len(record.Body)))
+	}
+	idempotencyMu.Unlock()
+
+	if sharedRedis == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var err error
+	if record != nil {
+		data, _ := json.Marshal(record)
+		_, err = sharedRedis.Do(ctx, "SET", sharedIdempotencyKey(cacheKey), string(data),
+			"PX", strconv.FormatInt(IdempotencyRetention.Milliseconds(), 10))
+	} else {
+		_, err = sharedRedis.Do(ctx, "DEL", sharedIdempotencyKey(cacheKey))
+	}
+	if err != nil {
+		idempotencyErrorsMetric.Add(1)
+		log.Printf("Recording the idempotency key in Redis failed: %v", err)
+	}
+}
+
+// idempotent dedupes retried admin mutations carrying an Idempotency-Key header, e.g. a client
+// retrying after a timeout: the response to the first submission is replayed instead of applying
+// the mutation again. Reusing a key for a different body is rejected, as is a retry while the
+// first submission is still in progress. Server errors aren't replayed, so that they can be retried.
+func idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" || r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "reading request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(body)
+		bodyHash := hex.EncodeToString(hash[:])
+		cacheKey := r.Method + " " + r.URL.Path + " " + key
+
+		if earlier := claimIdempotencyKey(cacheKey, bodyHash); earlier != nil {
+			if earlier.BodyHash != bodyHash {
+				http.Error(w, IdempotencyKeyHeader+" was already used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			if !earlier.Done {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "a request with this "+IdempotencyKeyHeader+" is in progress", http.StatusConflict)
+				return
+			}
+			for name, values := range earlier.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(earlier.Status)
+			w.Write(earlier.Body)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r)
+
+		var record *idempotencyRecord
+		if recorder.status < http.StatusInternalServerError {
+			record = &idempotencyRecord{
+				BodyHash: bodyHash,
+				Done:     true,
+				Status:   recorder.status,
+				Header:   w.Header().Clone(),
+				Body:     recorder.body.Bytes(),
+			}
+		}
+		completeIdempotencyKey(cacheKey, record)
+	}
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
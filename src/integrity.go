@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// snapshotSigningKey signs snapshots, archives and exports if SNAPSHOT_SIGNING_KEY is set.
+// Without it, they only carry a checksum.
+var snapshotSigningKey ed25519.PrivateKey
+
+// sealSuffix starts the integrity fields that sealJSON appends to a JSON object.
+const sealSuffix = `,"checksum":"`
+
+// loadSigningKey reads SNAPSHOT_SIGNING_KEY, the base64-encoded 32-byte Ed25519 seed.
+func loadSigningKey() error {
+	encoded := getSecret("SNAPSHOT_SIGNING_KEY")
+	if encoded == "" {
+		return nil
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decoding SNAPSHOT_SIGNING_KEY: %v", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("SNAPSHOT_SIGNING_KEY must be a %d-byte seed, got %d bytes", ed25519.SeedSize, len(seed))
+	}
+
+	snapshotSigningKey = ed25519.NewKeyFromSeed(seed)
+	log.Printf("Signing snapshots and exports with public key %s",
+		base64.StdEncoding.EncodeToString(snapshotSigningKey.Public().(ed25519.PublicKey)))
+	return nil
+}
+
+// checksumAndSign returns the hex SHA-256 of data and, if a signing key is configured,
+// the base64 Ed25519 signature of the checksum.
+func checksumAndSign(data []byte) (string, string) {
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	signature := ""
+	if snapshotSigningKey != nil {
+		signature = base64.StdEncoding.EncodeToString(ed25519.Sign(snapshotSigningKey, []byte(checksum)))
+	}
+	return checksum, signature
+}
+
+// sealJSON appends the checksum and signature of a JSON object as its last fields.
+// They cover the exact bytes of the object before them, so that verification
+// doesn't depend on the object being re-encoded identically by later versions.
+func sealJSON(object []byte) []byte {
+	checksum, signature := checksumAndSign(object)
+
+	sealed := append([]byte{}, bytes.TrimSuffix(object, []byte("}"))...)
+	sealed = append(sealed, sealSuffix+checksum+`"`...)
+	if signature != "" {
+		sealed = append(sealed, `,"signature":"`+signature+`"`...)
+	}
+	return append(sealed, '}')
+}
+
+// verifySealedJSON checks the checksum of a sealed JSON object and, if a public key is given, its signature.
+func verifySealedJSON(sealed []byte, publicKey ed25519.PublicKey) error {
+	i := bytes.LastIndex(sealed, []byte(sealSuffix))
+	if i < 0 {
+		return fmt.Errorf("no checksum")
+	}
+
+	var seal struct {
+		Checksum  string `json:"checksum"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(append([]byte("{"), sealed[i+1:]...), &seal); err != nil {
+		return fmt.Errorf("decoding checksum: %v", err)
+	}
+
+	object := append(append([]byte{}, sealed[:i]...), '}')
+	return verifyChecksum(object, seal.Checksum, seal.Signature, publicKey)
+}
+
+func verifyChecksum(data []byte, checksum string, signature string, publicKey ed25519.PublicKey) error {
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != checksum {
+		return fmt.Errorf("checksum mismatch")
+	}
+
+	if publicKey == nil {
+		return nil
+	}
+	if signature == "" {
+		return fmt.Errorf("not signed")
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("decoding signature: %v", err)
+	}
+	if !ed25519.Verify(publicKey, []byte(checksum), sig) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// writeSealedFile writes data to path, together with a sha256sum-compatible path.sha256 file
+// and, if a signing key is configured, the signature of the checksum in path.sig.
+func writeSealedFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+
+	checksum, signature := checksumAndSign(data)
+	if err := os.WriteFile(path+".sha256", []byte(checksum+"  "+filepath.Base(path)+"\n"), 0o644); err != nil {
+		return err
+	}
+	if signature != "" {
+		if err := os.WriteFile(path+".sig", []byte(signature+"\n"), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifySealedFile checks a file written by writeSealedFile.
+func verifySealedFile(path string, publicKey ed25519.PublicKey) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	checksumLine, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return fmt.Errorf("reading checksum: %v", err)
+	}
+	checksum, _, _ := strings.Cut(strings.TrimSpace(string(checksumLine)), " ")
+
+	signature := ""
+	if sig, err := os.ReadFile(path + ".sig"); err == nil {
+		signature = strings.TrimSpace(string(sig))
+	}
+
+	return verifyChecksum(data, checksum, signature, publicKey)
+}
+
+// runVerify implements the verify command, which checks the checksums and signatures
+// of the snapshots and of exported files:
+//
+//	deployment_tracking verify --snapshot-dir snapshots --public-key <base64>
+//	deployment_tracking verify --public-key <base64> report-2025-01.xlsx 2025-01-31.json
+func runVerify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	snapshotDir := flags.String("snapshot-dir", "", "Directory of snapshots to verify")
+	publicKeyStr := flags.String("public-key", "", "Base64 Ed25519 public key to check signatures with, derived from SNAPSHOT_SIGNING_KEY if empty (only checksums are checked without either)")
+	flags.Parse(args)
+
+	var publicKey ed25519.PublicKey
+	if *publicKeyStr != "" {
+		key, err := base64.StdEncoding.DecodeString(*publicKeyStr)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid --public-key")
+		}
+		publicKey = key
+	} else {
+		if err := loadSigningKey(); err != nil {
+			return err
+		}
+		if snapshotSigningKey != nil {
+			publicKey = snapshotSigningKey.Public().(ed25519.PublicKey)
+		}
+	}
+
+	failures := 0
+	if *snapshotDir != "" {
+		paths, err := filepath.Glob(filepath.Join(*snapshotDir, "*.jsonl"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			failures += verifySnapshotFile(path, publicKey)
+		}
+	}
+
+	// archives are sealed JSON objects, everything else has sidecar files
+	for _, path := range flags.Args() {
+		var err error
+		if strings.HasSuffix(path, ".json") {
+			var data []byte
+			if data, err = os.ReadFile(path); err == nil {
+				err = verifySealedJSON(bytes.TrimSpace(data), publicKey)
+			}
+		} else {
+			err = verifySealedFile(path, publicKey)
+		}
+		if err != nil {
+			log.Printf("%s: %v", path, err)
+			failures++
+		}
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%d verification failures", failures)
+	}
+	log.Printf("All checked data is intact")
+	return nil
+}
+
+// verifySnapshotFile checks every snapshot in a snapshot file and returns the number of failures.
+// Unsealed snapshots, recorded before checksums were introduced, are skipped, unless a public key
+// is given or they follow a sealed one: stripping the seal of a snapshot mustn't pass.
+func verifySnapshotFile(path string, publicKey ed25519.PublicKey) int {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("%s: %v", path, err)
+		return 1
+	}
+	defer f.Close()
+
+	failures, skipped := 0, 0
+	sealed := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if !bytes.Contains(scanner.Bytes(), []byte(sealSuffix)) {
+			if publicKey != nil || sealed {
+				log.Printf("%s:%d: snapshot is not sealed", path, line)
+				failures++
+			} else {
+				skipped++
+			}
+			continue
+		}
+		sealed = true
+		if err := verifySealedJSON(scanner.Bytes(), publicKey); err != nil {
+			log.Printf("%s:%d: %v", path, line, err)
+			failures++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("%s: %v", path, err)
+		failures++
+	}
+	if skipped > 0 {
+		log.Printf("%s: skipped %d unsealed snapshots from before checksums", path, skipped)
+	}
+
+	return failures
+}
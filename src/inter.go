@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// IST_DENOM is the denom of Inter Protocol's stable token on Agoric.
+const IST_DENOM = "uist"
+
+// InterVenuePositionConfig is a position in Inter Protocol on Agoric: either a vault, which locks
+// collateral to mint IST, or IST minted by swapping the anchor of a parity stability module (PSM).
+type InterVenuePositionConfig struct {
+	VenueMetadata
+
+	Address string
+	// VaultManager and VaultID identify a vault, e.g. manager0 and vault12, which is
+	// collateralized with CollateralDenom.
+	VaultManager    string
+	VaultID         string
+	CollateralDenom string
+	// PSMAnchor names the PSM of a PSM position, e.g. USDC_axl, whose anchor is AnchorDenom.
+	PSMAnchor   string
+	AnchorDenom string
+}
+
+func (venueConfig InterVenuePositionConfig) GetProtocol() Protocol {
+	return Inter
+}
+
+// GetPoolID returns the vault, e.g. manager0.vault12, or the PSM, e.g. psm.USDC_axl.
+func (venueConfig InterVenuePositionConfig) GetPoolID() string {
+	if venueConfig.PSMAnchor != "" {
+		return "psm." + venueConfig.PSMAnchor
+	}
+	return venueConfig.VaultManager + "." + venueConfig.VaultID
+}
+
+func (venueConfig InterVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type InterPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig InterVenuePositionConfig
+}
+
+func NewInterPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*InterPosition, error) {
+	interVenuePositionConfig, ok := venuePositionConfig.(InterVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of InterVenuePositionConfig type")
+	}
+
+	return &InterPosition{protocolConfig: config, venuePositionConfig: interVenuePositionConfig}, nil
+}
+
+// Vaults pay no rewards, their interest is added to the debt.
+func (p InterPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+// ComputeTVL returns the collateral locked in all the vaults of the manager, or the anchor held
+// by the PSM.
+func (p InterPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	config := p.venuePositionConfig
+
+	if config.PSMAnchor != "" {
+		var metrics struct {
+			AnchorPoolBalance interAmount `json:"anchorPoolBalance"`
+		}
+		if err := p.readVstorage(ctx, "published.psm.IST."+config.PSMAnchor+".metrics", &metrics); err != nil {
+			return nil, fmt.Errorf("querying PSM metrics: %s", err)
+		}
+		return interHoldings(ctx, assetData, []interBalance{{config.AnchorDenom, metrics.AnchorPoolBalance}})
+	}
+
+	var metrics struct {
+		TotalCollateral interAmount `json:"totalCollateral"`
+	}
+	if err := p.readVstorage(ctx, "published.vaultFactory.managers."+config.VaultManager+".metrics", &metrics); err != nil {
+		return nil, fmt.Errorf("querying vault manager metrics: %s", err)
+	}
+	return interHoldings(ctx, assetData, []interBalance{{config.CollateralDenom, metrics.TotalCollateral}})
+}
+
+// ComputeAddressPrincipalHoldings returns the collateral of the vault net of its debt, or the IST
+// held by the address of a PSM position.
+func (p InterPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	config := p.venuePositionConfig
+
+	if config.PSMAnchor != "" {
+		balance, err := p.getISTBalance(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		return interHoldings(ctx, assetData, []interBalance{{IST_DENOM, balance}})
+	}
+
+	var vault struct {
+		Locked       interAmount `json:"locked"`
+		DebtSnapshot struct {
+			Debt interAmount `json:"debt"`
+		} `json:"debtSnapshot"`
+	}
+	path := "published.vaultFactory.managers." + config.VaultManager + ".vaults." + config.VaultID
+	if err := p.readVstorage(ctx, path, &vault); err != nil {
+		return nil, fmt.Errorf("querying vault: %s", err)
+	}
+
+	// the debt reduces the value of the vault, so it is reported as a negative IST balance
+	debt := vault.DebtSnapshot.Debt
+	debt.Value = "-" + strings.TrimPrefix(debt.Value, "+")
+	return interHoldings(ctx, assetData, []interBalance{
+		{config.CollateralDenom, vault.Locked},
+		{IST_DENOM, debt},
+	})
+}
+
+func (p InterPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Inter")
+}
+
+// interAmount is an amount in Agoric's smallcaps encoding, e.g.
+// {"brand": "$0.Alleged: IST brand", "value": "+1000000"}.
+type interAmount struct {
+	Brand string `json:"brand"`
+	Value string `json:"value"`
+}
+
+// readVstorage decodes the latest value published at a path of Agoric's vstorage, which is
+// smallcaps-encoded capdata wrapped in a stream cell.
+func (p InterPosition) readVstorage(ctx context.Context, path string, target interface{}) error {
+	var response struct {
+		Value string `json:"value"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf("%s/agoric/vstorage/data/%s", p.protocolConfig.PoolInfoUrl, url.PathEscape(path)), &response); err != nil {
+		return err
+	}
+	if response.Value == "" {
+		return fmt.Errorf("no data published at %s", path)
+	}
+
+	var cell struct {
+		Values []string `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(response.Value), &cell); err != nil {
+		return fmt.Errorf("decoding stream cell: %v", err)
+	}
+	if len(cell.Values) == 0 {
+		return fmt.Errorf("no values published at %s", path)
+	}
+
+	var capData struct {
+		Body string `json:"body"`
+	}
+	if err := json.Unmarshal([]byte(cell.Values[len(cell.Values)-1]), &capData); err != nil {
+		return fmt.Errorf("decoding capdata: %v", err)
+	}
+	if !strings.HasPrefix(capData.Body, "#") {
+		return fmt.Errorf("unsupported capdata encoding at %s", path)
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(capData.Body, "#")), target); err != nil {
+		return fmt.Errorf("decoding capdata body: %v", err)
+	}
+	return nil
+}
+
+// getISTBalance returns the IST held by the address.
+func (p InterPosition) getISTBalance(ctx context.Context, address string) (interAmount, error) {
+	var response struct {
+		Balance *struct {
+			Amount string `json:"amount"`
+		} `json:"balance"`
+	}
+	balanceUrl := fmt.Sprintf("%s/%s/by_denom?denom=%s", p.protocolConfig.AddressBalanceUrl, address, IST_DENOM)
+	if err := getJSON(ctx, balanceUrl, &response); err != nil {
+		return interAmount{}, fmt.Errorf("querying IST balance: %s", err)
+	}
+	if response.Balance == nil {
+		return interAmount{}, fmt.Errorf("invalid balance structure")
+	}
+	return interAmount{Value: response.Balance.Amount}, nil
+}
+
+// interBalance is an amount of a denom.
+type interBalance struct {
+	denom  string
+	amount interAmount
+}
+
+// interHoldings values balances. Smallcaps prefixes the values with their sign.
+func interHoldings(ctx context.Context, assetData *ChainInfo, balances []interBalance) (*Holdings, error) {
+	holdings := &Holdings{Balances: []Asset{}}
+	for _, balance := range balances {
+		denom := balance.denom
+		amount, err := strconv.ParseFloat(strings.TrimPrefix(balance.amount.Value, "+"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse amount of %s into float64: %s", denom, err)
+		}
+
+		tokenInfo, err := assetData.GetTokenInfo(denom)
+		if err != nil {
+			return nil, err
+		}
+
+		adjustedAmount := amount / math.Pow(10, float64(tokenInfo.Decimals))
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute token values: %s", err)
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       denom,
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+	return holdings, nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// JobQueueSize bounds the number of queued jobs, beyond which new jobs are rejected.
+	JobQueueSize = 16
+	// JobRetention is how long finished jobs can still be polled.
+	JobRetention = 24 * time.Hour
+)
+
+// Job states.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRequest starts a job. Which of the parameters apply depends on the kind.
+type JobRequest struct {
+	Kind     string `json:"kind"`
+	BidIds   []int  `json:"bid_ids,omitempty"`  // refresh, reconcile: the bids to recompute or check, all by default
+	Protocol string `json:"protocol,omitempty"` // refresh: only the venues of this protocol, by name or slug
+	From     string `json:"from,omitempty"`     // archive: the first day, YYYY-MM-DD
+	To       string `json:"to,omitempty"`       // archive: the last day, yesterday by default
+}
+
+// JobProgress counts the steps of a job, e.g. the venues of a refresh.
+type JobProgress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Job is an expensive operation that runs in the background, so that clients poll for its
+// result rather than holding a request open.
+type Job struct {
+	ID         string      `json:"id"`
+	Request    JobRequest  `json:"request"`
+	Status     string      `json:"status"`
+	Progress   JobProgress `json:"progress"`
+	CreatedAt  time.Time   `json:"created_at"`
+	StartedAt  *time.Time  `json:"started_at"`
+	FinishedAt *time.Time  `json:"finished_at"`
+	Error      string      `json:"error,omitempty"`
+	Result     interface{} `json:"result,omitempty"`
+}
+
+// jobRunner runs a job, reporting its progress through the job, and returns its result.
+type jobRunner func(ctx context.Context, job *Job) (interface{}, error)
+
+// jobKinds are the jobs that can be started. Kinds that depend on the configuration are added on startup.
+var jobKinds = map[string]jobRunner{
+	"refresh":   runRefreshJob,
+	"preflight": runPreflightJob,
+	"reconcile": runReconcileJob,
+}
+
+var errJobQueueFull = errors.New("too many queued jobs")
+
+var (
+	jobsMu   sync.Mutex
+	jobs     = make(map[string]*Job)
+	jobQueue = make(chan *Job, JobQueueSize)
+)
+
+// startJobWorker runs the queued jobs one after the other.
+func startJobWorker() {
+	go func() {
+		for job := range jobQueue {
+			runJob(job)
+		}
+	}()
+}
+
+func runJob(job *Job) {
+	jobsMu.Lock()
+	started := time.Now()
+	job.Status = JobRunning
+	job.StartedAt = &started
+	jobsMu.Unlock()
+
+	result, err := jobKinds[job.Request.Kind](context.Background(), job)
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	finished := time.Now()
+	job.FinishedAt = &finished
+	job.Result = result
+	if err != nil {
+		job.Status = JobFailed
+		job.Error = err.Error()
+		log.Printf("Job %s (%s) failed: %v", job.ID, job.Request.Kind, err)
+	} else {
+		job.Status = JobSucceeded
+	}
+}
+
+// setJobProgress updates the progress of a running job.
+func setJobProgress(job *Job, done int, total int) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	job.Progress = JobProgress{Done: done, Total: total}
+}
+
+// enqueueJob queues a job, dropping the finished jobs past their retention.
+func enqueueJob(request JobRequest) (*Job, error) {
+	if _, ok := jobKinds[request.Kind]; !ok {
+		return nil, fmt.Errorf("unknown job kind: %q", request.Kind)
+	}
+	for _, bidId := range request.BidIds {
+		if _, ok := activeBids()[bidId]; !ok {
+			return nil, fmt.Errorf("unknown bid: %d", bidId)
+		}
+	}
+	if request.Protocol != "" {
+		if _, ok := parseProtocol(request.Protocol); !ok {
+			return nil, fmt.Errorf("unknown protocol: %s", request.Protocol)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	job := &Job{ID: hex.EncodeToString(id), Request: request, Status: JobQueued, CreatedAt: time.Now()}
+
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	for jobId, other := range jobs {
+		if other.FinishedAt != nil && time.Since(*other.FinishedAt) > JobRetention {
+			delete(jobs, jobId)
+		}
+	}
+
+	select {
+	case jobQueue <- job:
+	default:
+		return nil, errJobQueueFull
+	}
+	jobs[job.ID] = job
+	return job, nil
+}
+
+// getJob returns a copy of a job, so that it can be serialized while the job runs.
+func getJob(id string) (Job, bool) {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	job, ok := jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// listJobs returns copies of all jobs, newest first.
+func listJobs() []Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	result := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, *job)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result
+}
+
+// runRefreshJob recomputes the venues of the requested bids right away, optionally only the ones of
+// a protocol, and republishes the bids.
+func runRefreshJob(ctx context.Context, job *Job) (interface{}, error) {
+	bidIds := job.Request.BidIds
+	if len(bidIds) == 0 {
+		bidIds = sortedBidIds()
+	}
+	protocol, _ := parseProtocol(job.Request.Protocol)
+
+	venues := make(map[int][]VenuePositionConfig)
+	total := 0
+	for _, bidId := range bidIds {
+		if activeBids()[bidId].Frozen != nil {
+			continue
+		}
+		for _, venueConfig := range productionVenues(activeBids()[bidId]) {
+			if protocol == "" || venueConfig.GetProtocol() == protocol {
+				venues[bidId] = append(venues[bidId], venueConfig)
+				total++
+			}
+		}
+	}
+
+	done := 0
+	refreshedBids := 0
+	failedBids := []int{}
+	for _, bidId := range bidIds {
+		if len(venues[bidId]) == 0 {
+			continue
+		}
+		refreshedBids++
+
+		failed := false
+		for _, venueConfig := range venues[bidId] {
+			if _, err := refreshVenue(ctx, bidId, venueConfig); err != nil {
+				log.Printf("Refresh of venue %s failed: %v", venueID(bidId, venueConfig), err)
+				failed = true
+			}
+			done++
+			setJobProgress(job, done, total)
+		}
+
+		if !failed {
+			if _, err := publishBidHoldings(bidId); err != nil {
+				log.Printf("Refresh of bid %d failed: %v", bidId, err)
+				failed = true
+			}
+		}
+		if failed {
+			failedBids = append(failedBids, bidId)
+		}
+	}
+
+	result := map[string]interface{}{"venues": total, "failed_bids": failedBids}
+	if len(failedBids) > 0 {
+		return result, fmt.Errorf("%d of %d bids failed", len(failedBids), refreshedBids)
+	}
+	return result, nil
+}
+
+// runPreflightJob checks every active venue.
+func runPreflightJob(ctx context.Context, job *Job) (interface{}, error) {
+	setJobProgress(job, 0, 1)
+	results := runPreflight(ctx, PreflightTimeout)
+	setJobProgress(job, 1, 1)
+	return results, nil
+}
+
+// archiveJob uploads the daily archives of a range of days, e.g. to backfill days that failed.
+func archiveJob(store ObjectStore) jobRunner {
+	return func(ctx context.Context, job *Job) (interface{}, error) {
+		from, err := time.Parse(snapshotFileDateFormat, job.Request.From)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+		to := time.Now().UTC().Truncate(24 * time.Hour).Add(-24 * time.Hour)
+		if job.Request.To != "" {
+			if to, err = time.Parse(snapshotFileDateFormat, job.Request.To); err != nil {
+				return nil, fmt.Errorf("invalid to: %v", err)
+			}
+		}
+		if to.Before(from) {
+			return nil, fmt.Errorf("to is before from")
+		}
+
+		total := int(to.Sub(from)/(24*time.Hour)) + 1
+		for i := 0; i < total; i++ {
+			day := from.Add(time.Duration(i) * 24 * time.Hour)
+			if err := archiveDay(ctx, store, day); err != nil {
+				return nil, fmt.Errorf("archiving %s: %v", day.Format(snapshotFileDateFormat), err)
+			}
+			setJobProgress(job, i+1, total)
+		}
+		return nil, nil
+	}
+}
+
+// jobsHandler starts a job with a POST, answering with the job to poll, and lists the jobs with a GET.
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		writeJobJSON(w, http.StatusOK, listJobs())
+		return
+	}
+
+	var request JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "invalid job request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	startJob(w, r, request)
+}
+
+// refreshHandler queues a refresh of the venues of one protocol, e.g. once its upstream recovered:
+// POST /admin/refresh?protocol=osmosis.
+func refreshHandler(w http.ResponseWriter, r *http.Request) {
+	protocol := r.URL.Query().Get("protocol")
+	if protocol == "" {
+		http.Error(w, "protocol is required", http.StatusBadRequest)
+		return
+	}
+
+	startJob(w, r, JobRequest{Kind: "refresh", Protocol: protocol})
+}
+
+// startJob queues a job and answers with it.
+func startJob(w http.ResponseWriter, r *http.Request, request JobRequest) {
+	job, err := enqueueJob(request)
+	if errors.Is(err, errJobQueueFull) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Job %s (%s) queued by %s", job.ID, request.Kind, adminActor(r))
+
+	snapshot, _ := getJob(job.ID)
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	writeJobJSON(w, http.StatusAccepted, snapshot)
+}
+
+// jobHandler serves the status, progress and result of a job.
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	job, ok := getJob(mux.Vars(r)["job_id"])
+	if !ok {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	writeJobJSON(w, http.StatusOK, job)
+}
+
+func writeJobJSON(w http.ResponseWriter, status int, v interface{}) {
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(jsonData)
+}
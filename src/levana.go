@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// LevanaVenuePositionConfig is liquidity provided to a Levana perps market, on Osmosis or Neutron.
+// Liquidity providers hold LP tokens, or xLP tokens which they stake for a larger share of the
+// yield at the cost of a lockup.
+type LevanaVenuePositionConfig struct {
+	VenueMetadata
+
+	MarketAddress string // Contract address of the market, e.g. the ATOM_USD market
+	Address       string
+	Protocol      Protocol // Levana for the markets on Osmosis, LevanaNeutron for the ones on Neutron
+}
+
+func (venueConfig LevanaVenuePositionConfig) GetProtocol() Protocol {
+	if venueConfig.Protocol == "" {
+		return Levana
+	}
+	return venueConfig.Protocol
+}
+
+func (venueConfig LevanaVenuePositionConfig) GetPoolID() string {
+	return venueConfig.MarketAddress
+}
+
+func (venueConfig LevanaVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type LevanaPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig LevanaVenuePositionConfig
+}
+
+func NewLevanaPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*LevanaPosition, error) {
+	levanaVenuePositionConfig, ok := venuePositionConfig.(LevanaVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of LevanaVenuePositionConfig type")
+	}
+
+	return &LevanaPosition{protocolConfig: config, venuePositionConfig: levanaVenuePositionConfig}, nil
+}
+
+// The yield of the market is paid out separately, it has to be claimed.
+func (p LevanaPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
+// ComputeTVL returns the collateral of the liquidity pool of the market, locked in open positions
+// or not.
+func (p LevanaPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	status, err := p.getStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	locked, err := parseLevanaDecimal(status.Liquidity["locked"], "locked liquidity")
+	if err != nil {
+		return nil, err
+	}
+	unlocked, err := parseLevanaDecimal(status.Liquidity["unlocked"], "unlocked liquidity")
+	if err != nil {
+		return nil, err
+	}
+
+	return levanaCollateralHoldings(ctx, assetData, status.CollateralDenom, locked+unlocked)
+}
+
+// ComputeAddressPrincipalHoldings returns the collateral value of the LP and xLP tokens of the
+// address. The LP tokens include the xLP being unstaked, and the xLP tokens the ones unstaking.
+func (p LevanaPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	status, err := p.getStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lpInfo, err := p.getLpInfo(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	lpCollateral, err := parseLevanaDecimal(lpInfo["lp_collateral"], "LP collateral")
+	if err != nil {
+		return nil, err
+	}
+	xlpCollateral, err := parseLevanaDecimal(lpInfo["xlp_collateral"], "xLP collateral")
+	if err != nil {
+		return nil, err
+	}
+
+	return levanaCollateralHoldings(ctx, assetData, status.CollateralDenom, lpCollateral+xlpCollateral)
+}
+
+// ComputeAddressRewardHoldings returns the yield of the address that hasn't been claimed yet.
+func (p LevanaPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	status, err := p.getStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	lpInfo, err := p.getLpInfo(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	availableYield, err := parseLevanaDecimal(lpInfo["available_yield"], "available yield")
+	if err != nil {
+		return nil, err
+	}
+
+	return levanaCollateralHoldings(ctx, assetData, status.CollateralDenom, availableYield)
+}
+
+// levanaStatus is the part of the status of a market the venues are valued with.
+type levanaStatus struct {
+	CollateralDenom string
+	Liquidity       map[string]interface{}
+}
+
+func (p LevanaPosition) getStatus(ctx context.Context) (*levanaStatus, error) {
+	statusData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.MarketAddress, map[string]interface{}{
+			"status": map[string]interface{}{},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("querying market status: %s", err)
+	}
+
+	status, ok := statusData.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid market status structure")
+	}
+	liquidity, ok := status["liquidity"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid market liquidity structure")
+	}
+	collateral, ok := status["collateral"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid market collateral structure")
+	}
+
+	// the collateral is either a native token or a CW20 token
+	denom := ""
+	if native, ok := collateral["native"].(map[string]interface{}); ok {
+		denom, _ = native["denom"].(string)
+	} else if cw20, ok := collateral["cw20"].(map[string]interface{}); ok {
+		denom, _ = cw20["addr"].(string)
+	}
+	if denom == "" {
+		return nil, fmt.Errorf("unsupported market collateral: %v", collateral)
+	}
+
+	return &levanaStatus{CollateralDenom: denom, Liquidity: liquidity}, nil
+}
+
+func (p LevanaPosition) getLpInfo(ctx context.Context, address string) (map[string]interface{}, error) {
+	lpInfoData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.MarketAddress, map[string]interface{}{
+			"lp_info": map[string]interface{}{
+				"liquidity_provider": address,
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("querying LP info: %s", err)
+	}
+
+	lpInfo, ok := lpInfoData.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid LP info structure")
+	}
+	return lpInfo, nil
+}
+
+// parseLevanaDecimal parses an amount of the market, which is a decimal in whole collateral
+// tokens rather than in their base unit.
+func parseLevanaDecimal(v interface{}, name string) (float64, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid %s: %v", name, v)
+	}
+	amount, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s into float64: %s", name, err)
+	}
+	return amount, nil
+}
+
+// levanaCollateralHoldings values an amount of whole collateral tokens.
+func levanaCollateralHoldings(ctx context.Context, assetData *ChainInfo, denom string, amount float64) (*Holdings, error) {
+	tokenInfo, err := assetData.GetTokenInfo(denom)
+	if err != nil {
+		return nil, err
+	}
+
+	usdValue, atomValue, err := getTokenValues(ctx, amount, *tokenInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute token values: %s", err)
+	}
+
+	return &Holdings{
+		Balances: []Asset{
+			{
+				Denom:       denom,
+				Amount:      amount,
+				USDValue:    usdValue,
+				DisplayName: tokenInfo.Display,
+			},
+		},
+		TotalUSDC: usdValue,
+		TotalAtom: atomValue,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import "sort"
+
+// Bid lifecycle statuses, derived from the withdrawals and the venues of a bid.
+const (
+	BidStatusActive     = "active"     // some venue still holds funds of the bid
+	BidStatusWithdrawn  = "withdrawn"  // all funds were withdrawn
+	BidStatusCompounded = "compounded" // all funds were withdrawn, and some went on into other bids
+)
+
+var terminalVenuesSkippedMetric = newCounter("terminal_venues_skipped_total",
+	"Number of venue computations skipped because the venue is fully exited, by protocol.")
+
+// bidStatus returns the lifecycle status of a bid and, for compounded bids, the bids its funds
+// were compounded or transferred into. holdings may be nil if they couldn't be computed.
+func bidStatus(bidId int, bidConfig BidPositionConfig, holdings []VenueHoldings) (string, []int) {
+	if len(bidConfig.Withdrawals) == 0 && len(bidConfig.Transfers) == 0 {
+		return BidStatusActive, nil
+	}
+
+	computed := make(map[string]VenueHoldings, len(holdings))
+	for _, venueHoldings := range holdings {
+		computed[venueHoldings.VenueID] = venueHoldings
+	}
+	for _, venueConfig := range bidConfig.Venues {
+		if venueLive(bidId, venueConfig, computed) {
+			return BidStatusActive, nil
+		}
+	}
+
+	targets := make(map[int]bool)
+	for _, withdrawal := range bidConfig.Withdrawals {
+		for _, target := range withdrawal.CompoundingTargets() {
+			targets[target.BidId] = true
+		}
+	}
+	for _, transfer := range bidConfig.Transfers {
+		targets[transfer.ToBidId] = true
+	}
+	if len(targets) == 0 {
+		return BidStatusWithdrawn, nil
+	}
+
+	into := make([]int, 0, len(targets))
+	for bidId := range targets {
+		into = append(into, bidId)
+	}
+	sort.Ints(into)
+	return BidStatusCompounded, into
+}
+
+// venueTerminal returns whether a venue is known to be fully exited without querying it: it is
+// tracked by its active shares, which are zero, and funds left the bid through a withdrawal or a
+// transfer. Its upstreams aren't queried anymore.
+func venueTerminal(bidConfig BidPositionConfig, venueConfig VenuePositionConfig) bool {
+	sharesConfig, ok := venueConfig.(interface{ GetActiveShares() float64 })
+	if !ok || sharesConfig.GetActiveShares() != 0 {
+		return false
+	}
+	return len(bidConfig.Withdrawals) > 0 || len(bidConfig.Transfers) > 0
+}
+
+// venueLive returns whether a venue may still hold funds. Venues tracked by their active shares are
+// live as long as they have shares; the others as long as their principal holdings aren't empty.
+// Venues that weren't computed yet are assumed live, the ones without an integration are not.
+func venueLive(bidId int, venueConfig VenuePositionConfig, computed map[string]VenueHoldings) bool {
+	if venueConfig.GetMetadata().SupersededBy != "" || venueConfig.GetMetadata().Testnet {
+		return false
+	}
+	if sharesConfig, ok := venueConfig.(interface{ GetActiveShares() float64 }); ok {
+		return sharesConfig.GetActiveShares() != 0
+	}
+
+	venueHoldings, ok := computed[venueID(bidId, venueConfig)]
+	switch {
+	case !ok:
+		return true
+	case venueHoldings.InfoMissing:
+		return false
+	case venueHoldings.Pending || venueHoldings.AddressPrincipal == nil:
+		return true
+	}
+	return venueHoldings.AddressPrincipal.TotalAtom > 0
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// linkTemplates are the URL templates of the pages of a venue, by protocol and label. The
+// placeholders {address} and {pool} are replaced with the address and pool of the venue; links
+// whose placeholders the venue has no value for are left out.
+var linkTemplates = map[Protocol]map[string]string{
+	Osmosis: {
+		"explorer": "https://www.mintscan.io/osmosis/address/{address}",
+		"app":      "https://app.osmosis.zone/pool/{pool}",
+	},
+	AstroportNeutron: {
+		"explorer": "https://www.mintscan.io/neutron/address/{address}",
+		"app":      "https://app.astroport.fi/pools/{pool}?chainId=neutron-1",
+	},
+	AstroportTerra: {
+		"explorer": "https://www.mintscan.io/terra/address/{address}",
+		"app":      "https://app.astroport.fi/pools/{pool}?chainId=phoenix-1",
+	},
+	// the address of a Mars venue is its credit account
+	Mars: {
+		"app": "https://app.marsprotocol.io/neutron/portfolio/{address}",
+	},
+	Duality: {
+		"explorer": "https://www.mintscan.io/neutron/address/{address}",
+		"pool":     "https://www.mintscan.io/neutron/wasm/contract/{pool}",
+	},
+	Nolus: {
+		"explorer": "https://www.mintscan.io/nolus/address/{address}",
+		"pool":     "https://www.mintscan.io/nolus/wasm/contract/{pool}",
+	},
+	Magma: {
+		"explorer": "https://www.mintscan.io/osmosis/address/{address}",
+		"app":      "https://app.magma.eco/vault/{pool}",
+	},
+	Margined: {
+		"explorer": "https://www.mintscan.io/osmosis/address/{address}",
+		"vault":    "https://www.mintscan.io/osmosis/wasm/contract/{pool}",
+	},
+	MarginedNeutron: {
+		"explorer": "https://www.mintscan.io/neutron/address/{address}",
+		"vault":    "https://www.mintscan.io/neutron/wasm/contract/{pool}",
+	},
+	Drop: {
+		"explorer": "https://www.mintscan.io/neutron/address/{address}",
+		"app":      "https://app.drop.money",
+	},
+	Levana: {
+		"explorer": "https://www.mintscan.io/osmosis/address/{address}",
+		"market":   "https://www.mintscan.io/osmosis/wasm/contract/{pool}",
+	},
+	LevanaNeutron: {
+		"explorer": "https://www.mintscan.io/neutron/address/{address}",
+		"market":   "https://www.mintscan.io/neutron/wasm/contract/{pool}",
+	},
+	Staking: {
+		"explorer":  "https://www.mintscan.io/cosmos/address/{address}",
+		"validator": "https://www.mintscan.io/cosmos/validators/{pool}",
+	},
+	// the pool of a Uniswap v3 venue is its position NFT
+	UniswapV3: {
+		"explorer": "https://etherscan.io/address/{address}",
+		"app":      "https://app.uniswap.org/positions/v3/ethereum/{pool}",
+	},
+	UniswapV3Base: {
+		"explorer": "https://basescan.org/address/{address}",
+		"app":      "https://app.uniswap.org/positions/v3/base/{pool}",
+	},
+	CosmWasm: {
+		"explorer": "https://www.mintscan.io/osmosis/address/{address}",
+		"contract": "https://www.mintscan.io/osmosis/wasm/contract/{pool}",
+	},
+	CosmWasmNeutron: {
+		"explorer": "https://www.mintscan.io/neutron/address/{address}",
+		"contract": "https://www.mintscan.io/neutron/wasm/contract/{pool}",
+	},
+	Demex: {
+		"explorer": "https://www.mintscan.io/carbon/address/{address}",
+		"app":      "https://app.dem.exchange/pools/perp/{pool}",
+	},
+	Elys: {
+		"explorer": "https://www.mintscan.io/elys/address/{address}",
+		"app":      "https://app.elys.network/liquidity/{pool}",
+	},
+	Inter: {
+		"explorer": "https://www.mintscan.io/agoric/address/{address}",
+	},
+	Mito: {
+		"explorer": "https://explorer.injective.network/account/{address}",
+		"vault":    "https://explorer.injective.network/contract/{pool}",
+	},
+	Neptune: {
+		"explorer": "https://explorer.injective.network/account/{address}",
+	},
+	Pryzm: {
+		"explorer": "https://www.mintscan.io/pryzm/address/{address}",
+		"app":      "https://app.pryzm.zone/pools/{pool}",
+	},
+	Quasar: {
+		"explorer": "https://www.mintscan.io/osmosis/address/{address}",
+		"vault":    "https://www.mintscan.io/osmosis/wasm/contract/{pool}",
+	},
+	Shade: {
+		"explorer": "https://www.mintscan.io/secret/address/{address}",
+		"pool":     "https://www.mintscan.io/secret/wasm/contract/{pool}",
+	},
+	Ux: {
+		"explorer": "https://www.mintscan.io/umee/address/{address}",
+	},
+	WhiteWhale: {
+		"explorer": "https://www.mintscan.io/migaloo/address/{address}",
+		"pool":     "https://www.mintscan.io/migaloo/wasm/contract/{pool}",
+	},
+}
+
+// loadLinkTemplates replaces the link templates of the protocols in a JSON file, e.g.
+// {"Osmosis": {"app": "https://app.osmosis.zone/pool/{pool}"}}. An empty set of templates drops
+// the links of a protocol.
+func loadLinkTemplates(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading link templates: %v", err)
+	}
+
+	var templates map[Protocol]map[string]string
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return fmt.Errorf("decoding link templates: %v", err)
+	}
+
+	for protocol, protocolTemplates := range templates {
+		for label, template := range protocolTemplates {
+			if !strings.HasPrefix(template, "https://") && !strings.HasPrefix(template, "http://") {
+				return fmt.Errorf("link template %s of %s is not an http(s) URL", label, protocol)
+			}
+		}
+		linkTemplates[protocol] = protocolTemplates
+	}
+	return nil
+}
+
+// VenueLink is a page where a venue can be inspected, e.g. its pool in the app of the protocol.
+type VenueLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// venueLinks fills in the link templates of the protocol of a venue, sorted by label.
+func venueLinks(venueConfig VenuePositionConfig) []VenueLink {
+	templates := linkTemplates[venueConfig.GetProtocol()]
+	if len(templates) == 0 {
+		return nil
+	}
+
+	values := map[string]string{
+		"{address}": venueConfig.GetAddress(),
+		"{pool}":    venueConfig.GetPoolID(),
+	}
+
+	labels := make([]string, 0, len(templates))
+	for label := range templates {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var links []VenueLink
+	for _, label := range labels {
+		url, ok := fillLinkTemplate(templates[label], values)
+		if !ok {
+			continue
+		}
+		links = append(links, VenueLink{Label: label, URL: url})
+	}
+	return links
+}
+
+// fillLinkTemplate replaces the placeholders of a template, and returns false if one of them has
+// no value.
+func fillLinkTemplate(template string, values map[string]string) (string, bool) {
+	for placeholder, value := range values {
+		if !strings.Contains(template, placeholder) {
+			continue
+		}
+		if value == "" {
+			return "", false
+		}
+		template = strings.ReplaceAll(template, placeholder, value)
+	}
+	return template, true
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Date formats of exports, by the order of day, month and year.
+const (
+	DateFormatISO = "iso" // 2025-08-31, the default
+	DateFormatDMY = "dmy" // 31.08.2025
+	DateFormatMDY = "mdy" // 08/31/2025
+)
+
+// ExportLocale is how numbers and dates are written in exports, so that they open correctly in
+// spreadsheet apps configured for other locales.
+type ExportLocale struct {
+	DecimalSeparator string // "." or ","
+	DateFormat       string // one of the DateFormat constants
+}
+
+// defaultExportLocale keeps exports in the formats they had before locales were configurable.
+var defaultExportLocale = ExportLocale{DecimalSeparator: ".", DateFormat: DateFormatISO}
+
+// parseExportLocale checks the locale options of an export, with empty options left at their
+// defaults.
+func parseExportLocale(decimalSeparator string, dateFormat string) (ExportLocale, error) {
+	locale := defaultExportLocale
+	if decimalSeparator != "" {
+		if decimalSeparator != "." && decimalSeparator != "," {
+			return locale, fmt.Errorf("invalid decimal separator %q, expected . or ,", decimalSeparator)
+		}
+		locale.DecimalSeparator = decimalSeparator
+	}
+	if dateFormat != "" {
+		switch dateFormat {
+		case DateFormatISO, DateFormatDMY, DateFormatMDY:
+			locale.DateFormat = dateFormat
+		default:
+			return locale, fmt.Errorf("invalid date format %q, expected iso, dmy or mdy", dateFormat)
+		}
+	}
+	return locale, nil
+}
+
+// CSVSeparator returns the field separator of CSV exports. Locales with a decimal comma use
+// semicolons, like spreadsheet apps set to them expect.
+func (l ExportLocale) CSVSeparator() rune {
+	if l.DecimalSeparator == "," {
+		return ';'
+	}
+	return ','
+}
+
+// FormatNumber writes a number with all its digits and the decimal separator of the locale.
+func (l ExportLocale) FormatNumber(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if l.DecimalSeparator == "," {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// FormatTimestamp writes a time as RFC 3339 for iso, and otherwise as a date and time in UTC.
+func (l ExportLocale) FormatTimestamp(t time.Time) string {
+	switch l.DateFormat {
+	case DateFormatDMY:
+		return t.UTC().Format("02.01.2006 15:04:05")
+	case DateFormatMDY:
+		return t.UTC().Format("01/02/2006 15:04:05")
+	}
+	return t.Format(time.RFC3339)
+}
+
+// xlsxDateFormat returns the number format code of date cells. The decimal separator of numbers
+// in workbooks is up to the app opening them, as they are stored as numbers.
+func (l ExportLocale) xlsxDateFormat() string {
+	switch l.DateFormat {
+	case DateFormatDMY:
+		return "dd.mm.yyyy"
+	case DateFormatMDY:
+		return "mm/dd/yyyy"
+	}
+	return "yyyy-mm-dd"
+}
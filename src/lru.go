@@ -0,0 +1,123 @@
+package main
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+var (
+	cacheEntriesMetric = newGauge("cache_entries",
+		"Number of entries in an in-memory cache.")
+	cacheBytesMetric = newGauge("cache_bytes",
+		"Estimated size of the entries in an in-memory cache.")
+	cacheEvictionsMetric = newCounter("cache_evictions_total",
+		"Number of entries evicted from an in-memory cache to stay within its bounds.")
+)
+
+// lruCache is a cache bounded by the number of entries and their total size, evicting the least
+// recently used entries, safe for concurrent use. Entries expire after a TTL, and are dropped
+// when they are read or evicted.
+type lruCache struct {
+	name       string // for the metrics
+	mu         sync.Mutex
+	maxEntries int
+	maxBytes   int64 // 0 for no bound
+	ttl        time.Duration
+	bytes      int64
+	order      *list.List // front is the most recently used
+	entries    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key       string
+	value     interface{}
+	size      int64
+	expiresAt time.Time
+}
+
+func newLRUCache(name string, maxEntries int, maxBytes int64, ttl time.Duration) *lruCache {
+	return &lruCache{
+		name:       name,
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		ttl:        ttl,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*lruEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		c.updateMetrics()
+		return nil, false
+	}
+
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+// Set stores a value of the given size with the default TTL.
+func (c *lruCache) Set(key string, value interface{}, size int64) {
+	c.SetWithTTL(key, value, size, c.ttl)
+}
+
+func (c *lruCache) SetWithTTL(key string, value interface{}, size int64, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, size: size, expiresAt: time.Now().Add(ttl)})
+	c.bytes += size
+
+	// the new entry is kept even if it exceeds the byte bound on its own
+	for c.order.Len() > 1 && (c.order.Len() > c.maxEntries || (c.maxBytes > 0 && c.bytes > c.maxBytes)) {
+		c.remove(c.order.Back())
+		cacheEvictionsMetric.Add(1, "cache", c.name)
+	}
+	c.updateMetrics()
+}
+
+func (c *lruCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+		c.updateMetrics()
+	}
+}
+
+func (c *lruCache) remove(element *list.Element) {
+	entry := element.Value.(*lruEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+func (c *lruCache) updateMetrics() {
+	cacheEntriesMetric.Set(float64(c.order.Len()), "cache", c.name)
+	cacheBytesMetric.Set(float64(c.bytes), "cache", c.name)
+}
+
+// jsonSize estimates the memory used by a value by the size of its JSON encoding.
+func jsonSize(v interface{}) int64 {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
@@ -1,11 +1,131 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strconv"
 )
 
+// MagmaVenuePositionConfig is a deposit into a Magma vault on Osmosis, which manages concentrated
+// liquidity positions of a pair of tokens. Deposits are represented by the vault's CW20 shares.
+type MagmaVenuePositionConfig struct {
+	VenueMetadata
+
+	VaultAddress string // Contract address of the vault
+	Address      string
+	Token0Denom  string // denoms of the two tokens of the vault, in the order of its balances
+	Token1Denom  string
+	// ActiveShares is the vault share amount of the bid, if the address holds vault shares of
+	// several bids. If 0, all vault shares held by the address count.
+	ActiveShares float64
+}
+
+func (venueConfig MagmaVenuePositionConfig) GetProtocol() Protocol {
+	return Magma
+}
+
+func (venueConfig MagmaVenuePositionConfig) GetPoolID() string {
+	return venueConfig.VaultAddress
+}
+
+func (venueConfig MagmaVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type MagmaPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig MagmaVenuePositionConfig
+}
+
+func NewMagmaPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*MagmaPosition, error) {
+	magmaVenuePositionConfig, ok := venuePositionConfig.(MagmaVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of MagmaVenuePositionConfig type")
+	}
+
+	return &MagmaPosition{protocolConfig: config, venuePositionConfig: magmaVenuePositionConfig}, nil
+}
+
+// The vault compounds the fees of its positions into them.
+func (p MagmaPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+func (p MagmaPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	return p.vaultHoldings(ctx, assetData, 1)
+}
+
+// ComputeAddressPrincipalHoldings returns the share of the vault's tokens of the address's vault
+// shares.
+func (p MagmaPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	config := p.venuePositionConfig
+
+	shares := config.ActiveShares
+	if shares == 0 {
+		var err error
+		if shares, err = queryMagmaShares(ctx, p.protocolConfig.PoolInfoUrl, config.VaultAddress, address); err != nil {
+			return nil, err
+		}
+	}
+	if shares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	totalShares, err := queryMagmaTotalShares(ctx, p.protocolConfig.PoolInfoUrl, config.VaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.vaultHoldings(ctx, assetData, shares/totalShares)
+}
+
+func (p MagmaPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Magma")
+}
+
+// vaultHoldings values the given share of the tokens of the vault.
+func (p MagmaPosition) vaultHoldings(ctx context.Context, assetData *ChainInfo, share float64) (*Holdings, error) {
+	config := p.venuePositionConfig
+
+	bal0, bal1, err := queryMagmaVaultBalances(ctx, p.protocolConfig.PoolInfoUrl, config.VaultAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := &Holdings{Balances: []Asset{}}
+	for _, balance := range []struct {
+		denom  string
+		amount float64
+	}{{config.Token0Denom, bal0}, {config.Token1Denom, bal1}} {
+		tokenInfo, err := assetData.GetTokenInfo(balance.denom)
+		if err != nil {
+			return nil, err
+		}
+
+		adjustedAmount := balance.amount * share / math.Pow(10, float64(tokenInfo.Decimals))
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute token values: %s", err)
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       balance.denom,
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+
+	return holdings, nil
+}
+
 // MagmaDeploymentConfig holds the configuration for a Magma deployment
 type MagmaDeploymentConfig struct {
 	// The address whose holdings in the Magma vault we want to query.
@@ -13,9 +133,13 @@ type MagmaDeploymentConfig struct {
 	// The address of the Magma vault.
 	VaultAddress string
 	// The denom of the first asset in the vault.
-	token0Denom string
+	Token0Denom string
 	// The denom of the second asset in the vault.
-	token1Denom string
+	Token1Denom string
+	// The fees of the vault's manager, in percent: the annual management fee on the value of the
+	// vault and the performance fee on its earnings.
+	ManagementFeePercent  float64
+	PerformanceFeePercent float64
 }
 
 // MagmaHoldingsData represents the response from Magma's API
@@ -35,85 +159,36 @@ func NewMagmaQuerier(config MagmaDeploymentConfig) *MagmaQuerier {
 	}
 }
 
-func (m *MagmaQuerier) computeHoldings(assetData *ChainInfo) (*Holdings, error) {
+func (m *MagmaQuerier) computeHoldings(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	nodeURL := "https://osmosis-lcd.numia.xyz/cosmwasm/wasm/v1/contract/"
 
 	// 1. Query balance of vault shares
-	balanceQuery := map[string]interface{}{
-		"balance": map[string]interface{}{
-			"address": m.config.HolderAddress,
-		},
-	}
-
-	balanceData, err := QuerySmartContractData(nodeURL, m.config.VaultAddress, balanceQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query balance: %v", err)
-	}
-
-	balance, ok := balanceData.(map[string]interface{})["balance"].(string)
-	if !ok {
-		return nil, fmt.Errorf("invalid balance response format")
-	}
-
-	holderBalance, err := strconv.ParseFloat(balance, 64)
+	holderBalance, err := queryMagmaShares(ctx, nodeURL, m.config.VaultAddress, m.config.HolderAddress)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse holder balance: %v", err)
+		return nil, err
 	}
 
 	// 2. Query token info for total supply
-	tokenInfoQuery := map[string]interface{}{
-		"token_info": map[string]interface{}{},
-	}
-
-	tokenInfoData, err := QuerySmartContractData(nodeURL, m.config.VaultAddress, tokenInfoQuery)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query token info: %v", err)
-	}
-
-	tokenInfo, ok := tokenInfoData.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid token info response format")
-	}
-
-	totalSupply, err := strconv.ParseFloat(tokenInfo["total_supply"].(string), 64)
+	totalSupply, err := queryMagmaTotalShares(ctx, nodeURL, m.config.VaultAddress)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse total supply: %v", err)
+		return nil, err
 	}
 
 	// Calculate share ratio
 	shareRatio := holderBalance / totalSupply
 
 	// 3. Query vault balances
-	vaultBalancesQuery := map[string]interface{}{
-		"vault_balances": map[string]interface{}{},
-	}
-
-	vaultBalancesData, err := QuerySmartContractData(nodeURL, m.config.VaultAddress, vaultBalancesQuery)
+	bal0, bal1, err := queryMagmaVaultBalances(ctx, nodeURL, m.config.VaultAddress)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query vault balances: %v", err)
-	}
-
-	vaultBalances, ok := vaultBalancesData.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid vault balances response format")
-	}
-
-	bal0, err := strconv.ParseFloat(vaultBalances["bal0"].(string), 64)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse bal0: %v", err)
-	}
-
-	bal1, err := strconv.ParseFloat(vaultBalances["bal1"].(string), 64)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse bal1: %v", err)
+		return nil, err
 	}
 
 	// Calculate user's share of each asset
 	userBal0 := bal0 * shareRatio
 	userBal1 := bal1 * shareRatio
 
-	token0Denom := m.config.token0Denom
-	token1Denom := m.config.token1Denom
+	token0Denom := m.config.Token0Denom
+	token1Denom := m.config.Token1Denom
 
 	// Get token info for both assets
 	token0Info, err := assetData.GetTokenInfo(token0Denom)
@@ -131,12 +206,12 @@ func (m *MagmaQuerier) computeHoldings(assetData *ChainInfo) (*Holdings, error)
 	adjustedBal1 := userBal1 / math.Pow10(int(token1Info.Decimals))
 
 	// Get USD prices using Numia API
-	price0, err := getNumiaPrice(token0Denom)
+	price0, err := getNumiaPrice(ctx, token0Denom)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token0 price: %v", err)
 	}
 
-	price1, err := getNumiaPrice(token1Denom)
+	price1, err := getNumiaPrice(ctx, token1Denom)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token1 price: %v", err)
 	}
@@ -146,7 +221,7 @@ func (m *MagmaQuerier) computeHoldings(assetData *ChainInfo) (*Holdings, error)
 	usdValue1 := adjustedBal1 * price1
 
 	// Get ATOM price for conversion
-	atomPrice, err := getNumiaPrice("ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2")
+	atomPrice, err := getNumiaPrice(ctx, "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ATOM price: %v", err)
 	}
@@ -179,10 +254,104 @@ func (m *MagmaQuerier) computeHoldings(assetData *ChainInfo) (*Holdings, error)
 	return holdings, nil
 }
 
-func (m *MagmaQuerier) GetCurrentAddressHoldings(assetData *ChainInfo) (*Holdings, error) {
-	holdings, err := m.computeHoldings(assetData)
+func (m *MagmaQuerier) FeeRates() (float64, float64) {
+	return m.config.ManagementFeePercent, m.config.PerformanceFeePercent
+}
+
+func (m *MagmaQuerier) GetCurrentAddressHoldings(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	holdings, err := m.computeHoldings(ctx, assetData)
 	if err != nil {
 		debugLog("Error computing Magma holdings", map[string]string{"error": err.Error()})
 	}
 	return holdings, err
 }
+
+// queryMagmaShares returns the vault shares held by an address. The shares are a CW20 token of
+// the vault contract.
+func queryMagmaShares(ctx context.Context, nodeURL string, vaultAddress string, holderAddress string) (float64, error) {
+	balanceQuery := map[string]interface{}{
+		"balance": map[string]interface{}{
+			"address": holderAddress,
+		},
+	}
+
+	balanceData, err := QuerySmartContractData(ctx, nodeURL, vaultAddress, balanceQuery)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query balance: %v", err)
+	}
+
+	balanceMap, ok := balanceData.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("invalid balance response format")
+	}
+	balance, ok := balanceMap["balance"].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid balance response format")
+	}
+
+	holderBalance, err := strconv.ParseFloat(balance, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse holder balance: %v", err)
+	}
+	return holderBalance, nil
+}
+
+// queryMagmaTotalShares returns the total supply of the vault shares.
+func queryMagmaTotalShares(ctx context.Context, nodeURL string, vaultAddress string) (float64, error) {
+	tokenInfoQuery := map[string]interface{}{
+		"token_info": map[string]interface{}{},
+	}
+
+	tokenInfoData, err := QuerySmartContractData(ctx, nodeURL, vaultAddress, tokenInfoQuery)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query token info: %v", err)
+	}
+
+	tokenInfo, ok := tokenInfoData.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("invalid token info response format")
+	}
+	totalSupplyStr, ok := tokenInfo["total_supply"].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid token info response format")
+	}
+
+	totalSupply, err := strconv.ParseFloat(totalSupplyStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse total supply: %v", err)
+	}
+	if totalSupply == 0 {
+		return 0, fmt.Errorf("vault %s has no shares", vaultAddress)
+	}
+	return totalSupply, nil
+}
+
+// queryMagmaVaultBalances returns the amounts of the two tokens of the vault, in their base unit.
+func queryMagmaVaultBalances(ctx context.Context, nodeURL string, vaultAddress string) (float64, float64, error) {
+	vaultBalancesQuery := map[string]interface{}{
+		"vault_balances": map[string]interface{}{},
+	}
+
+	vaultBalancesData, err := QuerySmartContractData(ctx, nodeURL, vaultAddress, vaultBalancesQuery)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query vault balances: %v", err)
+	}
+
+	vaultBalances, ok := vaultBalancesData.(map[string]interface{})
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid vault balances response format")
+	}
+
+	bal0Str, _ := vaultBalances["bal0"].(string)
+	bal0, err := strconv.ParseFloat(bal0Str, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse bal0: %v", err)
+	}
+
+	bal1Str, _ := vaultBalances["bal1"].(string)
+	bal1, err := strconv.ParseFloat(bal1Str, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse bal1: %v", err)
+	}
+	return bal0, bal1, nil
+}
@@ -1,133 +1,338 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
 	"time"
 
 	"github.com/gorilla/mux"
-	"github.com/patrickmn/go-cache"
 )
 
 // Constants
 const (
-	Debug = true
 	BidId = 71
 )
 
-// Global cache instance (cache duration: 30 minutes)
-var resultCache *cache.Cache
+// verboseLogging enables debugLog. Use ?trace=true to inspect the computation of a single bid instead.
+var verboseLogging bool
+
+// resultCache holds the assembled results of bids and experimental deployments.
+var resultCache *lruCache
+
+// experimentalCacheKey caches the last /experimental response for public requests, next to the bids.
+const experimentalCacheKey = "experimental"
+
+// ExperimentalAssetListURL is the asset list the holdings of experimental deployments are valued
+// with. All of them are on Osmosis for now.
+const ExperimentalAssetListURL = "https://chains.cosmos.directory/osmosis"
 
 // --- Business Logic Layer ---
 
 // computeHoldings computes the holdings for a given bid.
-func computeHoldings(bidId int) ([]VenueHoldings, error) {
-	// get the config for the bid
-	bidConfig, ok := bidMap[bidId]
+func computeHoldings(ctx context.Context, bidId int) ([]VenueHoldings, error) {
+	bidConfig, ok := activeBids()[bidId]
 	if !ok {
 		return nil, fmt.Errorf("bid not found: %d", bidId)
 	}
 
-	// if there is a result not older than 30 minutes, return it
+	// frozen bids are no longer queried
+	if bidConfig.Frozen != nil {
+		return bidConfig.Frozen.Holdings, nil
+	}
+
+	// a bid stays cached until the first of its venues expires, see --venue-ttl
 	if cached, found := resultCache.Get(strconv.Itoa(bidId)); found {
 		return cached.([]VenueHoldings), nil
 	}
 
-	bidHoldings := make([]VenueHoldings, 0, len(bidConfig.Venues))
-
-	for _, venueConfig := range bidConfig.Venues {
-		// get the protocol config
-		protocolConfig := protocolConfigMap[venueConfig.GetProtocol()]
-
-		// construct the protocol
-		protocol, err := NewDexProtocolFromConfig(protocolConfig, venueConfig)
+	// public requests get the last computation of every venue, even if it expired
+	if isCachedOnly(ctx) {
+		holdings, _, err := assembleBidHoldings(bidId, time.Now())
 		if err != nil {
-			return nil, fmt.Errorf("error creating protocol: %w", err)
+			return nil, errNotCached
 		}
+		return holdings, nil
+	}
 
-		if _, ok := protocol.(*MissingPosition); ok {
-			venueHoldings := VenueHoldings{
-				InfoMissing:      true,
-				Protocol:         venueConfig.GetProtocol(),
-				VenueTotal:       nil,
-				AddressPrincipal: nil,
-				AddressRewards:   nil,
-			}
+	return refreshHoldings(ctx, bidId)
+}
+
+// refreshHoldings recomputes the venues of a bid that have expired and caches the result.
+func refreshHoldings(ctx context.Context, bidId int) ([]VenueHoldings, error) {
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return nil, fmt.Errorf("bid not found: %d", bidId)
+	}
+	if bidConfig.Frozen != nil {
+		return bidConfig.Frozen.Holdings, nil
+	}
 
-			bidHoldings = append(bidHoldings, venueHoldings)
+	if deadline, ok := requestDeadline(ctx); ok {
+		return refreshHoldingsUntil(ctx, bidId, deadline)
+	}
 
+	// compute all venues even if one fails, so that the status reports every failing venue
+	var firstErr error
+	now := time.Now()
+	for _, venueConfig := range productionVenues(bidConfig) {
+		if cached, ok := getCachedVenue(venueID(bidId, venueConfig)); ok && !cached.Stale && now.Before(cached.expiresAt()) {
 			continue
 		}
 
-		assetData, err := fetchAssetList(protocolConfig.AssetListURL)
-		if err != nil {
+		if _, err := refreshVenue(ctx, bidId, venueConfig); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return publishBidHoldings(bidId)
+}
+
+// computeVenueHoldings queries the TVL, principal and rewards of a single venue position.
+func computeVenueHoldings(ctx context.Context, bidId int, venueConfig VenuePositionConfig) (*VenueHoldings, error) {
+	// get the protocol config
+	protocolConfig, err := venueProtocolConfig(venueConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// construct the protocol
+	protocol, err := NewDexProtocolFromConfig(protocolConfig, venueConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating protocol: %w", err)
+	}
+
+	if _, ok := protocol.(*MissingPosition); ok {
+		return &VenueHoldings{
+			VenueID:          venueID(bidId, venueConfig),
+			Name:             venueConfig.GetMetadata().Name,
+			Description:      venueConfig.GetMetadata().Description,
+			DisplayName:      venueDisplayName(venueConfig, nil),
+			Links:            venueLinks(venueConfig),
+			ProtocolLogo:     protocolLogos[venueConfig.GetProtocol()],
+			Pool:             venuePoolComposition(venueConfig),
+			Supersedes:       venueConfig.GetMetadata().Supersedes,
+			SupersededBy:     venueConfig.GetMetadata().SupersededBy,
+			InfoMissing:      true,
+			Testnet:          venueConfig.GetMetadata().Testnet,
+			Protocol:         venueConfig.GetProtocol(),
+			VenueTotal:       nil,
+			AddressPrincipal: nil,
+			AddressRewards:   nil,
+		}, nil
+	}
+
+	// unsupported holdings are left nil, rather than empty
+	capabilities := protocol.Capabilities()
+
+	if venueTerminal(activeBids()[bidId], venueConfig) {
+		terminalVenuesSkippedMetric.Add(1, "protocol", string(venueConfig.GetProtocol()))
+		return &VenueHoldings{
+			VenueID:          venueID(bidId, venueConfig),
+			Name:             venueConfig.GetMetadata().Name,
+			Description:      venueConfig.GetMetadata().Description,
+			DisplayName:      venueDisplayName(venueConfig, nil),
+			Links:            venueLinks(venueConfig),
+			ProtocolLogo:     protocolLogos[venueConfig.GetProtocol()],
+			Pool:             venuePoolComposition(venueConfig),
+			Supersedes:       venueConfig.GetMetadata().Supersedes,
+			SupersededBy:     venueConfig.GetMetadata().SupersededBy,
+			Terminal:         true,
+			Testnet:          venueConfig.GetMetadata().Testnet,
+			Protocol:         venueConfig.GetProtocol(),
+			VenueTotal:       nil,
+			AddressPrincipal: &Holdings{Balances: []Asset{}},
+			AddressRewards:   nil,
+			Capabilities:     &capabilities,
+		}, nil
+	}
+
+	// protocols on EVM chains have no asset list, they resolve their tokens on chain
+	assetData := &ChainInfo{Tokens: map[string]ChainTokenInfo{}}
+	if protocolConfig.AssetListURL != "" {
+		if assetData, err = fetchAssetList(ctx, protocolConfig.AssetListURL); err != nil {
 			return nil, fmt.Errorf("error fetching asset list: %w", err)
 		}
+	}
 
-		tvl, err := protocol.ComputeTVL(assetData)
-		if err != nil {
+	var tvl, addressHoldings, rewardHoldings *Holdings
+
+	if capabilities.SupportsTVL {
+		if tvl, err = protocol.ComputeTVL(ctx, assetData); err != nil {
 			return nil, fmt.Errorf("error computing TVL: %w", err)
 		}
+	}
 
-		addressHoldings, err := protocol.ComputeAddressPrincipalHoldings(assetData, venueConfig.GetAddress())
-		if err != nil {
+	if capabilities.SupportsPrincipal {
+		if addressHoldings, err = protocol.ComputeAddressPrincipalHoldings(ctx, assetData, venueConfig.GetAddress()); err != nil {
 			return nil, fmt.Errorf("error computing address principal holdings: %w", err)
 		}
+	}
 
-		rewardHoldings, err := protocol.ComputeAddressRewardHoldings(assetData, venueConfig.GetAddress())
-		if err != nil {
+	if capabilities.SupportsRewards {
+		if rewardHoldings, err = protocol.ComputeAddressRewardHoldings(ctx, assetData, venueConfig.GetAddress()); err != nil {
 			return nil, fmt.Errorf("error computing address reward holdings: %w", err)
 		}
+	}
 
-		venueHoldings := VenueHoldings{
-			InfoMissing:      false,
-			Protocol:         venueConfig.GetProtocol(),
-			VenueTotal:       tvl,
-			AddressPrincipal: addressHoldings,
-			AddressRewards:   rewardHoldings,
-		}
+	// the protocols only value assets in USD
+	tvl.fillAtomValues()
+	addressHoldings.fillAtomValues()
+	rewardHoldings.fillAtomValues()
 
-		bidHoldings = append(bidHoldings, venueHoldings)
+	composition := tvl
+	if composition == nil {
+		composition = addressHoldings
 	}
 
-	// Cache the JSON result for 30 minutes.
-	resultCache.Set(strconv.Itoa(bidId), bidHoldings, cache.DefaultExpiration)
-
-	return bidHoldings, nil
+	return &VenueHoldings{
+		VenueID:          venueID(bidId, venueConfig),
+		Name:             venueConfig.GetMetadata().Name,
+		Description:      venueConfig.GetMetadata().Description,
+		DisplayName:      venueDisplayName(venueConfig, composition),
+		Links:            venueLinks(venueConfig),
+		ProtocolLogo:     protocolLogos[venueConfig.GetProtocol()],
+		Pool:             venuePoolComposition(venueConfig),
+		Supersedes:       venueConfig.GetMetadata().Supersedes,
+		SupersededBy:     venueConfig.GetMetadata().SupersededBy,
+		InfoMissing:      false,
+		Protocol:         venueConfig.GetProtocol(),
+		VenueTotal:       tvl,
+		AddressPrincipal: addressHoldings,
+		AddressRewards:   rewardHoldings,
+		RewardBreakdown:  rewardBreakdown(rewardHoldings),
+		Capabilities:     &capabilities,
+		Testnet:          venueConfig.GetMetadata().Testnet,
+	}, nil
 }
 
 // --- HTTP Handler Layer ---
 
 // holdingsHandler serves the computed holdings data.
 // It first checks the cache and, if a valid cached result exists,
-// returns that. Otherwise, it computes the result, caches it for 30 minutes, and returns it.
+// returns that. Otherwise, it recomputes the expired venues of the bid, which the venue cache keeps
+// for --venue-ttl, and caches the result until the first of them expires.
 func holdingsHandler(w http.ResponseWriter, r *http.Request) {
 	bidIdStr := mux.Vars(r)["bid_id"]
 
+	r, err := withRequestDeadline(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	trace := traceRequested(r)
+	if trace && !checkAdmin(w, r) {
+		return
+	}
+
 	// If no Bid ID is provided, return holdings of all bids
 	if bidIdStr == "" {
-		allHoldings := make([]BidHoldings, 0, len(bidMap))
+		if trace {
+			http.Error(w, "trace is only supported for a single bid", http.StatusBadRequest)
+			return
+		}
+
+		round := 0
+		if roundStr := r.URL.Query().Get("round"); roundStr != "" {
+			round, err = strconv.Atoi(roundStr)
+			if err != nil || round <= 0 {
+				http.Error(w, fmt.Sprintf("invalid round %q", roundStr), http.StatusBadRequest)
+				return
+			}
+		}
+
+		bids := activeBids()
+		allHoldings := make([]BidHoldings, 0, len(bids))
 
-		for bidId, bidConfig := range bidMap {
-			holdings, err := computeHoldings(bidId)
+		// the bids may have been computed at different ATOM prices
+		prices := currentPrices.Load()
+		for bidId, bidConfig := range bids {
+			inRound := bidRound(bidId, bidConfig)
+			if round != 0 && inRound != round {
+				continue
+			}
+
+			if frozen := bidConfig.Frozen; frozen != nil {
+				holdings, holdingsHash := withContentHashes(emptyIfNil(frozen.Holdings))
+				allHoldings = append(allHoldings, BidHoldings{
+					BidId:             bidId,
+					Name:              bidConfig.Name,
+					Description:       bidConfig.Description,
+					Round:             inRound,
+					Status:            frozen.Status,
+					CompoundedInto:    frozen.CompoundedInto,
+					InitialAllocation: totalAllocatedAtom(bidConfig),
+					Allocations:       bidConfig.Allocations,
+					NetDeployed:       bidNetDeployed(bidId, bidConfig, frozen.FrozenAt),
+					Holdings:          holdings,
+					Withdrawals:       withCompoundingTargets(bidConfig.Withdrawals),
+					Transfers:         bidTransfers(bidId),
+					Performance:       frozen.Performance,
+					LifetimeRewards:   frozen.LifetimeRewards,
+					FrozenAt:          &frozen.FrozenAt,
+					HoldingsHash:      holdingsHash,
+				})
+				continue
+			}
+
+			holdings, err := computeHoldings(r.Context(), bidId)
 			if err != nil {
 				debugLog(fmt.Sprintf("failed to compute holdings for bid ID: %d", bidId), nil)
 				holdings = nil
+			} else {
+				// a bid without venues has no holdings rather than unknown ones
+				holdings = emptyIfNil(holdings)
+			}
+			holdings = revalueAtom(holdings, prices)
+
+			performance := computeBidPerformance(bidId, bidConfig, holdings, time.Now())
+			if performance != nil {
+				performance.USD = computeUSDPerformance(r.Context(), bidId, bidConfig, holdings, performance)
 			}
 
-			allHoldings = append(allHoldings, BidHoldings{BidId: bidId, InitialAllocation: bidConfig.InitialAllocation, Holdings: holdings, Withdrawals: bidConfig.Withdrawals})
+			status, compoundedInto := bidStatus(bidId, bidConfig, holdings)
+			holdings, holdingsHash := withContentHashes(holdings)
+
+			allHoldings = append(allHoldings, BidHoldings{
+				BidId:             bidId,
+				Name:              bidConfig.Name,
+				Description:       bidConfig.Description,
+				Round:             inRound,
+				Status:            status,
+				CompoundedInto:    compoundedInto,
+				InitialAllocation: totalAllocatedAtom(bidConfig),
+				Allocations:       bidConfig.Allocations,
+				NetDeployed:       bidNetDeployed(bidId, bidConfig, time.Now()),
+				Holdings:          holdings,
+				Withdrawals:       withCompoundingTargets(bidConfig.Withdrawals),
+				Transfers:         bidTransfers(bidId),
+				Performance:       performance,
+				LifetimeRewards:   computeLifetimeRewards(bidConfig, holdings),
+				HoldingsHash:      holdingsHash,
+			})
 		}
 
-		jsonData, err := json.MarshalIndent(allHoldings, "", "  ")
+		for i := range allHoldings {
+			allHoldings[i].ContentHash = bidContentHash(allHoldings[i])
+		}
+
+		jsonData, err := marshalResponse(r, allHoldings)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		setPriceSnapshotHeaders(w, prices)
+
 		w.Header().Set("Content-Type", "application/json")
 		w.Write(jsonData)
 
@@ -140,64 +345,117 @@ func holdingsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Compute holdings.
-	holdings, err := computeHoldings(bidId)
+	// Compute holdings, or recompute them from scratch if a trace is requested.
+	var holdings []VenueHoldings
+	if trace {
+		holdings, err = traceHoldings(r.Context(), bidId)
+	} else {
+		holdings, err = computeHoldings(r.Context(), bidId)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeComputeError(w, err)
 		return
 	}
 
-	// Marshal holdings to JSON.
-	jsonData, err := json.MarshalIndent(holdings, "", "  ")
+	// Marshal holdings to JSON. Frozen bids keep the prices they were frozen at.
+	prices := currentPrices.Load()
+	if activeBids()[bidId].Frozen == nil {
+		holdings = revalueAtom(holdings, prices)
+	}
+	holdings, holdingsHash := withContentHashes(emptyIfNil(holdings))
+	etag := `"` + holdingsHash + `"`
+	if !trace && r.Header.Get("If-None-Match") == etag {
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	jsonData, err := marshalResponse(r, holdings)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	setPriceSnapshotHeaders(w, prices)
+	if !trace {
+		w.Header().Set("ETag", etag)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(jsonData)
 }
 
 // experimentalHandler serves data about experimental deployments
 func experimentalHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	// public requests get the last computed response
+	if isCachedOnly(ctx) {
+		cached, found := resultCache.Get(experimentalCacheKey)
+		if !found {
+			writeComputeError(w, errNotCached)
+			return
+		}
+		writeExperimentalResponse(w, r, cached.([]ExperimentalDeploymentResponse))
+		return
+	}
+
 	// Get asset data for computing holdings
-	assetData, err := fetchAssetList("https://chains.cosmos.directory/osmosis") // Using Osmosis for now
+	assetData, err := fetchAssetList(ctx, ExperimentalAssetListURL)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error fetching asset list: %v", err), http.StatusInternalServerError)
 		return
 	}
 
 	// If no ID provided, return all experimental deployments
-	allDeployments := make([]ExperimentalDeploymentResponse, 0, len(experimentalMap))
-	for _, deployment := range experimentalMap {
-		// Compute current holdings for each deployment
-		currentHoldings, err := deployment.Querier.GetCurrentAddressHoldings(assetData)
-		if err != nil {
-			debugLog(fmt.Sprintf("Error computing holdings for deployment %d: %v", deployment.ExperimentalId, err), nil)
-			currentHoldings = nil
-		}
+	deployments := activeExperimental()
+	allDeployments := make([]ExperimentalDeploymentResponse, 0, len(deployments))
+	for _, deployment := range deployments {
+		allDeployments = append(allDeployments, computeExperimentalResponse(ctx, assetData, deployment))
+	}
+	resultCache.Set(experimentalCacheKey, allDeployments, jsonSize(allDeployments))
+
+	writeExperimentalResponse(w, r, allDeployments)
+}
 
-		// Compute initial holdings with prices at deployment time
-		initialHoldingsWithPrices, err := ComputeInitialHoldingsWithPrices(deployment.InitialAddressHoldings, assetData, deployment.StartTimestamp)
+// computeExperimentalResponse computes the current holdings and the return of an experimental
+// deployment.
+func computeExperimentalResponse(ctx context.Context, assetData *ChainInfo, deployment *ExperimentalDeployment) ExperimentalDeploymentResponse {
+	currentHoldings, err := deployment.Querier.GetCurrentAddressHoldings(ctx, assetData)
+	if err != nil {
+		debugLog(fmt.Sprintf("Error computing holdings for deployment %d: %v", deployment.ExperimentalId, err), nil)
+		currentHoldings = nil
+	}
+
+	// Serve the initial holdings valued on registration, or compute them with prices at deployment time
+	initialHoldingsWithPrices := deployment.InitialAddressHoldings
+	if deployment.InitialValuedAt == 0 {
+		initialHoldingsWithPrices, err = ComputeInitialHoldingsWithPrices(ctx, deployment.InitialAddressHoldings, assetData, deployment.StartTimestamp)
 		if err != nil {
 			debugLog(fmt.Sprintf("Error computing initial holdings with prices for deployment %d: %v", deployment.ExperimentalId, err), nil)
 			initialHoldingsWithPrices = deployment.InitialAddressHoldings
 		}
+	}
 
-		response := ExperimentalDeploymentResponse{
-			ExperimentalId:         deployment.ExperimentalId,
-			Name:                   deployment.Name,
-			Description:            deployment.Description,
-			Logo:                   deployment.Logo,
-			StartTimestamp:         deployment.StartTimestamp,
-			EndTimestamp:           deployment.EndTimestamp,
-			InitialAddressHoldings: initialHoldingsWithPrices,
-			CurrentAddressHoldings: currentHoldings,
-		}
-		allDeployments = append(allDeployments, response)
+	ret := computeExperimentalReturn(ctx, assetData, deployment, initialHoldingsWithPrices, currentHoldings)
+	return ExperimentalDeploymentResponse{
+		ExperimentalId:         deployment.ExperimentalId,
+		Name:                   deployment.Name,
+		Description:            deployment.Description,
+		Logo:                   deployment.Logo,
+		StartTimestamp:         deployment.StartTimestamp,
+		EndTimestamp:           deployment.EndTimestamp,
+		InitialAddressHoldings: initialHoldingsWithPrices,
+		CurrentAddressHoldings: currentHoldings,
+		Deposits:               deployment.Deposits,
+		Withdrawals:            deployment.Withdrawals,
+		Return:                 ret,
+		VaultFees:              computeVaultFees(deployment, ret, time.Now()),
 	}
+}
 
-	jsonData, err := json.MarshalIndent(allDeployments, "", "  ")
+func writeExperimentalResponse(w http.ResponseWriter, r *http.Request, allDeployments []ExperimentalDeploymentResponse) {
+	jsonData, err := marshalResponse(r, allDeployments)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -209,16 +467,123 @@ func experimentalHandler(w http.ResponseWriter, r *http.Request) {
 // --- Main / Server Bootstrap ---
 
 func main() {
+	// subcommands for offline use of the snapshots
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			if err := runExport(os.Args[2:]); err != nil {
+				log.Fatalf("Export failed: %v", err)
+			}
+			return
+		case "report":
+			if err := runReport(os.Args[2:]); err != nil {
+				log.Fatalf("Report failed: %v", err)
+			}
+			return
+		case "config":
+			if err := runConfig(os.Args[2:]); err != nil {
+				log.Fatalf("Config command failed: %v", err)
+			}
+			return
+		case "verify":
+			if err := runVerify(os.Args[2:]); err != nil {
+				log.Fatalf("Verification failed: %v", err)
+			}
+			return
+		case "secrets":
+			if err := runSecrets(os.Args[2:]); err != nil {
+				log.Fatalf("Secrets command failed: %v", err)
+			}
+			return
+		}
+	}
+
 	// Define the --debug flag.
 	debug := flag.Bool("debug", false, "Run the endpoint once for testing")
+	flag.BoolVar(&verboseLogging, "verbose", false, "Log every upstream query and intermediate result")
+	skipPreflight := flag.Bool("skip-preflight", false, "Don't check all venues on startup")
+	roundsFile := flag.String("rounds-file", "", "JSON file with the Hydro round calendar, replacing the built-in one")
+	brandingFile := flag.String("branding", "", "JSON file with logo URLs of protocols and assets, added to the built-in ones")
+	linkTemplatesFile := flag.String("link-templates", "", "JSON file with per-protocol URL templates of the explorer and app links of venues, replacing the built-in ones")
+	snapshotDir := flag.String("snapshot-dir", "snapshots", "Directory to store holdings snapshots in (empty disables snapshots)")
+	snapshotRetention := flag.String("snapshot-retention", DefaultSnapshotRetention, "Resolutions and ages snapshots are kept at, e.g. all=7d,hourly=30d,daily=forever (empty keeps all snapshots forever)")
+	auditLogPath := flag.String("audit-log", "audit.jsonl", "File to record config changes through the admin API in (empty disables the audit log)")
+	refreshInterval := flag.Duration("refresh-interval", DefaultRefreshInterval, "Interval of the background refresh of all bids (0 disables it)")
+	flag.DurationVar(&venueTTL, "venue-ttl", DefaultVenueTTL, "How long the computation of a venue is served before it is recomputed")
+	flag.DurationVar(&refreshStallTimeout, "refresh-stall-timeout", DefaultRefreshStallTimeout, "Time without progress after which a refresh cycle is cancelled and restarted (0 disables the watchdog)")
+	protocolBudgets := flag.String("protocol-budgets", "", "Per-protocol timeouts and latency budgets, e.g. osmosis=30s/10s,mars=45s")
+	upstreamRPS := flag.String("upstream-rps", "", "Per-domain rate limits of upstream requests, e.g. polkachu.com=5,api.coingecko.com=0.5/3")
+	upstreamProxy := flag.String("upstream-proxy", os.Getenv("UPSTREAM_PROXY_URL"), "HTTP(S) proxy for upstream requests (default $UPSTREAM_PROXY_URL)")
+	configStorePath := flag.String("config", os.Getenv("CONFIG_STORE"), "JSON or YAML file, or postgres:// or sqlite: database URL, to load the bid configs from instead of the code (see the config command) (default $CONFIG_STORE)")
+	flag.StringVar(configStorePath, "config-store", os.Getenv("CONFIG_STORE"), "Deprecated alias of --config")
+	strictValidation := flag.Bool("strict-validation", false, "Refuse to start on warnings about the bid configs too, not only on errors")
+	configReload := flag.Duration("config-reload", 30*time.Second, "Interval of checking --config for changes to reload (0 reloads only on SIGHUP)")
+	publicCachedOnly := flag.Bool("public-cached-only", false, "Serve requests without the admin token only from the cache, rate limited per client")
+	publicRateLimitStr := flag.String("public-rate-limit", "1/20", "Per-client rate limit of public requests with --public-cached-only, <rps>[/<burst>]")
+	secrets := flag.String("secrets", "", "Source of tokens and keys, overriding the environment: file:<path>, vault:<mount>/<path> or aws:<secret id>")
+	secretsRefresh := flag.Duration("secrets-refresh", 5*time.Minute, "Interval of reloading the secrets source to pick up rotated secrets (0 reloads only on SIGHUP)")
+	flag.Float64Var(&poolShareAlertPercent, "pool-share-alert", poolShareAlertPercent, "Alert if our principal exceeds this percentage of a venue's TVL (0 disables it)")
+	flag.Float64Var(&allocationTolerancePercent, "allocation-tolerance", allocationTolerancePercent, "Alert if the principal of a new bid is off its allocation by more than this percentage (0 disables it)")
+	flag.DurationVar(&maxRequestDeadline, "max-request-deadline", maxRequestDeadline, "Upper bound of the deadlines requested with the X-Deadline-Ms header")
+	resultCacheEntries := flag.Int("result-cache-entries", 1000, "Maximum number of results kept in memory")
+	resultCacheMB := flag.Int64("result-cache-mb", 256, "Maximum estimated size of the results kept in memory, in MiB (0 for no bound)")
+	maxUpstreamResponseMB := flag.Int64("max-upstream-response-mb", DefaultMaxUpstreamResponseMB, "Maximum size of an upstream response, in MiB, beyond which the request fails (0 for no bound)")
+	warmUpEnabled := flag.Bool("warm-up", false, "Compute all bids on startup before /readyz reports ready")
+	warmUpParallelism := flag.Int("warm-up-parallelism", 4, "Number of bids computed concurrently during the warm-up")
+	warmUpTimeout := flag.Duration("warm-up-timeout", 5*time.Minute, "Time after which the instance reports ready even if the warm-up hasn't finished")
+	upstreamFailover := flag.Bool("upstream-failover", false, "Reroute requests to dead upstream hosts to a replacement from the chain registry")
+	profilesPath := flag.String("profiles", "", "JSON file of environment profiles by name, with endpoints, logging and bid subsets")
+	profileName := flag.String("profile", os.Getenv("DEPLOYMENT_PROFILE"), "Environment profile to run with, e.g. staging (default $DEPLOYMENT_PROFILE or prod)")
+	freezeBidsAfter := flag.Duration("freeze-bids-after", DefaultFreezeAfter, "Time after the last withdrawal of a fully withdrawn bid after which its final summary is frozen into the --config store and its venues are no longer queried (0 disables it)")
+	userAgent := flag.String("user-agent", os.Getenv("UPSTREAM_USER_AGENT"), "User-Agent sent to upstreams, ideally with contact info (default $UPSTREAM_USER_AGENT or a built-in one)")
 	flag.Parse()
 
-	// Initialize the in-memory cache with a 30-minute expiration and a 10-minute cleanup interval.
-	resultCache = cache.New(30*time.Minute, 10*time.Minute)
+	if *secrets != "" {
+		source, err := parseSecretsSource(*secrets)
+		if err != nil {
+			log.Fatalf("Error configuring the secrets source: %v", err)
+		}
+		secretsSource = source
+		if err := loadSecrets(); err != nil {
+			log.Fatalf("Error loading secrets: %v", err)
+		}
+		startSecretsRotation(*secretsRefresh)
+	}
+
+	if numiaAuthToken() == "" {
+		log.Fatal("NUMIA_API_TOKEN must be set")
+	}
+
+	if venueTTL <= 0 {
+		log.Fatal("--venue-ttl must be positive")
+	}
+	if *refreshInterval >= venueTTL {
+		log.Printf("Warning: --refresh-interval %s is not shorter than --venue-ttl %s, requests will recompute expired venues", *refreshInterval, venueTTL)
+	}
+
+	if err := configureUpstreamClient(*upstreamProxy, *userAgent); err != nil {
+		log.Fatalf("Error configuring upstream client: %v", err)
+	}
+	maxUpstreamResponseBytes = *maxUpstreamResponseMB << 20
+
+	profile, err := loadProfile(*profilesPath, *profileName)
+	if err != nil {
+		log.Fatalf("Error loading the profile: %v", err)
+	}
+	if err := applyProfileSettings(profile); err != nil {
+		log.Fatalf("Error applying the profile: %v", err)
+	}
+	if *profileName != "" {
+		activeProfileName = *profileName
+	}
+
+	// bids are cached until their first venue expires, experimental deployments for the venue TTL
+	resultCache = newLRUCache("results", *resultCacheEntries, *resultCacheMB<<20, venueTTL)
 
 	// If the --debug flag is provided, run the endpoint logic once and exit.
 	if *debug {
-		holdings, err := computeHoldings(BidId)
+		verboseLogging = true
+		holdings, err := computeHoldings(context.Background(), BidId)
 		if err != nil {
 			log.Fatalf("Error computing holdings: %v", err)
 		}
@@ -230,12 +595,191 @@ func main() {
 		return
 	}
 
+	if err := loadSigningKey(); err != nil {
+		log.Fatalf("Error loading the signing key: %v", err)
+	}
+
+	if *roundsFile != "" {
+		if err := loadRoundCalendar(*roundsFile); err != nil {
+			log.Fatalf("Error loading round calendar: %v", err)
+		}
+	}
+
+	if *linkTemplatesFile != "" {
+		if err := loadLinkTemplates(*linkTemplatesFile); err != nil {
+			log.Fatalf("Error loading link templates: %v", err)
+		}
+	}
+
+	if *brandingFile != "" {
+		if err := loadBranding(*brandingFile); err != nil {
+			log.Fatalf("Error loading branding: %v", err)
+		}
+	}
+
+	if err := parseProtocolBudgets(*protocolBudgets); err != nil {
+		log.Fatalf("Error parsing protocol budgets: %v", err)
+	}
+
+	if err := parseUpstreamRateLimits(*upstreamRPS); err != nil {
+		log.Fatalf("Error parsing upstream rate limits: %v", err)
+	}
+	upstreamFailoverEnabled = *upstreamFailover
+	startEndpointMonitor()
+
+	if *publicCachedOnly {
+		if err := parsePublicRateLimit(*publicRateLimitStr); err != nil {
+			log.Fatalf("Error parsing the public rate limit: %v", err)
+		}
+		startPublicTier()
+	}
+
+	var configStore ConfigStore
+	if *configStorePath != "" {
+		if configStore, err = newConfigStore(*configStorePath); err != nil {
+			log.Fatalf("Error opening the config store: %v", err)
+		}
+		if err := loadBidsFromStore(configStore); err != nil {
+			log.Fatalf("Error loading the config store: %v", err)
+		}
+		if err := loadExperimentalFromStore(configStore); err != nil {
+			log.Fatalf("Error loading the experimental deployments from the config store: %v", err)
+		}
+		configSource = "file"
+		if isDatabaseURL(*configStorePath) {
+			configSource = "database"
+		}
+		if !isYAMLPath(*configStorePath) {
+			adminConfigStore = configStore
+		}
+	}
+
+	bids, err := profileBids(profile, activeBids())
+	if err != nil {
+		log.Fatalf("Error applying the profile: %v", err)
+	}
+	setActiveBids(bids)
+
+	if errs := validateBidConfigs(activeBids()); len(errs) > 0 {
+		for _, err := range errs {
+			log.Printf("Invalid bid config: %v", err)
+		}
+		log.Fatalf("Found %d errors in the bid configs", len(errs))
+	}
+	if warnings := logBidConfigWarnings(activeBids()); len(warnings) > 0 && *strictValidation {
+		log.Fatalf("Found %d warnings in the bid configs, refusing to start with --strict-validation", len(warnings))
+	}
+
+	if configStore != nil {
+		startBidsReload(configStore, profile, *configReload)
+	}
+
+	if err := clusterFromEnv(); err != nil {
+		log.Fatalf("Error configuring the shared cache: %v", err)
+	}
+	startLeaderElection()
+
+	if *snapshotDir != "" {
+		store, err := NewSnapshotStore(*snapshotDir)
+		if err != nil {
+			log.Fatalf("Error opening snapshot store: %v", err)
+		}
+		snapshotStore = store
+
+		if priceLedger, err = NewPriceLedger(*snapshotDir); err != nil {
+			log.Fatalf("Error loading the price ledger: %v", err)
+		}
+
+		tiers, err := parseSnapshotRetention(*snapshotRetention)
+		if err != nil {
+			log.Fatalf("Error parsing the snapshot retention: %v", err)
+		}
+		startSnapshotCompaction(store, tiers)
+	}
+
+	if *auditLogPath != "" {
+		store, err := NewAuditLog(*auditLogPath)
+		if err != nil {
+			log.Fatalf("Error opening the audit log: %v", err)
+		}
+		auditLog = store
+	}
+
+	archiveStore, err := archiveStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring the archive bucket: %v", err)
+	}
+	if archiveStore != nil {
+		if snapshotStore == nil {
+			log.Fatalf("Archiving requires snapshots to be enabled")
+		}
+		startArchiver(archiveStore)
+		jobKinds["archive"] = archiveJob(archiveStore)
+	}
+	startJobWorker()
+
+	if *freezeBidsAfter > 0 && adminConfigStore != nil {
+		startFreezer(*freezeBidsAfter)
+	}
+
+	if analyticsSink := analyticsSinkFromEnv(); analyticsSink != nil {
+		startAnalyticsSink(analyticsSink)
+	}
+
+	// Check every active venue in the background so that broken configs show up in the logs right away.
+	if !*skipPreflight {
+		go logPreflight()
+	}
+
+	if *warmUpEnabled {
+		if *warmUpParallelism < 1 {
+			log.Fatalf("--warm-up-parallelism must be at least 1")
+		}
+		go warmUp(*warmUpParallelism, *warmUpTimeout)
+	} else {
+		ready.Store(true)
+	}
+
+	if *refreshInterval > 0 {
+		startRefresher(*refreshInterval)
+	}
+
 	router := mux.NewRouter()
 
 	// Register the endpoints.
-	router.HandleFunc("/holdings/", holdingsHandler)
-	router.HandleFunc("/holdings/{bid_id}", holdingsHandler)
-	router.HandleFunc("/experimental", experimentalHandler)
+	router.HandleFunc("/holdings/", publicTier(holdingsHandler))
+	router.HandleFunc("/holdings/{bid_id}", publicTier(holdingsHandler))
+	router.HandleFunc("/testnet/holdings", publicTier(testnetHoldingsHandler))
+	router.HandleFunc("/testnet/holdings/{bid_id}", publicTier(testnetHoldingsHandler))
+	router.HandleFunc("/experimental", publicTier(experimentalHandler))
+	router.HandleFunc("/experimental/{experimental_id}/compare", publicTier(experimentalCompareHandler))
+	router.HandleFunc("/venues/{venue_id}/history", publicTier(venueHistoryHandler))
+	router.HandleFunc("/venues/{venue_id}/tvl_history", publicTier(venueTVLHistoryHandler))
+	router.HandleFunc("/venues/{venue_id}/accrual", publicTier(venueAccrualHandler))
+	router.HandleFunc("/risk", publicTier(riskHandler))
+	router.HandleFunc("/prices", publicTier(pricesHandler))
+	router.HandleFunc("/rounds", publicTier(roundsHandler))
+	router.HandleFunc("/protocols", publicTier(protocolsHandler))
+	router.HandleFunc("/venues", publicTier(venuesHandler))
+	router.HandleFunc("/branding", publicTier(brandingHandler))
+	router.HandleFunc("/reports/monthly", publicTier(monthlyReportHandler))
+	router.HandleFunc("/status", publicTier(statusHandler))
+	router.HandleFunc("/metrics", metricsHandler)
+	router.HandleFunc("/readyz", readyzHandler)
+	router.HandleFunc("/admin/preflight", requireAdmin(preflightHandler))
+	router.HandleFunc("/admin/audit", requireAdmin(auditHandler))
+	router.HandleFunc("/config", requireAdmin(configHandler))
+	router.HandleFunc("/admin/refresh", requireAdmin(idempotent(refreshHandler))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/bids", requireAdmin(idempotent(createBidHandler))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/bids/{bid_id}", requireAdmin(adminBidHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/bids/{bid_id}", requireAdmin(idempotent(updateBidHandler))).Methods(http.MethodPut, http.MethodDelete)
+	router.HandleFunc("/admin/bids/{bid_id}/withdrawals", requireAdmin(idempotent(addWithdrawalHandler))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/bids/{bid_id}/venues/{venue_id}", requireAdmin(idempotent(updateVenueHandler))).Methods(http.MethodPatch)
+	router.HandleFunc("/admin/experimental", requireAdmin(idempotent(createExperimentalHandler))).Methods(http.MethodPost)
+	router.HandleFunc("/admin/experimental/{experimental_id}", requireAdmin(adminExperimentalHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/admin/experimental/{experimental_id}", requireAdmin(idempotent(updateExperimentalHandler))).Methods(http.MethodPut, http.MethodDelete)
+	router.HandleFunc("/jobs", requireAdmin(idempotent(jobsHandler))).Methods(http.MethodGet, http.MethodPost)
+	router.HandleFunc("/jobs/{job_id}", requireAdmin(jobHandler)).Methods(http.MethodGet)
 
 	// Start the HTTP server.
 	port := ":8080"
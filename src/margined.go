@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+)
+
+// MarginedVenuePositionConfig is a deposit into a Margined vault, on Osmosis or Neutron. The
+// vaults implement the CosmWasm vault standard: deposits of the base token are represented by a
+// native vault token.
+type MarginedVenuePositionConfig struct {
+	VenueMetadata
+
+	VaultAddress string // Contract address of the vault
+	Address      string
+	Protocol     Protocol // Margined for the vaults on Osmosis, MarginedNeutron for the ones on Neutron
+	// ActiveShares is the vault token amount of the bid, if the address holds vault tokens of
+	// several bids. If 0, all vault tokens held by the address count.
+	ActiveShares float64
+}
+
+func (venueConfig MarginedVenuePositionConfig) GetProtocol() Protocol {
+	if venueConfig.Protocol == "" {
+		return Margined
+	}
+	return venueConfig.Protocol
+}
+
+func (venueConfig MarginedVenuePositionConfig) GetPoolID() string {
+	return venueConfig.VaultAddress
+}
+
+func (venueConfig MarginedVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type MarginedPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig MarginedVenuePositionConfig
+}
+
+func NewMarginedPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*MarginedPosition, error) {
+	marginedVenuePositionConfig, ok := venuePositionConfig.(MarginedVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of MarginedVenuePositionConfig type")
+	}
+
+	return &MarginedPosition{protocolConfig: config, venuePositionConfig: marginedVenuePositionConfig}, nil
+}
+
+// The yield of the vault accrues to the redemption value of its vault token.
+func (p MarginedPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+func (p MarginedPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	vault, err := p.getVaultInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	totalAssets, err := p.queryAmount(ctx, map[string]interface{}{
+		"total_assets": map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying total assets: %s", err)
+	}
+
+	return p.baseTokenHoldings(ctx, assetData, vault.BaseToken, totalAssets)
+}
+
+func (p MarginedPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	vault, err := p.getVaultInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := p.venuePositionConfig.ActiveShares
+	if shares == 0 {
+		if shares, err = p.getShareBalance(ctx, address, vault.VaultToken); err != nil {
+			return nil, err
+		}
+	}
+	if shares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	// the vault rounds the redemption value of the vault tokens the way a withdrawal would
+	redemptionValue, err := p.queryAmount(ctx, map[string]interface{}{
+		"convert_to_assets": map[string]interface{}{
+			"amount": strconv.FormatFloat(shares, 'f', 0, 64),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying redemption value: %s", err)
+	}
+
+	return p.baseTokenHoldings(ctx, assetData, vault.BaseToken, redemptionValue)
+}
+
+func (p MarginedPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Margined")
+}
+
+// marginedVaultInfo are the denoms of a vault: the token it takes deposits in and its vault token.
+type marginedVaultInfo struct {
+	BaseToken  string
+	VaultToken string
+}
+
+func (p MarginedPosition) getVaultInfo(ctx context.Context) (*marginedVaultInfo, error) {
+	infoData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.VaultAddress, map[string]interface{}{
+			"info": map[string]interface{}{},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("querying vault info: %s", err)
+	}
+
+	info, ok := infoData.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid vault info structure")
+	}
+	baseToken, ok := info["base_token"].(string)
+	if !ok || baseToken == "" {
+		return nil, fmt.Errorf("invalid base_token in vault info")
+	}
+	vaultToken, ok := info["vault_token"].(string)
+	if !ok || vaultToken == "" {
+		return nil, fmt.Errorf("invalid vault_token in vault info")
+	}
+
+	return &marginedVaultInfo{BaseToken: baseToken, VaultToken: vaultToken}, nil
+}
+
+// queryAmount runs a vault query that answers with an amount of the base token.
+func (p MarginedPosition) queryAmount(ctx context.Context, query map[string]interface{}) (float64, error) {
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.VaultAddress, query)
+	if err != nil {
+		return 0, err
+	}
+
+	amountStr, ok := data.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid amount structure")
+	}
+	amount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse amount into float64: %s", err)
+	}
+	return amount, nil
+}
+
+// getShareBalance returns the amount of vault tokens held by the address.
+func (p MarginedPosition) getShareBalance(ctx context.Context, address string, vaultToken string) (float64, error) {
+	var response struct {
+		Balance *struct {
+			Amount string `json:"amount"`
+		} `json:"balance"`
+	}
+	balanceUrl := fmt.Sprintf("%s/%s/by_denom?denom=%s", p.protocolConfig.AddressBalanceUrl, address, url.QueryEscape(vaultToken))
+	if err := getJSON(ctx, balanceUrl, &response); err != nil {
+		return 0, fmt.Errorf("querying vault token balance: %s", err)
+	}
+	if response.Balance == nil {
+		return 0, fmt.Errorf("invalid balance structure")
+	}
+
+	shares, err := strconv.ParseFloat(response.Balance.Amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse balance into float64: %s", err)
+	}
+	return shares, nil
+}
+
+// baseTokenHoldings values an amount of the base token of the vault, in its base unit.
+func (p MarginedPosition) baseTokenHoldings(ctx context.Context, assetData *ChainInfo, baseToken string, amount float64) (*Holdings, error) {
+	tokenInfo, err := assetData.GetTokenInfo(baseToken)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustedAmount := amount / math.Pow(10, float64(tokenInfo.Decimals))
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute token values: %s", err)
+	}
+
+	return &Holdings{
+		Balances: []Asset{
+			{
+				Denom:       baseToken,
+				Amount:      adjustedAmount,
+				USDValue:    usdValue,
+				DisplayName: tokenInfo.Display,
+			},
+		},
+		TotalUSDC: usdValue,
+		TotalAtom: atomValue,
+	}, nil
+}
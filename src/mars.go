@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strconv"
@@ -13,6 +14,8 @@ const (
 )
 
 type MarsVenuePositionConfig struct {
+	VenueMetadata
+
 	CreditAccountID string
 	DepositedDenom  string
 }
@@ -43,34 +46,38 @@ func NewMarsPosition(config ProtocolConfig, venuePositionConfig VenuePositionCon
 	return &MarsPosition{protocolConfig: config, venuePositionConfig: marsVenuePositionConfig}, nil
 }
 
-func (p MarsPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
-	return p.computeHoldings(assetData, p.getTotalDepositInPool)
+func (p MarsPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	return p.computeHoldings(ctx, assetData, p.getTotalDepositInPool)
+}
+
+func (p MarsPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return p.computeHoldings(ctx, assetData, p.getCreditAccountDepositInPool)
 }
 
-func (p MarsPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
-	return p.computeHoldings(assetData, p.getCreditAccountDepositInPool)
+func (p MarsPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
 }
 
-func (p MarsPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p MarsPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	// rewards are already counted-in into principal address holdings, since Mars protocol doesn't keep track of
 	// the initial holdings and yield separately
 	return &Holdings{}, nil
 }
 
-func (p MarsPosition) computeHoldings(assetData *ChainInfo, getTokenAmountFunc func() (int, error)) (*Holdings, error) {
+func (p MarsPosition) computeHoldings(ctx context.Context, assetData *ChainInfo, getTokenAmountFunc func(context.Context) (int, error)) (*Holdings, error) {
 	poolToken := p.venuePositionConfig.DepositedDenom
 	tokenInfo, ok := assetData.Tokens[poolToken]
 	if !ok {
 		return nil, fmt.Errorf("token info not found for %s", poolToken)
 	}
 
-	tokenAmount, err := getTokenAmountFunc()
+	tokenAmount, err := getTokenAmountFunc(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load token amount: %s", err)
 	}
 
 	adjustedTokenAmount := float64(tokenAmount) / math.Pow(10, float64(tokenInfo.Decimals))
-	totalValueUSD, totalValueAtom, err := getTokenValues(adjustedTokenAmount, tokenInfo)
+	totalValueUSD, totalValueAtom, err := getTokenValues(ctx, adjustedTokenAmount, tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute token values: %s", err)
 	}
@@ -92,14 +99,14 @@ func (p MarsPosition) computeHoldings(assetData *ChainInfo, getTokenAmountFunc f
 	return &holdings, nil
 }
 
-func (p MarsPosition) getTotalDepositInPool() (int, error) {
+func (p MarsPosition) getTotalDepositInPool(ctx context.Context) (int, error) {
 	queryJson := map[string]interface{}{
 		"total_deposit": struct {
 			Denom string `json:"denom"`
 		}{Denom: p.venuePositionConfig.DepositedDenom},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, PARAMS_CONTRACT_ADDRESS, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, PARAMS_CONTRACT_ADDRESS, queryJson)
 	if err != nil {
 		return 0, err
 	}
@@ -112,23 +119,29 @@ func (p MarsPosition) getTotalDepositInPool() (int, error) {
 	return strconv.Atoi(amountStr)
 }
 
-func (p MarsPosition) getCreditAccountDepositInPool() (int, error) {
+func (p MarsPosition) getCreditAccountDepositInPool(ctx context.Context) (int, error) {
 	queryJson := map[string]interface{}{
 		"positions": struct {
 			AccountID string `json:"account_id"`
 		}{AccountID: p.venuePositionConfig.CreditAccountID},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, CREDIT_MANAGER_CONTRACT_ADDRESS, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, CREDIT_MANAGER_CONTRACT_ADDRESS, queryJson)
 	if err != nil {
 		return 0, err
 	}
 
-	lends, ok := (data.(map[string]interface{}))["lends"].([]interface{})
+	positions, ok := data.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("invalid credit account positions")
+	}
+	lends, ok := positions["lends"].([]interface{})
 	if !ok {
 		return 0, fmt.Errorf("invalid credit account lend positions")
 	}
 
+	// If we don't find the specified denom in the lends and vaults, the liquidity is already withdrawn
+	total := 0
 	for _, lend := range lends {
 		lendStruct, ok := lend.(map[string]interface{})
 		if !ok {
@@ -140,11 +153,112 @@ func (p MarsPosition) getCreditAccountDepositInPool() (int, error) {
 			continue
 		}
 
-		lendAmountStr := lendStruct["amount"].(string)
+		lendAmount, err := strconv.Atoi(lendStruct["amount"].(string))
+		if err != nil {
+			return 0, err
+		}
+		total += lendAmount
+	}
+
+	// accounts without vault positions may leave out the list
+	vaults, _ := positions["vaults"].([]interface{})
+	for _, vault := range vaults {
+		vaultAmount, err := p.getVaultPositionAmount(ctx, vault)
+		if err != nil {
+			return 0, err
+		}
+		total += vaultAmount
+	}
+
+	return total, nil
+}
 
-		return strconv.Atoi(lendAmountStr)
+// getVaultPositionAmount returns the amount of the deposited denom that a vault position of the
+// credit account redeems for: its locked or unlocked vault shares converted by the vault, plus the
+// amounts being unlocked, which are already in the vault's base token. Vaults of other tokens
+// count as 0.
+func (p MarsPosition) getVaultPositionAmount(ctx context.Context, position interface{}) (int, error) {
+	positionStruct, ok := position.(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("invalid credit account vault position")
+	}
+	vault, ok := positionStruct["vault"].(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("invalid credit account vault")
+	}
+	vaultAddress, ok := vault["address"].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid credit account vault address")
+	}
+
+	info, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, vaultAddress, map[string]interface{}{
+		"info": struct{}{},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("querying vault %s: %s", vaultAddress, err)
+	}
+	baseToken, ok := (info.(map[string]interface{}))["base_token"].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid base token of vault %s", vaultAddress)
+	}
+	if baseToken != p.venuePositionConfig.DepositedDenom {
+		return 0, nil
+	}
+
+	// the amount is either {"unlocked": shares} or {"locking": {"locked": shares, "unlocking": [...]}}
+	amount, ok := positionStruct["amount"].(map[string]interface{})
+	if !ok {
+		return 0, fmt.Errorf("invalid amount of vault position in %s", vaultAddress)
+	}
+	var sharesStr string
+	var unlocking []interface{}
+	if unlocked, ok := amount["unlocked"].(string); ok {
+		sharesStr = unlocked
+	} else if locking, ok := amount["locking"].(map[string]interface{}); ok {
+		sharesStr, _ = locking["locked"].(string)
+		unlocking, _ = locking["unlocking"].([]interface{})
+	}
+	if sharesStr == "" {
+		return 0, fmt.Errorf("invalid amount of vault position in %s", vaultAddress)
+	}
+
+	total := 0
+	for _, entry := range unlocking {
+		entryStruct, ok := entry.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("invalid unlocking position in %s", vaultAddress)
+		}
+		coin, ok := entryStruct["coin"].(map[string]interface{})
+		if !ok || coin["denom"] != baseToken {
+			continue
+		}
+		unlockingAmount, err := strconv.Atoi(coin["amount"].(string))
+		if err != nil {
+			return 0, err
+		}
+		total += unlockingAmount
+	}
+
+	if sharesStr == "0" {
+		return total, nil
+	}
+
+	assets, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, vaultAddress, map[string]interface{}{
+		"convert_to_assets": struct {
+			Amount string `json:"amount"`
+		}{Amount: sharesStr},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("converting shares of vault %s: %s", vaultAddress, err)
+	}
+	assetsStr, ok := assets.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid assets of vault %s", vaultAddress)
+	}
+	assetsAmount, err := strconv.Atoi(assetsStr)
+	if err != nil {
+		return 0, err
 	}
 
-	// If we didn't find the specifed denom in the lends list, it means that the liquidity is already withdrawn
-	return 0, nil
+	return total + assetsAmount, nil
 }
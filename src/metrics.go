@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A minimal metrics registry that renders the Prometheus text exposition format.
+
+type metricKind string
+
+const (
+	gaugeMetric   metricKind = "gauge"
+	counterMetric metricKind = "counter"
+)
+
+type metricFamily struct {
+	name   string
+	help   string
+	kind   metricKind
+	values map[string]float64 // rendered label set -> value
+}
+
+var (
+	metricsMu sync.Mutex
+	registry  = map[string]*metricFamily{}
+)
+
+func newMetric(name string, kind metricKind, help string) *metricFamily {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	family := &metricFamily{name: name, help: help, kind: kind, values: map[string]float64{}}
+	registry[name] = family
+	return family
+}
+
+func newGauge(name string, help string) *metricFamily {
+	return newMetric(name, gaugeMetric, help)
+}
+
+func newCounter(name string, help string) *metricFamily {
+	return newMetric(name, counterMetric, help)
+}
+
+// Set sets the value for the given labels, passed as alternating names and values.
+func (m *metricFamily) Set(value float64, labels ...string) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	m.values[renderLabels(labels)] = value
+}
+
+// Add increments the value for the given labels, passed as alternating names and values.
+func (m *metricFamily) Add(delta float64, labels ...string) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	m.values[renderLabels(labels)] += delta
+}
+
+func renderLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", labels[i], strconv.Quote(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// metricsHandler serves all registered metrics in the Prometheus text format.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		family := registry[name]
+		fmt.Fprintf(&sb, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&sb, "# TYPE %s %s\n", family.name, family.kind)
+
+		labelSets := make([]string, 0, len(family.values))
+		for labelSet := range family.values {
+			labelSets = append(labelSets, labelSet)
+		}
+		sort.Strings(labelSets)
+
+		for _, labelSet := range labelSets {
+			fmt.Fprintf(&sb, "%s%s %s\n", family.name, labelSet,
+				strconv.FormatFloat(family.values[labelSet], 'g', -1, 64))
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(sb.String()))
+}
@@ -1,8 +1,13 @@
 package main
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type MissingVenuePositionConfig struct {
+	VenueMetadata
+
 	Protocol Protocol
 }
 
@@ -32,14 +37,19 @@ func NewMissingPosition(config ProtocolConfig, venuePositionConfig VenuePosition
 	return &MissingPosition{protocolConfig: config, venuePositionConfig: missingVenuePositionConfig}, nil
 }
 
-func (p MissingPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
+// Capabilities reports nothing, as venues without integration only have their withdrawals.
+func (p MissingPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{}
+}
+
+func (p MissingPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	return nil, nil
 }
 
-func (p MissingPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p MissingPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	return nil, nil
 }
 
-func (p MissingPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p MissingPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	return nil, nil
 }
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+// MitoVenuePositionConfig is a deposit into a Mito vault on Injective. Deposits are represented by
+// the vault's LP token, and the vault trades with the funds of its exchange subaccount.
+type MitoVenuePositionConfig struct {
+	VenueMetadata
+
+	VaultAddress string // Contract address of the vault
+	Address      string
+	LPDenom      string // LP token of the vault, e.g. factory/inj1.../lp...
+	SubaccountID string // Exchange subaccount the vault trades from, as shown on the explorers
+	// ActiveShares is the LP token amount of the bid, if the address holds LP tokens of several
+	// bids. If 0, all LP tokens held by the address count.
+	ActiveShares float64
+}
+
+func (venueConfig MitoVenuePositionConfig) GetProtocol() Protocol {
+	return Mito
+}
+
+func (venueConfig MitoVenuePositionConfig) GetPoolID() string {
+	return venueConfig.VaultAddress
+}
+
+func (venueConfig MitoVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type MitoPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig MitoVenuePositionConfig
+}
+
+func NewMitoPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*MitoPosition, error) {
+	mitoVenuePositionConfig, ok := venuePositionConfig.(MitoVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of MitoVenuePositionConfig type")
+	}
+
+	return &MitoPosition{protocolConfig: config, venuePositionConfig: mitoVenuePositionConfig}, nil
+}
+
+// The trading profits of the vault accrue to the value of its LP token.
+func (p MitoPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+// ComputeTVL returns the composition of the vault: the deposits of its subaccount, including the
+// ones locked in open orders.
+func (p MitoPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	deposits, err := p.getDeposits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mitoHoldings(ctx, assetData, deposits, 1)
+}
+
+// ComputeAddressPrincipalHoldings returns the share of the vault's composition of the LP tokens
+// of the address.
+func (p MitoPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	shares := p.venuePositionConfig.ActiveShares
+	if shares == 0 {
+		var err error
+		if shares, err = p.getLPBalance(ctx, address); err != nil {
+			return nil, err
+		}
+	}
+	if shares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	totalShares, err := p.getLPSupply(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if totalShares == 0 {
+		return nil, fmt.Errorf("vault %s has no LP tokens", p.venuePositionConfig.VaultAddress)
+	}
+
+	deposits, err := p.getDeposits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return mitoHoldings(ctx, assetData, deposits, shares/totalShares)
+}
+
+func (p MitoPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Mito")
+}
+
+// getDeposits returns the total balances of the vault's subaccount by denom, in their base unit.
+func (p MitoPosition) getDeposits(ctx context.Context) (map[string]float64, error) {
+	var response struct {
+		Deposits map[string]struct {
+			TotalBalance string `json:"total_balance"`
+		} `json:"deposits"`
+	}
+	depositsUrl := fmt.Sprintf("%s/injective/exchange/v1beta1/exchange/subaccountDeposits?subaccount_id=%s",
+		p.protocolConfig.PoolInfoUrl, url.QueryEscape(p.venuePositionConfig.SubaccountID))
+	if err := getJSON(ctx, depositsUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying subaccount deposits: %s", err)
+	}
+
+	deposits := make(map[string]float64, len(response.Deposits))
+	for denom, deposit := range response.Deposits {
+		// exchange balances are decimals of the base unit, e.g. "1000000.000000000000000000"
+		amount, err := strconv.ParseFloat(deposit.TotalBalance, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse deposit of %s into float64: %s", denom, err)
+		}
+		deposits[denom] = amount
+	}
+	return deposits, nil
+}
+
+// getLPBalance returns the amount of LP tokens held by the address.
+func (p MitoPosition) getLPBalance(ctx context.Context, address string) (float64, error) {
+	var response struct {
+		Balance *struct {
+			Amount string `json:"amount"`
+		} `json:"balance"`
+	}
+	balanceUrl := fmt.Sprintf("%s/%s/by_denom?denom=%s", p.protocolConfig.AddressBalanceUrl, address, url.QueryEscape(p.venuePositionConfig.LPDenom))
+	if err := getJSON(ctx, balanceUrl, &response); err != nil {
+		return 0, fmt.Errorf("querying LP token balance: %s", err)
+	}
+	if response.Balance == nil {
+		return 0, fmt.Errorf("invalid balance structure")
+	}
+
+	shares, err := parseAmount(response.Balance.Amount)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LP token balance: %w", err)
+	}
+	return amountFloat(shares), nil
+}
+
+// getLPSupply returns the amount of LP tokens of the vault in circulation.
+func (p MitoPosition) getLPSupply(ctx context.Context) (float64, error) {
+	var response struct {
+		Amount *struct {
+			Amount string `json:"amount"`
+		} `json:"amount"`
+	}
+	supplyUrl := fmt.Sprintf("%s/cosmos/bank/v1beta1/supply/by_denom?denom=%s", p.protocolConfig.PoolInfoUrl, url.QueryEscape(p.venuePositionConfig.LPDenom))
+	if err := getJSON(ctx, supplyUrl, &response); err != nil {
+		return 0, fmt.Errorf("querying LP token supply: %s", err)
+	}
+	if response.Amount == nil {
+		return 0, fmt.Errorf("invalid supply structure")
+	}
+
+	supply, err := parseAmount(response.Amount.Amount)
+	if err != nil {
+		return 0, fmt.Errorf("invalid LP token supply: %w", err)
+	}
+	return amountFloat(supply), nil
+}
+
+// mitoHoldings values the given share of the deposits of a vault, by denom. Denoms without token
+// info or price are left out.
+func mitoHoldings(ctx context.Context, assetData *ChainInfo, deposits map[string]float64, share float64) (*Holdings, error) {
+	denoms := make([]string, 0, len(deposits))
+	for denom := range deposits {
+		denoms = append(denoms, denom)
+	}
+	sort.Strings(denoms)
+
+	holdings := &Holdings{Balances: []Asset{}}
+	for _, denom := range denoms {
+		tokenInfo, err := assetData.GetTokenInfo(denom)
+		if err != nil {
+			debugLog("Token info not found", map[string]string{"denom": denom})
+			continue
+		}
+
+		adjustedAmount := deposits[denom] * share / math.Pow(10, float64(tokenInfo.Decimals))
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			debugLog("Error getting token values", map[string]string{"denom": denom})
+			continue
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       denom,
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+
+	return holdings, nil
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
-	"strconv"
+	"math/big"
 )
 
 const (
@@ -12,6 +13,8 @@ const (
 )
 
 type NeptuneVenuePositionConfig struct {
+	VenueMetadata
+
 	Denom        string
 	Address      string
 	ActiveShares int64 // LP token amount
@@ -29,9 +32,22 @@ func (venueConfig NeptuneVenuePositionConfig) GetAddress() string {
 	return venueConfig.Address
 }
 
+func (venueConfig NeptuneVenuePositionConfig) GetActiveShares() float64 {
+	return float64(venueConfig.ActiveShares)
+}
+
 type NeptunePosition struct {
 	protocolConfig      ProtocolConfig
 	venuePositionConfig NeptuneVenuePositionConfig
+
+	// market is fetched once per computation, as the TVL and the redemption rate are both derived from it
+	market *neptuneMarket
+}
+
+// neptuneMarket holds the data of the lending market of the position's denom.
+type neptuneMarket struct {
+	LendingPrincipal *big.Int
+	ReceiptAddr      string
 }
 
 func NewNeptunePosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*NeptunePosition, error) {
@@ -46,8 +62,8 @@ func NewNeptunePosition(config ProtocolConfig, venuePositionConfig VenuePosition
 	}, nil
 }
 
-func (p NeptunePosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
-	amount, err := p.getPoolLentAmount()
+func (p *NeptunePosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	amount, err := p.getPoolLentAmount(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error getting pool lent amount: %v", err)
 	}
@@ -60,9 +76,9 @@ func (p NeptunePosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		return nil, fmt.Errorf("error getting token info for denom: %s", denom)
 	}
 
-	adjustedAmount := float64(amount) / math.Pow(10, float64(tokenInfo.Decimals))
+	adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
 
-	usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 	if err != nil {
 		debugLog("Error getting token values", map[string]string{"denom": denom})
 		return nil, fmt.Errorf("error calculating token values for denom: %s", denom)
@@ -72,6 +88,7 @@ func (p NeptunePosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		},
@@ -84,7 +101,7 @@ func (p NeptunePosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 	}, nil
 }
 
-func (p NeptunePosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _ string) (*Holdings, error) {
+func (p *NeptunePosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, _ string) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -93,12 +110,12 @@ func (p NeptunePosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 		}, nil
 	}
 
-	receiptAddr, err := p.getPoolReceiptToken()
+	receiptAddr, err := p.getPoolReceiptToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error getting pool receipt token: %v", err)
 	}
 
-	redemptionRate, err := p.calculateRedemptionRate(receiptAddr)
+	redemptionRate, err := p.calculateRedemptionRate(ctx, receiptAddr)
 	if err != nil {
 		return nil, fmt.Errorf("error calculating redemption rate: %v", err)
 	}
@@ -112,7 +129,7 @@ func (p NeptunePosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 	adjustedAmount := float64(p.venuePositionConfig.ActiveShares) / math.Pow(10, float64(tokenInfo.Decimals))
 	holdings := adjustedAmount * redemptionRate
 
-	usdValue, atomValue, err := getTokenValues(holdings, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, holdings, *tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("calculating token values: %v", err)
 	}
@@ -133,71 +150,55 @@ func (p NeptunePosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, _
 	}, nil
 }
 
-func (p NeptunePosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p *NeptunePosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+func (p *NeptunePosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	// Neptune protocol doesn't keep track of the initial holdings and yield separately
 	return &Holdings{}, nil
 }
 
-func (p NeptunePosition) getPoolLentAmount() (float64, error) {
-	queryJson := map[string]interface{}{
-		"get_all_markets": map[string]interface{}{},
+func (p *NeptunePosition) getPoolLentAmount(ctx context.Context) (*big.Int, error) {
+	market, err := p.getMarket(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, MarketMakerAddress, queryJson)
+	return market.LendingPrincipal, nil
+}
+
+func (p *NeptunePosition) getPoolReceiptToken(ctx context.Context) (string, error) {
+	market, err := p.getMarket(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("querying smart contract data: %v", err)
+		return "", err
 	}
 
-	markets, ok := data.([]interface{})
-	if !ok {
-		return 0, fmt.Errorf("unexpected response format: expected an array")
+	if market.ReceiptAddr == "" {
+		return "", fmt.Errorf("missing or invalid receipt_addr in market_asset_details")
 	}
 
-	for _, market := range markets {
-		marketArray, ok := market.([]interface{})
-		if !ok || len(marketArray) != 2 {
-			continue
-		}
-
-		nativeToken, ok := marketArray[0].(map[string]interface{})["native_token"].(map[string]interface{})
-		if !ok {
-			continue
-		}
-
-		denom, ok := nativeToken["denom"].(string)
-		if !ok || denom != p.venuePositionConfig.Denom {
-			continue
-		}
-
-		lendingPrincipalStr, ok := marketArray[1].(map[string]interface{})["lending_principal"].(string)
-		if !ok {
-			return 0, fmt.Errorf("missing or invalid lending_principal in market data")
-		}
-
-		lendingPrincipal, err := strconv.ParseFloat(lendingPrincipalStr, 64)
-		if err != nil {
-			return 0, fmt.Errorf("parsing lending_principal: %v", err)
-		}
+	return market.ReceiptAddr, nil
+}
 
-		return lendingPrincipal, nil
+// getMarket fetches the market of the position's denom from get_all_markets, once per position.
+func (p *NeptunePosition) getMarket(ctx context.Context) (*neptuneMarket, error) {
+	if p.market != nil {
+		return p.market, nil
 	}
 
-	return 0, fmt.Errorf("no matching pool found for denom: %s", p.venuePositionConfig.Denom)
-}
-
-func (p NeptunePosition) getPoolReceiptToken() (string, error) {
 	queryJson := map[string]interface{}{
 		"get_all_markets": map[string]interface{}{},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, MarketMakerAddress, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, MarketMakerAddress, queryJson)
 	if err != nil {
-		return "", fmt.Errorf("querying smart contract data: %v", err)
+		return nil, fmt.Errorf("querying smart contract data: %v", err)
 	}
 
 	markets, ok := data.([]interface{})
 	if !ok {
-		return "", fmt.Errorf("unexpected response format: expected an array")
+		return nil, fmt.Errorf("unexpected response format: expected an array")
 	}
 
 	for _, market := range markets {
@@ -216,28 +217,39 @@ func (p NeptunePosition) getPoolReceiptToken() (string, error) {
 			continue
 		}
 
-		marketAssetDetails, ok := marketArray[1].(map[string]interface{})["market_asset_details"].(map[string]interface{})
+		marketData, ok := marketArray[1].(map[string]interface{})
 		if !ok {
-			continue
+			return nil, fmt.Errorf("invalid market data for denom: %s", denom)
 		}
 
-		receiptAddr, ok := marketAssetDetails["receipt_addr"].(string)
+		lendingPrincipalStr, ok := marketData["lending_principal"].(string)
 		if !ok {
-			return "", fmt.Errorf("missing or invalid receipt_addr in market_asset_details")
+			return nil, fmt.Errorf("missing or invalid lending_principal in market data")
 		}
 
-		return receiptAddr, nil
+		lendingPrincipal, err := parseAmount(lendingPrincipalStr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing lending_principal: %w", err)
+		}
+
+		result := &neptuneMarket{LendingPrincipal: lendingPrincipal}
+		if marketAssetDetails, ok := marketData["market_asset_details"].(map[string]interface{}); ok {
+			result.ReceiptAddr, _ = marketAssetDetails["receipt_addr"].(string)
+		}
+
+		p.market = result
+		return result, nil
 	}
 
-	return "", fmt.Errorf("no matching pool found for denom: %s", p.venuePositionConfig.Denom)
+	return nil, fmt.Errorf("no matching pool found for denom: %s", p.venuePositionConfig.Denom)
 }
 
-func (p NeptunePosition) calculateRedemptionRate(receiptAddr string) (float64, error) {
+func (p *NeptunePosition) calculateRedemptionRate(ctx context.Context, receiptAddr string) (float64, error) {
 	queryJson := map[string]interface{}{
 		"token_info": map[string]interface{}{},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, receiptAddr, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, receiptAddr, queryJson)
 	if err != nil {
 		return 0, fmt.Errorf("querying receipt token info: %v", err)
 	}
@@ -247,16 +259,19 @@ func (p NeptunePosition) calculateRedemptionRate(receiptAddr string) (float64, e
 		return 0, fmt.Errorf("missing or invalid total_supply in receipt token info")
 	}
 
-	totalSupply, err := strconv.ParseFloat(totalSupplyStr, 64)
+	totalSupply, err := parseAmount(totalSupplyStr)
 	if err != nil {
-		return 0, fmt.Errorf("parsing total_supply: %v", err)
+		return 0, fmt.Errorf("parsing total_supply: %w", err)
+	}
+	if totalSupply.Sign() == 0 {
+		return 0, fmt.Errorf("receipt token %s has no supply", receiptAddr)
 	}
 
-	lendingPrincipal, err := p.getPoolLentAmount()
+	lendingPrincipal, err := p.getPoolLentAmount(ctx)
 	if err != nil {
 		return 0, fmt.Errorf("error getting pool lent amount: %v", err)
 	}
 
-	redemptionRate := lendingPrincipal / totalSupply
+	redemptionRate, _ := new(big.Rat).SetFrac(lendingPrincipal, totalSupply).Float64()
 	return redemptionRate, nil
 }
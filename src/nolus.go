@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"strconv"
@@ -14,6 +15,8 @@ const (
 )
 
 type NolusVenuePositionConfig struct {
+	VenueMetadata
+
 	PoolContractAddress string
 	PoolContractToken   string
 	Address             string
@@ -32,6 +35,10 @@ func (venueConfig NolusVenuePositionConfig) GetAddress() string {
 	return venueConfig.Address
 }
 
+func (venueConfig NolusVenuePositionConfig) GetActiveShares() float64 {
+	return float64(venueConfig.ActiveShares)
+}
+
 type NolusPosition struct {
 	protocolConfig      ProtocolConfig
 	venuePositionConfig NolusVenuePositionConfig
@@ -46,20 +53,24 @@ func NewNolusPosition(config ProtocolConfig, venuePositionConfig VenuePositionCo
 	return &NolusPosition{protocolConfig: config, venuePositionConfig: nolusVenuePositionConfig}, nil
 }
 
-func (p NolusPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
-	return p.computeHoldings(assetData, p.getTotalPoolShares)
+func (p NolusPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	return p.computeHoldings(ctx, assetData, p.getTotalPoolShares)
 }
 
-func (p NolusPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
-	return p.computeHoldings(assetData, func() (int, error) { return p.venuePositionConfig.ActiveShares, nil })
+func (p NolusPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return p.computeHoldings(ctx, assetData, func(ctx context.Context) (int, error) { return p.venuePositionConfig.ActiveShares, nil })
 }
 
 // We can only calculate rewards per address, not per bid.
-func (p NolusPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
-	return p.computeHoldings(assetData, func() (int, error) { return p.getAddressRewardsShares(address) })
+func (p NolusPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
+func (p NolusPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return p.computeHoldings(ctx, assetData, func(ctx context.Context) (int, error) { return p.getAddressRewardsShares(ctx, address) })
 }
 
-func (p NolusPosition) computeHoldings(assetData *ChainInfo, getSharesFunc func() (int, error)) (*Holdings, error) {
+func (p NolusPosition) computeHoldings(ctx context.Context, assetData *ChainInfo, getSharesFunc func(context.Context) (int, error)) (*Holdings, error) {
 	if p.venuePositionConfig.ActiveShares == 0 {
 		return &Holdings{
 			Balances:  []Asset{},
@@ -75,12 +86,12 @@ func (p NolusPosition) computeHoldings(assetData *ChainInfo, getSharesFunc func(
 		return nil, fmt.Errorf("token info not found for %s", poolToken)
 	}
 
-	tokenShares, err := getSharesFunc()
+	tokenShares, err := getSharesFunc(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load pool shares: %s", err.Error())
 	}
 
-	ratio, err := p.getShareToTokenRatio()
+	ratio, err := p.getShareToTokenRatio(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load share to token ratio: %s", err.Error())
 	}
@@ -88,7 +99,7 @@ func (p NolusPosition) computeHoldings(assetData *ChainInfo, getSharesFunc func(
 	rawTokenAmount := float64(tokenShares) * ratio
 	adjustedTokenAmount := rawTokenAmount / math.Pow(10, float64(tokenInfo.Decimals))
 
-	totalValueUSD, totalValueAtom, err := getTokenValues(adjustedTokenAmount, tokenInfo)
+	totalValueUSD, totalValueAtom, err := getTokenValues(ctx, adjustedTokenAmount, tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to compute token values: %s", err)
 	}
@@ -110,12 +121,12 @@ func (p NolusPosition) computeHoldings(assetData *ChainInfo, getSharesFunc func(
 	return &holdings, nil
 }
 
-func (p NolusPosition) getShareToTokenRatio() (float64, error) {
+func (p NolusPosition) getShareToTokenRatio(ctx context.Context) (float64, error) {
 	queryJson := map[string]interface{}{
 		"price": []interface{}{},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolContractAddress, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolContractAddress, queryJson)
 	if err != nil {
 		return 0, err
 	}
@@ -145,12 +156,12 @@ func (p NolusPosition) getShareToTokenRatio() (float64, error) {
 	return amountQuote / amount, nil
 }
 
-func (p NolusPosition) getTotalPoolShares() (int, error) {
+func (p NolusPosition) getTotalPoolShares(ctx context.Context) (int, error) {
 	queryJson := map[string]interface{}{
 		"lpp_balance": []interface{}{},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolContractAddress, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolContractAddress, queryJson)
 	if err != nil {
 		return 0, err
 	}
@@ -164,14 +175,14 @@ func (p NolusPosition) getTotalPoolShares() (int, error) {
 	return poolBalance, err
 }
 
-func (p NolusPosition) getAddressRewardsShares(address string) (int, error) {
+func (p NolusPosition) getAddressRewardsShares(ctx context.Context, address string) (int, error) {
 	queryJson := map[string]interface{}{
 		"rewards": struct {
 			Address string `json:"address"`
 		}{Address: address},
 	}
 
-	data, err := QuerySmartContractData(p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolContractAddress, queryJson)
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolContractAddress, queryJson)
 	if err != nil {
 		if strings.Contains(err.Error(), "The deposit does not exist") {
 			return 0, nil
@@ -1,17 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math"
+	"math/big"
 	"net/http"
-	"strconv"
 )
 
 const OsmosisAPIURL = "https://sqs.osmosis.zone"
 
 type OsmosisVenuePositionConfig struct {
+	VenueMetadata
+
 	PoolID     string
 	Address    string
 	PositionID string
@@ -29,6 +31,10 @@ func (venueConfig OsmosisVenuePositionConfig) GetAddress() string {
 	return venueConfig.Address
 }
 
+func (venueConfig OsmosisVenuePositionConfig) GetPositionID() string {
+	return venueConfig.PositionID
+}
+
 // Osmosis implementation
 type OsmosisPosition struct {
 	protocolConfig      ProtocolConfig
@@ -44,11 +50,11 @@ func NewOsmosisPosition(config ProtocolConfig, venuePositionConfig VenuePosition
 	return &OsmosisPosition{protocolConfig: config, venuePositionConfig: osmosisVenuePositionConfig}, nil
 }
 
-func (p OsmosisPosition) FetchPoolData() (map[string]interface{}, error) {
+func (p OsmosisPosition) FetchPoolData(ctx context.Context) (map[string]interface{}, error) {
 	url := fmt.Sprintf("%s/pools?IDs=%s", p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PoolID)
 	debugLog("Fetching pool data from Osmosis API", map[string]string{"url": url})
 
-	resp, err := http.Get(url)
+	resp, err := httpGet(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("fetching pool data: %v", err)
 	}
@@ -76,9 +82,9 @@ func (p OsmosisPosition) FetchPoolData() (map[string]interface{}, error) {
 	return pools[0], nil
 }
 
-func (p OsmosisPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
+func (p OsmosisPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	// Fetch pool data
-	poolData, err := p.FetchPoolData()
+	poolData, err := p.FetchPoolData(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("fetching pool data: %s", err)
 	}
@@ -101,15 +107,18 @@ func (p OsmosisPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		}
 
 		denom := balanceMap["denom"].(string)
-		rawAmount, _ := strconv.ParseInt(balanceMap["amount"].(string), 10, 64)
+		rawAmount, err := parseAmountValue(balanceMap["amount"])
+		if err != nil {
+			return nil, fmt.Errorf("parsing pool balance of %s: %w", denom, err)
+		}
 		tokenInfo := assetData.Tokens[denom]
 
 		// Calculate adjusted amount
-		adjustedAmount := float64(rawAmount) / math.Pow(10, float64(tokenInfo.Decimals))
+		adjustedAmount := tokenAmount(rawAmount, tokenInfo.Decimals)
 
 		// Get token price from asset data
 		usdValue := 0.0
-		price, err := getTokenPrice(tokenInfo.CoingeckoID)
+		price, err := getTokenPrice(ctx, tokenInfo.CoingeckoID)
 		if err != nil {
 			return nil, fmt.Errorf("fetching token price: %s", err)
 		}
@@ -122,6 +131,7 @@ func (p OsmosisPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		asset := Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(rawAmount, tokenInfo.Decimals),
 			CoingeckoID: nil, // Optional field
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
@@ -130,7 +140,7 @@ func (p OsmosisPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 	}
 
 	// Get ATOM price and calculate equivalent
-	atomPrice, err := getAtomPrice()
+	atomPrice, err := getAtomPrice(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("fetching ATOM price: %s", err)
 	}
@@ -148,11 +158,11 @@ func (p OsmosisPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 	}, nil
 }
 
-func (p OsmosisPosition) fetchPositionsData(address string) (map[string]interface{}, error) {
+func (p OsmosisPosition) fetchPositionsData(ctx context.Context, address string) (map[string]interface{}, error) {
 	positionsURL := fmt.Sprintf("%s/osmosis/concentratedliquidity/v1beta1/positions/%s",
 		p.protocolConfig.AddressBalanceUrl, address)
 
-	resp, err := http.Get(positionsURL)
+	resp, err := httpGet(ctx, positionsURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetching positions: %v", err)
 	}
@@ -170,17 +180,16 @@ func (p OsmosisPosition) fetchPositionsData(address string) (map[string]interfac
 	return positionsData, nil
 }
 
-func (p *OsmosisPosition) calculateAssetValues(amounts map[string]int64, assetData *ChainInfo) ([]Asset, float64, error) {
+func (p *OsmosisPosition) calculateAssetValues(ctx context.Context, amounts map[string]*big.Int, assetData *ChainInfo) ([]Asset, float64, error) {
 	var assets []Asset
 	totalUSD := 0.0
 
 	for denom, amount := range amounts {
 		tokenInfo := assetData.Tokens[denom]
-		exp := tokenInfo.Decimals
-		adjustedAmount := float64(amount) / math.Pow(10, float64(exp))
+		adjustedAmount := tokenAmount(amount, tokenInfo.Decimals)
 		displayName := tokenInfo.Display
 
-		price, err := getTokenPrice(tokenInfo.CoingeckoID)
+		price, err := getTokenPrice(ctx, tokenInfo.CoingeckoID)
 		if err != nil {
 			return nil, 0, fmt.Errorf("getting token price: %v", err)
 		}
@@ -191,6 +200,7 @@ func (p *OsmosisPosition) calculateAssetValues(amounts map[string]int64, assetDa
 		asset := Asset{
 			Denom:       denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amount, tokenInfo.Decimals),
 			CoingeckoID: nil,
 			USDValue:    usdValue,
 			DisplayName: displayName,
@@ -214,8 +224,8 @@ func createHoldings(assets []Asset, totalUSD float64, atomPrice float64) *Holdin
 	}
 }
 
-func (p OsmosisPosition) processPositionBalances(positions []interface{}) (map[string]int64, error) {
-	balances := make(map[string]int64)
+func (p OsmosisPosition) processPositionBalances(positions []interface{}) (map[string]*big.Int, error) {
+	balances := make(map[string]*big.Int)
 
 	for _, pos := range positions {
 		position, ok := pos.(map[string]interface{})
@@ -246,7 +256,10 @@ func (p OsmosisPosition) processPositionBalances(positions []interface{}) (map[s
 
 		for _, asset := range assets {
 			denom := asset["denom"].(string)
-			amount, _ := strconv.ParseInt(asset["amount"].(string), 10, 64)
+			amount, err := parseAmountValue(asset["amount"])
+			if err != nil {
+				return nil, fmt.Errorf("parsing position amount of %s: %w", denom, err)
+			}
 			balances[denom] = amount
 		}
 
@@ -257,8 +270,8 @@ func (p OsmosisPosition) processPositionBalances(positions []interface{}) (map[s
 	return balances, nil
 }
 
-func (p OsmosisPosition) processPositionRewards(positions []interface{}) (map[string]int64, error) {
-	rewards := make(map[string]int64)
+func (p OsmosisPosition) processPositionRewards(positions []interface{}) (map[string]*big.Int, error) {
+	rewards := make(map[string]*big.Int)
 
 	for _, pos := range positions {
 		position, ok := pos.(map[string]interface{})
@@ -286,8 +299,11 @@ func (p OsmosisPosition) processPositionRewards(positions []interface{}) (map[st
 			for _, reward := range spreadRewards {
 				rewardMap := reward.(map[string]interface{})
 				denom := rewardMap["denom"].(string)
-				amount, _ := strconv.ParseInt(rewardMap["amount"].(string), 10, 64)
-				rewards[denom] += amount
+				amount, err := parseAmountValue(rewardMap["amount"])
+				if err != nil {
+					return nil, fmt.Errorf("parsing reward amount of %s: %w", denom, err)
+				}
+				addAmount(rewards, denom, amount)
 			}
 		}
 
@@ -295,8 +311,11 @@ func (p OsmosisPosition) processPositionRewards(positions []interface{}) (map[st
 			for _, reward := range incentiveRewards {
 				rewardMap := reward.(map[string]interface{})
 				denom := rewardMap["denom"].(string)
-				amount, _ := strconv.ParseInt(rewardMap["amount"].(string), 10, 64)
-				rewards[denom] += amount
+				amount, err := parseAmountValue(rewardMap["amount"])
+				if err != nil {
+					return nil, fmt.Errorf("parsing reward amount of %s: %w", denom, err)
+				}
+				addAmount(rewards, denom, amount)
 			}
 		}
 
@@ -307,8 +326,8 @@ func (p OsmosisPosition) processPositionRewards(positions []interface{}) (map[st
 	return rewards, nil
 }
 
-func (p OsmosisPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
-	positionsData, err := p.fetchPositionsData(address)
+func (p OsmosisPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	positionsData, err := p.fetchPositionsData(ctx, address)
 	if err != nil {
 		return nil, err
 	}
@@ -323,12 +342,12 @@ func (p OsmosisPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, a
 		return nil, err
 	}
 
-	assets, totalUSD, err := p.calculateAssetValues(balances, assetData)
+	assets, totalUSD, err := p.calculateAssetValues(ctx, balances, assetData)
 	if err != nil {
 		return nil, err
 	}
 
-	atomPrice, err := getAtomPrice()
+	atomPrice, err := getAtomPrice(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting ATOM price: %v", err)
 	}
@@ -336,8 +355,12 @@ func (p OsmosisPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, a
 	return createHoldings(assets, totalUSD, atomPrice), nil
 }
 
-func (p OsmosisPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
-	positionsData, err := p.fetchPositionsData(address)
+func (p OsmosisPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
+func (p OsmosisPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	positionsData, err := p.fetchPositionsData(ctx, address)
 	if err != nil {
 		return nil, err
 	}
@@ -352,12 +375,12 @@ func (p OsmosisPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, addr
 		return nil, err
 	}
 
-	assets, totalUSD, err := p.calculateAssetValues(rewards, assetData)
+	assets, totalUSD, err := p.calculateAssetValues(ctx, rewards, assetData)
 	if err != nil {
 		return nil, err
 	}
 
-	atomPrice, err := getAtomPrice()
+	atomPrice, err := getAtomPrice(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting ATOM price: %v", err)
 	}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// A minimal Parquet writer: one row group, required flat columns, PLAIN encoding, uncompressed.
+// That is all the export needs, and it is readable by pandas, DuckDB and Spark.
+
+// Parquet physical types.
+const (
+	parquetInt32     int32 = 1
+	parquetInt64     int32 = 2
+	parquetDouble    int32 = 5
+	parquetByteArray int32 = 6
+)
+
+// Parquet converted (logical) types.
+const (
+	parquetNoConvertedType int32 = -1
+	parquetUTF8            int32 = 0
+	parquetTimestampMillis int32 = 9
+)
+
+// parquetColumn accumulates the PLAIN-encoded values of a column.
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedType int32
+	numValues     int
+	data          bytes.Buffer
+}
+
+func newParquetColumn(name string, physicalType int32, convertedType int32) *parquetColumn {
+	return &parquetColumn{name: name, physicalType: physicalType, convertedType: convertedType}
+}
+
+func (c *parquetColumn) AppendInt32(v int32) {
+	binary.Write(&c.data, binary.LittleEndian, v)
+	c.numValues++
+}
+
+func (c *parquetColumn) AppendInt64(v int64) {
+	binary.Write(&c.data, binary.LittleEndian, v)
+	c.numValues++
+}
+
+func (c *parquetColumn) AppendDouble(v float64) {
+	binary.Write(&c.data, binary.LittleEndian, math.Float64bits(v))
+	c.numValues++
+}
+
+func (c *parquetColumn) AppendString(v string) {
+	binary.Write(&c.data, binary.LittleEndian, uint32(len(v)))
+	c.data.WriteString(v)
+	c.numValues++
+}
+
+// writeParquet writes the columns as a Parquet file with a single row group.
+// All columns must hold the same number of values.
+func writeParquet(w io.Writer, columns []*parquetColumn) error {
+	numRows := 0
+	if len(columns) > 0 {
+		numRows = columns[0].numValues
+	}
+	for _, column := range columns {
+		if column.numValues != numRows {
+			return fmt.Errorf("column %s has %d values, expected %d", column.name, column.numValues, numRows)
+		}
+	}
+
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+
+	// column chunks, each a single data page
+	chunks := make([]*thriftCompactWriter, len(columns))
+	totalSize := int64(0)
+	for i, column := range columns {
+		offset := int64(file.Len())
+
+		header := &thriftCompactWriter{}
+		header.fieldI32(1, 0) // type: DATA_PAGE
+		header.fieldI32(2, int32(column.data.Len()))
+		header.fieldI32(3, int32(column.data.Len()))
+		header.beginStruct(5) // data_page_header
+		header.fieldI32(1, int32(column.numValues))
+		header.fieldI32(2, 0) // encoding: PLAIN
+		header.fieldI32(3, 3) // definition_level_encoding: RLE
+		header.fieldI32(4, 3) // repetition_level_encoding: RLE
+		header.endStruct()
+		header.stop()
+
+		file.Write(header.buf.Bytes())
+		file.Write(column.data.Bytes())
+		size := int64(header.buf.Len() + column.data.Len())
+		totalSize += size
+
+		chunk := &thriftCompactWriter{}
+		chunk.fieldI64(2, offset) // file_offset
+		chunk.beginStruct(3)      // meta_data
+		chunk.fieldI32(1, column.physicalType)
+		chunk.fieldListI32(2, []int32{0}) // encodings: PLAIN
+		chunk.fieldListString(3, []string{column.name})
+		chunk.fieldI32(4, 0) // codec: UNCOMPRESSED
+		chunk.fieldI64(5, int64(column.numValues))
+		chunk.fieldI64(6, size)
+		chunk.fieldI64(7, size)
+		chunk.fieldI64(9, offset) // data_page_offset
+		chunk.endStruct()
+		chunk.stop()
+		chunks[i] = chunk
+	}
+
+	meta := &thriftCompactWriter{}
+	meta.fieldI32(1, 1) // version
+
+	// schema: a root element followed by the columns
+	meta.beginList(2, thriftStruct, len(columns)+1)
+	meta.beginListStruct()
+	meta.fieldString(4, "schema")
+	meta.fieldI32(5, int32(len(columns)))
+	meta.endListStruct()
+	for _, column := range columns {
+		meta.beginListStruct()
+		meta.fieldI32(1, column.physicalType)
+		meta.fieldI32(3, 0) // repetition_type: REQUIRED
+		meta.fieldString(4, column.name)
+		if column.convertedType != parquetNoConvertedType {
+			meta.fieldI32(6, column.convertedType)
+		}
+		meta.endListStruct()
+	}
+
+	meta.fieldI64(3, int64(numRows))
+
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginListStruct()
+	meta.beginList(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.buf.Write(chunk.buf.Bytes())
+	}
+	meta.fieldI64(2, totalSize)
+	meta.fieldI64(3, int64(numRows))
+	meta.endListStruct()
+
+	meta.fieldString(6, "deployment-tracking")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString("PAR1")
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    byte = 5
+	thriftI64    byte = 6
+	thriftBinary byte = 8
+	thriftList   byte = 9
+	thriftStruct byte = 12
+)
+
+// thriftCompactWriter encodes the subset of the Thrift compact protocol used by the Parquet metadata.
+type thriftCompactWriter struct {
+	buf         bytes.Buffer
+	lastField   int16
+	fieldsStack []int16
+}
+
+func (t *thriftCompactWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	t.buf.Write(b[:n])
+}
+
+func (t *thriftCompactWriter) zigzag(v int64) {
+	t.varint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftCompactWriter) fieldHeader(id int16, typ byte) {
+	delta := id - t.lastField
+	if delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.lastField = id
+}
+
+func (t *thriftCompactWriter) fieldI32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftCompactWriter) fieldI64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftCompactWriter) fieldString(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftCompactWriter) beginList(id int16, elemType byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xF0 | elemType)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftCompactWriter) fieldListI32(id int16, values []int32) {
+	t.beginList(id, thriftI32, len(values))
+	for _, v := range values {
+		t.zigzag(int64(v))
+	}
+}
+
+func (t *thriftCompactWriter) fieldListString(id int16, values []string) {
+	t.beginList(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.varint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
+
+// beginStruct starts a struct-valued field; field IDs inside it are relative to the struct.
+func (t *thriftCompactWriter) beginStruct(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.beginListStruct()
+}
+
+func (t *thriftCompactWriter) endStruct() {
+	t.endListStruct()
+}
+
+// beginListStruct starts a struct that is an element of a list.
+func (t *thriftCompactWriter) beginListStruct() {
+	t.fieldsStack = append(t.fieldsStack, t.lastField)
+	t.lastField = 0
+}
+
+func (t *thriftCompactWriter) endListStruct() {
+	t.stop()
+	t.lastField = t.fieldsStack[len(t.fieldsStack)-1]
+	t.fieldsStack = t.fieldsStack[:len(t.fieldsStack)-1]
+}
+
+func (t *thriftCompactWriter) stop() {
+	t.buf.WriteByte(0)
+}
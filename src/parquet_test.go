@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// thriftReader decodes the Thrift compact protocol independently of thriftCompactWriter, into
+// structs as maps from field IDs to values, following the Thrift compact protocol spec.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic("invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size, elemType := int(header>>4), header&0x0f
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(elemType)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("unexpected thrift type %d", typ))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := map[int16]interface{}{}
+	last := int16(0)
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+		last = id
+	}
+}
+
+// TestWriteParquetRoundTrip writes a file and reads it back by the Parquet format spec: magic
+// bytes, footer length, FileMetaData, and the PLAIN values of each column's data page.
+func TestWriteParquetRoundTrip(t *testing.T) {
+	ts := newParquetColumn("ts", parquetInt64, parquetTimestampMillis)
+	bidId := newParquetColumn("bid_id", parquetInt32, parquetNoConvertedType)
+	denom := newParquetColumn("denom", parquetByteArray, parquetUTF8)
+	usd := newParquetColumn("usd", parquetDouble, parquetNoConvertedType)
+	rows := []struct {
+		ts    int64
+		bidId int32
+		denom string
+		usd   float64
+	}{
+		{1740787200000, 42, "uatom", 6976.354},
+		{1740787260000, -1, "", -0.5},
+		{1740787320000, 7, "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2", math.MaxFloat64},
+	}
+	for _, row := range rows {
+		ts.AppendInt64(row.ts)
+		bidId.AppendInt32(row.bidId)
+		denom.AppendString(row.denom)
+		usd.AppendDouble(row.usd)
+	}
+
+	var buf bytes.Buffer
+	if err := writeParquet(&buf, []*parquetColumn{ts, bidId, denom, usd}); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+
+	if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("missing magic bytes")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLength
+	footer := &thriftReader{data: file[:len(file)-8], pos: footerStart}
+	meta := footer.readStruct()
+	if footer.pos != len(file)-8 {
+		t.Fatalf("footer of %d bytes ends at %d, want %d", footerLength, footer.pos, len(file)-8)
+	}
+
+	// FileMetaData: 1 version, 2 schema, 3 num_rows, 4 row_groups, 6 created_by
+	if meta[1] != int64(1) || meta[3] != int64(len(rows)) || meta[6] != "deployment-tracking" {
+		t.Errorf("file metadata: version %v, num_rows %v, created_by %v", meta[1], meta[3], meta[6])
+	}
+
+	// SchemaElement: 1 type, 3 repetition_type, 4 name, 5 num_children, 6 converted_type
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if root[4] != "schema" || root[5] != int64(4) {
+		t.Errorf("schema root: %v", root)
+	}
+	wantSchema := []map[int16]interface{}{
+		{1: int64(parquetInt64), 3: int64(0), 4: "ts", 6: int64(parquetTimestampMillis)},
+		{1: int64(parquetInt32), 3: int64(0), 4: "bid_id"},
+		{1: int64(parquetByteArray), 3: int64(0), 4: "denom", 6: int64(parquetUTF8)},
+		{1: int64(parquetDouble), 3: int64(0), 4: "usd"},
+	}
+	for i, want := range wantSchema {
+		if got := fmt.Sprint(schema[i+1]); got != fmt.Sprint(want) {
+			t.Errorf("schema element %d: got %s, want %s", i+1, got, fmt.Sprint(want))
+		}
+	}
+
+	// RowGroup: 1 columns, 2 total_byte_size, 3 num_rows
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("got %d row groups", len(rowGroups))
+	}
+	rowGroup := rowGroups[0].(map[int16]interface{})
+	if rowGroup[3] != int64(len(rows)) {
+		t.Errorf("row group num_rows: %v", rowGroup[3])
+	}
+
+	values := make([][]interface{}, 4)
+	totalSize := int64(0)
+	for i, c := range rowGroup[1].([]interface{}) {
+		// ColumnChunk: 2 file_offset, 3 meta_data; ColumnMetaData: 1 type, 2 encodings,
+		// 3 path_in_schema, 4 codec, 5 num_values, 6 total_uncompressed_size, 7 total_compressed_size,
+		// 9 data_page_offset
+		chunk := c.(map[int16]interface{})[3].(map[int16]interface{})
+		if chunk[3].([]interface{})[0] != wantSchema[i][4] || chunk[4] != int64(0) || chunk[5] != int64(len(rows)) {
+			t.Errorf("column chunk %d: %v", i, chunk)
+		}
+		totalSize += chunk[6].(int64)
+
+		// PageHeader: 1 type, 2 uncompressed_page_size, 3 compressed_page_size, 5 data_page_header
+		page := &thriftReader{data: file, pos: int(chunk[9].(int64))}
+		header := page.readStruct()
+		dataHeader := header[5].(map[int16]interface{})
+		if header[1] != int64(0) || dataHeader[1] != int64(len(rows)) || dataHeader[2] != int64(0) {
+			t.Errorf("page header of column %d: %v", i, header)
+		}
+		if headerSize := int64(page.pos) - chunk[9].(int64); headerSize+header[3].(int64) != chunk[7].(int64) {
+			t.Errorf("column %d: page of %d bytes in a chunk of %d", i, headerSize+header[3].(int64), chunk[7])
+		}
+
+		data := file[page.pos : page.pos+int(header[3].(int64))]
+		for len(data) > 0 {
+			switch chunk[1] {
+			case int64(parquetInt64):
+				values[i] = append(values[i], int64(binary.LittleEndian.Uint64(data)))
+				data = data[8:]
+			case int64(parquetInt32):
+				values[i] = append(values[i], int32(binary.LittleEndian.Uint32(data)))
+				data = data[4:]
+			case int64(parquetDouble):
+				values[i] = append(values[i], math.Float64frombits(binary.LittleEndian.Uint64(data)))
+				data = data[8:]
+			case int64(parquetByteArray):
+				n := binary.LittleEndian.Uint32(data)
+				values[i] = append(values[i], string(data[4:4+n]))
+				data = data[4+n:]
+			}
+		}
+	}
+	if rowGroup[2] != totalSize {
+		t.Errorf("row group total_byte_size %v, columns add up to %d", rowGroup[2], totalSize)
+	}
+
+	for r, row := range rows {
+		got := []interface{}{values[0][r], values[1][r], values[2][r], values[3][r]}
+		want := []interface{}{row.ts, row.bidId, row.denom, row.usd}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("row %d: got %v, want %v", r, got, want)
+		}
+	}
+}
+
+func TestWriteParquetRejectsRaggedColumns(t *testing.T) {
+	a := newParquetColumn("a", parquetInt32, parquetNoConvertedType)
+	b := newParquetColumn("b", parquetInt32, parquetNoConvertedType)
+	a.AppendInt32(1)
+	if err := writeParquet(&bytes.Buffer{}, []*parquetColumn{a, b}); err == nil {
+		t.Errorf("expected an error for columns of different lengths")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"time"
+)
+
+// Sources of the deployment date used for annualization.
+const (
+	DeployedAtAllocations = "allocations"
+	DeployedAtVenueConfig = "venue_config"
+	DeployedAtRoundStart  = "round_start"
+)
+
+// BidPerformance summarizes the return of a bid since its deployment, in ATOM.
+type BidPerformance struct {
+	DeployedAt         time.Time `json:"deployed_at"`
+	DeployedAtSource   string    `json:"deployed_at_source"`
+	EndDate            time.Time `json:"end_date"` // now for live bids, the last withdrawal for exited ones
+	DurationDays       float64   `json:"duration_days"`
+	CurrentValueAtom   float64   `json:"current_value_atom"`
+	WithdrawnAtom      float64   `json:"withdrawn_atom"`
+	CompoundedInAtom   float64   `json:"compounded_in_atom"`
+	TransferredInAtom  float64   `json:"transferred_in_atom"`
+	TransferredOutAtom float64   `json:"transferred_out_atom"`
+	ReturnAtom         float64   `json:"return_atom"`
+	// TimeWeightedAllocationAtom is the allocation averaged over the period, which the APR is based
+	// on, so that later installments only count for the time they were deployed.
+	TimeWeightedAllocationAtom float64 `json:"time_weighted_allocation_atom"`
+	// ReturnPercent is relative to the net deployed amount, or to all the funds the bid received
+	// once it exited.
+	ReturnPercent float64  `json:"return_percent"`
+	APR           *float64 `json:"apr"` // nil if it can't be computed reliably
+	// USD is only set for bids in USD-denominated venues.
+	USD *USDPerformance `json:"usd,omitempty"`
+}
+
+// bidDeploymentDate returns the date from which the bid's funds count as deployed,
+// and where that date was taken from.
+func bidDeploymentDate(bidId int, bidConfig BidPositionConfig) (time.Time, string, bool) {
+	if deployedAt, source := configuredDeploymentDate(bidId, bidConfig); source != "" {
+		return deployedAt, source, true
+	}
+
+	if round, ok := roundForBid(bidId); ok {
+		return round.Start, DeployedAtRoundStart, true
+	}
+	return time.Time{}, "", false
+}
+
+// configuredDeploymentDate returns the deployment date of a bid from its allocations or venue
+// configs, and its source, which is empty if neither has one.
+func configuredDeploymentDate(bidId int, bidConfig BidPositionConfig) (time.Time, string) {
+	if len(bidConfig.Allocations) > 0 {
+		return allocationTranches(bidConfig, time.Time{})[0].Date, DeployedAtAllocations
+	}
+
+	// the earliest venue deployment, including venues that were migrated into this bid's venues
+	var deployedAt time.Time
+	for _, venueConfig := range productionVenues(bidConfig) {
+		for _, id := range venueLineage(venueID(bidId, venueConfig)) {
+			venueDeployedAt := venueDeploymentDate(id)
+			if !venueDeployedAt.IsZero() && (deployedAt.IsZero() || venueDeployedAt.Before(deployedAt)) {
+				deployedAt = venueDeployedAt
+			}
+		}
+	}
+	if !deployedAt.IsZero() {
+		return deployedAt, DeployedAtVenueConfig
+	}
+	return time.Time{}, ""
+}
+
+// computeBidPerformance computes the return and APR of a bid from its current holdings and withdrawals.
+// It returns nil if the bid can't be valued at all.
+func computeBidPerformance(bidId int, bidConfig BidPositionConfig, holdings []VenueHoldings, now time.Time) *BidPerformance {
+	if totalAllocatedAtom(bidConfig) <= 0 || holdings == nil {
+		return nil
+	}
+
+	deployedAt, source, ok := bidDeploymentDate(bidId, bidConfig)
+	if !ok {
+		return nil
+	}
+
+	currentValue := 0.0
+	infoMissing := false
+	for _, venueHoldings := range holdings {
+		if venueHoldings.InfoMissing || venueHoldings.Pending {
+			infoMissing = true
+			continue
+		}
+		if venueHoldings.AddressPrincipal != nil {
+			currentValue += venueHoldings.AddressPrincipal.TotalAtom
+		}
+		if venueHoldings.AddressRewards != nil {
+			currentValue += venueHoldings.AddressRewards.TotalAtom
+		}
+	}
+
+	withdrawn := 0.0
+	withdrawnKnown := true
+	var lastWithdrawal time.Time
+	for _, withdrawal := range bidConfig.Withdrawals {
+		// like transfers, withdrawals after now haven't happened yet, e.g. in a past snapshot
+		if withdrawal.Date.After(now) {
+			continue
+		}
+		amount := withdrawal.WithdrawnAmount
+		if amount == 0 {
+			// compounded withdrawals may only record the amounts per target
+			for _, target := range withdrawal.CompoundingTargets() {
+				amount += target.Amount
+			}
+		}
+		if amount == 0 && len(withdrawal.CompoundingTargets()) > 0 {
+			withdrawnKnown = false
+		}
+
+		withdrawn += amount
+		if withdrawal.Date.After(lastWithdrawal) {
+			lastWithdrawal = withdrawal.Date
+		}
+	}
+	// transfers to other bids leave the bid like withdrawals
+	for _, transfer := range bidConfig.Transfers {
+		if !transfer.Date.After(now) && transfer.Date.After(lastWithdrawal) {
+			lastWithdrawal = transfer.Date
+		}
+	}
+
+	// A bid with withdrawals and nothing left in its venues has exited at its last withdrawal.
+	// For venues we have no integration for, the withdrawals are the only data we have.
+	exited := !lastWithdrawal.IsZero() && currentValue == 0
+	endDate := now
+	if exited {
+		endDate = lastWithdrawal
+	}
+
+	compoundedIn, compoundedInKnown := compoundedIntoBid(bidId, endDate)
+	transfersIn, transfersOut := transferredIn(bidId, endDate), transferredOut(bidConfig, endDate)
+	allocated := allocatedAtom(bidConfig, endDate)
+	netDeployed := netDeployedAtom(allocated, withdrawn, compoundedIn) + transfersIn - transfersOut
+	returnAtom := currentValue - netDeployed
+
+	// funds received in total, for bids that have withdrawn everything
+	denominator := allocated + compoundedIn + transfersIn
+	if !exited && netDeployed > 0 {
+		denominator = netDeployed
+	}
+	timeWeighted := timeWeightedAllocationAtom(bidConfig, deployedAt, endDate)
+
+	performance := &BidPerformance{
+		DeployedAt:         deployedAt,
+		DeployedAtSource:   source,
+		EndDate:            endDate,
+		DurationDays:       endDate.Sub(deployedAt).Hours() / 24,
+		CurrentValueAtom:   currentValue,
+		WithdrawnAtom:      withdrawn,
+		CompoundedInAtom:   compoundedIn,
+		TransferredInAtom:  transfersIn,
+		TransferredOutAtom: transfersOut,
+		ReturnAtom:         returnAtom,
+		ReturnPercent:      returnAtom / denominator * 100,
+
+		TimeWeightedAllocationAtom: timeWeighted,
+	}
+
+	// the return is only meaningful if all the value of the bid is known
+	valueKnown := withdrawnKnown && compoundedInKnown && (!infoMissing || exited)
+	// the allocation in the denominator is replaced by its time-weighted average, which is the
+	// same for bids that received all their funds at once
+	aprDenominator := denominator - allocated + timeWeighted
+	if valueKnown && performance.DurationDays >= 1 && aprDenominator > 0 {
+		apr := returnAtom / aprDenominator * 100 * 365 / performance.DurationDays
+		performance.APR = &apr
+	}
+
+	return performance
+}
+
+// venueDeploymentDate returns the configured deployment date of a venue, or the zero time.
+func venueDeploymentDate(id string) time.Time {
+	_, venueConfig, ok := findVenue(id)
+	if !ok {
+		return time.Time{}
+	}
+	return venueConfig.GetMetadata().DeployedAt
+}
+
+// netDeployedAtom is the amount a bid has deployed after its withdrawals and the withdrawals of
+// other bids that were compounded into it, before transfers.
+func netDeployedAtom(allocated float64, withdrawn float64, compoundedIn float64) float64 {
+	return allocated - withdrawn + compoundedIn
+}
+
+// compoundedIntoBid sums the withdrawals of other bids until the given time that were compounded
+// into the bid. It also reports whether all their amounts are known.
+func compoundedIntoBid(bidId int, until time.Time) (float64, bool) {
+	amount := 0.0
+	known := true
+	for _, bidConfig := range activeBids() {
+		for _, withdrawal := range bidConfig.Withdrawals {
+			if withdrawal.Date.After(until) {
+				continue
+			}
+			for _, target := range withdrawal.CompoundingTargets() {
+				if target.BidId != bidId {
+					continue
+				}
+				if target.Amount == 0 {
+					known = false
+				}
+				amount += target.Amount
+			}
+		}
+	}
+	return amount, known
+}
+
+// bidNetDeployed returns the net deployed amount of a bid at the given time.
+func bidNetDeployed(bidId int, bidConfig BidPositionConfig, at time.Time) float64 {
+	compoundedIn, _ := compoundedIntoBid(bidId, at)
+	return netDeployedAtom(allocatedAtom(bidConfig, at), bidWithdrawnAtom(bidConfig, at), compoundedIn) +
+		transferredIn(bidId, at) - transferredOut(bidConfig, at)
+}
+
+// bidWithdrawnAtom sums the withdrawals of a bid until the given time.
+func bidWithdrawnAtom(bidConfig BidPositionConfig, until time.Time) float64 {
+	withdrawn := 0.0
+	for _, withdrawal := range bidConfig.Withdrawals {
+		if withdrawal.Date.After(until) {
+			continue
+		}
+		amount := withdrawal.WithdrawnAmount
+		if amount == 0 {
+			for _, target := range withdrawal.CompoundingTargets() {
+				amount += target.Amount
+			}
+		}
+		withdrawn += amount
+	}
+	return withdrawn
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeBidPerformanceIgnoresLaterWithdrawals(t *testing.T) {
+	const bidId = 1000
+	day := func(month time.Month, d int) time.Time { return time.Date(2025, month, d, 0, 0, 0, 0, time.UTC) }
+	bidConfig := BidPositionConfig{
+		Allocations: []Allocation{{Date: day(1, 1), Amount: 1000}},
+		Withdrawals: []Withdrawal{{Date: day(3, 1), WithdrawnAmount: 400}},
+		Transfers:   []Transfer{{Date: day(3, 1), ToBidId: bidId + 1, Amount: 100}},
+	}
+	previous := activeBids()
+	setActiveBids(map[int]BidPositionConfig{bidId: bidConfig})
+	defer setActiveBids(previous)
+
+	holdings := func(atom float64) []VenueHoldings {
+		return []VenueHoldings{{VenueID: "1000:osmosis:1", AddressPrincipal: &Holdings{TotalAtom: atom}}}
+	}
+
+	tests := []struct {
+		name                         string
+		now                          time.Time
+		value                        float64
+		withdrawn, transferred, gain float64
+	}{
+		{"before the withdrawal", day(2, 1), 1010, 0, 0, 10},
+		{"after the withdrawal", day(3, 15), 520, 400, 100, 20},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			performance := computeBidPerformance(bidId, bidConfig, holdings(test.value), test.now)
+			if performance == nil {
+				t.Fatal("no performance")
+			}
+			if performance.WithdrawnAtom != test.withdrawn {
+				t.Errorf("withdrawn %v, want %v", performance.WithdrawnAtom, test.withdrawn)
+			}
+			if performance.TransferredOutAtom != test.transferred {
+				t.Errorf("transferred out %v, want %v", performance.TransferredOutAtom, test.transferred)
+			}
+			if math.Abs(performance.ReturnAtom-test.gain) > 1e-9 {
+				t.Errorf("return %v, want %v", performance.ReturnAtom, test.gain)
+			}
+			if !performance.EndDate.Equal(test.now) {
+				t.Errorf("end date %s, want %s", performance.EndDate, test.now)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Pool types of venues.
+const (
+	PoolTypeCL         = "cl"  // concentrated liquidity
+	PoolTypeXYK        = "xyk" // constant product
+	PoolTypeStableswap = "stableswap"
+	PoolTypeLending    = "lending"
+	PoolTypePerp       = "perp"  // liquidity for perpetuals traders
+	PoolTypeVault      = "vault" // deposits managed by a strategy
+	PoolTypeStaking    = "staking"
+)
+
+var poolTypes = map[string]bool{
+	PoolTypeCL:         true,
+	PoolTypeXYK:        true,
+	PoolTypeStableswap: true,
+	PoolTypeLending:    true,
+	PoolTypePerp:       true,
+	PoolTypeVault:      true,
+	PoolTypeStaking:    true,
+}
+
+// PoolComposition describes the pool of a venue from its config alone, so that clients can group
+// and label venues before they are valued.
+type PoolComposition struct {
+	Type   string   `json:"type,omitempty"`
+	Assets []string `json:"assets"` // denoms of the assets of the pool
+}
+
+// venuePoolComposition returns the pool of a venue: its type and assets as far as the config of
+// its protocol tells them, completed or overridden by the PoolType and PoolAssets of the venue.
+// It returns nil if nothing is known about the pool.
+func venuePoolComposition(venueConfig VenuePositionConfig) *PoolComposition {
+	pool := &PoolComposition{Assets: []string{}}
+
+	switch venueConfig := venueConfig.(type) {
+	case OsmosisVenuePositionConfig:
+		pool.Type = PoolTypeXYK
+		if venueConfig.PositionID != "" {
+			pool.Type = PoolTypeCL
+		}
+	case AstroportVenuePositionConfig, ShadeVenuePositionConfig, WhiteWhaleVenuePositionConfig:
+		pool.Type = PoolTypeXYK
+	case DualityVenuePositionConfig:
+		pool.Type = PoolTypeCL
+	case ElysVenuePositionConfig:
+		pool.Type = PoolTypeXYK
+		if venueConfig.PoolType == Stablestake {
+			pool.Type = PoolTypeLending
+		}
+	case DemexVenuePositionConfig, LevanaVenuePositionConfig:
+		pool.Type = PoolTypePerp
+	case InterVenuePositionConfig:
+		if venueConfig.PSMAnchor != "" {
+			pool.Assets = append(pool.Assets, venueConfig.AnchorDenom, IST_DENOM)
+		} else {
+			pool.Assets = append(pool.Assets, venueConfig.CollateralDenom, IST_DENOM)
+		}
+	case MagmaVenuePositionConfig:
+		pool.Type = PoolTypeVault
+		pool.Assets = append(pool.Assets, venueConfig.Token0Denom, venueConfig.Token1Denom)
+	case MarginedVenuePositionConfig, MitoVenuePositionConfig, QuasarVenuePositionConfig:
+		pool.Type = PoolTypeVault
+	case DropVenuePositionConfig:
+		pool.Assets = append(pool.Assets, venueConfig.DAtomDenom, NEUTRON_ATOM)
+	case GenericCosmWasmVenuePositionConfig:
+		pool.Assets = append(pool.Assets, venueConfig.Denom)
+		if venueConfig.RedemptionDenom != "" {
+			pool.Assets = append(pool.Assets, venueConfig.RedemptionDenom)
+		}
+	case UniswapV3VenuePositionConfig:
+		pool.Type = PoolTypeCL
+	case MarsVenuePositionConfig:
+		pool.Type = PoolTypeLending
+		pool.Assets = append(pool.Assets, venueConfig.DepositedDenom)
+	case NolusVenuePositionConfig:
+		pool.Type = PoolTypeLending
+		pool.Assets = append(pool.Assets, venueConfig.PoolContractToken)
+	case NeptuneVenuePositionConfig:
+		pool.Type = PoolTypeLending
+		pool.Assets = append(pool.Assets, venueConfig.Denom)
+	case UxVenuePositionConfig:
+		pool.Type = PoolTypeLending
+		pool.Assets = append(pool.Assets, venueConfig.Denom)
+	case StakingVenuePositionConfig:
+		pool.Type = PoolTypeStaking
+		pool.Assets = append(pool.Assets, HUB_ATOM)
+	}
+
+	metadata := venueConfig.GetMetadata()
+	if metadata.PoolType != "" {
+		pool.Type = metadata.PoolType
+	}
+	if len(metadata.PoolAssets) > 0 {
+		pool.Assets = metadata.PoolAssets
+	}
+
+	if pool.Type == "" && len(pool.Assets) == 0 {
+		return nil
+	}
+	return pool
+}
+
+// validatePoolType checks that the pool type a venue overrides is a known one.
+func validatePoolType(bidId int, venueConfig VenuePositionConfig) []error {
+	poolType := venueConfig.GetMetadata().PoolType
+	if poolType != "" && !poolTypes[poolType] {
+		return []error{fmt.Errorf("venue %s has unknown PoolType %q", venueID(bidId, venueConfig), poolType)}
+	}
+	return nil
+}
+
+// VenueInfo is a venue as configured, without its holdings.
+type VenueInfo struct {
+	VenueID      string           `json:"venue_id"`
+	BidId        int              `json:"bid_id"`
+	Protocol     Protocol         `json:"protocol"`
+	ProtocolLogo string           `json:"protocol_logo,omitempty"`
+	DisplayName  string           `json:"display_name"`
+	Pool         *PoolComposition `json:"pool,omitempty"`
+	Links        []VenueLink      `json:"links,omitempty"`
+	Testnet      bool             `json:"testnet,omitempty"`
+}
+
+// venuesHandler lists all venues with their metadata, e.g. for the UI to lay out the venues while
+// their holdings are computed.
+func venuesHandler(w http.ResponseWriter, r *http.Request) {
+	bids := activeBids()
+
+	venues := []VenueInfo{}
+	for _, bidId := range sortedBidIdsOf(bids) {
+		for _, venueConfig := range bids[bidId].Venues {
+			venues = append(venues, VenueInfo{
+				VenueID:      venueID(bidId, venueConfig),
+				BidId:        bidId,
+				Protocol:     venueConfig.GetProtocol(),
+				ProtocolLogo: protocolLogos[venueConfig.GetProtocol()],
+				DisplayName:  venueDisplayName(venueConfig, nil),
+				Pool:         venuePoolComposition(venueConfig),
+				Links:        venueLinks(venueConfig),
+				Testnet:      venueConfig.GetMetadata().Testnet,
+			})
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(venues, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+)
+
+// poolShareAlertPercent is the share of a venue's TVL above which our principal is an exit risk.
+// Zero disables the alert.
+var poolShareAlertPercent = 20.0
+
+var poolShareMetric = newGauge("venue_pool_share_percent",
+	"Share of the TVL of a venue held by our principal, in percent.")
+
+// checkPoolShare alerts if our principal in a venue exceeds the configured share of its TVL,
+// in which case exiting the position would move the pool considerably.
+func checkPoolShare(id string, venueHoldings *VenueHoldings) {
+	tvl, principal := venueHoldings.VenueTotal, venueHoldings.AddressPrincipal
+	if tvl == nil || principal == nil || tvl.TotalUSDC <= 0 {
+		return
+	}
+
+	share := principal.TotalUSDC / tvl.TotalUSDC * 100
+	poolShareMetric.Set(share, "venue", id)
+
+	if poolShareAlertPercent > 0 && share > poolShareAlertPercent {
+		fireAlert(AlertPoolShare, id, fmt.Sprintf("our principal of $%.0f is %.1f%% of the venue's TVL of $%.0f, over the threshold of %.1f%%",
+			principal.TotalUSDC, share, tvl.TotalUSDC, poolShareAlertPercent))
+	}
+}
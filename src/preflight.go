@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// PreflightTimeout bounds the computation of a single venue during preflight.
+	PreflightTimeout = 20 * time.Second
+	// PreflightParallelism is the number of venues checked concurrently.
+	PreflightParallelism = 4
+)
+
+// PreflightResult holds the outcome of checking a single venue.
+type PreflightResult struct {
+	BidId      int      `json:"bid_id"`
+	Protocol   Protocol `json:"protocol"`
+	PoolID     string   `json:"pool_id"`
+	Address    string   `json:"address"`
+	OK         bool     `json:"ok"`
+	Error      string   `json:"error,omitempty"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+// runPreflight computes every active venue once, each with its own short timeout,
+// and reports which of them returned data.
+func runPreflight(ctx context.Context, timeout time.Duration) []PreflightResult {
+	type preflightJob struct {
+		bidId       int
+		venueConfig VenuePositionConfig
+	}
+
+	var jobs []preflightJob
+	for bidId, bidConfig := range activeBids() {
+		if bidConfig.Frozen != nil {
+			continue
+		}
+		for _, venueConfig := range productionVenues(bidConfig) {
+			// venues we don't have an integration for can't fail
+			if _, ok := venueConfig.(MissingVenuePositionConfig); ok {
+				continue
+			}
+			jobs = append(jobs, preflightJob{bidId: bidId, venueConfig: venueConfig})
+		}
+	}
+
+	results := make([]PreflightResult, len(jobs))
+	sem := make(chan struct{}, PreflightParallelism)
+	var wg sync.WaitGroup
+
+	for i, job := range jobs {
+		wg.Add(1)
+		go func(i int, job preflightJob) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			venueCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			_, err := computeVenueHoldings(venueCtx, job.bidId, job.venueConfig)
+
+			result := PreflightResult{
+				BidId:      job.bidId,
+				Protocol:   job.venueConfig.GetProtocol(),
+				PoolID:     job.venueConfig.GetPoolID(),
+				Address:    job.venueConfig.GetAddress(),
+				OK:         err == nil,
+				DurationMs: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Error = err.Error()
+			}
+			results[i] = result
+		}(i, job)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].BidId != results[j].BidId {
+			return results[i].BidId < results[j].BidId
+		}
+		return results[i].PoolID < results[j].PoolID
+	})
+
+	return results
+}
+
+// formatPreflightTable renders preflight results as a plain-text table.
+func formatPreflightTable(results []PreflightResult) string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "BID\tPROTOCOL\tPOOL\tSTATUS\tDURATION\tERROR")
+	for _, result := range results {
+		status := "OK"
+		if !result.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%dms\t%s\n",
+			result.BidId, result.Protocol, result.PoolID, status, result.DurationMs, result.Error)
+	}
+	tw.Flush()
+
+	return buf.String()
+}
+
+// logPreflight runs the preflight and logs the resulting table.
+func logPreflight() {
+	results := runPreflight(context.Background(), PreflightTimeout)
+
+	failed := 0
+	for _, result := range results {
+		if !result.OK {
+			failed++
+		}
+	}
+
+	log.Printf("Preflight finished: %d/%d venues OK\n%s", len(results)-failed, len(results), formatPreflightTable(results))
+}
+
+// preflightHandler runs the preflight on demand.
+// It returns JSON by default, or a plain-text table with ?format=table.
+func preflightHandler(w http.ResponseWriter, r *http.Request) {
+	results := runPreflight(r.Context(), PreflightTimeout)
+
+	if r.URL.Query().Get("format") == "table" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(formatPreflightTable(results)))
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
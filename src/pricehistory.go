@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// PricePinDelay is how old a point in time must be before its historical prices are pinned. The
+// price history of the last day may still be filled in by the provider.
+const PricePinDelay = 24 * time.Hour
+
+// priceLedgerFile is the file in the snapshot directory that the pinned prices are kept in.
+const priceLedgerFile = "historical_prices.jsonl"
+
+// SnapshotPrices are the USD prices a snapshot was valued at: the ATOM price all ATOM values were
+// converted at, and the price of every denom held, implied by its value.
+type SnapshotPrices struct {
+	TakenAt time.Time          `json:"taken_at"`
+	AtomUSD float64            `json:"atom_usd"`
+	Denoms  map[string]float64 `json:"denoms"`
+}
+
+// snapshotPrices collects the prices holdings were valued at, or returns nil if there is no price
+// snapshot.
+func snapshotPrices(holdings []VenueHoldings, snapshot *PriceSnapshot) *SnapshotPrices {
+	if snapshot == nil {
+		return nil
+	}
+
+	prices := &SnapshotPrices{TakenAt: snapshot.TakenAt, AtomUSD: snapshot.atomPrice(), Denoms: make(map[string]float64)}
+	for _, venueHoldings := range holdings {
+		for _, h := range []*Holdings{venueHoldings.AddressPrincipal, venueHoldings.AddressRewards} {
+			if h == nil {
+				continue
+			}
+			for _, asset := range h.allAssets() {
+				if asset.Amount != 0 && asset.USDValue != 0 {
+					prices.Denoms[asset.Denom] = asset.USDValue / asset.Amount
+				}
+			}
+		}
+	}
+	return prices
+}
+
+// PinnedPrice is a historical price as it was first looked up.
+type PinnedPrice struct {
+	Denom    string    `json:"denom"`
+	At       time.Time `json:"at"`
+	USD      float64   `json:"usd"`
+	PinnedAt time.Time `json:"pinned_at"`
+}
+
+// PriceLedger pins historical prices once they were looked up, so that values derived from them,
+// like the USD performance of a bid, don't change when the provider revises its history. Prices
+// are appended as JSON lines and never rewritten.
+type PriceLedger struct {
+	path   string
+	mu     sync.Mutex
+	prices map[string]float64
+}
+
+// priceLedger is nil if snapshots are disabled, in which case historical prices aren't pinned.
+var priceLedger *PriceLedger
+
+func pinnedPriceKey(denom string, at time.Time) string {
+	return denom + "@" + strconv.FormatInt(at.Unix(), 10)
+}
+
+// NewPriceLedger loads the prices pinned in the given directory.
+func NewPriceLedger(dir string) (*PriceLedger, error) {
+	l := &PriceLedger{path: filepath.Join(dir, priceLedgerFile), prices: make(map[string]float64)}
+
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening price ledger: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var price PinnedPrice
+		if err := json.Unmarshal(scanner.Bytes(), &price); err != nil {
+			return nil, fmt.Errorf("decoding price ledger: %v", err)
+		}
+		// the first price pinned wins, should the ledger have been appended to concurrently
+		key := pinnedPriceKey(price.Denom, price.At)
+		if _, ok := l.prices[key]; !ok {
+			l.prices[key] = price.USD
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading price ledger: %v", err)
+	}
+
+	return l, nil
+}
+
+// Price returns the historical USD price of a denom at a time, looking it up with fetch and pinning
+// it if it isn't pinned yet. Prices of the last PricePinDelay are looked up, but not pinned.
+func (l *PriceLedger) Price(denom string, at time.Time, fetch func() (float64, error)) (float64, error) {
+	if l == nil {
+		return fetch()
+	}
+
+	key := pinnedPriceKey(denom, at)
+	l.mu.Lock()
+	price, ok := l.prices[key]
+	l.mu.Unlock()
+	if ok {
+		return price, nil
+	}
+
+	price, err := fetch()
+	if err != nil || price <= 0 || time.Since(at) < PricePinDelay {
+		return price, err
+	}
+
+	if err := l.pin(PinnedPrice{Denom: denom, At: at.UTC(), USD: price, PinnedAt: time.Now().UTC()}); err != nil {
+		log.Printf("Failed to pin the price of %s at %s: %v", denom, at.Format(time.RFC3339), err)
+	}
+	return price, nil
+}
+
+func (l *PriceLedger) pin(price PinnedPrice) error {
+	line, err := json.Marshal(price)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := pinnedPriceKey(price.Denom, price.At)
+	if _, ok := l.prices[key]; ok {
+		return nil
+	}
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	l.prices[key] = price.USD
+	return nil
+}
@@ -1,27 +1,29 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
 func getTokenValues(
+	ctx context.Context,
 	adjustedAmount float64,
 	tokenInfo ChainTokenInfo,
 ) (float64, float64, error) {
-	price, err := getTokenPrice(tokenInfo.CoingeckoID)
+	price, err := getTokenPrice(ctx, tokenInfo.CoingeckoID)
 	if err != nil {
 		return 0, 0, fmt.Errorf("fetching token price: %s", err)
 	}
 
 	usdValue := adjustedAmount * price
-	atomPrice, err := getAtomPrice()
+	atomPrice, err := getAtomPrice(ctx)
 	if err != nil {
 		return 0, 0, fmt.Errorf("fetching ATOM price: %s", err)
 	}
@@ -57,6 +59,13 @@ var (
 
 const PriceCacheTTL = 30 * time.Minute
 
+// The caches are refreshed lazily by whichever caller finds them expired,
+// which can happen concurrently from request handlers and the background refresher.
+var (
+	priceCacheMu sync.Mutex
+	skipCacheMu  sync.Mutex
+)
+
 type SkipCache struct {
 	Assets    map[string]map[string]SkipAsset
 	Timestamp time.Time
@@ -68,7 +77,10 @@ type PriceCache struct {
 }
 
 // Fetch all prices in one call
-func initializePriceCache() error {
+func initializePriceCache(ctx context.Context) error {
+	priceCacheMu.Lock()
+	defer priceCacheMu.Unlock()
+
 	if pricesInitialized {
 		if time.Since(priceCache.Timestamp) < PriceCacheTTL {
 			return nil
@@ -76,10 +88,10 @@ func initializePriceCache() error {
 	}
 
 	// refresh skip assets
-	fetchSkipAssets()
+	fetchSkipAssets(ctx)
 
 	coinIDs := make(map[string]bool)
-	for _, chainAssets := range skipCache.Assets {
+	for _, chainAssets := range getSkipAssets() {
 		for _, asset := range chainAssets {
 			if asset.CoingeckoID != "" {
 				coinIDs[asset.CoingeckoID] = true
@@ -102,7 +114,16 @@ func initializePriceCache() error {
 		"coin_count": len(idList),
 	})
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %v", err)
+	}
+	// optional, raises the rate limit of the public API
+	if apiKey := getSecret("COINGECKO_API_KEY"); apiKey != "" {
+		req.Header.Set("x-cg-demo-api-key", apiKey)
+	}
+
+	resp, err := upstreamClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("fetching coingecko prices: %v", err)
 	}
@@ -135,7 +156,10 @@ func initializePriceCache() error {
 	return nil
 }
 
-func fetchSkipAssets() error {
+func fetchSkipAssets(ctx context.Context) error {
+	skipCacheMu.Lock()
+	defer skipCacheMu.Unlock()
+
 	// Check if cache is still valid
 	if skipCache != nil {
 		if time.Since(skipCache.Timestamp) < PriceCacheTTL {
@@ -143,7 +167,7 @@ func fetchSkipAssets() error {
 		}
 	}
 
-	resp, err := http.Get("https://api.skip.build/v2/fungible/assets")
+	resp, err := httpGet(ctx, "https://api.skip.build/v2/fungible/assets")
 	if err != nil {
 		return fmt.Errorf("fetching skip assets: %v", err)
 	}
@@ -171,27 +195,50 @@ func fetchSkipAssets() error {
 	return nil
 }
 
-func getTokenPrice(coingeckoId string) (float64, error) {
+// getSkipAssets returns the cached skip assets, keyed by chain ID and denom.
+func getSkipAssets() map[string]map[string]SkipAsset {
+	skipCacheMu.Lock()
+	defer skipCacheMu.Unlock()
+
+	if skipCache == nil {
+		return nil
+	}
+	return skipCache.Assets
+}
+
+func getTokenPrice(ctx context.Context, coingeckoId string) (float64, error) {
 	debugLog("Getting token price", map[string]string{
 		"token": coingeckoId,
 	})
 
+	// computations within a refresh cycle share the prices of its snapshot
+	if snapshot := priceSnapshotFromContext(ctx); snapshot != nil {
+		if price, ok := snapshot.Prices[coingeckoId]; ok {
+			traceFromContext(ctx).addPrice(coingeckoId, price)
+			return price, nil
+		}
+	}
+
 	// initialize the price cache (will be a no-op if the cache was already initialized
 	// and not expired yet)
-	if err := initializePriceCache(); err != nil {
+	if err := initializePriceCache(ctx); err != nil {
 		return 0, fmt.Errorf("refreshing price cache: %v", err)
 	}
 
+	priceCacheMu.Lock()
+	defer priceCacheMu.Unlock()
+
 	// Try cache again after refresh
 	if price, ok := priceCache.Prices[coingeckoId]; ok {
+		traceFromContext(ctx).addPrice(coingeckoId, price)
 		return price, nil
 	}
 
 	return 0, fmt.Errorf("no price found for token: %s", coingeckoId)
 }
 
-func getAtomPrice() (float64, error) {
-	return getTokenPrice("cosmos")
+func getAtomPrice(ctx context.Context) (float64, error) {
+	return getTokenPrice(ctx, "cosmos")
 }
 
 // Numia API types and constants
@@ -199,7 +246,9 @@ const (
 	NumiaAPIBaseURL = "https://osmosis.numia.xyz/tokens/v2"
 )
 
-var NumiaAuthToken = os.Getenv("NUMIA_API_TOKEN")
+func numiaAuthToken() string {
+	return getSecret("NUMIA_API_TOKEN")
+}
 
 type NumiaHistoricalPrice struct {
 	Time   int64   `json:"time"`
@@ -215,20 +264,19 @@ type NumiaRealtimePrice struct {
 	USDPrice float64 `json:"usd_price"`
 }
 
-func getNumiaPrice(denom string) (float64, error) {
+func getNumiaPrice(ctx context.Context, denom string) (float64, error) {
 	// Replace standard IBC slash with percent encoded value
 	encodedDenom := strings.Replace(denom, "ibc/", "ibc%2F", 1)
 	url := fmt.Sprintf("%s/real-time/%s/price", NumiaAPIBaseURL, encodedDenom)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return 0, fmt.Errorf("creating request: %v", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", NumiaAuthToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", numiaAuthToken()))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := upstreamClient.Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("fetching price data: %v", err)
 	}
@@ -242,31 +290,110 @@ func getNumiaPrice(denom string) (float64, error) {
 	return result.USDPrice, nil
 }
 
-func getNumiaHistoricalPrice(denom string, timestamp int64) (float64, error) {
+// getHistoricalPrice returns the USD price of a denom at a time, as pinned in the price ledger.
+// The price is looked up on Numia, falling back to CoinGecko by the coingecko ID of the denom if
+// Numia has no price for it or its token isn't set.
+func getHistoricalPrice(ctx context.Context, denom string, coingeckoID string, timestamp int64) (float64, error) {
+	return priceLedger.Price(denom, time.Unix(timestamp, 0), func() (float64, error) {
+		var numiaErr error
+		if numiaAuthToken() == "" {
+			numiaErr = fmt.Errorf("NUMIA_API_TOKEN is not set")
+		} else if prices, err := fetchNumiaPriceChart(ctx, denom); err != nil {
+			numiaErr = err
+		} else if price, err := closestHistoricalPrice(prices, timestamp); err != nil {
+			numiaErr = err
+		} else {
+			return price, nil
+		}
+
+		if coingeckoID == "" {
+			return 0, numiaErr
+		}
+		debugLog("Falling back to CoinGecko for historical price", map[string]interface{}{
+			"denom": denom,
+			"error": numiaErr.Error(),
+		})
+		prices, err := fetchCoingeckoPriceChart(ctx, coingeckoID, timestamp)
+		if err != nil {
+			return 0, fmt.Errorf("numia: %v; coingecko: %v", numiaErr, err)
+		}
+		return closestHistoricalPrice(prices, timestamp)
+	})
+}
+
+// fetchNumiaPriceChart fetches the price history of a denom.
+func fetchNumiaPriceChart(ctx context.Context, denom string) ([]NumiaHistoricalPrice, error) {
 	// Replace standard IBC slash with percent encoded value
 	encodedDenom := strings.Replace(denom, "ibc/", "ibc%2F", 1)
 	url := fmt.Sprintf("%s/historical/%s/chart", NumiaAPIBaseURL, encodedDenom)
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return 0, fmt.Errorf("creating request: %v", err)
+		return nil, fmt.Errorf("creating request: %v", err)
 	}
 
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", NumiaAuthToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", numiaAuthToken()))
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := upstreamClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("fetching historical price data: %v", err)
+		return nil, fmt.Errorf("fetching historical price data: %v", err)
 	}
 	defer resp.Body.Close()
 
 	var prices []NumiaHistoricalPrice
 	if err := json.NewDecoder(resp.Body).Decode(&prices); err != nil {
-		return 0, fmt.Errorf("decoding historical price response: %v", err)
+		return nil, fmt.Errorf("decoding historical price response: %v", err)
 	}
 
-	// Find the closest price point to the requested timestamp
+	return prices, nil
+}
+
+// CoingeckoChartWindow is how far around a time the CoinGecko price history is fetched, which
+// CoinGecko returns with hourly granularity.
+const CoingeckoChartWindow = 24 * time.Hour
+
+// fetchCoingeckoPriceChart fetches the price history of a coin around a time, as price points
+// whose close is the price.
+func fetchCoingeckoPriceChart(ctx context.Context, coingeckoID string, timestamp int64) ([]NumiaHistoricalPrice, error) {
+	window := int64(CoingeckoChartWindow.Seconds())
+	url := fmt.Sprintf("https://api.coingecko.com/api/v3/coins/%s/market_chart/range?vs_currency=usd&from=%d&to=%d",
+		coingeckoID, timestamp-window, timestamp+window)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %v", err)
+	}
+	if apiKey := getSecret("COINGECKO_API_KEY"); apiKey != "" {
+		req.Header.Set("x-cg-demo-api-key", apiKey)
+	}
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching coingecko price chart: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching coingecko price chart: unexpected status code: %d", resp.StatusCode)
+	}
+
+	// prices are [milliseconds, price] pairs
+	var result struct {
+		Prices [][2]float64 `json:"prices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding coingecko price chart: %v", err)
+	}
+
+	prices := make([]NumiaHistoricalPrice, 0, len(result.Prices))
+	for _, point := range result.Prices {
+		prices = append(prices, NumiaHistoricalPrice{Time: int64(point[0]) / 1000, Close: point[1]})
+	}
+	return prices, nil
+}
+
+// closestHistoricalPrice returns the closing price of the price point closest to the timestamp.
+func closestHistoricalPrice(prices []NumiaHistoricalPrice, timestamp int64) (float64, error) {
 	var closestPrice *NumiaHistoricalPrice
 	var smallestDiff int64 = math.MaxInt64
 
@@ -292,25 +419,25 @@ func abs64(n int64) int64 {
 	return n
 }
 
-func ComputeInitialHoldingsWithPrices(holdings *Holdings, assetData *ChainInfo, timestamp int64) (*Holdings, error) {
+func ComputeInitialHoldingsWithPrices(ctx context.Context, holdings *Holdings, assetData *ChainInfo, timestamp int64) (*Holdings, error) {
 	var assets []Asset
 	totalUSD := 0.0
 	totalAtom := 0.0
 
 	// Get ATOM price for conversion
-	atomPrice, err := getNumiaHistoricalPrice("ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2", timestamp)
+	atomPrice, err := getHistoricalPrice(ctx, OsmosisAtomDenom, "cosmos", timestamp)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get historical ATOM price: %v", err)
 	}
 
 	for _, asset := range holdings.Balances {
-		_, ok := assetData.Tokens[asset.Denom]
+		tokenInfo, ok := assetData.Tokens[asset.Denom]
 		if !ok {
 			continue
 		}
 
-		// Get historical price from Numia API
-		price, err := getNumiaHistoricalPrice(asset.Denom, timestamp)
+		// Get historical price from Numia API, or CoinGecko if Numia has none
+		price, err := getHistoricalPrice(ctx, asset.Denom, tokenInfo.CoingeckoID, timestamp)
 		if err != nil {
 			debugLog("Failed to get historical price, skipping asset", map[string]interface{}{
 				"denom": asset.Denom,
@@ -330,6 +457,7 @@ func ComputeInitialHoldingsWithPrices(holdings *Holdings, assetData *ChainInfo,
 			Amount:      asset.Amount,
 			DisplayName: asset.DisplayName,
 			USDValue:    usdValue,
+			AtomValue:   atomValue,
 		})
 	}
 
@@ -341,11 +469,7 @@ func ComputeInitialHoldingsWithPrices(holdings *Holdings, assetData *ChainInfo,
 }
 
 func init() {
-	if NumiaAuthToken == "" {
-		log.Fatal("NUMIA_API_TOKEN environment variable must be set")
-	}
-
-	if err := initializePriceCache(); err != nil {
+	if err := initializePriceCache(context.Background()); err != nil {
 		log.Printf("Warning: Failed to fetch Skip assets: %v", err)
 	}
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// PriceSnapshot is a copy of the USD prices at one point in time, by CoinGecko ID. All venues
+// computed while a snapshot is current use its prices, and all ATOM values of a response are
+// converted at its ATOM price, so that totals within a response are consistent.
+type PriceSnapshot struct {
+	TakenAt time.Time          `json:"taken_at"`
+	Prices  map[string]float64 `json:"prices"`
+}
+
+// currentPrices is replaced at the start of every refresh cycle, or once it is as old as the price
+// cache if the refresher is disabled.
+var currentPrices atomic.Pointer[PriceSnapshot]
+
+type priceSnapshotKey struct{}
+
+// withPriceSnapshot makes the price lookups of a computation use the given snapshot.
+func withPriceSnapshot(ctx context.Context, snapshot *PriceSnapshot) context.Context {
+	if snapshot == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, priceSnapshotKey{}, snapshot)
+}
+
+func priceSnapshotFromContext(ctx context.Context) *PriceSnapshot {
+	snapshot, _ := ctx.Value(priceSnapshotKey{}).(*PriceSnapshot)
+	return snapshot
+}
+
+// atomPrice returns the USD price of ATOM in the snapshot, or 0 if there is none.
+func (s *PriceSnapshot) atomPrice() float64 {
+	if s == nil {
+		return 0
+	}
+	return s.Prices["cosmos"]
+}
+
+// takePriceSnapshot refreshes the price cache if it expired and makes a copy of it the current snapshot.
+func takePriceSnapshot(ctx context.Context) (*PriceSnapshot, error) {
+	if err := initializePriceCache(ctx); err != nil {
+		return nil, err
+	}
+
+	priceCacheMu.Lock()
+	snapshot := &PriceSnapshot{TakenAt: priceCache.Timestamp, Prices: make(map[string]float64, len(priceCache.Prices))}
+	for id, price := range priceCache.Prices {
+		snapshot.Prices[id] = price
+	}
+	priceCacheMu.Unlock()
+
+	currentPrices.Store(snapshot)
+	return snapshot, nil
+}
+
+// priceSnapshotFor returns the current snapshot for a computation, taking a new one if there is none
+// or it is older than the price cache. Without a snapshot, the prices are looked up individually.
+func priceSnapshotFor(ctx context.Context) *PriceSnapshot {
+	snapshot := currentPrices.Load()
+	if isCachedOnly(ctx) || (snapshot != nil && time.Since(snapshot.TakenAt) < PriceCacheTTL) {
+		return snapshot
+	}
+
+	if fresh, err := takePriceSnapshot(ctx); err == nil {
+		return fresh
+	}
+	return snapshot
+}
+
+// revalueAtom converts the ATOM values of venues at the ATOM price of a snapshot. The venues may
+// have been computed at different times and thus ATOM prices. It returns copies, as the holdings are
+// shared with the caches.
+func revalueAtom(holdings []VenueHoldings, snapshot *PriceSnapshot) []VenueHoldings {
+	atomPrice := snapshot.atomPrice()
+	if atomPrice <= 0 || holdings == nil {
+		return holdings
+	}
+
+	revalue := func(h *Holdings) *Holdings {
+		if h == nil {
+			return nil
+		}
+		revalued := *h
+		revalued.Balances = append([]Asset(nil), h.Balances...)
+		if h.Liabilities != nil {
+			revalued.Liabilities = append([]Asset(nil), h.Liabilities...)
+		}
+		revalued.TotalAtom = h.TotalUSDC / atomPrice
+		revalued.fillAtomValues()
+		return &revalued
+	}
+
+	result := make([]VenueHoldings, len(holdings))
+	for i, venueHoldings := range holdings {
+		venueHoldings.VenueTotal = revalue(venueHoldings.VenueTotal)
+		venueHoldings.AddressPrincipal = revalue(venueHoldings.AddressPrincipal)
+		venueHoldings.AddressRewards = revalue(venueHoldings.AddressRewards)
+		if venueHoldings.RewardBreakdown != nil {
+			venueHoldings.RewardBreakdown = rewardBreakdown(venueHoldings.AddressRewards)
+		}
+		result[i] = venueHoldings
+	}
+	return result
+}
+
+// setPriceSnapshotHeaders exposes the snapshot a response was valued with.
+func setPriceSnapshotHeaders(w http.ResponseWriter, snapshot *PriceSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	w.Header().Set("X-Prices-Taken-At", snapshot.TakenAt.UTC().Format(time.RFC3339))
+	w.Header().Set("X-Atom-Price-USD", strconv.FormatFloat(snapshot.atomPrice(), 'f', -1, 64))
+}
+
+// pricesHandler serves the current price snapshot.
+func pricesHandler(w http.ResponseWriter, r *http.Request) {
+	snapshot := currentPrices.Load()
+	if snapshot == nil {
+		writeComputeError(w, errNotCached)
+		return
+	}
+
+	jsonData, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
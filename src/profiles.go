@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DefaultProfile is the production setup as configured in the code.
+const DefaultProfile = "prod"
+
+// Profile adapts the setup to an environment, e.g. staging pointing at testnet nodes.
+type Profile struct {
+	// Endpoints replace the URLs of protocols, by protocol slug. Empty URLs are kept.
+	Endpoints map[string]ProfileEndpoints `json:"endpoints,omitempty"`
+	// Verbose enables the verbose logging.
+	Verbose bool `json:"verbose,omitempty"`
+	// BidIds restricts the served bids to a subset, all bids if empty.
+	BidIds []int `json:"bid_ids,omitempty"`
+}
+
+// ProfileEndpoints are the URLs of a protocol.
+type ProfileEndpoints struct {
+	PoolInfoURL       string `json:"pool_info_url,omitempty"`
+	AddressBalanceURL string `json:"address_balance_url,omitempty"`
+	AssetListURL      string `json:"asset_list_url,omitempty"`
+}
+
+// loadProfile reads the named profile from a JSON file of profiles by name. The default profile,
+// also used if no name is given, is empty unless the file defines it.
+func loadProfile(path string, name string) (Profile, error) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	profiles := map[string]Profile{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Profile{}, fmt.Errorf("reading profiles: %v", err)
+		}
+		if err := json.Unmarshal(data, &profiles); err != nil {
+			return Profile{}, fmt.Errorf("decoding profiles: %v", err)
+		}
+	}
+
+	profile, ok := profiles[name]
+	if !ok && name != DefaultProfile {
+		names := make([]string, 0, len(profiles))
+		for profileName := range profiles {
+			names = append(names, profileName)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("unknown profile %q, the profiles are %v", name, names)
+	}
+	return profile, nil
+}
+
+// applyProfileSettings applies the endpoints and logging of a profile.
+func applyProfileSettings(profile Profile) error {
+	for slug, endpoints := range profile.Endpoints {
+		var protocol Protocol
+		for p := range protocolConfigMap {
+			if protocolSlug(p) == slug {
+				protocol = p
+			}
+		}
+		if protocol == "" {
+			return fmt.Errorf("unknown protocol: %s", slug)
+		}
+
+		config := protocolConfigMap[protocol]
+		if endpoints.PoolInfoURL != "" {
+			config.PoolInfoUrl = endpoints.PoolInfoURL
+		}
+		if endpoints.AddressBalanceURL != "" {
+			config.AddressBalanceUrl = endpoints.AddressBalanceURL
+		}
+		if endpoints.AssetListURL != "" {
+			config.AssetListURL = endpoints.AssetListURL
+		}
+		protocolConfigMap[protocol] = config
+	}
+
+	if profile.Verbose {
+		verboseLogging = true
+	}
+	return nil
+}
+
+// profileBids restricts bid configs to the subset of a profile.
+func profileBids(profile Profile, bids map[int]BidPositionConfig) (map[int]BidPositionConfig, error) {
+	if len(profile.BidIds) == 0 {
+		return bids, nil
+	}
+
+	subset := make(map[int]BidPositionConfig, len(profile.BidIds))
+	for _, bidId := range profile.BidIds {
+		bidConfig, ok := bids[bidId]
+		if !ok {
+			return nil, fmt.Errorf("unknown bid: %d", bidId)
+		}
+		subset[bidId] = bidConfig
+	}
+	return subset, nil
+}
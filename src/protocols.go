@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Capabilities of a protocol integration, i.e. which parts of a venue it can compute.
+const (
+	CapabilityTVL       = "tvl"
+	CapabilityPrincipal = "principal"
+	CapabilityRewards   = "rewards"
+)
+
+// emptyVenueConfigs are venue configs of the type of every protocol, to ask its integration for
+// its capabilities.
+var emptyVenueConfigs = map[Protocol]VenuePositionConfig{
+	Osmosis:          OsmosisVenuePositionConfig{},
+	Nolus:            NolusVenuePositionConfig{},
+	Mars:             MarsVenuePositionConfig{},
+	Magma:            MagmaVenuePositionConfig{},
+	Margined:         MarginedVenuePositionConfig{},
+	MarginedNeutron:  MarginedVenuePositionConfig{Protocol: MarginedNeutron},
+	AstroportNeutron: AstroportVenuePositionConfig{},
+	AstroportTerra:   AstroportVenuePositionConfig{},
+	Elys:             ElysVenuePositionConfig{},
+	CosmWasm:         GenericCosmWasmVenuePositionConfig{},
+	CosmWasmNeutron:  GenericCosmWasmVenuePositionConfig{Protocol: CosmWasmNeutron},
+	Inter:            InterVenuePositionConfig{},
+	Levana:           LevanaVenuePositionConfig{},
+	LevanaNeutron:    LevanaVenuePositionConfig{Protocol: LevanaNeutron},
+	Demex:            DemexVenuePositionConfig{},
+	Drop:             DropVenuePositionConfig{},
+	Duality:          DualityVenuePositionConfig{},
+	Mito:             MitoVenuePositionConfig{},
+	Neptune:          NeptuneVenuePositionConfig{},
+	Pryzm:            PryzmVenuePositionConfig{},
+	Quasar:           QuasarVenuePositionConfig{},
+	Shade:            ShadeVenuePositionConfig{},
+	Staking:          StakingVenuePositionConfig{},
+	UniswapV3:        UniswapV3VenuePositionConfig{},
+	UniswapV3Base:    UniswapV3VenuePositionConfig{Protocol: UniswapV3Base},
+	Ux:               UxVenuePositionConfig{},
+	WhiteWhale:       WhiteWhaleVenuePositionConfig{},
+}
+
+// protocolCapabilities returns the capabilities of the integration of a protocol.
+// Protocols without an integration have none.
+func protocolCapabilities(protocol Protocol) ProtocolCapabilities {
+	venueConfig, ok := emptyVenueConfigs[protocol]
+	if !ok {
+		venueConfig = MissingVenuePositionConfig{}
+	}
+	dexProtocol, err := NewDexProtocolFromConfig(protocolConfigMap[protocol], venueConfig)
+	if err != nil {
+		return ProtocolCapabilities{}
+	}
+	return dexProtocol.Capabilities()
+}
+
+// capabilityNames lists the supported capabilities.
+func (c ProtocolCapabilities) capabilityNames() []string {
+	names := []string{}
+	if c.SupportsTVL {
+		names = append(names, CapabilityTVL)
+	}
+	if c.SupportsPrincipal {
+		names = append(names, CapabilityPrincipal)
+	}
+	if c.SupportsRewards {
+		names = append(names, CapabilityRewards)
+	}
+	return names
+}
+
+// Health of a protocol.
+const (
+	ProtocolHealthy  = "healthy"
+	ProtocolDegraded = "degraded" // an upstream host is unhealthy or a venue failed
+	ProtocolUnknown  = "unknown"  // no requests were made yet
+)
+
+// ProtocolInfo describes a supported protocol for clients.
+type ProtocolInfo struct {
+	Protocol     Protocol `json:"protocol"`
+	Chain        string   `json:"chain,omitempty"` // chain registry name
+	Logo         string   `json:"logo,omitempty"`
+	Endpoints    []string `json:"endpoints"`
+	Capabilities []string `json:"capabilities"`
+	// RewardsIncludedInPrincipal is set if the protocol has no separate rewards because its principal grows.
+	RewardsIncludedInPrincipal bool   `json:"rewards_included_in_principal"`
+	Health                     string `json:"health"`
+	// UnhealthyEndpoints and FailingVenues explain a degraded health.
+	UnhealthyEndpoints []string `json:"unhealthy_endpoints"`
+	FailingVenues      []string `json:"failing_venues"`
+}
+
+// listProtocols describes all protocols, sorted by name.
+func listProtocols() []ProtocolInfo {
+	upstreams := make(map[string]UpstreamHealth)
+	for _, health := range getUpstreamHealth() {
+		upstreams[health.Host] = health
+	}
+
+	venueStateMu.Lock()
+	failingVenues := make(map[Protocol][]string)
+	for _, bidId := range sortedBidIds() {
+		for _, venueConfig := range activeBids()[bidId].Venues {
+			id := venueID(bidId, venueConfig)
+			if _, failed := venueErrors[id]; failed {
+				failingVenues[venueConfig.GetProtocol()] = append(failingVenues[venueConfig.GetProtocol()], id)
+			}
+		}
+	}
+	venueStateMu.Unlock()
+
+	protocols := make([]ProtocolInfo, 0, len(protocolConfigMap))
+	for protocol, config := range protocolConfigMap {
+		capabilities := protocolCapabilities(protocol)
+		info := ProtocolInfo{
+			Protocol:                   protocol,
+			Logo:                       protocolLogos[protocol],
+			Endpoints:                  []string{},
+			Capabilities:               capabilities.capabilityNames(),
+			RewardsIncludedInPrincipal: capabilities.RewardsIncludedInPrincipal,
+			Health:                     ProtocolUnknown,
+			UnhealthyEndpoints:         []string{},
+			FailingVenues:              failingVenues[protocol],
+		}
+		if info.FailingVenues == nil {
+			info.FailingVenues = []string{}
+		}
+		if chain, ok := strings.CutPrefix(config.AssetListURL, "https://chains.cosmos.directory/"); ok {
+			info.Chain = chain
+		}
+
+		requested := false
+		for _, endpoint := range []string{config.PoolInfoUrl, config.AddressBalanceUrl, config.AssetListURL} {
+			if endpoint == "" {
+				continue
+			}
+			info.Endpoints = append(info.Endpoints, endpoint)
+
+			if health, ok := upstreams[hostOf(endpoint)]; ok {
+				requested = true
+				if !health.Healthy {
+					info.UnhealthyEndpoints = append(info.UnhealthyEndpoints, endpoint)
+				}
+			}
+		}
+
+		switch {
+		case len(info.UnhealthyEndpoints) > 0 || len(info.FailingVenues) > 0:
+			info.Health = ProtocolDegraded
+		case requested:
+			info.Health = ProtocolHealthy
+		}
+
+		protocols = append(protocols, info)
+	}
+
+	sort.Slice(protocols, func(i, j int) bool { return protocols[i].Protocol < protocols[j].Protocol })
+	return protocols
+}
+
+// protocolsHandler serves the supported protocols with their capabilities and health.
+func protocolsHandler(w http.ResponseWriter, r *http.Request) {
+	jsonData, err := json.MarshalIndent(listProtocols(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
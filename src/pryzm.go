@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// PryzmVenuePositionConfig is a position on Pryzm: shares of an AMM pool, and the pAssets and
+// yAssets the address got from refracting a yield-bearing asset.
+type PryzmVenuePositionConfig struct {
+	VenueMetadata
+
+	Address string
+	PoolID  string // AMM pool of the LP shares, empty for positions without LP shares
+	// ActiveShares is the LP token amount of the bid, if the address holds LP tokens of several
+	// bids. If 0, all LP tokens of the pool held by the address count.
+	ActiveShares float64
+	// PriceAs prices the denoms starting with a key like the denom it maps to, e.g. the pAssets
+	// "p:catom" like the cAsset they redeem for at maturity. The decimals are taken from it too.
+	PriceAs map[string]string
+}
+
+func (venueConfig PryzmVenuePositionConfig) GetProtocol() Protocol {
+	return Pryzm
+}
+
+func (venueConfig PryzmVenuePositionConfig) GetPoolID() string {
+	return venueConfig.PoolID
+}
+
+func (venueConfig PryzmVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type PryzmPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig PryzmVenuePositionConfig
+}
+
+func NewPryzmPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*PryzmPosition, error) {
+	pryzmVenuePositionConfig, ok := venuePositionConfig.(PryzmVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of PryzmVenuePositionConfig type")
+	}
+
+	return &PryzmPosition{protocolConfig: config, venuePositionConfig: pryzmVenuePositionConfig}, nil
+}
+
+// The yield of yAssets and the fees of pools accrue to their value.
+func (p PryzmPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+// ComputeTVL returns the tokens of the AMM pool of the venue.
+func (p PryzmPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	if p.venuePositionConfig.PoolID == "" {
+		return nil, fmt.Errorf("venue has no pool")
+	}
+
+	pool, err := p.getPool(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := &Holdings{Balances: []Asset{}}
+	for _, token := range pool.Tokens {
+		if err := p.addBalance(ctx, assetData, holdings, token.Denom, token.Balance, 1); err != nil {
+			return nil, err
+		}
+	}
+	return holdings, nil
+}
+
+// ComputeAddressPrincipalHoldings returns the share of the pool tokens of the LP shares of the
+// address, and the pAssets and yAssets it holds.
+func (p PryzmPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	balances, err := p.getBalances(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := &Holdings{Balances: []Asset{}}
+
+	if p.venuePositionConfig.PoolID != "" {
+		shares := p.venuePositionConfig.ActiveShares
+		if shares == 0 {
+			lpPrefix := "lp:" + p.venuePositionConfig.PoolID + ":"
+			for _, balance := range balances {
+				if strings.HasPrefix(balance.Denom, lpPrefix) {
+					if shares, err = strconv.ParseFloat(balance.Amount, 64); err != nil {
+						return nil, fmt.Errorf("failed to parse LP balance into float64: %s", err)
+					}
+				}
+			}
+		}
+
+		if shares > 0 {
+			pool, err := p.getPool(ctx)
+			if err != nil {
+				return nil, err
+			}
+			totalShares, err := strconv.ParseFloat(pool.Pool.TotalLPTokenSupply, 64)
+			if err != nil || totalShares == 0 {
+				return nil, fmt.Errorf("invalid total LP token supply of pool %s", p.venuePositionConfig.PoolID)
+			}
+			for _, token := range pool.Tokens {
+				if err := p.addBalance(ctx, assetData, holdings, token.Denom, token.Balance, shares/totalShares); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	// refracted assets are denominated like p:<cAsset>:<maturity> and y:<cAsset>:<maturity>
+	for _, balance := range balances {
+		if strings.HasPrefix(balance.Denom, "p:") || strings.HasPrefix(balance.Denom, "y:") {
+			if err := p.addBalance(ctx, assetData, holdings, balance.Denom, balance.Amount, 1); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return holdings, nil
+}
+
+func (p PryzmPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Pryzm")
+}
+
+// pryzmPool is an AMM pool with its token balances.
+type pryzmPool struct {
+	Pool struct {
+		ID                 string `json:"id"`
+		TotalLPTokenSupply string `json:"total_lp_token_supply"`
+	} `json:"pool"`
+	Tokens []struct {
+		Denom   string `json:"denom"`
+		Balance string `json:"balance"`
+	} `json:"tokens"`
+}
+
+func (p PryzmPosition) getPool(ctx context.Context) (*pryzmPool, error) {
+	var response struct {
+		Pool *pryzmPool `json:"pool"`
+	}
+	poolUrl := fmt.Sprintf("%s/pryzm-finance/pryzmchain/amm/v1/pool/%s", p.protocolConfig.PoolInfoUrl, url.PathEscape(p.venuePositionConfig.PoolID))
+	if err := getJSON(ctx, poolUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying pool: %s", err)
+	}
+	if response.Pool == nil {
+		return nil, fmt.Errorf("invalid pool structure")
+	}
+	return response.Pool, nil
+}
+
+type pryzmBalance struct {
+	Denom  string `json:"denom"`
+	Amount string `json:"amount"`
+}
+
+// getBalances returns all the balances of the address.
+func (p PryzmPosition) getBalances(ctx context.Context, address string) ([]pryzmBalance, error) {
+	var response struct {
+		Balances []pryzmBalance `json:"balances"`
+	}
+	balancesUrl := fmt.Sprintf("%s/%s?pagination.limit=1000", p.protocolConfig.AddressBalanceUrl, address)
+	if err := getJSON(ctx, balancesUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying balances: %s", err)
+	}
+	return response.Balances, nil
+}
+
+// priceDenom returns the denom a denom is priced like, by the longest matching PriceAs prefix.
+func (p PryzmPosition) priceDenom(denom string) string {
+	priceDenom, matched := denom, ""
+	for prefix, other := range p.venuePositionConfig.PriceAs {
+		if strings.HasPrefix(denom, prefix) && len(prefix) > len(matched) {
+			priceDenom, matched = other, prefix
+		}
+	}
+	return priceDenom
+}
+
+// addBalance adds the given share of an amount of a denom to the holdings. Denoms that can't be
+// priced are left out, like in the other integrations.
+func (p PryzmPosition) addBalance(ctx context.Context, assetData *ChainInfo, holdings *Holdings, denom string, amountStr string, share float64) error {
+	amount, err := strconv.ParseFloat(amountStr, 64)
+	if err != nil {
+		return fmt.Errorf("failed to parse amount of %s into float64: %s", denom, err)
+	}
+
+	priceDenom := p.priceDenom(denom)
+	tokenInfo, err := assetData.GetTokenInfo(priceDenom)
+	if err != nil {
+		debugLog("Token info not found", map[string]string{"denom": denom})
+		return nil
+	}
+
+	adjustedAmount := amount * share / math.Pow(10, float64(tokenInfo.Decimals))
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+	if err != nil {
+		debugLog("Error getting token values", map[string]string{"denom": denom})
+		return nil
+	}
+
+	// a pAsset isn't the asset it is priced like
+	displayName := tokenInfo.Display
+	if priceDenom != denom {
+		displayName = denom
+	}
+
+	holdings.TotalUSDC += usdValue
+	holdings.TotalAtom += atomValue
+	holdings.Balances = append(holdings.Balances, Asset{
+		Denom:       denom,
+		Amount:      adjustedAmount,
+		USDValue:    usdValue,
+		DisplayName: displayName,
+	})
+	return nil
+}
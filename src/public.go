@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PublicClientIdleTimeout is how long the rate limit state of a client is kept after its last request.
+const PublicClientIdleTimeout = 10 * time.Minute
+
+// errNotCached is returned for cached-only requests whose result hasn't been computed yet.
+var errNotCached = errors.New("not computed yet, try again later")
+
+var (
+	// publicTierEnabled restricts requests without the admin token to cached results, rate limited per client.
+	publicTierEnabled bool
+	publicRateLimit   = RateLimit{RPS: 1, Burst: 20}
+)
+
+var (
+	publicRejectedMetric = newCounter("public_requests_rejected_total",
+		"Number of public requests rejected by the rate limit.")
+	publicCacheMissesMetric = newCounter("public_cache_misses_total",
+		"Number of public requests that couldn't be served from the cache.")
+)
+
+var (
+	publicClientsMu sync.Mutex
+	// publicClients holds the rate limit state of every public client, by IP.
+	publicClients = make(map[string]*tokenBucket)
+)
+
+type cachedOnlyKey struct{}
+
+// withCachedOnly marks a request as one that must not trigger computations.
+func withCachedOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cachedOnlyKey{}, true)
+}
+
+// isCachedOnly reports whether results have to be served from the cache.
+func isCachedOnly(ctx context.Context) bool {
+	cachedOnly, _ := ctx.Value(cachedOnlyKey{}).(bool)
+	return cachedOnly
+}
+
+// parsePublicRateLimit parses the --public-rate-limit flag, "<rps>[/<burst>]".
+func parsePublicRateLimit(s string) error {
+	rpsStr, burstStr, hasBurst := strings.Cut(s, "/")
+
+	rps, err := strconv.ParseFloat(rpsStr, 64)
+	if err != nil || rps <= 0 {
+		return fmt.Errorf("invalid public rate limit %q, expected <rps>[/<burst>]", s)
+	}
+
+	limit := RateLimit{RPS: rps, Burst: int(math.Ceil(rps))}
+	if hasBurst {
+		if limit.Burst, err = strconv.Atoi(burstStr); err != nil {
+			return fmt.Errorf("invalid burst in public rate limit %q: %v", s, err)
+		}
+	}
+
+	publicRateLimit = limit
+	return nil
+}
+
+// clientIP identifies the client of a request for rate limiting.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allowPublicRequest takes a token from the client's bucket, or returns how long the client has to wait.
+func allowPublicRequest(ip string) (bool, time.Duration) {
+	publicClientsMu.Lock()
+	bucket, ok := publicClients[ip]
+	if !ok {
+		bucket = newTokenBucket(publicRateLimit)
+		publicClients[ip] = bucket
+	}
+	publicClientsMu.Unlock()
+
+	return bucket.allow()
+}
+
+// forgetIdlePublicClients drops the rate limit state of clients that haven't sent a request in a while.
+func forgetIdlePublicClients() {
+	publicClientsMu.Lock()
+	defer publicClientsMu.Unlock()
+
+	for ip, bucket := range publicClients {
+		if bucket.idleSince() > PublicClientIdleTimeout {
+			delete(publicClients, ip)
+		}
+	}
+}
+
+// startPublicTier enables the public tier and periodically cleans up its rate limit state.
+func startPublicTier() {
+	publicTierEnabled = true
+
+	go func() {
+		ticker := time.NewTicker(PublicClientIdleTimeout)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			forgetIdlePublicClients()
+		}
+	}()
+}
+
+// publicTier rate limits requests without the admin token and restricts them to cached results,
+// if the public tier is enabled.
+func publicTier(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !publicTierEnabled || hasAdminToken(r) {
+			next(w, r)
+			return
+		}
+
+		if ok, wait := allowPublicRequest(clientIP(r)); !ok {
+			publicRejectedMetric.Add(1)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+
+		next(w, r.WithContext(withCachedOnly(r.Context())))
+	}
+}
+
+// writeComputeError responds with 503 for cached-only requests that missed the cache, and 500 otherwise.
+func writeComputeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errNotCached) {
+		publicCacheMissesMetric.Add(1)
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
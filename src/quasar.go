@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// QuasarVenuePositionConfig is a deposit into a Quasar vault, which manages a concentrated
+// liquidity position in an Osmosis pool. Deposits are represented by vault shares.
+type QuasarVenuePositionConfig struct {
+	VenueMetadata
+
+	VaultAddress string // Contract address of the vault
+	Address      string
+	// ActiveShares is the vault share amount of the bid, if the address holds vault shares of
+	// several bids. If 0, all vault shares held by the address count.
+	ActiveShares float64
+}
+
+func (venueConfig QuasarVenuePositionConfig) GetProtocol() Protocol {
+	return Quasar
+}
+
+func (venueConfig QuasarVenuePositionConfig) GetPoolID() string {
+	return venueConfig.VaultAddress
+}
+
+func (venueConfig QuasarVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type QuasarPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig QuasarVenuePositionConfig
+}
+
+func NewQuasarPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*QuasarPosition, error) {
+	quasarVenuePositionConfig, ok := venuePositionConfig.(QuasarVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of QuasarVenuePositionConfig type")
+	}
+
+	return &QuasarPosition{protocolConfig: config, venuePositionConfig: quasarVenuePositionConfig}, nil
+}
+
+// The vault compounds the fees and incentives of its position into it.
+func (p QuasarPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+// ComputeTVL returns the two tokens of the vault's position.
+func (p QuasarPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	assets, err := p.getTotalAssets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return quasarHoldings(ctx, assetData, assets, 1)
+}
+
+// ComputeAddressPrincipalHoldings returns the share of the vault's tokens of the address's vault
+// shares, which is their share price times the number of shares, split into the two tokens.
+func (p QuasarPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	shares := p.venuePositionConfig.ActiveShares
+	if shares == 0 {
+		balance, err := p.queryVault(ctx, map[string]interface{}{
+			"vault_extension": map[string]interface{}{
+				"balances": map[string]interface{}{
+					"user_shares_balance": map[string]interface{}{
+						"user": address,
+					},
+				},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("querying share balance: %s", err)
+		}
+		balanceShares, err := parseAmountValue(balance["balance"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid share balance: %w", err)
+		}
+		shares = amountFloat(balanceShares)
+	}
+	if shares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	supply, err := p.queryVault(ctx, map[string]interface{}{
+		"total_vault_token_supply": map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying share supply: %s", err)
+	}
+	totalShares, err := parseAmountValue(supply["total"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid share supply: %w", err)
+	}
+	if totalShares.Sign() == 0 {
+		return nil, fmt.Errorf("vault %s has no shares", p.venuePositionConfig.VaultAddress)
+	}
+
+	assets, err := p.getTotalAssets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return quasarHoldings(ctx, assetData, assets, shares/amountFloat(totalShares))
+}
+
+func (p QuasarPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are included in the principal for Quasar")
+}
+
+// quasarAsset is an amount of one of the tokens of a vault, in its base unit.
+type quasarAsset struct {
+	denom  string
+	amount *big.Int
+}
+
+// getTotalAssets returns the amounts of the two tokens of the vault's position.
+func (p QuasarPosition) getTotalAssets(ctx context.Context) ([]quasarAsset, error) {
+	totalAssets, err := p.queryVault(ctx, map[string]interface{}{
+		"total_assets": map[string]interface{}{},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("querying total assets: %s", err)
+	}
+
+	var assets []quasarAsset
+	for _, key := range []string{"token0", "token1"} {
+		token, ok := totalAssets[key].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid %s structure", key)
+		}
+		denom, ok := token["denom"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %s denom", key)
+		}
+		amount, err := parseAmountValue(token["amount"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s amount: %w", key, err)
+		}
+		assets = append(assets, quasarAsset{denom: denom, amount: amount})
+	}
+	return assets, nil
+}
+
+func (p QuasarPosition) queryVault(ctx context.Context, query map[string]interface{}) (map[string]interface{}, error) {
+	data, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.VaultAddress, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid vault response structure")
+	}
+	return result, nil
+}
+
+// quasarHoldings values the given share of the tokens of a vault. Tokens without token info or
+// price are left out.
+func quasarHoldings(ctx context.Context, assetData *ChainInfo, assets []quasarAsset, share float64) (*Holdings, error) {
+	holdings := &Holdings{Balances: []Asset{}}
+
+	for _, asset := range assets {
+		tokenInfo, err := assetData.GetTokenInfo(asset.denom)
+		if err != nil {
+			debugLog("Token info not found", map[string]string{"denom": asset.denom})
+			continue
+		}
+
+		adjustedAmount := tokenAmount(asset.amount, tokenInfo.Decimals) * share
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			debugLog("Error getting token values", map[string]string{"denom": asset.denom})
+			continue
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       asset.denom,
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+
+	return holdings, nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit limits the requests to all hosts ending in a domain.
+type RateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// upstreamRateLimits are keyed by domain suffix, so that e.g. all polkachu.com APIs share a single limit.
+// They can be overridden with --upstream-rps.
+var upstreamRateLimits = map[string]RateLimit{
+	"polkachu.com":      {RPS: 5, Burst: 5},
+	"api.coingecko.com": {RPS: 0.5, Burst: 3},
+	"numia.xyz":         {RPS: 5, Burst: 5},
+	"sqs.osmosis.zone":  {RPS: 5, Burst: 5},
+}
+
+var rateLimitWaitMetric = newCounter("upstream_rate_limit_wait_seconds_total",
+	"Time requests spent waiting for the rate limit of their upstream domain.")
+
+// tokenBucket allows RPS requests per second on average, and bursts of up to Burst requests.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(limit RateLimit) *tokenBucket {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: limit.RPS, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// reserve takes a token and returns how long the caller has to wait until it is available.
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel returns a reserved token that won't be used, e.g. because the request was cancelled
+// while waiting for it, so that the following requests don't wait for it.
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+}
+
+// allow takes a token if one is available. Otherwise, it returns how long until one is.
+func (b *tokenBucket) allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// idleSince returns the time since the bucket was last used.
+func (b *tokenBucket) idleSince() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return time.Since(b.last)
+}
+
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = make(map[string]*tokenBucket)
+)
+
+// rateLimitDomain returns the domain of the rate limit that applies to the host, if any.
+// The longest matching suffix wins.
+func rateLimitDomain(host string) (string, bool) {
+	host = strings.ToLower(host)
+	if h, _, ok := strings.Cut(host, ":"); ok {
+		host = h
+	}
+
+	best := ""
+	for domain := range upstreamRateLimits {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(best) {
+			best = domain
+		}
+	}
+	return best, best != ""
+}
+
+// waitForRateLimit blocks until a request to the host is allowed by its rate limit.
+// Waits are jittered by up to 10% of the request interval, so that requests queued
+// at the same time don't hit the upstream in lockstep.
+func waitForRateLimit(ctx context.Context, host string) error {
+	domain, ok := rateLimitDomain(host)
+	if !ok {
+		return nil
+	}
+
+	limit := upstreamRateLimits[domain]
+	if limit.RPS <= 0 {
+		return nil
+	}
+
+	rateLimitersMu.Lock()
+	bucket, ok := rateLimiters[domain]
+	if !ok {
+		bucket = newTokenBucket(limit)
+		rateLimiters[domain] = bucket
+	}
+	rateLimitersMu.Unlock()
+
+	// a cancelled request must not take a token
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	wait := bucket.reserve()
+	if wait == 0 {
+		return nil
+	}
+	wait += time.Duration(rand.Float64() * 0.1 / limit.RPS * float64(time.Second))
+	rateLimitWaitMetric.Add(wait.Seconds(), "domain", domain)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		bucket.cancel()
+		return ctx.Err()
+	}
+}
+
+// parseUpstreamRateLimits applies overrides like "polkachu.com=5,api.coingecko.com=0.5/3",
+// where the optional number after the slash is the burst.
+func parseUpstreamRateLimits(s string) error {
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		domain, limitStr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid rate limit %q, expected <domain>=<rps>[/<burst>]", entry)
+		}
+
+		rpsStr, burstStr, hasBurst := strings.Cut(limitStr, "/")
+		rps, err := strconv.ParseFloat(rpsStr, 64)
+		if err != nil {
+			return fmt.Errorf("invalid RPS for %s: %v", domain, err)
+		}
+
+		limit := RateLimit{RPS: rps, Burst: int(rps)}
+		if hasBurst {
+			if limit.Burst, err = strconv.Atoi(burstStr); err != nil {
+				return fmt.Errorf("invalid burst for %s: %v", domain, err)
+			}
+		}
+
+		upstreamRateLimits[strings.ToLower(domain)] = limit
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// Discrepancies found by the reconcile job.
+const (
+	// DiscrepancyUnrecordedExit is a bid with nothing left in its venues but no withdrawal of its funds.
+	DiscrepancyUnrecordedExit = "unrecorded_exit"
+	// DiscrepancyUnrecordedWithdrawal is a bid whose principal is short of its net deployed amount.
+	DiscrepancyUnrecordedWithdrawal = "unrecorded_withdrawal"
+	// DiscrepancyFundsAfterExit is a bid that withdrew everything but still holds funds.
+	DiscrepancyFundsAfterExit = "funds_after_exit"
+)
+
+// ReconcileTolerancePercent is how far the principal of a bid may fall short of its net deployed
+// amount, e.g. through impermanent loss, before it counts as an unrecorded withdrawal.
+const ReconcileTolerancePercent = 10.0
+
+// ReconcileDustPercent of its allocation is what a bid that withdrew everything may still hold.
+const ReconcileDustPercent = 1.0
+
+// BidDiscrepancy is a bid whose recorded allocations, withdrawals and transfers don't match the
+// principal in its venues.
+type BidDiscrepancy struct {
+	BidId            int     `json:"bid_id"`
+	Discrepancy      string  `json:"discrepancy"`
+	NetDeployedAtom  float64 `json:"net_deployed_atom"`
+	PrincipalAtom    float64 `json:"principal_atom"`
+	DeviationPercent float64 `json:"deviation_percent"`
+}
+
+// ReconcileResult is the result of a reconcile job. Bids whose venues couldn't all be valued are
+// skipped, as their principal is unknown.
+type ReconcileResult struct {
+	Checked       int              `json:"checked"`
+	Skipped       []int            `json:"skipped"`
+	Discrepancies []BidDiscrepancy `json:"discrepancies"`
+}
+
+// runReconcileJob compares the books of every bid, its allocations less its withdrawals and
+// transfers, to the principal in its venues, to find withdrawals and exits missing from the
+// configs. Venues that expired are recomputed first.
+func runReconcileJob(ctx context.Context, job *Job) (interface{}, error) {
+	bidIds := job.Request.BidIds
+	if len(bidIds) == 0 {
+		bidIds = sortedBidIds()
+	}
+
+	result := ReconcileResult{Skipped: []int{}, Discrepancies: []BidDiscrepancy{}}
+	for i, bidId := range bidIds {
+		setJobProgress(job, i, len(bidIds))
+		bidConfig := activeBids()[bidId]
+		if bidConfig.Frozen != nil || len(productionVenues(bidConfig)) == 0 {
+			continue
+		}
+
+		holdings, err := refreshHoldings(ctx, bidId)
+		if err != nil {
+			log.Printf("Reconciling bid %d failed: %v", bidId, err)
+			result.Skipped = append(result.Skipped, bidId)
+			continue
+		}
+		principal, ok := bidPrincipalAtom(holdings)
+		if !ok {
+			result.Skipped = append(result.Skipped, bidId)
+			continue
+		}
+
+		result.Checked++
+		if discrepancy := reconcileBid(bidId, bidConfig, principal); discrepancy != nil {
+			result.Discrepancies = append(result.Discrepancies, *discrepancy)
+		}
+	}
+	setJobProgress(job, len(bidIds), len(bidIds))
+
+	if len(result.Discrepancies) > 0 {
+		return result, fmt.Errorf("%d of %d bids don't match their books", len(result.Discrepancies), result.Checked)
+	}
+	return result, nil
+}
+
+// bidPrincipalAtom sums the principal of the venues of a bid. It reports false if one of them
+// isn't valued.
+func bidPrincipalAtom(holdings []VenueHoldings) (float64, bool) {
+	principal := 0.0
+	for _, venueHoldings := range holdings {
+		if venueHoldings.Testnet {
+			continue
+		}
+		if venueHoldings.InfoMissing || venueHoldings.Pending || venueHoldings.Stale || venueHoldings.AddressPrincipal == nil {
+			return 0, false
+		}
+		principal += venueHoldings.AddressPrincipal.TotalAtom
+	}
+	return principal, true
+}
+
+// reconcileBid compares the net deployed amount of a bid to its principal. Principal above the
+// net deployed amount is yield, and not a discrepancy.
+func reconcileBid(bidId int, bidConfig BidPositionConfig, principal float64) *BidDiscrepancy {
+	netDeployed := bidNetDeployed(bidId, bidConfig, time.Now())
+	discrepancy := &BidDiscrepancy{BidId: bidId, NetDeployedAtom: netDeployed, PrincipalAtom: principal}
+
+	// a bid that withdrew everything may be a little off from rounding in the recorded amounts, and
+	// keep some dust in its venues
+	dust := totalAllocatedAtom(bidConfig) * ReconcileDustPercent / 100
+	exited := netDeployed <= dust
+
+	switch {
+	case exited && principal > dust:
+		discrepancy.Discrepancy = DiscrepancyFundsAfterExit
+	case exited:
+		return nil
+	case principal <= 0:
+		discrepancy.Discrepancy = DiscrepancyUnrecordedExit
+		discrepancy.DeviationPercent = -100
+	default:
+		discrepancy.DeviationPercent = (principal - netDeployed) / netDeployed * 100
+		if discrepancy.DeviationPercent >= -ReconcileTolerancePercent {
+			return nil
+		}
+		discrepancy.Discrepancy = DiscrepancyUnrecordedWithdrawal
+	}
+	discrepancy.DeviationPercent = math.Round(discrepancy.DeviationPercent*100) / 100
+	return discrepancy
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedisTimeout bounds Redis commands whose context has no deadline.
+const RedisTimeout = 5 * time.Second
+
+// redisClient is a minimal client for the RESP protocol, with a single connection
+// that is re-established after errors. It supports just what the shared cache and
+// the leader election need.
+type redisClient struct {
+	addr     string
+	useTLS   bool
+	username string
+	password string
+	db       int
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// redisError is an error reply of the server, after which the connection is still usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// newRedisClient parses a URL like redis://[[user]:password@]host[:port][/db], or rediss:// for TLS.
+func newRedisClient(rawURL string) (*redisClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %v", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid Redis URL scheme: %s", u.Scheme)
+	}
+
+	client := &redisClient{addr: u.Host, useTLS: u.Scheme == "rediss"}
+	if u.Port() == "" {
+		client.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		client.username = u.User.Username()
+		client.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if client.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid Redis database: %s", db)
+		}
+	}
+
+	return client, nil
+}
+
+// Do sends a command and returns its reply: a string, an int64, nil, or a slice of replies.
+func (c *redisClient) Do(ctx context.Context, args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.roundTrip(ctx, args)
+	if err != nil {
+		if _, ok := err.(redisError); !ok {
+			// the connection is in an unknown state
+			c.conn.Close()
+			c.conn = nil
+		}
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (c *redisClient) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: RedisTimeout}
+
+	var conn net.Conn
+	var err error
+	if c.useTLS {
+		host, _, _ := net.SplitHostPort(c.addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to Redis: %v", err)
+	}
+
+	c.conn = conn
+	c.rd = bufio.NewReader(conn)
+
+	var setup [][]string
+	if c.password != "" {
+		if c.username != "" {
+			setup = append(setup, []string{"AUTH", c.username, c.password})
+		} else {
+			setup = append(setup, []string{"AUTH", c.password})
+		}
+	}
+	if c.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(c.db)})
+	}
+
+	for _, args := range setup {
+		if _, err := c.roundTrip(ctx, args); err != nil {
+			conn.Close()
+			c.conn = nil
+			return fmt.Errorf("setting up Redis connection: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (c *redisClient) roundTrip(ctx context.Context, args []string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(RedisTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+
+	var cmd strings.Builder
+	fmt.Fprintf(&cmd, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&cmd, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, cmd.String()); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+func (c *redisClient) readReply() (interface{}, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length: %s", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid array length: %s", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := c.readReply()
+			if rerr, ok := err.(redisError); ok {
+				// error elements don't break the framing, so the rest of the array is still read
+				items[i] = rerr
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply: %s", line)
+	}
+}
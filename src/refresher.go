@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshInterval is shorter than DefaultVenueTTL,
+// so that requests are always served from a warm cache.
+const DefaultRefreshInterval = 20 * time.Minute
+
+var (
+	lastSuccessfulFullRefreshMetric = newGauge("last_successful_full_refresh_timestamp",
+		"Unix time of the last refresh cycle in which every due venue was computed successfully.")
+	lastRefreshDurationMetric = newGauge("last_refresh_duration_seconds",
+		"Duration of the last refresh cycle.")
+	refreshFailuresMetric = newCounter("refresh_bid_failures_total",
+		"Number of bid computations that failed during background refreshes.")
+)
+
+// RefreshState describes the progress of the background refresher.
+type RefreshState struct {
+	Interval                  time.Duration
+	LastStarted               time.Time
+	LastFinished              time.Time
+	LastDuration              time.Duration
+	LastFailedBids            int
+	LastSuccessfulFullRefresh time.Time
+	LastHeartbeat             time.Time // the last progress of a cycle, see refreshHeartbeat
+}
+
+var (
+	refreshStateMu sync.RWMutex
+	refreshState   RefreshState
+)
+
+func getRefreshState() RefreshState {
+	refreshStateMu.RLock()
+	defer refreshStateMu.RUnlock()
+
+	return refreshState
+}
+
+// RefreshSpread is the share of the refresh interval over which the due venues are spread,
+// leaving some slack before the next cycle starts.
+const RefreshSpread = 0.75
+
+// startRefresher recomputes the venues that are about to expire every interval,
+// so that the result cache stays warm. A cycle that stalls is restarted right away.
+func startRefresher(interval time.Duration) {
+	refreshStateMu.Lock()
+	refreshState.Interval = interval
+	refreshStateMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// with several replicas, only the one holding the lease refreshes
+			if isLeader() && !runWatchedRefreshCycle(interval) {
+				continue
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// sortedBidIds returns the IDs of all bids in ascending order.
+func sortedBidIds() []int {
+	return sortedBidIdsOf(activeBids())
+}
+
+func sortedBidIdsOf(bids map[int]BidPositionConfig) []int {
+	bidIds := make([]int, 0, len(bids))
+	for bidId := range bids {
+		bidIds = append(bidIds, bidId)
+	}
+	sort.Ints(bidIds)
+	return bidIds
+}
+
+// refreshExpiredVenues recomputes the venues that would expire before the next cycle.
+// Rather than computing them all at once, their computations are spread evenly over the window
+// to smooth the load on the upstreams. Each bid is republished once its due venues are done.
+func refreshExpiredVenues(ctx context.Context, window time.Duration) {
+	start := time.Now()
+
+	refreshStateMu.Lock()
+	refreshState.LastStarted = start
+	refreshStateMu.Unlock()
+
+	// all venues of the cycle are valued at the same prices
+	if _, err := takePriceSnapshot(ctx); err != nil {
+		log.Printf("Taking a price snapshot failed, keeping the previous one: %v", err)
+	}
+	refreshHeartbeat(ctx, time.Now())
+
+	due := dueVenues(start, window)
+
+	remaining := make(map[int]int)
+	for _, venue := range due {
+		remaining[venue.bidId]++
+	}
+
+	// terminal venues aren't queried, so they don't take a slot of the window
+	queried := 0
+	for _, venue := range due {
+		if !venue.terminal {
+			queried++
+		}
+	}
+	var spacing time.Duration
+	if queried > 0 {
+		spacing = time.Duration(float64(window) * RefreshSpread / float64(queried))
+	}
+
+	failedBids := make(map[int]bool)
+	slot := 0
+	for _, venue := range due {
+		if !venue.terminal {
+			scheduled := start.Add(time.Duration(slot) * spacing)
+			slot++
+			refreshHeartbeat(ctx, scheduled)
+			select {
+			case <-time.After(time.Until(scheduled)):
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if _, err := refreshVenue(ctx, venue.bidId, venue.venueConfig); err != nil {
+			log.Printf("Refresh of venue %s failed: %v", venueID(venue.bidId, venue.venueConfig), err)
+			failedBids[venue.bidId] = true
+		}
+
+		remaining[venue.bidId]--
+		if remaining[venue.bidId] > 0 || failedBids[venue.bidId] {
+			continue
+		}
+
+		if _, err := publishBidHoldings(venue.bidId); err != nil {
+			log.Printf("Refresh of bid %d failed: %v", venue.bidId, err)
+			failedBids[venue.bidId] = true
+		}
+	}
+
+	// a cycle cancelled by the watchdog is superseded by the next one
+	if ctx.Err() != nil {
+		return
+	}
+
+	failed := len(failedBids)
+	refreshFailuresMetric.Add(float64(failed))
+
+	finished := time.Now()
+	duration := finished.Sub(start)
+	lastRefreshDurationMetric.Set(duration.Seconds())
+
+	refreshStateMu.Lock()
+	defer refreshStateMu.Unlock()
+
+	refreshState.LastFinished = finished
+	refreshState.LastDuration = duration
+	refreshState.LastFailedBids = failed
+	if failed == 0 {
+		refreshState.LastSuccessfulFullRefresh = finished
+		lastSuccessfulFullRefreshMetric.Set(float64(finished.Unix()))
+	}
+
+	debugLog("Refresh cycle finished", map[string]interface{}{
+		"duration":    duration.String(),
+		"due_venues":  len(due),
+		"failed_bids": failed,
+	})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var configReloadsMetric = newCounter("config_reloads_total",
+	"Number of reloads of the bid configs from the config file, by result.")
+
+// currentBids holds the bid configs being served once they were loaded from a config file. It is
+// replaced as a whole, so that readers see either the old or the new configs.
+var currentBids atomic.Pointer[map[int]BidPositionConfig]
+
+// activeBids returns the bid configs being served: the ones in the code, unless they were loaded
+// from a config file. The map must not be modified.
+func activeBids() map[int]BidPositionConfig {
+	if bids := currentBids.Load(); bids != nil {
+		return *bids
+	}
+	return bidMap
+}
+
+func setActiveBids(bids map[int]BidPositionConfig) {
+	currentBids.Store(&bids)
+}
+
+// startBidsReload reloads the bid configs when the config store changes, checked every interval,
+// and on SIGHUP.
+func startBidsReload(store ConfigStore, profile Profile, interval time.Duration) {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+	}
+
+	lastRevision, _ := store.Revision()
+
+	go func() {
+		for {
+			select {
+			case <-tick:
+				revision, err := store.Revision()
+				if err != nil {
+					debugLog("Error checking the config store for changes", err)
+					continue
+				}
+				if revision == lastRevision {
+					continue
+				}
+				lastRevision = revision
+				log.Printf("Reloading the bid configs, the config store changed")
+			case <-reload:
+				log.Printf("Reloading the bid configs on SIGHUP")
+			}
+
+			if err := reloadBids(store, profile); err != nil {
+				configReloadsMetric.Add(1, "result", "failure")
+				log.Printf("Reloading the bid configs failed, keeping the previous ones: %v", err)
+				continue
+			}
+			configReloadsMetric.Add(1, "result", "success")
+		}
+	}()
+}
+
+// reloadBids reads the bid configs from the store and, if they are valid, swaps them in. The cached
+// results of changed bids are invalidated, but not dropped.
+func reloadBids(store ConfigStore, profile Profile) error {
+	// serialized with the changes through the admin API, which save over the revision of the store
+	// they loaded
+	bidConfigMu.Lock()
+	defer bidConfigMu.Unlock()
+
+	stored, err := store.LoadBids()
+	if err != nil {
+		return err
+	}
+	bids, err := profileBids(profile, stored)
+	if err != nil {
+		return err
+	}
+	if errs := validateBidConfigs(bids); len(errs) > 0 {
+		for _, err := range errs {
+			log.Printf("Invalid bid config: %v", err)
+		}
+		return fmt.Errorf("found %d errors in the bid configs", len(errs))
+	}
+	// a store without experimental deployments keeps serving the ones of the code
+	experimental, err := store.LoadExperimental()
+	if err != nil {
+		return err
+	}
+
+	previous := activeBids()
+	changed, err := changedBids(previous, bids)
+	if err != nil {
+		return err
+	}
+	var experimentalDiffs []string
+	if experimental != nil {
+		if experimentalDiffs, err = diffExperimentals(activeExperimental(), experimental, "served", "store"); err != nil {
+			return err
+		}
+	}
+	setActiveBids(bids)
+	logBidConfigWarnings(bids)
+	if len(experimentalDiffs) > 0 {
+		setActiveExperimental(experimental)
+		log.Printf("Reloaded %d experimental deployments", len(experimental))
+	}
+
+	staleVenues := 0
+	for _, bidId := range changed {
+		staleVenues += invalidateBid(bidId, previous[bidId], bids[bidId])
+	}
+
+	log.Printf("Reloaded %d bids: %d added, removed or changed, %d venues to recompute", len(bids), len(changed), staleVenues)
+	return nil
+}
+
+// invalidateBid drops the cached result of a changed bid, so that it is reassembled on its next
+// request, and marks its changed venues stale, so that they are recomputed but served until then.
+// It returns the number of venues marked stale.
+func invalidateBid(bidId int, previous BidPositionConfig, next BidPositionConfig) int {
+	resultCache.Delete(strconv.Itoa(bidId))
+
+	staleVenues := 0
+	for _, id := range changedVenues(bidId, previous, next) {
+		if _, ok := markVenueStale(id); ok {
+			staleVenues++
+		}
+	}
+	return staleVenues
+}
+
+// changedBids returns the IDs of the bids that were added, removed or changed.
+func changedBids(previous map[int]BidPositionConfig, next map[int]BidPositionConfig) ([]int, error) {
+	var changed []int
+	for _, bidId := range sortedBidIdsOf(previous) {
+		if _, ok := next[bidId]; !ok {
+			changed = append(changed, bidId)
+		}
+	}
+	for _, bidId := range sortedBidIdsOf(next) {
+		before, ok := previous[bidId]
+		if !ok {
+			changed = append(changed, bidId)
+			continue
+		}
+
+		beforeVersion, err := bidConfigVersion(bidId, before)
+		if err != nil {
+			return nil, err
+		}
+		afterVersion, err := bidConfigVersion(bidId, next[bidId])
+		if err != nil {
+			return nil, err
+		}
+		if beforeVersion != afterVersion {
+			changed = append(changed, bidId)
+		}
+	}
+	return changed, nil
+}
+
+// changedVenues returns the IDs of the venues of a bid whose config changed while keeping their ID,
+// e.g. their active shares.
+func changedVenues(bidId int, previous BidPositionConfig, next BidPositionConfig) []string {
+	before := make(map[string][]byte, len(previous.Venues))
+	for _, venueConfig := range previous.Venues {
+		data, _ := json.Marshal(venueConfig)
+		before[venueID(bidId, venueConfig)] = data
+	}
+
+	var changed []string
+	for _, venueConfig := range next.Venues {
+		id := venueID(bidId, venueConfig)
+		data, _ := json.Marshal(venueConfig)
+		if previousData, ok := before[id]; ok && string(previousData) != string(data) {
+			changed = append(changed, id)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const reportMonthFormat = "2006-01"
+
+// buildMonthlyReport builds the monthly report workbook from the last snapshot of every bid
+// taken in the given month: a summary sheet with returns, APRs and withdrawals, and one sheet per bid.
+func buildMonthlyReport(store *SnapshotStore, month time.Time, now time.Time) ([]xlsxSheet, error) {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0).Add(-time.Nanosecond)
+
+	latest := make(map[int]BidSnapshot)
+	err := store.Range(start, end, func(snapshot BidSnapshot) error {
+		latest[snapshot.BidId] = snapshot
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bidIds := make([]int, 0, len(latest))
+	for bidId := range latest {
+		if _, ok := activeBids()[bidId]; ok {
+			bidIds = append(bidIds, bidId)
+		}
+	}
+	sort.Ints(bidIds)
+
+	summary := xlsxSheet{
+		Name:      "Summary",
+		ColWidths: []float64{8, 8, 18, 18, 18, 22, 16, 12, 12, 18, 14, 16},
+		Rows: [][]xlsxCell{
+			{{Value: "Deployment report " + start.Format(reportMonthFormat), Style: xlsxStyleHeader}},
+			{},
+			xlsxHeaderRow("Bid", "Round", "Initial allocation", "Value at month end", "Withdrawn to date",
+				"Withdrawn this month", "Return (ATOM)", "Return", "APR", "Lifetime rewards", "Snapshot", "ATOM price (USD)"),
+		},
+	}
+	sheets := []xlsxSheet{}
+
+	for _, bidId := range bidIds {
+		snapshot := latest[bidId]
+		bidConfig := activeBids()[bidId]
+
+		// only count the withdrawals that had happened at the time of the snapshot
+		var withdrawals []Withdrawal
+		withdrawnThisMonth := 0.0
+		for _, withdrawal := range bidConfig.Withdrawals {
+			if withdrawal.Date.After(snapshot.Timestamp) {
+				continue
+			}
+			withdrawals = append(withdrawals, withdrawal)
+			if !withdrawal.Date.Before(start) {
+				withdrawnThisMonth += withdrawal.WithdrawnAmount
+			}
+		}
+		bidConfigAtSnapshot := bidConfig
+		bidConfigAtSnapshot.Withdrawals = withdrawals
+
+		// likewise for the reward claims
+		bidConfigAtSnapshot.RewardClaims = nil
+		for _, claim := range bidConfig.RewardClaims {
+			if !claim.Date.After(snapshot.Timestamp) {
+				bidConfigAtSnapshot.RewardClaims = append(bidConfigAtSnapshot.RewardClaims, claim)
+			}
+		}
+
+		round := xlsxCell{}
+		if r, ok := roundForBid(bidId); ok {
+			round = xlsxCell{Value: r.Round}
+		}
+
+		row := []xlsxCell{{Value: bidId}, round, xlsxNumber(totalAllocatedAtom(bidConfig))}
+		performance := computeBidPerformance(bidId, bidConfigAtSnapshot, snapshot.Holdings, snapshot.Timestamp)
+		if performance != nil {
+			row = append(row, xlsxNumber(performance.CurrentValueAtom), xlsxNumber(performance.WithdrawnAtom),
+				xlsxNumber(withdrawnThisMonth), xlsxNumber(performance.ReturnAtom), xlsxPercent(performance.ReturnPercent/100))
+			if performance.APR != nil {
+				row = append(row, xlsxPercent(*performance.APR/100))
+			} else {
+				row = append(row, xlsxCell{})
+			}
+		} else {
+			row = append(row, xlsxCell{}, xlsxCell{}, xlsxNumber(withdrawnThisMonth), xlsxCell{}, xlsxCell{}, xlsxCell{})
+		}
+		row = append(row, xlsxNumber(computeLifetimeRewards(bidConfigAtSnapshot, snapshot.Holdings).TotalAtom), xlsxDate(snapshot.Timestamp))
+		// the price the snapshot was valued at, for snapshots that recorded it
+		if snapshot.Prices != nil {
+			row = append(row, xlsxNumber(snapshot.Prices.AtomUSD))
+		}
+		summary.Rows = append(summary.Rows, row)
+
+		sheets = append(sheets, bidReportSheet(bidId, snapshot, withdrawals))
+	}
+
+	if len(bidIds) == 0 {
+		summary.Rows = append(summary.Rows, []xlsxCell{{Value: "No snapshots in this month"}})
+	}
+	summary.Rows = append(summary.Rows, []xlsxCell{}, []xlsxCell{{Value: "Generated " + now.UTC().Format(time.RFC3339)}})
+
+	return append([]xlsxSheet{summary}, sheets...), nil
+}
+
+// bidReportSheet lists the venues and withdrawals of a bid.
+func bidReportSheet(bidId int, snapshot BidSnapshot, withdrawals []Withdrawal) xlsxSheet {
+	sheet := xlsxSheet{
+		Name:      fmt.Sprintf("Bid %d", bidId),
+		ColWidths: []float64{40, 22, 16, 16, 16, 16, 14},
+		Rows: [][]xlsxCell{
+			{{Value: "Bid", Style: xlsxStyleHeader}, {Value: bidId}},
+			{{Value: "Initial allocation", Style: xlsxStyleHeader}, xlsxNumber(totalAllocatedAtom(activeBids()[bidId]))},
+			{{Value: "Snapshot", Style: xlsxStyleHeader}, xlsxDate(snapshot.Timestamp)},
+			{},
+			xlsxHeaderRow("Venue", "Protocol", "Principal (USD)", "Principal (ATOM)", "Rewards (USD)", "Rewards (ATOM)", "Note"),
+		},
+	}
+
+	for _, venueHoldings := range snapshot.Holdings {
+		row := []xlsxCell{{Value: venueHoldings.VenueID}, {Value: string(venueHoldings.Protocol)}}
+		for _, holdings := range []*Holdings{venueHoldings.AddressPrincipal, venueHoldings.AddressRewards} {
+			if holdings != nil {
+				row = append(row, xlsxNumber(holdings.TotalUSDC), xlsxNumber(holdings.TotalAtom))
+			} else {
+				row = append(row, xlsxCell{}, xlsxCell{})
+			}
+		}
+		if venueHoldings.InfoMissing {
+			row = append(row, xlsxCell{Value: "no integration"})
+		} else if venueHoldings.Capabilities != nil && venueHoldings.Capabilities.RewardsIncludedInPrincipal {
+			row = append(row, xlsxCell{Value: "rewards included in principal"})
+		}
+		sheet.Rows = append(sheet.Rows, row)
+	}
+
+	sheet.Rows = append(sheet.Rows, []xlsxCell{},
+		xlsxHeaderRow("Withdrawal date", "Amount (ATOM)", "Shares", "Compounded into"))
+	for _, withdrawal := range withdrawals {
+		var targets []string
+		for _, target := range withdrawal.CompoundingTargets() {
+			targets = append(targets, "bid "+strconv.Itoa(target.BidId))
+		}
+		sheet.Rows = append(sheet.Rows, []xlsxCell{
+			xlsxDate(withdrawal.Date),
+			xlsxNumber(withdrawal.WithdrawnAmount),
+			xlsxNumber(withdrawal.WithdrawnShares),
+			{Value: strings.Join(targets, ", ")},
+		})
+	}
+
+	return sheet
+}
+
+func parseReportMonth(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+	}
+
+	month, err := time.Parse(reportMonthFormat, s)
+	if err != nil {
+		return month, fmt.Errorf("invalid month, expected YYYY-MM: %v", err)
+	}
+	return month, nil
+}
+
+// runReport implements the report command, which writes the monthly report workbook:
+//
+//	deployment_tracking report --month 2025-08 --output report-2025-08.xlsx
+func runReport(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	monthStr := flags.String("month", "", "Month of the report (YYYY-MM), the current month if empty")
+	snapshotDir := flags.String("snapshot-dir", "snapshots", "Directory the snapshots are stored in")
+	output := flags.String("output", "", "File to write to, report-<month>.xlsx if empty")
+	dateFormat := flags.String("date-format", DateFormatISO, "Format of dates: iso (2025-08-31), dmy (31.08.2025) or mdy (08/31/2025)")
+	flags.Parse(args)
+
+	now := time.Now()
+	month, err := parseReportMonth(*monthStr, now)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		*output = "report-" + month.Format(reportMonthFormat) + ".xlsx"
+	}
+	locale, err := parseExportLocale("", *dateFormat)
+	if err != nil {
+		return err
+	}
+
+	if err := loadSigningKey(); err != nil {
+		return err
+	}
+
+	store, err := NewSnapshotStore(*snapshotDir)
+	if err != nil {
+		return err
+	}
+
+	sheets, err := buildMonthlyReport(store, month, now)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, sheets, locale); err != nil {
+		return err
+	}
+
+	if err := writeSealedFile(*output, buf.Bytes()); err != nil {
+		return err
+	}
+
+	log.Printf("Wrote the report for %s to %s", month.Format(reportMonthFormat), *output)
+	return nil
+}
+
+// monthlyReportHandler serves the monthly report workbook for ?month=YYYY-MM (the current month by default).
+func monthlyReportHandler(w http.ResponseWriter, r *http.Request) {
+	if snapshotStore == nil {
+		http.Error(w, "snapshots are disabled", http.StatusNotFound)
+		return
+	}
+
+	now := time.Now()
+	month, err := parseReportMonth(r.URL.Query().Get("month"), now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := parseExportLocale("", r.URL.Query().Get("date_format"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sheets, err := buildMonthlyReport(snapshotStore, month, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, sheets, locale); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	checksum, signature := checksumAndSign(buf.Bytes())
+	w.Header().Set("X-Content-SHA256", checksum)
+	if signature != "" {
+		w.Header().Set("X-Signature", signature)
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="report-%s.xlsx"`, month.Format(reportMonthFormat)))
+	w.Write(buf.Bytes())
+}
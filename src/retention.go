@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SnapshotCompactionInterval is how often the snapshot store is compacted.
+const SnapshotCompactionInterval = time.Hour
+
+// DefaultSnapshotRetention keeps every snapshot for a week, then the last one per bid and hour
+// for 30 days, and the last one per bid and day forever.
+const DefaultSnapshotRetention = "all=7d,hourly=30d,daily=forever"
+
+// Snapshot resolutions of a retention tier.
+const (
+	ResolutionAll    = "all"
+	ResolutionHourly = "hourly"
+	ResolutionDaily  = "daily"
+)
+
+var snapshotsCompactedMetric = newCounter("snapshots_compacted_total",
+	"Number of snapshots removed by the retention policy.")
+
+// RetentionTier keeps snapshots at a resolution until they are MaxAge old. A zero MaxAge keeps them forever.
+type RetentionTier struct {
+	Resolution string
+	MaxAge     time.Duration
+}
+
+// parseSnapshotRetention parses a retention policy like "all=7d,hourly=30d,daily=forever". Each tier
+// applies to snapshots older than the previous one; snapshots older than the last tier are deleted.
+func parseSnapshotRetention(spec string) ([]RetentionTier, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var tiers []RetentionTier
+	for _, part := range strings.Split(spec, ",") {
+		resolution, ageStr, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid retention tier %q, expected <resolution>=<age>", part)
+		}
+		if resolution != ResolutionAll && resolution != ResolutionHourly && resolution != ResolutionDaily {
+			return nil, fmt.Errorf("unknown resolution %q, expected all, hourly or daily", resolution)
+		}
+		if len(tiers) > 0 && tiers[len(tiers)-1].MaxAge == 0 {
+			return nil, fmt.Errorf("retention tier %q follows a tier that is kept forever", part)
+		}
+
+		tier := RetentionTier{Resolution: resolution}
+		if ageStr != "forever" {
+			age, err := parseRetentionAge(ageStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid age of retention tier %q: %v", part, err)
+			}
+			if len(tiers) > 0 && age <= tiers[len(tiers)-1].MaxAge {
+				return nil, fmt.Errorf("retention tier %q doesn't keep snapshots longer than the previous one", part)
+			}
+			tier.MaxAge = age
+		}
+		tiers = append(tiers, tier)
+	}
+
+	return tiers, nil
+}
+
+// parseRetentionAge parses a duration, also accepting days like "30d".
+func parseRetentionAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of days %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	age, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if age <= 0 {
+		return 0, fmt.Errorf("age must be positive")
+	}
+	return age, nil
+}
+
+// snapshotResolution returns the resolution at which a snapshot of the given age is kept, or false
+// if it is past the retention.
+func snapshotResolution(tiers []RetentionTier, age time.Duration) (string, bool) {
+	for _, tier := range tiers {
+		if tier.MaxAge == 0 || age < tier.MaxAge {
+			return tier.Resolution, true
+		}
+	}
+	return "", false
+}
+
+// startSnapshotCompaction compacts the snapshot store according to the retention policy, on
+// startup and then periodically.
+func startSnapshotCompaction(store *SnapshotStore, tiers []RetentionTier) {
+	go func() {
+		ticker := time.NewTicker(SnapshotCompactionInterval)
+		defer ticker.Stop()
+
+		for {
+			if removed, err := store.Compact(tiers, time.Now()); err != nil {
+				log.Printf("Compacting snapshots failed: %v", err)
+			} else if removed > 0 {
+				log.Printf("Compacted snapshots, removed %d", removed)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// Compact thins out the snapshots according to the retention tiers, keeping the last snapshot of
+// every bid per hour or day at those resolutions, and deletes snapshots past the retention. The
+// kept snapshots are copied verbatim, so that their checksums and signatures stay valid. It returns
+// the number of removed snapshots.
+func (s *SnapshotStore) Compact(tiers []RetentionTier, now time.Time) (int, error) {
+	if len(tiers) == 0 {
+		return 0, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days, err := s.days()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, day := range days {
+		// skip the days whose snapshots are all kept, i.e. even the oldest possible one
+		if resolution, ok := snapshotResolution(tiers, now.Sub(day)); ok && resolution == ResolutionAll {
+			continue
+		}
+
+		n, err := s.compactFile(s.fileForDay(day), tiers, now)
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+
+	snapshotsCompactedMetric.Add(float64(removed))
+	return removed, nil
+}
+
+// compactFile rewrites a snapshot file with the snapshots to keep, or deletes it if none are left.
+func (s *SnapshotStore) compactFile(path string, tiers []RetentionTier, now time.Time) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("reading snapshot file: %v", err)
+	}
+
+	type snapshotLine struct {
+		line []byte
+		key  string // bid and period, for the hourly and daily resolutions
+	}
+
+	var lines []snapshotLine
+	last := make(map[string]int) // the index of the last snapshot of every key
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var snapshot struct {
+			Timestamp time.Time `json:"timestamp"`
+			BidId     int       `json:"bid_id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			return 0, fmt.Errorf("decoding snapshot in %s: %v", path, err)
+		}
+
+		resolution, ok := snapshotResolution(tiers, now.Sub(snapshot.Timestamp))
+		if !ok {
+			lines = append(lines, snapshotLine{})
+			continue
+		}
+
+		line := snapshotLine{line: append([]byte(nil), scanner.Bytes()...)}
+		switch resolution {
+		case ResolutionHourly:
+			line.key = fmt.Sprintf("%d/%s", snapshot.BidId, snapshot.Timestamp.UTC().Format("2006-01-02T15"))
+		case ResolutionDaily:
+			line.key = fmt.Sprintf("%d/%s", snapshot.BidId, snapshot.Timestamp.UTC().Format(snapshotFileDateFormat))
+		}
+		if line.key != "" {
+			last[line.key] = len(lines)
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, fmt.Errorf("reading snapshot file: %v", err)
+	}
+
+	var kept bytes.Buffer
+	removed := 0
+	for i, line := range lines {
+		if line.line == nil || (line.key != "" && last[line.key] != i) {
+			removed++
+			continue
+		}
+		kept.Write(line.line)
+		kept.WriteByte('\n')
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	if kept.Len() == 0 {
+		return removed, os.Remove(path)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("writing snapshot file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(kept.Bytes()); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("writing snapshot file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("writing snapshot file: %v", err)
+	}
+	return removed, os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Reward categories, by what the value of a reward asset follows.
+const (
+	RewardCategoryAtom   = "atom" // ATOM and its liquid staking tokens
+	RewardCategoryStable = "stable"
+	RewardCategoryOther  = "other"
+)
+
+// RewardSubtotal is the value of the reward assets of one category.
+type RewardSubtotal struct {
+	Category  string   `json:"category"`
+	Assets    []string `json:"assets"` // display names, or denoms if unknown
+	TotalUSDC float64  `json:"total_usdc"`
+	TotalAtom float64  `json:"total_atom"`
+}
+
+// RewardClaim records rewards claimed from a venue of a bid, valued in ATOM at the time of the claim.
+// Claimed rewards leave the venue, so they are no longer part of its pending rewards.
+type RewardClaim struct {
+	Date       time.Time `json:"date"`
+	VenueID    string    `json:"venue_id"`
+	AmountAtom float64   `json:"amount_atom"`
+	TxHash     string    `json:"tx_hash,omitempty"`
+}
+
+// LifetimeRewards are the rewards a bid earned since its deployment, in ATOM.
+type LifetimeRewards struct {
+	PendingAtom float64 `json:"pending_atom"`
+	ClaimedAtom float64 `json:"claimed_atom"`
+	TotalAtom   float64 `json:"total_atom"`
+	// PendingKnown is false if the pending rewards of some venues are unknown, i.e. the total is a lower bound.
+	PendingKnown bool `json:"pending_known"`
+}
+
+// computeLifetimeRewards adds the recorded claims of a bid to the rewards currently pending in its venues.
+func computeLifetimeRewards(bidConfig BidPositionConfig, holdings []VenueHoldings) *LifetimeRewards {
+	rewards := &LifetimeRewards{PendingKnown: holdings != nil}
+
+	for _, venueHoldings := range holdings {
+		if venueHoldings.InfoMissing || venueHoldings.Pending {
+			rewards.PendingKnown = false
+			continue
+		}
+		if venueHoldings.AddressRewards != nil {
+			rewards.PendingAtom += venueHoldings.AddressRewards.TotalAtom
+		}
+	}
+
+	for _, claim := range bidConfig.RewardClaims {
+		rewards.ClaimedAtom += claim.AmountAtom
+	}
+
+	rewards.TotalAtom = rewards.PendingAtom + rewards.ClaimedAtom
+	return rewards
+}
+
+// validateRewardClaims checks that the claims of a bid are from its own venues.
+func validateRewardClaims(bids map[int]BidPositionConfig, bidId int, bidConfig BidPositionConfig) []error {
+	var errs []error
+
+	for _, claim := range bidConfig.RewardClaims {
+		date := claim.Date.Format("2006-01-02")
+		if claimBidId, _, ok := findVenueIn(bids, claim.VenueID); !ok || claimBidId != bidId {
+			errs = append(errs, fmt.Errorf("bid %d: reward claim on %s is from venue %s, which is not a venue of the bid", bidId, date, claim.VenueID))
+		}
+		if claim.AmountAtom < 0 {
+			errs = append(errs, fmt.Errorf("bid %d: reward claim on %s has a negative amount", bidId, date))
+		}
+	}
+
+	return errs
+}
+
+// rewardCategory returns the category of a reward asset by its display name.
+func rewardCategory(displayName string) string {
+	symbol := strings.ToUpper(displayName)
+	switch {
+	case symbol == "ATOM" || (strings.HasSuffix(symbol, "ATOM") && len(symbol) <= len("STKATOM")):
+		return RewardCategoryAtom
+	case assetClass(displayName) == AssetClassStable:
+		return RewardCategoryStable
+	default:
+		return RewardCategoryOther
+	}
+}
+
+// rewardBreakdown groups reward holdings by category, in the order ATOM, stable, other.
+// Categories without assets are left out.
+func rewardBreakdown(rewards *Holdings) []RewardSubtotal {
+	if rewards == nil {
+		return nil
+	}
+
+	subtotals := make(map[string]*RewardSubtotal)
+	for _, asset := range rewards.Balances {
+		category := rewardCategory(asset.DisplayName)
+		subtotal, ok := subtotals[category]
+		if !ok {
+			subtotal = &RewardSubtotal{Category: category, Assets: []string{}}
+			subtotals[category] = subtotal
+		}
+
+		name := asset.DisplayName
+		if name == "" {
+			name = asset.Denom
+		}
+		subtotal.Assets = append(subtotal.Assets, name)
+		subtotal.TotalUSDC += asset.USDValue
+		subtotal.TotalAtom += asset.AtomValue
+	}
+
+	breakdown := []RewardSubtotal{}
+	for _, category := range []string{RewardCategoryAtom, RewardCategoryStable, RewardCategoryOther} {
+		if subtotal, ok := subtotals[category]; ok {
+			breakdown = append(breakdown, *subtotal)
+		}
+	}
+	return breakdown
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultRiskWindow is how far back the risk of the bids is estimated from by default.
+const DefaultRiskWindow = 90 * 24 * time.Hour
+
+// BidRisk is the volatility of the value of a bid and its return per unit of volatility, from
+// its daily returns between snapshots. The returns are in the unit of the bid, like the accrual
+// of venues: ATOM for bids holding only ATOM, USD for the others.
+type BidRisk struct {
+	BidId int    `json:"bid_id"`
+	Unit  string `json:"unit"`
+	// Returns is the number of daily returns, ExcludedReturns the days with cash flows of the bid,
+	// which would pass for returns.
+	Returns         int `json:"returns"`
+	ExcludedReturns int `json:"excluded_returns"`
+	// The statistics are nil with fewer than two returns.
+	MeanDailyReturnPercent      *float64 `json:"mean_daily_return_percent"`
+	DailyVolatilityPercent      *float64 `json:"daily_volatility_percent"` // standard deviation of the daily returns
+	AnnualizedReturnPercent     *float64 `json:"annualized_return_percent"`
+	AnnualizedVolatilityPercent *float64 `json:"annualized_volatility_percent"`
+	// RiskAdjustedReturn is the annualized return over the annualized volatility, a Sharpe ratio
+	// without a risk-free rate. It is nil for bids without volatility.
+	RiskAdjustedReturn *float64 `json:"risk_adjusted_return"`
+}
+
+type riskPoint struct {
+	timestamp time.Time
+	valueAtom float64
+	valueUSD  float64
+	atomOnly  bool
+}
+
+// bidRiskPoints returns the value of every bid at the last snapshot of each day. Snapshots in
+// which a venue of the bid couldn't be valued are left out, so that they don't pass for losses.
+func bidRiskPoints(from time.Time, to time.Time) (map[int][]riskPoint, error) {
+	points := make(map[int][]riskPoint)
+	err := snapshotStore.Range(from, to, func(snapshot BidSnapshot) error {
+		point := riskPoint{timestamp: snapshot.Timestamp, atomOnly: true}
+		for _, venueHoldings := range snapshot.Holdings {
+			if venueHoldings.InfoMissing || venueHoldings.Testnet {
+				continue
+			}
+			if venueHoldings.AddressPrincipal == nil || venueHoldings.Stale {
+				return nil
+			}
+			valueAtom, valueUSD, atomOnly := venueValue(venueHoldings)
+			point.valueAtom += valueAtom
+			point.valueUSD += valueUSD
+			point.atomOnly = point.atomOnly && atomOnly
+		}
+
+		bidPoints := points[snapshot.BidId]
+		last := len(bidPoints) - 1
+		if last >= 0 && bidPoints[last].timestamp.Truncate(24*time.Hour).Equal(point.timestamp.Truncate(24*time.Hour)) {
+			bidPoints[last] = point
+		} else {
+			bidPoints = append(bidPoints, point)
+		}
+		points[snapshot.BidId] = bidPoints
+		return nil
+	})
+	return points, err
+}
+
+// computeBidRisk computes the risk statistics of a bid from its daily values.
+func computeBidRisk(bidId int, points []riskPoint) BidRisk {
+	risk := BidRisk{BidId: bidId, Unit: AccrualUnitUSD}
+	if len(points) > 0 && points[len(points)-1].atomOnly {
+		risk.Unit = AccrualUnitAtom
+	}
+	value := func(point riskPoint) float64 {
+		if risk.Unit == AccrualUnitAtom {
+			return point.valueAtom
+		}
+		return point.valueUSD
+	}
+
+	flows := bidCashFlowDates(bidId, "")
+	var returns []float64
+	for i := 1; i < len(points); i++ {
+		start, end := points[i-1], points[i]
+		days := end.timestamp.Sub(start.timestamp).Hours() / 24
+		if days <= 0 || value(start) <= 0 {
+			continue
+		}
+		if hasDateBetween(flows, start.timestamp, end.timestamp) {
+			risk.ExcludedReturns++
+			continue
+		}
+		returns = append(returns, (value(end)-value(start))/value(start)/days*100)
+	}
+
+	risk.Returns = len(returns)
+	if len(returns) < 2 {
+		return risk
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	volatility := math.Sqrt(variance / float64(len(returns)-1))
+
+	annualizedReturn := mean * 365
+	annualizedVolatility := volatility * math.Sqrt(365)
+	risk.MeanDailyReturnPercent = &mean
+	risk.DailyVolatilityPercent = &volatility
+	risk.AnnualizedReturnPercent = &annualizedReturn
+	risk.AnnualizedVolatilityPercent = &annualizedVolatility
+	if annualizedVolatility > 0 {
+		riskAdjusted := annualizedReturn / annualizedVolatility
+		risk.RiskAdjustedReturn = &riskAdjusted
+	}
+	return risk
+}
+
+// riskHandler serves the risk statistics of every active bid with snapshots. The time window can
+// be restricted with the RFC 3339 from and to query parameters, and defaults to the last 90 days.
+func riskHandler(w http.ResponseWriter, r *http.Request) {
+	if snapshotStore == nil {
+		http.Error(w, "snapshots are disabled", http.StatusNotFound)
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if from.IsZero() {
+		from = time.Now().Add(-DefaultRiskWindow)
+	}
+
+	points, err := bidRiskPoints(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bids := activeBids()
+	var bidIds []int
+	for bidId := range points {
+		if _, ok := bids[bidId]; ok {
+			bidIds = append(bidIds, bidId)
+		}
+	}
+	sort.Ints(bidIds)
+
+	risks := []BidRisk{}
+	for _, bidId := range bidIds {
+		risks = append(risks, computeBidRisk(bidId, points[bidId]))
+	}
+
+	jsonData, err := json.MarshalIndent(risks, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// HydroRound describes the time window of a Hydro round.
+// Bid IDs are assigned consecutively, so the bids of a round start at FirstBidId.
+type HydroRound struct {
+	Round      int       `json:"round"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	FirstBidId int       `json:"first_bid_id"`
+	Budget     float64   `json:"budget,omitempty"` // ATOM allocated to the round by the committee, 0 if unknown
+}
+
+// roundCalendar lists the Hydro rounds in chronological order.
+// It can be replaced at startup with --rounds-file.
+var roundCalendar = []HydroRound{
+	{Round: 1, Start: time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC), FirstBidId: 0},
+	{Round: 2, Start: time.Date(2025, 1, 17, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 2, 14, 0, 0, 0, 0, time.UTC), FirstBidId: 11},
+	{Round: 3, Start: time.Date(2025, 2, 14, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), FirstBidId: 18},
+	{Round: 4, Start: time.Date(2025, 3, 14, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC), FirstBidId: 25},
+	{Round: 5, Start: time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 5, 9, 0, 0, 0, 0, time.UTC), FirstBidId: 31},
+	{Round: 6, Start: time.Date(2025, 5, 9, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 6, 6, 0, 0, 0, 0, time.UTC), FirstBidId: 41},
+	{Round: 7, Start: time.Date(2025, 6, 6, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 7, 4, 0, 0, 0, 0, time.UTC), FirstBidId: 50},
+	{Round: 8, Start: time.Date(2025, 7, 4, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), FirstBidId: 57},
+	{Round: 9, Start: time.Date(2025, 8, 1, 0, 0, 0, 0, time.UTC), End: time.Date(2025, 8, 29, 0, 0, 0, 0, time.UTC), FirstBidId: 70},
+}
+
+// loadRoundCalendar replaces the round calendar with the rounds from a JSON file.
+func loadRoundCalendar(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading rounds file: %v", err)
+	}
+
+	var rounds []HydroRound
+	if err := json.Unmarshal(data, &rounds); err != nil {
+		return fmt.Errorf("decoding rounds file: %v", err)
+	}
+
+	sort.Slice(rounds, func(i, j int) bool { return rounds[i].Round < rounds[j].Round })
+	for i, round := range rounds {
+		if !round.End.After(round.Start) {
+			return fmt.Errorf("round %d ends before it starts", round.Round)
+		}
+		if round.Budget < 0 {
+			return fmt.Errorf("round %d has a negative budget", round.Round)
+		}
+		if i > 0 && round.FirstBidId <= rounds[i-1].FirstBidId {
+			return fmt.Errorf("round %d starts at bid %d, which is not after the first bid of round %d", round.Round, round.FirstBidId, rounds[i-1].Round)
+		}
+	}
+
+	roundCalendar = rounds
+	return nil
+}
+
+// roundForBid returns the round a bid was placed in: its configured round, if the calendar has it,
+// or else the round its ID falls into. The IDs of the last round of the calendar are open-ended, so a bid deployed after
+// its end is past the calendar rather than in it. Bids without a deployment date predate
+// FirstBidRequiringDeployedAt and are taken to be in it.
+func roundForBid(bidId int) (*HydroRound, bool) {
+	bidConfig := activeBids()[bidId]
+	if bidConfig.Round != 0 {
+		for i := range roundCalendar {
+			if roundCalendar[i].Round == bidConfig.Round {
+				return &roundCalendar[i], true
+			}
+		}
+		return nil, false
+	}
+
+	for i := len(roundCalendar) - 1; i >= 0; i-- {
+		if bidId < roundCalendar[i].FirstBidId {
+			continue
+		}
+		if i == len(roundCalendar)-1 {
+			if deployedAt, source := configuredDeploymentDate(bidId, bidConfig); source != "" && !deployedAt.Before(roundCalendar[i].End) {
+				return nil, false
+			}
+		}
+		return &roundCalendar[i], true
+	}
+	return nil, false
+}
+
+// bidRound returns the number of the round a bid was placed in, 0 if unknown.
+func bidRound(bidId int, bidConfig BidPositionConfig) int {
+	if bidConfig.Round != 0 {
+		return bidConfig.Round
+	}
+	if round, ok := roundForBid(bidId); ok {
+		return round.Round
+	}
+	return 0
+}
+
+// RoundResponse is a round together with the bids placed in it and what they did with its budget,
+// all in ATOM.
+type RoundResponse struct {
+	HydroRound
+	BidIds    []int   `json:"bid_ids"`
+	Deployed  float64 `json:"deployed"`  // initial allocations of the bids
+	Withdrawn float64 `json:"withdrawn"` // withdrawals of the bids to date, including compounded ones
+	// Undeployed is the part of the budget that was never allocated to a bid, 0 if the budget is unknown.
+	Undeployed float64 `json:"undeployed"`
+}
+
+var roundUndeployedMetric = newGauge("round_undeployed_atom",
+	"ATOM of the budget of a round that was never allocated to a bid, as of the last /rounds request.")
+
+// roundsHandler serves the round calendar.
+func roundsHandler(w http.ResponseWriter, r *http.Request) {
+	rounds := make([]RoundResponse, len(roundCalendar))
+	for i, round := range roundCalendar {
+		rounds[i] = RoundResponse{HydroRound: round, BidIds: []int{}}
+	}
+
+	for bidId, bidConfig := range activeBids() {
+		round := bidRound(bidId, bidConfig)
+		for i := range rounds {
+			if rounds[i].Round == round {
+				rounds[i].BidIds = append(rounds[i].BidIds, bidId)
+				rounds[i].Deployed += totalAllocatedAtom(bidConfig)
+				rounds[i].Withdrawn += bidWithdrawnAtom(bidConfig, time.Now())
+			}
+		}
+	}
+
+	for i := range rounds {
+		sort.Ints(rounds[i].BidIds)
+		if rounds[i].Budget > 0 {
+			rounds[i].Undeployed = math.Max(rounds[i].Budget-rounds[i].Deployed, 0)
+			roundUndeployedMetric.Set(rounds[i].Undeployed, "round", strconv.Itoa(rounds[i].Round))
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(rounds, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
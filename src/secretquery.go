@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+)
+
+// Contract queries on Secret Network are encrypted for the enclave of the chain, the same way as
+// secret.js does it: the query is sealed with AES-SIV under a key derived from our ephemeral
+// X25519 key, the consensus IO key of the chain and a nonce, and the response is sealed under
+// the same key.
+
+// secretHKDFSalt is the salt of the derivation of the encryption keys on Secret Network.
+var secretHKDFSalt, _ = hex.DecodeString("000000000000000000024bead8df69990852c202db0e0097c1a12ea637d7e96d")
+
+// secretEncryptedErrorRegexp extracts the encrypted message of a failed contract query.
+var secretEncryptedErrorRegexp = regexp.MustCompile(`encrypted: (.+?): query contract failed`)
+
+var (
+	secretKeyOnce sync.Once
+	secretKey     *ecdh.PrivateKey
+	secretKeyErr  error
+
+	secretCacheMu sync.Mutex
+	// consensus IO keys by node and code hashes by contract, which only change on upgrades
+	secretConsensusKeys = make(map[string][]byte)
+	secretCodeHashes    = make(map[string]string)
+)
+
+// querySecretContract runs a query against a contract on Secret Network through the LCD at
+// nodeUrl and decodes the result into target.
+func querySecretContract(ctx context.Context, nodeUrl string, contractAddress string, query interface{}, target interface{}) error {
+	debugLog("Querying secret contract", query)
+
+	secretKeyOnce.Do(func() {
+		secretKey, secretKeyErr = ecdh.X25519().GenerateKey(rand.Reader)
+	})
+	if secretKeyErr != nil {
+		return fmt.Errorf("generating encryption key: %v", secretKeyErr)
+	}
+
+	codeHash, err := secretCodeHash(ctx, nodeUrl, contractAddress)
+	if err != nil {
+		return err
+	}
+	consensusKey, err := secretConsensusKey(ctx, nodeUrl)
+	if err != nil {
+		return err
+	}
+
+	queryJson, err := json.Marshal(query)
+	if err != nil {
+		return fmt.Errorf("failed to marshal query into JSON: %v", err)
+	}
+
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generating nonce: %v", err)
+	}
+	key, err := secretEncryptionKey(consensusKey, nonce)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := sivSeal(key, append([]byte(codeHash), queryJson...))
+	if err != nil {
+		return err
+	}
+
+	encrypted := append(append(append([]byte{}, nonce...), secretKey.PublicKey().Bytes()...), ciphertext...)
+	queryUrl := fmt.Sprintf("%s/compute/v1beta1/query/%s?query=%s",
+		nodeUrl, contractAddress, url.QueryEscape(base64.StdEncoding.EncodeToString(encrypted)))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", queryUrl, nil)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %v", err)
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := upstreamClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching data failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		debugLog("Failed to query secret contract", map[string]interface{}{
+			"status_code": resp.StatusCode,
+			"response":    string(body),
+		})
+
+		// contract errors are encrypted too
+		if match := secretEncryptedErrorRegexp.FindSubmatch(body); match != nil {
+			if sealed, err := base64.StdEncoding.DecodeString(string(match[1])); err == nil {
+				if message, err := sivOpen(key, sealed); err == nil {
+					return fmt.Errorf("error querying secret contract: %s", message)
+				}
+			}
+		}
+		return fmt.Errorf("querying secret contract: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("decoding secret contract response: %v", err)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(response.Data)
+	if err != nil {
+		return fmt.Errorf("decoding secret contract response: %v", err)
+	}
+	// the result is the base64 encoded JSON response of the contract
+	plaintext, err := sivOpen(key, sealed)
+	if err != nil {
+		return fmt.Errorf("decrypting secret contract response: %v", err)
+	}
+	result, err := base64.StdEncoding.DecodeString(string(plaintext))
+	if err != nil {
+		return fmt.Errorf("decoding secret contract result: %v", err)
+	}
+
+	debugLog("secret contract response", string(result))
+
+	if err := json.Unmarshal(result, target); err != nil {
+		return fmt.Errorf("decoding secret contract result: %v", err)
+	}
+	return nil
+}
+
+// secretCodeHash returns the code hash of a contract, which prefixes the queries to it.
+func secretCodeHash(ctx context.Context, nodeUrl string, contractAddress string) (string, error) {
+	secretCacheMu.Lock()
+	codeHash, ok := secretCodeHashes[contractAddress]
+	secretCacheMu.Unlock()
+	if ok {
+		return codeHash, nil
+	}
+
+	var response struct {
+		CodeHash string `json:"code_hash"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf("%s/compute/v1beta1/code_hash/by_contract_address/%s", nodeUrl, contractAddress), &response); err != nil {
+		return "", fmt.Errorf("fetching code hash of %s: %v", contractAddress, err)
+	}
+
+	secretCacheMu.Lock()
+	secretCodeHashes[contractAddress] = response.CodeHash
+	secretCacheMu.Unlock()
+	return response.CodeHash, nil
+}
+
+// secretConsensusKey returns the consensus IO public key of the chain, which the enclave decrypts
+// the queries with.
+func secretConsensusKey(ctx context.Context, nodeUrl string) ([]byte, error) {
+	secretCacheMu.Lock()
+	key, ok := secretConsensusKeys[nodeUrl]
+	secretCacheMu.Unlock()
+	if ok {
+		return key, nil
+	}
+
+	var response struct {
+		Key string `json:"key"`
+	}
+	if err := getJSON(ctx, nodeUrl+"/registration/v1beta1/tx-key", &response); err != nil {
+		return nil, fmt.Errorf("fetching consensus IO key: %v", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(response.Key)
+	if err != nil {
+		return nil, fmt.Errorf("decoding consensus IO key: %v", err)
+	}
+
+	secretCacheMu.Lock()
+	secretConsensusKeys[nodeUrl] = key
+	secretCacheMu.Unlock()
+	return key, nil
+}
+
+// secretEncryptionKey derives the key of a query from the shared secret with the chain and the
+// nonce of the query, with HKDF-SHA256.
+func secretEncryptionKey(consensusKey []byte, nonce []byte) ([]byte, error) {
+	publicKey, err := ecdh.X25519().NewPublicKey(consensusKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consensus IO key: %v", err)
+	}
+	shared, err := secretKey.ECDH(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("deriving shared secret: %v", err)
+	}
+
+	extract := hmac.New(sha256.New, secretHKDFSalt)
+	extract.Write(shared)
+	extract.Write(nonce)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte{1})
+	return expand.Sum(nil), nil
+}
+
+// sivAssociatedData is the associated data of the AES-SIV encryption of secret.js.
+var sivAssociatedData = [][]byte{nil}
+
+// sivSeal encrypts with AES-SIV (RFC 5297) with a single empty associated data, as secret.js
+// does. The first half of the key authenticates, the second half encrypts.
+func sivSeal(key []byte, plaintext []byte) ([]byte, error) {
+	macCipher, ctrCipher, err := sivCiphers(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v := sivS2V(macCipher, sivAssociatedData, plaintext)
+	ciphertext := make([]byte, len(plaintext))
+	sivCTR(ctrCipher, v).XORKeyStream(ciphertext, plaintext)
+	return append(v, ciphertext...), nil
+}
+
+// sivOpen decrypts and authenticates what sivSeal encrypted.
+func sivOpen(key []byte, sealed []byte) ([]byte, error) {
+	if len(sealed) < aes.BlockSize {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	macCipher, ctrCipher, err := sivCiphers(key)
+	if err != nil {
+		return nil, err
+	}
+
+	v, ciphertext := sealed[:aes.BlockSize], sealed[aes.BlockSize:]
+	plaintext := make([]byte, len(ciphertext))
+	sivCTR(ctrCipher, v).XORKeyStream(plaintext, ciphertext)
+	if subtle.ConstantTimeCompare(v, sivS2V(macCipher, sivAssociatedData, plaintext)) != 1 {
+		return nil, fmt.Errorf("ciphertext not authentic")
+	}
+	return plaintext, nil
+}
+
+func sivCiphers(key []byte) (cipher.Block, cipher.Block, error) {
+	macCipher, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, nil, err
+	}
+	ctrCipher, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return macCipher, ctrCipher, nil
+}
+
+// sivCTR returns the CTR stream of a synthetic IV, whose bits 31 and 63 are cleared.
+func sivCTR(block cipher.Block, v []byte) cipher.Stream {
+	iv := append([]byte{}, v...)
+	iv[8] &= 0x7f
+	iv[12] &= 0x7f
+	return cipher.NewCTR(block, iv)
+}
+
+// sivS2V computes the synthetic IV of a plaintext and its associated data. Secret Network uses a
+// single empty associated data.
+func sivS2V(block cipher.Block, associatedData [][]byte, plaintext []byte) []byte {
+	d := cmac(block, make([]byte, aes.BlockSize))
+	for _, data := range associatedData {
+		d = xorBlock(dbl(d), cmac(block, data))
+	}
+
+	var t []byte
+	if len(plaintext) >= aes.BlockSize {
+		t = append([]byte{}, plaintext...)
+		tail := t[len(t)-aes.BlockSize:]
+		copy(tail, xorBlock(tail, d))
+	} else {
+		padded := make([]byte, aes.BlockSize)
+		copy(padded, plaintext)
+		padded[len(plaintext)] = 0x80
+		t = xorBlock(dbl(d), padded)
+	}
+	return cmac(block, t)
+}
+
+// cmac computes the AES-CMAC (RFC 4493) of a message.
+func cmac(block cipher.Block, message []byte) []byte {
+	l := make([]byte, aes.BlockSize)
+	block.Encrypt(l, l)
+	k1 := dbl(l)
+	k2 := dbl(k1)
+
+	n := (len(message) + aes.BlockSize - 1) / aes.BlockSize
+	last := make([]byte, aes.BlockSize)
+	if n > 0 && len(message)%aes.BlockSize == 0 {
+		copy(last, xorBlock(message[(n-1)*aes.BlockSize:], k1))
+	} else {
+		if n == 0 {
+			n = 1
+		}
+		copy(last, message[(n-1)*aes.BlockSize:])
+		last[len(message)-(n-1)*aes.BlockSize] = 0x80
+		last = xorBlock(last, k2)
+	}
+
+	x := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		x = xorBlock(x, message[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x, x)
+	}
+	x = xorBlock(x, last)
+	block.Encrypt(x, x)
+	return x
+}
+
+// dbl multiplies a block by x in GF(2^128).
+func dbl(b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	for i := 0; i < aes.BlockSize-1; i++ {
+		out[i] = b[i]<<1 | b[i+1]>>7
+	}
+	out[aes.BlockSize-1] = b[aes.BlockSize-1] << 1
+	if b[0]&0x80 != 0 {
+		out[aes.BlockSize-1] ^= 0x87
+	}
+	return out
+}
+
+func xorBlock(a []byte, b []byte) []byte {
+	out := make([]byte, aes.BlockSize)
+	for i := range out {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/ecdh"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+// TestCMAC checks the AES-CMAC examples of RFC 4493, section 4.
+func TestCMAC(t *testing.T) {
+	block, err := aes.NewCipher(mustHex(t, "2b7e151628aed2a6abf7158809cf4f3c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+	tests := []struct {
+		length int
+		mac    string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+	for _, test := range tests {
+		got := cmac(block, mustHex(t, message)[:test.length])
+		if want := mustHex(t, test.mac); !bytes.Equal(got, want) {
+			t.Errorf("CMAC of %d bytes: got %x, want %x", test.length, got, want)
+		}
+	}
+}
+
+// TestSIV checks AES-SIV against the deterministic example of RFC 5297, appendix A.1, and against
+// the same example with the single empty associated data that secret.js uses, which was computed
+// with an implementation written independently from the RFC (Node.js, AES from OpenSSL) that
+// reproduces A.1.
+func TestSIV(t *testing.T) {
+	key := mustHex(t, "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	plaintext := mustHex(t, "112233445566778899aabbccddee")
+
+	macCipher, ctrCipher, err := sivCiphers(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := sivS2V(macCipher, [][]byte{mustHex(t, "101112131415161718191a1b1c1d1e1f2021222324252627")}, plaintext)
+	if want := mustHex(t, "85632d07c6e8f37f950acd320a2ecc93"); !bytes.Equal(v, want) {
+		t.Errorf("RFC 5297 A.1 IV: got %x, want %x", v, want)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	sivCTR(ctrCipher, v).XORKeyStream(ciphertext, plaintext)
+	if want := mustHex(t, "40c02b9690c4dc04daef7f6afe5c"); !bytes.Equal(ciphertext, want) {
+		t.Errorf("RFC 5297 A.1 ciphertext: got %x, want %x", ciphertext, want)
+	}
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+		sealed    string
+	}{
+		{"A.1 plaintext", plaintext, "d1022f5b3664e5a4dfaf90f85be6f28ab66cff6b8eca0b79f083b39a0901"},
+		{"query longer than a block", []byte(`{"query":{"pair_info":{}}}`), "86b68eaf7cee86268ae32013ded85eeedf70f6e965a2dbb07bfaa6a21b9ab612293cbdd6847d4531f150"},
+	}
+	for _, test := range tests {
+		sealed, err := sivSeal(key, test.plaintext)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if want := mustHex(t, test.sealed); !bytes.Equal(sealed, want) {
+			t.Errorf("%s: sealed %x, want %x", test.name, sealed, want)
+		}
+
+		opened, err := sivOpen(key, sealed)
+		if err != nil || !bytes.Equal(opened, test.plaintext) {
+			t.Errorf("%s: opened %q (%v), want %q", test.name, opened, err, test.plaintext)
+		}
+		sealed[len(sealed)-1] ^= 1
+		if _, err := sivOpen(key, sealed); err == nil {
+			t.Errorf("%s: a tampered ciphertext was opened", test.name)
+		}
+	}
+}
+
+// TestSecretEncryptionKey checks the key derivation against a fixture derived like
+// EncryptionUtils.getTxEncryptionKey of secret.js: HKDF-SHA256 of the X25519 shared secret and
+// the nonce, with the salt of Secret Network, no info and 32 bytes of output. The X25519 keys are
+// the ones of RFC 7748, section 6.1, whose shared secret is known.
+func TestSecretEncryptionKey(t *testing.T) {
+	privateKey, err := ecdh.X25519().NewPrivateKey(mustHex(t, "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := secretKey
+	secretKey = privateKey
+	defer func() { secretKey = previous }()
+
+	consensusKey := mustHex(t, "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f")
+	nonce := bytes.Repeat([]byte{7}, 32)
+	key, err := secretEncryptionKey(consensusKey, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustHex(t, "0d818cacdfcb0473da846b237c6f8fc6f288c2b0c3c73c939b2b30f8556cfe8d"); !bytes.Equal(key, want) {
+		t.Errorf("got %x, want %x", key, want)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// SecretsTimeout bounds a single load of the secrets.
+const SecretsTimeout = 30 * time.Second
+
+// SecretsSource provides secrets by their environment variable name, e.g. NUMIA_API_TOKEN.
+type SecretsSource interface {
+	Load(ctx context.Context) (map[string]string, error)
+}
+
+var (
+	secretsSource SecretsSource
+	// secretValues holds the secrets last loaded from the source. Secrets missing from
+	// the source fall back to the environment.
+	secretValues atomic.Pointer[map[string]string]
+)
+
+var secretsReloadErrorsMetric = newCounter("secrets_reload_errors_total",
+	"Number of failed reloads of the secrets source.")
+
+// getSecret returns a secret from the secrets source or, if it isn't there, from the environment.
+// Callers must not hold on to the value, so that rotated secrets take effect.
+func getSecret(name string) string {
+	if values := secretValues.Load(); values != nil {
+		if value, ok := (*values)[name]; ok {
+			return value
+		}
+	}
+	return os.Getenv(name)
+}
+
+// parseSecretsSource parses the --secrets flag, one of
+//
+//	file:<path>                   a JSON object, encrypted if SECRETS_FILE_KEY is set
+//	vault:<mount>/<path>          a KV v2 secret, read with VAULT_ADDR and VAULT_TOKEN
+//	aws:<secret id or ARN>        an AWS Secrets Manager secret holding a JSON object
+func parseSecretsSource(s string) (SecretsSource, error) {
+	kind, location, ok := strings.Cut(s, ":")
+	if !ok || location == "" {
+		return nil, fmt.Errorf("invalid secrets source %q, expected file:, vault: or aws:<location>", s)
+	}
+
+	switch kind {
+	case "file":
+		return &FileSecrets{Path: location, Key: os.Getenv("SECRETS_FILE_KEY")}, nil
+	case "vault":
+		mount, path, ok := strings.Cut(location, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid Vault secret %q, expected <mount>/<path>", location)
+		}
+		addr := os.Getenv("VAULT_ADDR")
+		if addr == "" || os.Getenv("VAULT_TOKEN") == "" {
+			return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set")
+		}
+		return &VaultSecrets{Addr: strings.TrimSuffix(addr, "/"), Mount: mount, Path: path}, nil
+	case "aws":
+		source := &AWSSecrets{
+			SecretID: location,
+			Region:   os.Getenv("AWS_REGION"),
+			Credentials: AWSCredentials{
+				AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+				SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+				SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			},
+		}
+		if source.Region == "" {
+			source.Region = "us-east-1"
+		}
+		if source.Credentials.AccessKeyID == "" || source.Credentials.SecretAccessKey == "" {
+			return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+		}
+		return source, nil
+	default:
+		return nil, fmt.Errorf("unknown secrets source: %s", kind)
+	}
+}
+
+// loadSecrets (re)loads the secrets from the source. On failure, the previous secrets stay in use.
+func loadSecrets() error {
+	ctx, cancel := context.WithTimeout(context.Background(), SecretsTimeout)
+	defer cancel()
+
+	values, err := secretsSource.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	// only the names are logged, never the values
+	var changed []string
+	previous := secretValues.Load()
+	for name, value := range values {
+		if previous == nil || (*previous)[name] != value {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	secretValues.Store(&values)
+	if len(changed) > 0 {
+		log.Printf("Loaded secrets: %s", strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// startSecretsRotation reloads the secrets periodically and on SIGHUP.
+func startSecretsRotation(interval time.Duration) {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-tick:
+			case <-reload:
+				log.Printf("Reloading secrets on SIGHUP")
+			}
+			if err := loadSecrets(); err != nil {
+				secretsReloadErrorsMetric.Add(1)
+				log.Printf("Reloading secrets failed, keeping the previous ones: %v", err)
+			}
+		}
+	}()
+}
+
+// FileSecrets reads secrets from a JSON file. If Key is set, the file is encrypted with
+// AES-256-GCM, as written by the secrets encrypt command.
+type FileSecrets struct {
+	Path string
+	Key  string // base64
+}
+
+func (s *FileSecrets) Load(ctx context.Context) (map[string]string, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading secrets file: %v", err)
+	}
+
+	if s.Key != "" {
+		if data, err = decryptSecrets(s.Key, data); err != nil {
+			return nil, err
+		}
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("decoding secrets file: %v", err)
+	}
+	return values, nil
+}
+
+func secretsCipher(key string) (cipher.AEAD, error) {
+	rawKey, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(rawKey) != 32 {
+		return nil, fmt.Errorf("SECRETS_FILE_KEY must be a base64-encoded 32-byte key")
+	}
+
+	block, err := aes.NewCipher(rawKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecrets encrypts data to base64(nonce || ciphertext).
+func encryptSecrets(key string, data []byte) ([]byte, error) {
+	aead, err := secretsCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := aead.Seal(nonce, nonce, data, nil)
+	return []byte(base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+func decryptSecrets(key string, data []byte) ([]byte, error) {
+	aead, err := secretsCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("secrets file is not encrypted with SECRETS_FILE_KEY")
+	}
+
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting secrets file: %v", err)
+	}
+	return plain, nil
+}
+
+// VaultSecrets reads secrets from a Vault KV v2 secret.
+type VaultSecrets struct {
+	Addr  string
+	Mount string
+	Path  string
+}
+
+func (s *VaultSecrets) Load(ctx context.Context) (map[string]string, error) {
+	url := fmt.Sprintf("%s/v1/%s/data/%s", s.Addr, s.Mount, s.Path)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// read on every load, so that the token can be rotated as well
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+
+	var result struct {
+		Data struct {
+			Data map[string]string `json:"data"`
+		} `json:"data"`
+	}
+	if err := doSecretsRequest(req, &result); err != nil {
+		return nil, fmt.Errorf("reading Vault secret %s/%s: %v", s.Mount, s.Path, err)
+	}
+	return result.Data.Data, nil
+}
+
+// AWSSecrets reads secrets from an AWS Secrets Manager secret whose value is a JSON object.
+type AWSSecrets struct {
+	SecretID    string
+	Region      string
+	Credentials AWSCredentials
+}
+
+func (s *AWSSecrets) Load(ctx context.Context) (map[string]string, error) {
+	body, err := json.Marshal(map[string]string{"SecretId": s.SecretID})
+	if err != nil {
+		return nil, err
+	}
+
+	url := "https://secretsmanager." + s.Region + ".amazonaws.com/"
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signV4(req, body, s.Region, "secretsmanager", s.Credentials, time.Now())
+
+	var result struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doSecretsRequest(req, &result); err != nil {
+		return nil, fmt.Errorf("reading AWS secret %s: %v", s.SecretID, err)
+	}
+
+	var values map[string]string
+	if err := json.Unmarshal([]byte(result.SecretString), &values); err != nil {
+		return nil, fmt.Errorf("decoding AWS secret %s: %v", s.SecretID, err)
+	}
+	return values, nil
+}
+
+func doSecretsRequest(req *http.Request, result interface{}) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// runSecrets implements the secrets command, which encrypts a JSON secrets file with SECRETS_FILE_KEY:
+//
+//	deployment_tracking secrets encrypt --in secrets.json --out secrets.enc
+func runSecrets(args []string) error {
+	if len(args) == 0 || args[0] != "encrypt" {
+		return fmt.Errorf("expected a subcommand: encrypt")
+	}
+
+	flags := flag.NewFlagSet("secrets encrypt", flag.ExitOnError)
+	in := flags.String("in", "", "JSON file of secrets to encrypt")
+	out := flags.String("out", "", "Path of the encrypted file")
+	flags.Parse(args[1:])
+
+	if *in == "" || *out == "" {
+		return fmt.Errorf("--in and --out are required")
+	}
+	key := os.Getenv("SECRETS_FILE_KEY")
+	if key == "" {
+		return fmt.Errorf("SECRETS_FILE_KEY must be set")
+	}
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	var values map[string]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("%s must be a JSON object of strings: %v", *in, err)
+	}
+
+	encrypted, err := encryptSecrets(key, data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*out, encrypted, 0o600); err != nil {
+		return err
+	}
+
+	log.Printf("Encrypted %d secrets to %s", len(values), *out)
+	return nil
+}
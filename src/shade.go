@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// ShadeVenuePositionConfig is a liquidity position in a ShadeSwap pair on Secret Network.
+type ShadeVenuePositionConfig struct {
+	VenueMetadata
+
+	PairAddress  string // Contract address of the pair
+	Address      string
+	ActiveShares float64 // LP token amount, this is a way to track the funds deployed per bid
+	// ViewingKeySecret names the secret holding the viewing key of Address, to query its LP token
+	// balance instead of relying on ActiveShares. LP token balances are private on Secret Network.
+	ViewingKeySecret string
+}
+
+func (venueConfig ShadeVenuePositionConfig) GetProtocol() Protocol {
+	return Shade
+}
+
+func (venueConfig ShadeVenuePositionConfig) GetPoolID() string {
+	return venueConfig.PairAddress
+}
+
+func (venueConfig ShadeVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type ShadePosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig ShadeVenuePositionConfig
+}
+
+func NewShadePosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*ShadePosition, error) {
+	shadeVenuePositionConfig, ok := venuePositionConfig.(ShadeVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of ShadeVenuePositionConfig type")
+	}
+
+	return &ShadePosition{protocolConfig: config, venuePositionConfig: shadeVenuePositionConfig}, nil
+}
+
+// The rewards of the LP staking contract aren't tracked yet.
+func (p ShadePosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true}
+}
+
+func (p ShadePosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	pair, err := p.getPairInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return p.pairHoldings(ctx, assetData, pair, 1)
+}
+
+func (p ShadePosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	shares, err := p.getShares(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if shares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	pair, err := p.getPairInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totalLiquidity, err := strconv.ParseFloat(pair.TotalLiquidity, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse total_liquidity into float64: %s", err)
+	}
+	if totalLiquidity == 0 {
+		return nil, fmt.Errorf("pair %s has no liquidity", p.venuePositionConfig.PairAddress)
+	}
+
+	return p.pairHoldings(ctx, assetData, pair, shares/totalLiquidity)
+}
+
+func (p ShadePosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	return nil, fmt.Errorf("rewards are not supported for Shade")
+}
+
+type shadeTokenType struct {
+	CustomToken *struct {
+		ContractAddr string `json:"contract_addr"`
+	} `json:"custom_token"`
+	NativeToken *struct {
+		Denom string `json:"denom"`
+	} `json:"native_token"`
+}
+
+type shadePairInfo struct {
+	LiquidityToken struct {
+		Address string `json:"address"`
+	} `json:"liquidity_token"`
+	Pair struct {
+		Token0 shadeTokenType `json:"token_0"`
+		Token1 shadeTokenType `json:"token_1"`
+	} `json:"pair"`
+	Amount0        string `json:"amount_0"`
+	Amount1        string `json:"amount_1"`
+	TotalLiquidity string `json:"total_liquidity"`
+}
+
+func (p ShadePosition) getPairInfo(ctx context.Context) (*shadePairInfo, error) {
+	var response struct {
+		GetPairInfo *shadePairInfo `json:"get_pair_info"`
+	}
+	query := map[string]interface{}{"get_pair_info": map[string]interface{}{}}
+	if err := querySecretContract(ctx, p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.PairAddress, query, &response); err != nil {
+		return nil, fmt.Errorf("querying pair info: %s", err)
+	}
+	if response.GetPairInfo == nil {
+		return nil, fmt.Errorf("invalid pair info structure")
+	}
+	return response.GetPairInfo, nil
+}
+
+// getShares returns the LP token amount of the address: its balance if the venue has a viewing key,
+// otherwise the configured ActiveShares.
+func (p ShadePosition) getShares(ctx context.Context, address string) (float64, error) {
+	if p.venuePositionConfig.ViewingKeySecret == "" {
+		return p.venuePositionConfig.ActiveShares, nil
+	}
+
+	viewingKey := getSecret(p.venuePositionConfig.ViewingKeySecret)
+	if viewingKey == "" {
+		return 0, fmt.Errorf("viewing key %s is not set", p.venuePositionConfig.ViewingKeySecret)
+	}
+
+	pair, err := p.getPairInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var response struct {
+		Balance *struct {
+			Amount string `json:"amount"`
+		} `json:"balance"`
+		ViewingKeyError *struct {
+			Msg string `json:"msg"`
+		} `json:"viewing_key_error"`
+	}
+	query := map[string]interface{}{
+		"balance": map[string]interface{}{"address": address, "key": viewingKey},
+	}
+	if err := querySecretContract(ctx, p.protocolConfig.AddressBalanceUrl, pair.LiquidityToken.Address, query, &response); err != nil {
+		return 0, fmt.Errorf("querying LP token balance: %s", err)
+	}
+	if response.ViewingKeyError != nil {
+		return 0, fmt.Errorf("viewing key %s rejected: %s", p.venuePositionConfig.ViewingKeySecret, response.ViewingKeyError.Msg)
+	}
+	if response.Balance == nil {
+		return 0, fmt.Errorf("invalid balance structure")
+	}
+
+	shares, err := strconv.ParseFloat(response.Balance.Amount, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse balance into float64: %s", err)
+	}
+	return shares, nil
+}
+
+// pairHoldings returns the given share of the assets of the pair.
+func (p ShadePosition) pairHoldings(ctx context.Context, assetData *ChainInfo, pair *shadePairInfo, share float64) (*Holdings, error) {
+	holdings := &Holdings{Balances: []Asset{}}
+
+	for _, side := range []struct {
+		token  shadeTokenType
+		amount string
+	}{{pair.Pair.Token0, pair.Amount0}, {pair.Pair.Token1, pair.Amount1}} {
+		tokenInfo, err := shadeTokenInfo(assetData, side.token)
+		if err != nil {
+			return nil, err
+		}
+
+		amount, err := strconv.ParseFloat(side.amount, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse amount of %s into float64: %s", tokenInfo.Denom, err)
+		}
+		adjustedAmount := amount * share / math.Pow(10, float64(tokenInfo.Decimals))
+
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute token values: %s", err)
+		}
+
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       tokenInfo.Denom,
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+	}
+
+	return holdings, nil
+}
+
+// shadeTokenInfo looks up a token of a pair in the asset list, where SNIP-20 tokens are listed
+// as cw20:<contract address>.
+func shadeTokenInfo(assetData *ChainInfo, token shadeTokenType) (*ChainTokenInfo, error) {
+	switch {
+	case token.NativeToken != nil:
+		return assetData.GetTokenInfo(token.NativeToken.Denom)
+	case token.CustomToken != nil:
+		if tokenInfo, err := assetData.GetTokenInfo("cw20:" + token.CustomToken.ContractAddr); err == nil {
+			return tokenInfo, nil
+		}
+		return assetData.GetTokenInfo(token.CustomToken.ContractAddr)
+	}
+	return nil, fmt.Errorf("invalid token structure")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const snapshotFileDateFormat = "2006-01-02"
+
+// BidSnapshot records the holdings of a bid at the time they were computed.
+type BidSnapshot struct {
+	Timestamp time.Time       `json:"timestamp"`
+	BidId     int             `json:"bid_id"`
+	Holdings  []VenueHoldings `json:"holdings"`
+	Prices    *SnapshotPrices `json:"prices,omitempty"` // nil for snapshots taken before prices were recorded
+}
+
+// SnapshotStore persists bid snapshots as JSON lines, one file per day.
+type SnapshotStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// snapshotStore is nil if snapshots are disabled.
+var snapshotStore *SnapshotStore
+
+func NewSnapshotStore(dir string) (*SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating snapshot directory: %v", err)
+	}
+
+	return &SnapshotStore{dir: dir}, nil
+}
+
+func (s *SnapshotStore) fileForDay(day time.Time) string {
+	return filepath.Join(s.dir, day.UTC().Format(snapshotFileDateFormat)+".jsonl")
+}
+
+// Append stores a snapshot, sealed with its checksum and signature.
+func (s *SnapshotStore) Append(snapshot BidSnapshot) error {
+	line, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %v", err)
+	}
+	line = sealJSON(line)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.fileForDay(snapshot.Timestamp), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening snapshot file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("writing snapshot: %v", err)
+	}
+
+	return nil
+}
+
+// days lists the days for which snapshot files exist, in chronological order.
+func (s *SnapshotStore) days() ([]time.Time, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshot files: %v", err)
+	}
+
+	var days []time.Time
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".jsonl") {
+			continue
+		}
+
+		day, err := time.Parse(snapshotFileDateFormat, strings.TrimSuffix(name, ".jsonl"))
+		if err != nil {
+			continue
+		}
+		days = append(days, day)
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+// Range calls fn for every snapshot taken in [from, to], in chronological order.
+// A zero from or to leaves that side of the window open.
+func (s *SnapshotStore) Range(from time.Time, to time.Time, fn func(BidSnapshot) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days, err := s.days()
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		if !from.IsZero() && day.Add(24*time.Hour).Before(from) {
+			continue
+		}
+		if !to.IsZero() && day.After(to) {
+			break
+		}
+
+		if err := s.rangeFile(s.fileForDay(day), from, to, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SnapshotStore) rangeFile(path string, from time.Time, to time.Time, fn func(BidSnapshot) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening snapshot file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var snapshot BidSnapshot
+		if err := json.Unmarshal(scanner.Bytes(), &snapshot); err != nil {
+			return fmt.Errorf("decoding snapshot in %s: %v", path, err)
+		}
+
+		if !from.IsZero() && snapshot.Timestamp.Before(from) {
+			continue
+		}
+		if !to.IsZero() && snapshot.Timestamp.After(to) {
+			continue
+		}
+
+		if err := fn(snapshot); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// recordSnapshot stores freshly computed holdings of a bid, if snapshots are enabled,
+// and streams them to the analytics sink, if configured.
+func recordSnapshot(bidId int, holdings []VenueHoldings) {
+	snapshot := BidSnapshot{
+		Timestamp: time.Now().UTC(),
+		BidId:     bidId,
+		Holdings:  holdings,
+		Prices:    snapshotPrices(holdings, currentPrices.Load()),
+	}
+
+	// with several replicas, only the leader records snapshots, so that they aren't duplicated
+	if !isLeader() {
+		return
+	}
+
+	queueAnalytics(snapshot)
+
+	if snapshotStore == nil {
+		return
+	}
+
+	if err := snapshotStore.Append(snapshot); err != nil {
+		log.Printf("Failed to record snapshot of bid %d: %v", bidId, err)
+	}
+}
+
+// parseTimeWindow reads the optional RFC 3339 from and to query parameters.
+func parseTimeWindow(r *http.Request) (time.Time, time.Time, error) {
+	var from, to time.Time
+	var err error
+
+	if fromStr := r.URL.Query().Get("from"); fromStr != "" {
+		if from, err = time.Parse(time.RFC3339, fromStr); err != nil {
+			return from, to, fmt.Errorf("invalid from: %v", err)
+		}
+	}
+
+	if toStr := r.URL.Query().Get("to"); toStr != "" {
+		if to, err = time.Parse(time.RFC3339, toStr); err != nil {
+			return from, to, fmt.Errorf("invalid to: %v", err)
+		}
+	}
+
+	return from, to, nil
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// HUB_ATOM is the denom of ATOM on the Cosmos Hub.
+const HUB_ATOM = "uatom"
+
+// StakingVenuePositionConfig is ATOM parked as native delegations of an address on the Cosmos Hub,
+// to one validator or to all of the validators it delegates to.
+type StakingVenuePositionConfig struct {
+	VenueMetadata
+
+	Address string
+	// ValidatorAddress restricts the venue to the delegation to one validator, e.g. cosmosvaloper1...
+	// If empty, all delegations of the address count.
+	ValidatorAddress string
+}
+
+func (venueConfig StakingVenuePositionConfig) GetProtocol() Protocol {
+	return Staking
+}
+
+func (venueConfig StakingVenuePositionConfig) GetPoolID() string {
+	return venueConfig.ValidatorAddress
+}
+
+func (venueConfig StakingVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type StakingPosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig StakingVenuePositionConfig
+}
+
+func NewStakingPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*StakingPosition, error) {
+	stakingVenuePositionConfig, ok := venuePositionConfig.(StakingVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of StakingVenuePositionConfig type")
+	}
+
+	return &StakingPosition{protocolConfig: config, venuePositionConfig: stakingVenuePositionConfig}, nil
+}
+
+// The TVL is only known for a single validator, as the tokens delegated to it.
+func (p StakingPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: p.venuePositionConfig.ValidatorAddress != "", SupportsPrincipal: true, SupportsRewards: true}
+}
+
+// ComputeTVL returns the tokens delegated to the validator.
+func (p StakingPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	if p.venuePositionConfig.ValidatorAddress == "" {
+		return nil, fmt.Errorf("the TVL is only supported for a single validator")
+	}
+
+	var response struct {
+		Validator *struct {
+			Tokens string `json:"tokens"`
+		} `json:"validator"`
+	}
+	validatorUrl := fmt.Sprintf("%s/cosmos/staking/v1beta1/validators/%s", p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.ValidatorAddress)
+	if err := getJSON(ctx, validatorUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying validator: %s", err)
+	}
+	if response.Validator == nil {
+		return nil, fmt.Errorf("invalid validator structure")
+	}
+	tokens, err := parseAmount(response.Validator.Tokens)
+	if err != nil {
+		return nil, fmt.Errorf("invalid validator tokens: %w", err)
+	}
+
+	return stakingHoldings(ctx, assetData, map[string]*big.Int{HUB_ATOM: tokens})
+}
+
+// ComputeAddressPrincipalHoldings returns the delegations of the address, to the validator if one
+// is configured. Unbonding delegations aren't included.
+func (p StakingPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	type delegationResponse struct {
+		Delegation struct {
+			ValidatorAddress string `json:"validator_address"`
+		} `json:"delegation"`
+		Balance struct {
+			Denom  string `json:"denom"`
+			Amount string `json:"amount"`
+		} `json:"balance"`
+	}
+	var response struct {
+		DelegationResponses []delegationResponse `json:"delegation_responses"`
+	}
+	delegationsUrl := fmt.Sprintf("%s/cosmos/staking/v1beta1/delegations/%s?pagination.limit=1000", p.protocolConfig.PoolInfoUrl, address)
+	if err := getJSON(ctx, delegationsUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying delegations: %s", err)
+	}
+
+	amounts := make(map[string]*big.Int)
+	for _, delegation := range response.DelegationResponses {
+		if p.venuePositionConfig.ValidatorAddress != "" && delegation.Delegation.ValidatorAddress != p.venuePositionConfig.ValidatorAddress {
+			continue
+		}
+		amount, err := parseAmount(delegation.Balance.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid delegation to %s: %w", delegation.Delegation.ValidatorAddress, err)
+		}
+		addAmount(amounts, delegation.Balance.Denom, amount)
+	}
+
+	return stakingHoldings(ctx, assetData, amounts)
+}
+
+// ComputeAddressRewardHoldings returns the pending staking rewards of the address, from the
+// validator if one is configured.
+func (p StakingPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	type coin struct {
+		Denom  string `json:"denom"`
+		Amount string `json:"amount"`
+	}
+	var response struct {
+		Rewards []struct {
+			ValidatorAddress string `json:"validator_address"`
+			Reward           []coin `json:"reward"`
+		} `json:"rewards"`
+	}
+	rewardsUrl := fmt.Sprintf("%s/cosmos/distribution/v1beta1/delegators/%s/rewards", p.protocolConfig.PoolInfoUrl, address)
+	if err := getJSON(ctx, rewardsUrl, &response); err != nil {
+		return nil, fmt.Errorf("querying staking rewards: %s", err)
+	}
+
+	amounts := make(map[string]*big.Int)
+	for _, rewards := range response.Rewards {
+		if p.venuePositionConfig.ValidatorAddress != "" && rewards.ValidatorAddress != p.venuePositionConfig.ValidatorAddress {
+			continue
+		}
+		for _, reward := range rewards.Reward {
+			// rewards are decimals of the base unit, e.g. "1234.567890000000000000", of which a
+			// withdrawal pays out the integer part
+			whole, _, _ := strings.Cut(reward.Amount, ".")
+			amount, err := parseAmount(whole)
+			if err != nil {
+				return nil, fmt.Errorf("invalid reward of %s: %w", reward.Denom, err)
+			}
+			addAmount(amounts, reward.Denom, amount)
+		}
+	}
+
+	return stakingHoldings(ctx, assetData, amounts)
+}
+
+// stakingHoldings values amounts by denom, in their base unit. Denoms without token info or price
+// are left out.
+func stakingHoldings(ctx context.Context, assetData *ChainInfo, amounts map[string]*big.Int) (*Holdings, error) {
+	denoms := make([]string, 0, len(amounts))
+	for denom := range amounts {
+		denoms = append(denoms, denom)
+	}
+	sort.Strings(denoms)
+
+	holdings := &Holdings{Balances: []Asset{}}
+	for _, denom := range denoms {
+		if amounts[denom].Sign() == 0 {
+			continue
+		}
+		tokenInfo, err := assetData.GetTokenInfo(denom)
+		if err != nil {
+			debugLog("Token info not found", map[string]string{"denom": denom})
+			continue
+		}
+
+		adjustedAmount := tokenAmount(amounts[denom], tokenInfo.Decimals)
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			debugLog("Error getting token values", map[string]string{"denom": denom})
+			continue
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       denom,
+			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(amounts[denom], tokenInfo.Decimals),
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+
+	return holdings, nil
+}
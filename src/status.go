@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// StalledRefreshIntervals is the number of refresh intervals without a successful
+// full refresh after which the refresher is reported as stalled.
+const StalledRefreshIntervals = 3
+
+var serverStartTime = time.Now()
+
+var venuesWithErrorsMetric = newGauge("venues_with_errors",
+	"Number of venues whose last computation failed.")
+
+// StatusResponse summarizes the health of the service for a public status page.
+type StatusResponse struct {
+	// Degraded is set if any of the data may be stale or incomplete.
+	Degraded bool `json:"degraded"`
+
+	LastSuccessfulFullRefreshTimestamp int64   `json:"last_successful_full_refresh_timestamp"`
+	LastRefreshStartedTimestamp        int64   `json:"last_refresh_started_timestamp"`
+	LastRefreshDurationSeconds         float64 `json:"last_refresh_duration_seconds"`
+	LastRefreshFailedBids              int     `json:"last_refresh_failed_bids"`
+	RefreshIntervalSeconds             float64 `json:"refresh_interval_seconds"`
+	RefreshStalled                     bool    `json:"refresh_stalled"`
+	LastRefreshHeartbeatTimestamp      int64   `json:"last_refresh_heartbeat_timestamp"`
+
+	TotalBids                    int     `json:"total_bids"`
+	CachedBids                   int     `json:"cached_bids"`
+	OldestCachedResultAgeSeconds float64 `json:"oldest_cached_result_age_seconds"`
+
+	VenuesWithErrors int      `json:"venues_with_errors"`
+	FailingVenues    []string `json:"failing_venues"`
+	// DuplicatePositions are groups of venues of different bids tracking the same position, whose
+	// holdings are counted twice.
+	DuplicatePositions [][]string `json:"duplicate_positions"`
+
+	UnhealthyUpstreams int              `json:"unhealthy_upstreams"`
+	Upstreams          []UpstreamHealth `json:"upstreams"`
+	// DeadUpstreams failed every request for a while and come with suggested replacements.
+	DeadUpstreams []DeadUpstream `json:"dead_upstreams"`
+
+	// InstanceID is only set if several replicas share a cache, of which the leader runs the refresher.
+	InstanceID      string `json:"instance_id,omitempty"`
+	RefresherLeader bool   `json:"refresher_leader"`
+}
+
+var (
+	venueStateMu  sync.Mutex
+	venueErrors   = make(map[string]string)
+	bidComputedAt = make(map[int]time.Time)
+)
+
+// recordVenueResult tracks whether the last computation of a venue failed.
+func recordVenueResult(id string, err error) {
+	venueStateMu.Lock()
+	defer venueStateMu.Unlock()
+
+	if err != nil {
+		venueErrors[id] = err.Error()
+	} else {
+		delete(venueErrors, id)
+	}
+	venuesWithErrorsMetric.Set(float64(len(venueErrors)))
+}
+
+// recordBidComputed tracks when the cached result of a bid was computed.
+func recordBidComputed(bidId int, t time.Time) {
+	venueStateMu.Lock()
+	defer venueStateMu.Unlock()
+
+	bidComputedAt[bidId] = t
+}
+
+// unixOrZero avoids reporting the zero time as a large negative timestamp.
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	state := getRefreshState()
+
+	status := StatusResponse{
+		LastSuccessfulFullRefreshTimestamp: unixOrZero(state.LastSuccessfulFullRefresh),
+		LastRefreshStartedTimestamp:        unixOrZero(state.LastStarted),
+		LastRefreshDurationSeconds:         state.LastDuration.Seconds(),
+		LastRefreshFailedBids:              state.LastFailedBids,
+		RefreshIntervalSeconds:             state.Interval.Seconds(),
+		LastRefreshHeartbeatTimestamp:      unixOrZero(state.LastHeartbeat),
+		TotalBids:                          len(activeBids()),
+		FailingVenues:                      []string{},
+		DuplicatePositions:                 duplicatePositions(activeBids()),
+		Upstreams:                          getUpstreamHealth(),
+		DeadUpstreams:                      getDeadUpstreams(),
+		InstanceID:                         instanceID,
+		RefresherLeader:                    isLeader(),
+	}
+
+	// The refresher is stalled if it is enabled but hasn't completed a full refresh in a while.
+	// Replicas that don't hold the refresher lease can't tell.
+	if state.Interval > 0 && status.RefresherLeader {
+		lastProgress := state.LastSuccessfulFullRefresh
+		if lastProgress.IsZero() {
+			lastProgress = serverStartTime
+		}
+		status.RefreshStalled = time.Since(lastProgress) > StalledRefreshIntervals*state.Interval
+	}
+
+	venueStateMu.Lock()
+	for id := range venueErrors {
+		status.FailingVenues = append(status.FailingVenues, id)
+	}
+	for bidId := range activeBids() {
+		if _, found := resultCache.Get(strconv.Itoa(bidId)); !found {
+			continue
+		}
+		status.CachedBids++
+		if computedAt, ok := bidComputedAt[bidId]; ok {
+			if age := time.Since(computedAt).Seconds(); age > status.OldestCachedResultAgeSeconds {
+				status.OldestCachedResultAgeSeconds = age
+			}
+		}
+	}
+	venueStateMu.Unlock()
+
+	sort.Strings(status.FailingVenues)
+	status.VenuesWithErrors = len(status.FailingVenues)
+
+	for _, upstream := range status.Upstreams {
+		if !upstream.Healthy {
+			status.UnhealthyUpstreams++
+		}
+	}
+
+	status.Degraded = status.RefreshStalled || status.VenuesWithErrors > 0 || status.UnhealthyUpstreams > 0
+
+	jsonData, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// the status page may be hosted elsewhere
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// testnetProtocolConfigMap holds the configs of the protocols that can be rehearsed on a testnet,
+// used for venues flagged with VenueMetadata.Testnet. Timeouts and latency budgets are shared
+// with the mainnet config.
+var testnetProtocolConfigMap = map[Protocol]ProtocolConfig{
+	// osmo-test-5
+	Osmosis: {
+		Protocol:          Osmosis,
+		PoolInfoUrl:       "https://sqs.testnet.osmosis.zone",
+		AssetListURL:      "https://chains.testcosmos.directory/osmosistestnet",
+		AddressBalanceUrl: "https://lcd.testnet.osmosis.zone/",
+	},
+	// pion-1
+	Mars: {
+		Protocol:          Mars,
+		PoolInfoUrl:       "https://rest-falcron.pion-1.ntrn.tech/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.testcosmos.directory/neutrontestnet",
+		AddressBalanceUrl: "",
+	},
+	AstroportNeutron: {
+		Protocol:          AstroportNeutron,
+		PoolInfoUrl:       "https://rest-falcron.pion-1.ntrn.tech/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.testcosmos.directory/neutrontestnet",
+		AddressBalanceUrl: "https://rest-falcron.pion-1.ntrn.tech/cosmos/bank/v1beta1/balances",
+	},
+	Duality: {
+		Protocol:          Duality,
+		PoolInfoUrl:       "https://rest-falcron.pion-1.ntrn.tech/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.testcosmos.directory/neutrontestnet",
+		AddressBalanceUrl: "https://rest-falcron.pion-1.ntrn.tech/cosmos/bank/v1beta1/balances",
+	},
+}
+
+// venueProtocolConfig returns the config of the protocol of a venue, on the network of the venue.
+func venueProtocolConfig(venueConfig VenuePositionConfig) (ProtocolConfig, error) {
+	protocolConfig := protocolConfigMap[venueConfig.GetProtocol()]
+	if !venueConfig.GetMetadata().Testnet {
+		return protocolConfig, nil
+	}
+
+	testnetConfig, ok := testnetProtocolConfigMap[venueConfig.GetProtocol()]
+	if !ok {
+		return ProtocolConfig{}, fmt.Errorf("no testnet config for protocol %s", venueConfig.GetProtocol())
+	}
+	testnetConfig.Timeout = protocolConfig.Timeout
+	testnetConfig.LatencyBudget = protocolConfig.LatencyBudget
+	return testnetConfig, nil
+}
+
+// productionVenues returns the venues of a bid that count towards its holdings, leaving out the
+// testnet venues.
+func productionVenues(bidConfig BidPositionConfig) []VenuePositionConfig {
+	venues := make([]VenuePositionConfig, 0, len(bidConfig.Venues))
+	for _, venueConfig := range bidConfig.Venues {
+		if !venueConfig.GetMetadata().Testnet {
+			venues = append(venues, venueConfig)
+		}
+	}
+	return venues
+}
+
+// testnetVenues returns the testnet venues of a bid.
+func testnetVenues(bidConfig BidPositionConfig) []VenuePositionConfig {
+	var venues []VenuePositionConfig
+	for _, venueConfig := range bidConfig.Venues {
+		if venueConfig.GetMetadata().Testnet {
+			venues = append(venues, venueConfig)
+		}
+	}
+	return venues
+}
+
+// validateTestnetVenue checks that a testnet venue is on a protocol with a testnet config.
+func validateTestnetVenue(bidId int, venueConfig VenuePositionConfig) []error {
+	if !venueConfig.GetMetadata().Testnet {
+		return nil
+	}
+	if _, ok := testnetProtocolConfigMap[venueConfig.GetProtocol()]; !ok {
+		return []error{fmt.Errorf("venue %s is on a testnet, but %s has no testnet config", venueID(bidId, venueConfig), venueConfig.GetProtocol())}
+	}
+	return nil
+}
+
+// TestnetBidHoldings are the testnet venues of a bid.
+type TestnetBidHoldings struct {
+	BidId    int             `json:"bid_id"`
+	Holdings []VenueHoldings `json:"holdings"`
+}
+
+// computeTestnetHoldings computes the testnet venues of a bid, reusing unexpired results. Unlike
+// the production venues, they are only computed on request, and their failures neither count
+// towards the status nor fail the bid: failing venues are returned without holdings.
+func computeTestnetHoldings(ctx context.Context, bidId int) ([]VenueHoldings, error) {
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return nil, fmt.Errorf("bid not found: %d", bidId)
+	}
+
+	venues := testnetVenues(bidConfig)
+	holdings := make([]VenueHoldings, 0, len(venues))
+	now := time.Now()
+	for _, venueConfig := range venues {
+		id := venueID(bidId, venueConfig)
+		if cached, ok := getCachedVenue(id); ok && (isCachedOnly(ctx) || (!cached.Stale && now.Before(cached.expiresAt()))) {
+			venueHoldings := cached.Holdings
+			venueHoldings.Stale = cached.Stale || !now.Before(cached.expiresAt())
+			holdings = append(holdings, venueHoldings)
+			continue
+		}
+		if isCachedOnly(ctx) {
+			return nil, errNotCached
+		}
+
+		venueHoldings, err := computeVenueHoldingsWithBudget(ctx, bidId, venueConfig)
+		if err != nil {
+			debugLog(fmt.Sprintf("failed to compute testnet venue %s", id), err)
+			venueHoldings = &VenueHoldings{VenueID: id, Protocol: venueConfig.GetProtocol(), Testnet: true}
+		}
+		holdings = append(holdings, *venueHoldings)
+	}
+
+	return revalueAtom(holdings, currentPrices.Load()), nil
+}
+
+// testnetHoldingsHandler serves the testnet venues of a bid, or of all bids that have some.
+func testnetHoldingsHandler(w http.ResponseWriter, r *http.Request) {
+	var bidIds []int
+	if bidIdStr := mux.Vars(r)["bid_id"]; bidIdStr != "" {
+		bidId, err := strconv.Atoi(bidIdStr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := activeBids()[bidId]; !ok {
+			http.Error(w, fmt.Sprintf("bid not found: %d", bidId), http.StatusNotFound)
+			return
+		}
+		bidIds = []int{bidId}
+	} else {
+		for _, bidId := range sortedBidIds() {
+			if len(testnetVenues(activeBids()[bidId])) > 0 {
+				bidIds = append(bidIds, bidId)
+			}
+		}
+	}
+
+	result := make([]TestnetBidHoldings, 0, len(bidIds))
+	for _, bidId := range bidIds {
+		holdings, err := computeTestnetHoldings(r.Context(), bidId)
+		if err != nil {
+			writeComputeError(w, err)
+			return
+		}
+		result = append(result, TestnetBidHoldings{BidId: bidId, Holdings: holdings})
+	}
+
+	jsonData, err := marshalResponse(r, result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// VenueTrace records how a venue was computed, for ?trace=true requests.
+type VenueTrace struct {
+	mu sync.Mutex
+
+	DurationMs float64            `json:"duration_ms"`
+	Error      string             `json:"error,omitempty"`
+	Calls      []TraceCall        `json:"calls"`
+	Prices     map[string]float64 `json:"prices"` // USD prices used, by CoinGecko ID
+}
+
+// TraceCall is an upstream request made during a traced computation,
+// or a smart contract query served from memory.
+type TraceCall struct {
+	URL        string  `json:"url"`
+	Status     int     `json:"status,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+	CacheHit   bool    `json:"cache_hit,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type traceKey struct{}
+
+func newVenueTrace() *VenueTrace {
+	return &VenueTrace{Calls: []TraceCall{}, Prices: make(map[string]float64)}
+}
+
+// withTrace returns a context whose upstream requests and prices are recorded in trace.
+func withTrace(ctx context.Context, trace *VenueTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// traceFromContext returns the trace of the computation, or nil if it isn't traced.
+func traceFromContext(ctx context.Context) *VenueTrace {
+	trace, _ := ctx.Value(traceKey{}).(*VenueTrace)
+	return trace
+}
+
+func (t *VenueTrace) addCall(call TraceCall) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Calls = append(t.Calls, call)
+}
+
+func (t *VenueTrace) addPrice(coingeckoId string, price float64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Prices[coingeckoId] = price
+}
+
+// traceHoldings computes every venue of a bid from scratch with a trace attached.
+// Unlike computeHoldings, it bypasses the venue and bid caches and keeps going if a venue fails,
+// so that the trace of the failing venue can be inspected.
+func traceHoldings(ctx context.Context, bidId int) ([]VenueHoldings, error) {
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return nil, fmt.Errorf("bid not found: %d", bidId)
+	}
+
+	result := make([]VenueHoldings, 0, len(bidConfig.Venues))
+	for _, venueConfig := range productionVenues(bidConfig) {
+		trace := newVenueTrace()
+		venueCtx, cancel := context.WithTimeout(withTrace(withPriceSnapshot(ctx, priceSnapshotFor(ctx)), trace), protocolConfigMap[venueConfig.GetProtocol()].timeout())
+
+		start := time.Now()
+		holdings, err := computeVenueHoldings(venueCtx, bidId, venueConfig)
+		cancel()
+		trace.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+
+		if err != nil {
+			trace.Error = err.Error()
+			holdings = &VenueHoldings{
+				VenueID:  venueID(bidId, venueConfig),
+				Protocol: venueConfig.GetProtocol(),
+			}
+		}
+		holdings.Trace = trace
+		result = append(result, *holdings)
+	}
+
+	return result, nil
+}
+
+// traceRequested reports whether a request asks for a trace.
+func traceRequested(r *http.Request) bool {
+	return r.URL.Query().Get("trace") == "true"
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Transfer records funds moved directly from one bid to another mid-round, without a withdrawal.
+// Transfers are configured on the bid the funds leave. The venues are optional.
+type Transfer struct {
+	Date        time.Time `json:"date"`
+	FromBidId   int       `json:"from_bid_id"`
+	ToBidId     int       `json:"to_bid_id"`
+	FromVenueID string    `json:"from_venue_id,omitempty"`
+	ToVenueID   string    `json:"to_venue_id,omitempty"`
+	Amount      float64   `json:"amount"` // in ATOM
+}
+
+// transferredOut sums the transfers out of a bid until the given time.
+func transferredOut(bidConfig BidPositionConfig, until time.Time) float64 {
+	amount := 0.0
+	for _, transfer := range bidConfig.Transfers {
+		if !transfer.Date.After(until) {
+			amount += transfer.Amount
+		}
+	}
+	return amount
+}
+
+// transferredIn sums the transfers of other bids into the bid until the given time.
+func transferredIn(bidId int, until time.Time) float64 {
+	amount := 0.0
+	for _, transfer := range bidTransfers(bidId) {
+		if transfer.ToBidId == bidId && !transfer.Date.After(until) {
+			amount += transfer.Amount
+		}
+	}
+	return amount
+}
+
+// bidTransfers returns the transfers into and out of a bid, sorted by date.
+func bidTransfers(bidId int) []Transfer {
+	transfers := []Transfer{}
+	for _, otherBidId := range sortedBidIds() {
+		for _, transfer := range activeBids()[otherBidId].Transfers {
+			if transfer.FromBidId == bidId || transfer.ToBidId == bidId {
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool { return transfers[i].Date.Before(transfers[j].Date) })
+	return transfers
+}
+
+// validateTransfers checks that the transfers of a bid leave it for another bid, between the venues of the two bids.
+func validateTransfers(bids map[int]BidPositionConfig, bidId int, bidConfig BidPositionConfig) []error {
+	var errs []error
+
+	for _, transfer := range bidConfig.Transfers {
+		date := transfer.Date.Format("2006-01-02")
+		if transfer.FromBidId != bidId {
+			errs = append(errs, fmt.Errorf("bid %d: transfer on %s is from bid %d, but transfers belong to the bid they leave", bidId, date, transfer.FromBidId))
+		}
+		if transfer.ToBidId == bidId {
+			errs = append(errs, fmt.Errorf("bid %d: transfer on %s goes to its own bid", bidId, date))
+		} else if _, ok := bids[transfer.ToBidId]; !ok {
+			errs = append(errs, fmt.Errorf("bid %d: transfer on %s goes to unknown bid %d", bidId, date, transfer.ToBidId))
+		}
+		if transfer.Amount <= 0 {
+			errs = append(errs, fmt.Errorf("bid %d: transfer on %s has no positive amount", bidId, date))
+		}
+		if transfer.FromVenueID != "" {
+			if venueBidId, _, ok := findVenueIn(bids, transfer.FromVenueID); !ok || venueBidId != bidId {
+				errs = append(errs, fmt.Errorf("bid %d: transfer on %s is from venue %s, which is not a venue of the bid", bidId, date, transfer.FromVenueID))
+			}
+		}
+		if transfer.ToVenueID != "" {
+			if venueBidId, _, ok := findVenueIn(bids, transfer.ToVenueID); !ok || venueBidId != transfer.ToBidId {
+				errs = append(errs, fmt.Errorf("bid %d: transfer on %s is to venue %s, which is not a venue of bid %d", bidId, date, transfer.ToVenueID, transfer.ToBidId))
+			}
+		}
+	}
+
+	return errs
+}
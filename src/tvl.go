@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// TVLPoint is the total value locked in the pool of a venue at a point in time.
+type TVLPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	TotalUSDC float64   `json:"total_usdc"`
+	TotalAtom float64   `json:"total_atom"`
+}
+
+// TVLHistory is the TVL of the pool of a venue over time, independent of our own position.
+type TVLHistory struct {
+	VenueID string     `json:"venue_id"`
+	Points  []TVLPoint `json:"points"`
+	// ChangePercent is the change of the USD TVL from the first to the last point.
+	ChangePercent *float64 `json:"change_percent"`
+	// DrawdownPercent is how far the last USD TVL is below the highest one, as a negative percentage.
+	DrawdownPercent *float64 `json:"drawdown_percent"`
+}
+
+// venueTVLHistory collects the TVL of a venue from the snapshots, keeping the last point of every
+// interval if an interval is given. Unlike the venue history, it doesn't follow migrations,
+// as these may move to a different pool.
+func venueTVLHistory(id string, from time.Time, to time.Time, interval time.Duration) (*TVLHistory, error) {
+	history := &TVLHistory{VenueID: id, Points: []TVLPoint{}}
+
+	err := snapshotStore.Range(from, to, func(snapshot BidSnapshot) error {
+		for _, venueHoldings := range snapshot.Holdings {
+			// stale venues repeat an earlier TVL
+			if venueHoldings.VenueID != id || venueHoldings.VenueTotal == nil || venueHoldings.Stale {
+				continue
+			}
+
+			point := TVLPoint{
+				Timestamp: snapshot.Timestamp,
+				TotalUSDC: venueHoldings.VenueTotal.TotalUSDC,
+				TotalAtom: venueHoldings.VenueTotal.TotalAtom,
+			}
+			last := len(history.Points) - 1
+			if interval > 0 && last >= 0 && history.Points[last].Timestamp.Truncate(interval).Equal(point.Timestamp.Truncate(interval)) {
+				history.Points[last] = point
+			} else {
+				history.Points = append(history.Points, point)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(history.Points) > 0 {
+		first, last := history.Points[0], history.Points[len(history.Points)-1]
+		peak := 0.0
+		for _, point := range history.Points {
+			if point.TotalUSDC > peak {
+				peak = point.TotalUSDC
+			}
+		}
+
+		if first.TotalUSDC > 0 {
+			change := (last.TotalUSDC - first.TotalUSDC) / first.TotalUSDC * 100
+			history.ChangePercent = &change
+		}
+		if peak > 0 {
+			drawdown := (last.TotalUSDC - peak) / peak * 100
+			history.DrawdownPercent = &drawdown
+		}
+	}
+
+	return history, nil
+}
+
+// venueTVLHistoryHandler serves the TVL history of the pool of a venue.
+// The time window can be restricted with the RFC 3339 from and to query parameters,
+// and the points thinned out with interval, e.g. interval=24h for daily points.
+func venueTVLHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["venue_id"]
+	if _, _, ok := findVenue(id); !ok {
+		http.Error(w, "venue not found: "+id, http.StatusNotFound)
+		return
+	}
+
+	if snapshotStore == nil {
+		http.Error(w, "snapshots are disabled", http.StatusNotFound)
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var interval time.Duration
+	if intervalStr := r.URL.Query().Get("interval"); intervalStr != "" {
+		if interval, err = time.ParseDuration(intervalStr); err != nil || interval < 0 {
+			http.Error(w, "invalid interval", http.StatusBadRequest)
+			return
+		}
+	}
+
+	history, err := venueTVLHistory(id, from, to, interval)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := marshalResponse(r, history)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"time"
 )
@@ -15,6 +16,7 @@ const (
 	AstroportNeutron Protocol = "Astroport (Neutron)"
 	AstroportTerra   Protocol = "Astroport (Terra)"
 	Margined         Protocol = "Margined"
+	MarginedNeutron  Protocol = "Margined (Neutron)"
 	Demex            Protocol = "Demex"
 	Neptune          Protocol = "Neptune"
 	Shade            Protocol = "Shade"
@@ -24,6 +26,17 @@ const (
 	Duality          Protocol = "Duality"
 	Ux               Protocol = "Ux"
 	Pryzm            Protocol = "Pryzm"
+	Levana           Protocol = "Levana"
+	LevanaNeutron    Protocol = "Levana (Neutron)"
+	Quasar           Protocol = "Quasar"
+	Mito             Protocol = "Mito"
+	Drop             Protocol = "Drop"
+	Magma            Protocol = "Magma"
+	CosmWasm         Protocol = "CosmWasm" // generic venues read with queries given in their config
+	CosmWasmNeutron  Protocol = "CosmWasm (Neutron)"
+	Staking          Protocol = "Staking" // native delegations on the Cosmos Hub
+	UniswapV3        Protocol = "Uniswap V3"
+	UniswapV3Base    Protocol = "Uniswap V3 (Base)"
 )
 
 // Core data structures
@@ -48,9 +61,16 @@ func (c *ChainInfo) GetTokenInfo(denom string) (*ChainTokenInfo, error) {
 
 // BidPositionConfig holds configuration for all venue positions of the given bid.
 type BidPositionConfig struct {
+	Name              string                `json:"name,omitempty"` // label for clients, e.g. "Mars ATOM lending, round 9"
+	Description       string                `json:"description,omitempty"`
+	Round             int                   `json:"round,omitempty"` // Hydro round the bid was placed in, 0 if unknown
 	InitialAllocation int                   `json:"initial_allocation"`
+	Allocations       []Allocation          `json:"allocations,omitempty"` // installments, instead of the InitialAllocation
 	Venues            []VenuePositionConfig `json:"venues"`
 	Withdrawals       []Withdrawal          `json:"withdrawals"`
+	RewardClaims      []RewardClaim         `json:"reward_claims,omitempty"`
+	Transfers         []Transfer            `json:"transfers,omitempty"` // Transfers out of the bid
+	Frozen            *FrozenBid            `json:"frozen,omitempty"`    // final summary of a fully withdrawn bid
 }
 
 // VenuePositionConfig holds the configuration for
@@ -63,6 +83,7 @@ type VenuePositionConfig interface {
 	GetPoolID() string
 	GetAddress() string
 	GetProtocol() Protocol
+	GetMetadata() VenueMetadata
 }
 
 // ProtocolConfig holds the configuration for a protocol, independent
@@ -72,115 +93,195 @@ type ProtocolConfig struct {
 	PoolInfoUrl       string
 	AddressBalanceUrl string
 	Protocol          Protocol
+	// Timeout bounds the computation of a venue, after which it is served from the last result.
+	// LatencyBudget is the expected computation time, above which a slow-upstream alert fires.
+	// Zero values use the defaults.
+	Timeout       time.Duration
+	LatencyBudget time.Duration
 }
 
 type Asset struct {
-	Denom       string  `json:"denom"`
-	Amount      float64 `json:"amount"`
+	Denom  string  `json:"denom"`
+	Amount float64 `json:"amount"`
+	// ExactAmount is the amount as an exact decimal, if it was read as is from the chain rather than
+	// computed, e.g. as a share of a pool
+	ExactAmount string  `json:"exact_amount,omitempty"`
 	CoingeckoID *string `json:"coingecko_id,omitempty"`
 	USDValue    float64 `json:"usd_value"`
+	AtomValue   float64 `json:"atom_value"`
 	DisplayName string  `json:"display_name,omitempty"`
 }
 
+// Holdings are the assets of a position. Leveraged positions also have liabilities, e.g. borrowed
+// tokens, whose amounts and values are negative. The totals are net of the liabilities.
 type Holdings struct {
-	Balances  []Asset `json:"balances"`
-	TotalUSDC float64 `json:"total_usdc"`
-	TotalAtom float64 `json:"total_atom"`
+	Balances    []Asset `json:"balances"`
+	Liabilities []Asset `json:"liabilities"`
+	TotalUSDC   float64 `json:"total_usdc"`
+	TotalAtom   float64 `json:"total_atom"`
+}
+
+// addLiability records a debt, given with positive amount and values, and deducts it from the totals.
+func (h *Holdings) addLiability(debt Asset) {
+	debt.Amount = -debt.Amount
+	if debt.ExactAmount != "" {
+		debt.ExactAmount = "-" + debt.ExactAmount
+	}
+	debt.USDValue = -debt.USDValue
+	debt.AtomValue = -debt.AtomValue
+	h.Liabilities = append(h.Liabilities, debt)
+	h.TotalUSDC += debt.USDValue
+	h.TotalAtom += debt.AtomValue
+}
+
+// allAssets returns the balances followed by the liabilities.
+func (h *Holdings) allAssets() []Asset {
+	return append(append([]Asset(nil), h.Balances...), h.Liabilities...)
+}
+
+// fillAtomValues values the assets in ATOM at the ATOM price implied by the totals, i.e. the
+// price used for TotalAtom, so that the asset values add up to the total.
+func (h *Holdings) fillAtomValues() {
+	if h == nil {
+		return
+	}
+
+	// the net total of a leveraged position may be negative
+	atomPerUSD := 0.0
+	if h.TotalUSDC != 0 {
+		atomPerUSD = h.TotalAtom / h.TotalUSDC
+	}
+	for i := range h.Balances {
+		h.Balances[i].AtomValue = h.Balances[i].USDValue * atomPerUSD
+	}
+	for i := range h.Liabilities {
+		h.Liabilities[i].AtomValue = h.Liabilities[i].USDValue * atomPerUSD
+	}
 }
 
 type VenueHoldings struct {
-	InfoMissing      bool      `json:"info_missing"`
-	Protocol         Protocol  `json:"protocol"`
-	VenueTotal       *Holdings `json:"venue_total"`
-	AddressPrincipal *Holdings `json:"address_holdings"`
-	AddressRewards   *Holdings `json:"address_rewards"`
+	VenueID          string           `json:"venue_id"`
+	Name             string           `json:"name,omitempty"`
+	Description      string           `json:"description,omitempty"`
+	DisplayName      string           `json:"display_name"` // the Name, or one generated from the pool, e.g. "Osmosis ATOM/USDC CL (pool 1283)"
+	Links            []VenueLink      `json:"links"`        // pages of the venue in explorers and apps
+	Supersedes       string           `json:"supersedes,omitempty"`
+	SupersededBy     string           `json:"superseded_by,omitempty"`
+	InfoMissing      bool             `json:"info_missing"`
+	Protocol         Protocol         `json:"protocol"`
+	Pool             *PoolComposition `json:"pool,omitempty"`
+	ProtocolLogo     string           `json:"protocol_logo,omitempty"`
+	VenueTotal       *Holdings        `json:"venue_total"`
+	AddressPrincipal *Holdings        `json:"address_holdings"`
+	AddressRewards   *Holdings        `json:"address_rewards"`
+	// Capabilities of the protocol; AddressRewards is nil if it has no separate rewards.
+	Capabilities *ProtocolCapabilities `json:"capabilities,omitempty"`
+	// RewardBreakdown groups the address rewards by category.
+	RewardBreakdown []RewardSubtotal `json:"reward_breakdown"`
+	Stale           bool             `json:"stale,omitempty"` // served from the last successful computation
+	Testnet         bool             `json:"testnet,omitempty"`
+	// PoolDeprecated is set if the pool no longer exists or was migrated, and the venue is served
+	// from its last known holdings.
+	PoolDeprecated bool `json:"pool_deprecated,omitempty"`
+	// Pending is set if the venue wasn't computed by the deadline of the request and has no earlier result.
+	Pending bool `json:"pending,omitempty"`
+	// Terminal is set for fully exited venues, which are reported empty without querying them.
+	Terminal bool `json:"terminal,omitempty"`
+	// Trace is only set on ?trace=true requests, and never cached.
+	Trace *VenueTrace `json:"trace,omitempty"`
+	// ContentHash changes whenever anything else served for the venue changes.
+	ContentHash string `json:"content_hash,omitempty"`
 }
 
 type BidHoldings struct {
-	BidId             int             `json:"bid_id"`
-	InitialAllocation int             `json:"initial_allocation"`
-	Holdings          []VenueHoldings `json:"holdings"`
-	Withdrawals       []Withdrawal    `json:"withdrawals"`
+	BidId             int          `json:"bid_id"`
+	Name              string       `json:"name,omitempty"`
+	Description       string       `json:"description,omitempty"`
+	Round             int          `json:"round,omitempty"`
+	Status            string       `json:"status"`             // active, withdrawn or compounded
+	CompoundedInto    []int        `json:"compounded_into"`    // bids the funds of a compounded bid went on into
+	InitialAllocation float64      `json:"initial_allocation"` // the sum of the allocations of bids paid in installments
+	Allocations       []Allocation `json:"allocations"`
+	// NetDeployed is the initial allocation less the withdrawals, plus what other bids compounded into the bid,
+	// plus the transfers into and less the transfers out of the bid.
+	NetDeployed     float64          `json:"net_deployed"`
+	Holdings        []VenueHoldings  `json:"holdings"`
+	Withdrawals     []Withdrawal     `json:"withdrawals"`
+	Transfers       []Transfer       `json:"transfers"` // into and out of the bid
+	Performance     *BidPerformance  `json:"performance"`
+	LifetimeRewards *LifetimeRewards `json:"lifetime_rewards"`
+	// FrozenAt is set for bids served from their frozen final summary.
+	FrozenAt *time.Time `json:"frozen_at,omitempty"`
+	// ContentHash changes whenever the holdings or config of the bid change, and HoldingsHash
+	// whenever its holdings do; it is the ETag of /holdings/<bid_id>.
+	ContentHash  string `json:"content_hash"`
+	HoldingsHash string `json:"holdings_hash"`
 }
 
 type Withdrawal struct {
-	Date            time.Time `json:"date"`              // Date of the withdrawal
-	WithdrawnAmount float64   `json:"withdrawn_amount"`  // Amount of withdrawal
-	WithdrawnShares float64   `json:"withdrawn_shares"`  // Amount of shares withdrawn (if applicable)
-	CompoundedBidId int       `json:"compounded_bid_id"` // ID of the compounded bid
+	Date            time.Time           `json:"date"`                      // Date of the withdrawal
+	WithdrawnAmount float64             `json:"withdrawn_amount"`          // Amount of withdrawal
+	WithdrawnShares float64             `json:"withdrawn_shares"`          // Amount of shares withdrawn (if applicable)
+	CompoundedBidId int                 `json:"compounded_bid_id"`         // ID of the compounded bid
+	CompoundedInto  []CompoundingTarget `json:"compounded_into,omitempty"` // Bids the withdrawal was split into, if more than one
 }
 
-// ExperimentalDeploymentQueryInterface defines the methods required for experimental deployments
-type ExperimentalDeploymentQueryInterface interface {
-	GetCurrentAddressHoldings(assetData *ChainInfo) (*Holdings, error)
+// CompoundingTarget is a bid that (part of) a withdrawal was compounded into.
+type CompoundingTarget struct {
+	BidId  int     `json:"bid_id"`
+	Amount float64 `json:"amount"` // Amount compounded into the bid (0 if unknown)
 }
 
-type ExperimentalDeployment struct {
-	ExperimentalId         int       `json:"experimental_id"`
-	Name                   string    `json:"name"`
-	Description            string    `json:"description"`
-	Logo                   string    `json:"logo"`
-	StartTimestamp         int64     `json:"start_timestamp"`
-	EndTimestamp           int64     `json:"end_timestamp"`
-	InitialAddressHoldings *Holdings `json:"initial_address_holdings"`
-	CurrentAddressHoldings *Holdings `json:"current_address_holdings"`
-	Querier                ExperimentalDeploymentQueryInterface
-}
+// CompoundingTargets returns all bids the withdrawal was compounded into.
+// A withdrawal with only a CompoundedBidId is compounded entirely into that bid.
+func (w Withdrawal) CompoundingTargets() []CompoundingTarget {
+	if len(w.CompoundedInto) > 0 {
+		return w.CompoundedInto
+	}
 
-// ExperimentalDeploymentResponse represents the response structure for experimental deployments
-type ExperimentalDeploymentResponse struct {
-	ExperimentalId         int       `json:"experimental_id"`
-	Name                   string    `json:"name"`
-	Description            string    `json:"description"`
-	Logo                   string    `json:"logo"`
-	StartTimestamp         int64     `json:"start_timestamp"`
-	EndTimestamp           int64     `json:"end_timestamp"`
-	InitialAddressHoldings *Holdings `json:"initial_address_holdings"`
-	CurrentAddressHoldings *Holdings `json:"current_address_holdings"`
+	if w.CompoundedBidId != 0 {
+		return []CompoundingTarget{{BidId: w.CompoundedBidId, Amount: w.WithdrawnAmount}}
+	}
+
+	return nil
 }
 
-// experimentalMap holds the configurations for experimental deployments
-var experimentalMap = map[int]*ExperimentalDeployment{
-	1: {
-		ExperimentalId: 1,
-		Name:           "Magma: ATOM<>stATOM vault managed by RoboMcGobo",
-		Description:    "This is a first experimental deployment to test the Magma vaults integration. The Hydro committee has allocated 10,000 ATOM to this test deployment, which are managed by committee member RoboMcGobo in a [0 fee vault](https://app.magma.eco/vault/osmo1ssm5lqgrxcp9lqvr33zcafyd6unme0q4kq2fpqzgwznnjwujts6sfmfass).",
-		Logo:           "https://pbs.twimg.com/profile_images/1830561644285714433/ImSkbXR0_400x400.jpg",
-		StartTimestamp: 1742325420,
-		EndTimestamp:   0,
-		Querier: NewMagmaQuerier(MagmaDeploymentConfig{
-			VaultAddress:  "osmo1ssm5lqgrxcp9lqvr33zcafyd6unme0q4kq2fpqzgwznnjwujts6sfmfass",
-			HolderAddress: "osmo1cuwe7dzgpemwxqzpkhyjwfeev2hcgd9de8xp566hrly6wtpcrc7qgp9jdx",
-			token0Denom:   "ibc/C140AFD542AE77BD7DCC83F13FDD8C5E5BB8C4929785E6EC2F4C636F98F17901",
-			token1Denom:   "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2",
-		}),
-		InitialAddressHoldings: &Holdings{
-			Balances: []Asset{
-				{
-					Denom:       "ibc/C140AFD542AE77BD7DCC83F13FDD8C5E5BB8C4929785E6EC2F4C636F98F17901",
-					Amount:      1968.1,
-					DisplayName: "stATOM",
-				},
-				{
-					Denom:       "ibc/27394FB092D2ECCD56123C74F36E4C1F926001CEADA9CA97EA622B25F41E5EB2",
-					Amount:      6976.354,
-					DisplayName: "ATOM",
-				},
-			},
-			TotalUSDC: 0, // Will be computed at runtime
-			TotalAtom: 0, // Will be computed at runtime
-		},
-	},
+// withCompoundingTargets fills in CompoundedInto for withdrawals that only set CompoundedBidId,
+// so that API consumers can rely on a single field.
+func withCompoundingTargets(withdrawals []Withdrawal) []Withdrawal {
+	normalized := make([]Withdrawal, len(withdrawals))
+	for i, withdrawal := range withdrawals {
+		withdrawal.CompoundedInto = withdrawal.CompoundingTargets()
+		normalized[i] = withdrawal
+	}
+	return normalized
 }
 
 // Protocol interface
 type DexProtocol interface {
-	ComputeTVL(assetData *ChainInfo) (*Holdings, error)
-	ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error)
-	ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error)
+	ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error)
+	ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error)
+	ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error)
+	Capabilities() ProtocolCapabilities
+}
+
+// ProtocolCapabilities tells which holdings a protocol integration computes. The Compute methods
+// of unsupported holdings are not called.
+type ProtocolCapabilities struct {
+	SupportsTVL       bool `json:"supports_tvl"`
+	SupportsPrincipal bool `json:"supports_principal"`
+	SupportsRewards   bool `json:"supports_rewards"`
+	// RewardsIncludedInPrincipal is set for protocols that don't track the yield separately,
+	// e.g. lending protocols whose deposits grow.
+	RewardsIncludedInPrincipal bool `json:"rewards_included_in_principal"`
 }
 
 func NewDexProtocolFromConfig(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (DexProtocol, error) {
+	// venues whose position isn't known, e.g. of the first bids on protocols integrated later
+	if _, ok := venuePositionConfig.(MissingVenuePositionConfig); ok {
+		return NewMissingPosition(config, venuePositionConfig)
+	}
+
 	switch config.Protocol {
 	case Osmosis:
 		return NewOsmosisPosition(config, venuePositionConfig)
@@ -194,8 +295,34 @@ func NewDexProtocolFromConfig(config ProtocolConfig, venuePositionConfig VenuePo
 		return NewElysPosition(config, venuePositionConfig)
 	case Neptune:
 		return NewNeptunePosition(config, venuePositionConfig)
-	case Margined, Demex, Shade, WhiteWhale, Inter, Pryzm:
-		return NewMissingPosition(config, venuePositionConfig)
+	case Margined, MarginedNeutron:
+		return NewMarginedPosition(config, venuePositionConfig)
+	case WhiteWhale:
+		return NewWhiteWhalePosition(config, venuePositionConfig)
+	case Inter:
+		return NewInterPosition(config, venuePositionConfig)
+	case Pryzm:
+		return NewPryzmPosition(config, venuePositionConfig)
+	case Levana, LevanaNeutron:
+		return NewLevanaPosition(config, venuePositionConfig)
+	case Quasar:
+		return NewQuasarPosition(config, venuePositionConfig)
+	case Mito:
+		return NewMitoPosition(config, venuePositionConfig)
+	case Drop:
+		return NewDropPosition(config, venuePositionConfig)
+	case Magma:
+		return NewMagmaPosition(config, venuePositionConfig)
+	case CosmWasm, CosmWasmNeutron:
+		return NewGenericCosmWasmPosition(config, venuePositionConfig)
+	case Staking:
+		return NewStakingPosition(config, venuePositionConfig)
+	case UniswapV3, UniswapV3Base:
+		return NewUniswapV3Position(config, venuePositionConfig)
+	case Shade:
+		return NewShadePosition(config, venuePositionConfig)
+	case Demex:
+		return NewDemexPosition(config, venuePositionConfig)
 	case Duality:
 		return NewDualityPosition(config, venuePositionConfig)
 	case Ux:
@@ -261,37 +388,110 @@ var protocolConfigMap = map[Protocol]ProtocolConfig{
 	},
 	Margined: {
 		Protocol:          Margined,
-		PoolInfoUrl:       "",
-		AssetListURL:      "",
-		AddressBalanceUrl: "",
+		PoolInfoUrl:       "https://osmosis-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/osmosis",
+		AddressBalanceUrl: "https://osmosis-api.polkachu.com/cosmos/bank/v1beta1/balances",
+	},
+	MarginedNeutron: {
+		Protocol:          MarginedNeutron,
+		PoolInfoUrl:       "https://neutron-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/neutron",
+		AddressBalanceUrl: "https://neutron-api.polkachu.com/cosmos/bank/v1beta1/balances",
 	},
 	Demex: {
 		Protocol:          Demex,
-		PoolInfoUrl:       "",
-		AssetListURL:      "",
-		AddressBalanceUrl: "",
+		PoolInfoUrl:       "https://api.carbon.network/carbon/perpspool/v1",
+		AssetListURL:      "https://chains.cosmos.directory/carbon",
+		AddressBalanceUrl: "https://api.carbon.network/cosmos/bank/v1beta1/balances",
 	},
 	Shade: {
 		Protocol:          Shade,
-		PoolInfoUrl:       "",
-		AssetListURL:      "",
-		AddressBalanceUrl: "",
+		PoolInfoUrl:       "https://lcd.secret.express",
+		AssetListURL:      "https://chains.cosmos.directory/secretnetwork",
+		AddressBalanceUrl: "https://lcd.secret.express",
 	},
 	WhiteWhale: {
 		Protocol:          WhiteWhale,
-		PoolInfoUrl:       "",
-		AssetListURL:      "",
+		PoolInfoUrl:       "https://migaloo-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/migaloo",
 		AddressBalanceUrl: "",
 	},
 	Inter: {
 		Protocol:          Inter,
-		PoolInfoUrl:       "",
-		AssetListURL:      "",
-		AddressBalanceUrl: "",
+		PoolInfoUrl:       "https://main.api.agoric.net",
+		AssetListURL:      "https://chains.cosmos.directory/agoric",
+		AddressBalanceUrl: "https://main.api.agoric.net/cosmos/bank/v1beta1/balances",
 	},
 	Pryzm: {
 		Protocol:          Pryzm,
-		PoolInfoUrl:       "",
+		PoolInfoUrl:       "https://pryzm-api.polkachu.com",
+		AssetListURL:      "https://chains.cosmos.directory/pryzm",
+		AddressBalanceUrl: "https://pryzm-api.polkachu.com/cosmos/bank/v1beta1/balances",
+	},
+	Levana: {
+		Protocol:          Levana,
+		PoolInfoUrl:       "https://osmosis-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/osmosis",
+		AddressBalanceUrl: "",
+	},
+	LevanaNeutron: {
+		Protocol:          LevanaNeutron,
+		PoolInfoUrl:       "https://neutron-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/neutron",
+		AddressBalanceUrl: "",
+	},
+	Quasar: {
+		Protocol:          Quasar,
+		PoolInfoUrl:       "https://osmosis-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/osmosis",
+		AddressBalanceUrl: "",
+	},
+	Mito: {
+		Protocol:          Mito,
+		PoolInfoUrl:       "https://injective-api.polkachu.com",
+		AssetListURL:      "https://chains.cosmos.directory/injective",
+		AddressBalanceUrl: "https://injective-api.polkachu.com/cosmos/bank/v1beta1/balances",
+	},
+	Drop: {
+		Protocol:          Drop,
+		PoolInfoUrl:       "https://neutron-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/neutron",
+		AddressBalanceUrl: "https://neutron-api.polkachu.com/cosmos/bank/v1beta1/balances",
+	},
+	Magma: {
+		Protocol:          Magma,
+		PoolInfoUrl:       "https://osmosis-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/osmosis",
+		AddressBalanceUrl: "",
+	},
+	CosmWasm: {
+		Protocol:          CosmWasm,
+		PoolInfoUrl:       "https://osmosis-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/osmosis",
+		AddressBalanceUrl: "",
+	},
+	CosmWasmNeutron: {
+		Protocol:          CosmWasmNeutron,
+		PoolInfoUrl:       "https://neutron-api.polkachu.com/cosmwasm/wasm/v1/contract",
+		AssetListURL:      "https://chains.cosmos.directory/neutron",
+		AddressBalanceUrl: "",
+	},
+	Staking: {
+		Protocol:          Staking,
+		PoolInfoUrl:       "https://cosmos-api.polkachu.com",
+		AssetListURL:      "https://chains.cosmos.directory/cosmoshub",
+		AddressBalanceUrl: "",
+	},
+	// the pool info URL of EVM protocols is a JSON-RPC endpoint, and their tokens are resolved on chain
+	UniswapV3: {
+		Protocol:          UniswapV3,
+		PoolInfoUrl:       "https://ethereum-rpc.publicnode.com",
+		AssetListURL:      "",
+		AddressBalanceUrl: "",
+	},
+	UniswapV3Base: {
+		Protocol:          UniswapV3Base,
+		PoolInfoUrl:       "https://base-rpc.publicnode.com",
 		AssetListURL:      "",
 		AddressBalanceUrl: "",
 	},
@@ -300,12 +500,14 @@ var protocolConfigMap = map[Protocol]ProtocolConfig{
 // map of bid ID to its position config
 var bidMap = map[int]BidPositionConfig{
 	0: {
+		Round:             1,
 		InitialAllocation: 10557,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Margined},
 		},
 	},
 	1: {
+		Round:             1,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Demex},
@@ -320,6 +522,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	2: {
+		Round:             1,
 		InitialAllocation: 18000,
 		Venues: []VenuePositionConfig{
 			NeptuneVenuePositionConfig{
@@ -338,12 +541,13 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	3: {
+		Round:             1,
 		InitialAllocation: 50000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
 				Protocol:         AstroportNeutron,
 				PoolAddress:      "neutron1yem82r0wf837lfkwvcu2zxlyds5qrzwkz8alvmg0apyrjthk64gqeq2e98",
-				IncentiveAddress: "neutron173fd8wpfzyqnfnpwq2zhtgdstujrjz2wkprkjfr6gqgknctjyq6m3tch",
+				IncentiveAddress: "neutron173fd8wpfzyqnfnpwq2zhtgdstujrjz2wkprkjfr6gqg4gknctjyq6m3tch",
 				Address:          "neutron1w7f40hgfc505a2wnjsl5pg35yl8qpawv48w5yekax4xj2m43j09s5fa44f",
 				ActiveShares:     0,
 			},
@@ -358,6 +562,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	4: {
+		Round:             1,
 		InitialAllocation: 36093,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Shade},
@@ -372,6 +577,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	5: {
+		Round:             1,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			NolusVenuePositionConfig{
@@ -391,6 +597,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	6: {
+		Round:             1,
 		InitialAllocation: 3143,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: WhiteWhale},
@@ -405,6 +612,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	7: {
+		Round:             1,
 		InitialAllocation: 17912,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -433,6 +641,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 2 starts here
 	11: {
+		Round:             2,
 		InitialAllocation: 81000,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -458,12 +667,14 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	12: {
+		Round:             2,
 		InitialAllocation: 33953,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Shade},
 		},
 	},
 	14: {
+		Round:             2,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: WhiteWhale},
@@ -478,6 +689,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	15: {
+		Round:             2,
 		InitialAllocation: 26000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -505,6 +717,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	16: {
+		Round:             2,
 		InitialAllocation: 42000,
 		Venues: []VenuePositionConfig{
 			MarsVenuePositionConfig{
@@ -522,6 +735,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	17: {
+		Round:             2,
 		InitialAllocation: 51000,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -541,6 +755,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 3 starts here
 	18: {
+		Round:             3,
 		InitialAllocation: 45585,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -559,6 +774,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	22: {
+		Round:             3,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -586,6 +802,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	23: {
+		Round:             3,
 		InitialAllocation: 22340,
 		Venues: []VenuePositionConfig{
 			NolusVenuePositionConfig{
@@ -605,6 +822,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	24: {
+		Round:             3,
 		InitialAllocation: 43962,
 		Venues: []VenuePositionConfig{
 			MarsVenuePositionConfig{
@@ -630,6 +848,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 4 starts here
 	25: {
+		Round:             4,
 		InitialAllocation: 170000,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -648,6 +867,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	27: {
+		Round:             4,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			NeptuneVenuePositionConfig{
@@ -667,6 +887,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 5 starts here
 	31: {
+		Round:             5,
 		InitialAllocation: 153000,
 		Venues: []VenuePositionConfig{
 			MarsVenuePositionConfig{
@@ -676,6 +897,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	32: {
+		Round:             5,
 		InitialAllocation: 48000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -696,6 +918,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	33: {
+		Round:             5,
 		InitialAllocation: 108000,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -714,6 +937,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	36: {
+		Round:             5,
 		InitialAllocation: 30000,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Margined},
@@ -728,12 +952,13 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	38: {
+		Round:             5,
 		InitialAllocation: 48000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
 				Protocol:         AstroportNeutron,
 				PoolAddress:      "neutron1yem82r0wf837lfkwvcu2zxlyds5qrzwkz8alvmg0apyrjthk64gqeq2e98",
-				IncentiveAddress: "neutron173fd8wpfzyqnfnpwq2zhtgdstujrjz2wkprkjfr6gqgknctjyq6m3tch",
+				IncentiveAddress: "neutron173fd8wpfzyqnfnpwq2zhtgdstujrjz2wkprkjfr6gqg4gknctjyq6m3tch",
 				Address:          "neutron1jdryd7eza5g68s9rzeqhckpsqx0dr8wcncpkq57pwdyvm3uvwhcqxp2865", //valence acc
 				ActiveShares:     0,
 			},
@@ -748,6 +973,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	39: {
+		Round:             5,
 		InitialAllocation: 37050,
 		Venues: []VenuePositionConfig{
 			NolusVenuePositionConfig{
@@ -767,6 +993,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	40: {
+		Round:             5,
 		InitialAllocation: 19950,
 		Venues: []VenuePositionConfig{
 			ElysVenuePositionConfig{
@@ -787,6 +1014,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 6 starts here
 	41: {
+		Round:             6,
 		InitialAllocation: 224000,
 		Venues: []VenuePositionConfig{
 			MarsVenuePositionConfig{
@@ -796,12 +1024,14 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	42: {
+		Round:             6,
 		InitialAllocation: 40000,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Shade},
 		},
 	},
 	43: {
+		Round:             6,
 		InitialAllocation: 112000,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -812,6 +1042,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	45: {
+		Round:             6,
 		InitialAllocation: 172000,
 		Venues: []VenuePositionConfig{
 			ElysVenuePositionConfig{
@@ -823,6 +1054,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	48: {
+		Round:             6,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Pryzm},
@@ -830,6 +1062,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 7 starts here
 	50: {
+		Round:             7,
 		InitialAllocation: 367300,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -845,6 +1078,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	51: {
+		Round:             7,
 		InitialAllocation: 78000,
 		Venues: []VenuePositionConfig{
 			DualityVenuePositionConfig{
@@ -855,6 +1089,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	53: {
+		Round:             7,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -882,6 +1117,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	54: {
+		Round:             7,
 		InitialAllocation: 26790,
 		Venues: []VenuePositionConfig{
 			ElysVenuePositionConfig{
@@ -901,6 +1137,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	55: {
+		Round:             7,
 		InitialAllocation: 42000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -914,6 +1151,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 8 starts here
 	57: {
+		Round:             8,
 		InitialAllocation: 31920,
 		Venues: []VenuePositionConfig{
 			NolusVenuePositionConfig{
@@ -925,6 +1163,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	58: {
+		Round:             8,
 		InitialAllocation: 101586,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -935,6 +1174,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	59: {
+		Round:             8,
 		InitialAllocation: 66020,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -947,6 +1187,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	60: {
+		Round:             8,
 		InitialAllocation: 198000,
 		Venues: []VenuePositionConfig{
 			MarsVenuePositionConfig{
@@ -956,6 +1197,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	62: {
+		Round:             8,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			AstroportVenuePositionConfig{
@@ -983,6 +1225,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	65: {
+		Round:             8,
 		InitialAllocation: 8755, // 2888 ATOM and 25084 USDC ~ 8.5k ATOM
 		Venues: []VenuePositionConfig{
 			ElysVenuePositionConfig{
@@ -994,6 +1237,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	67: {
+		Round:             8,
 		InitialAllocation: 30000,
 		Venues: []VenuePositionConfig{
 			UxVenuePositionConfig{
@@ -1004,6 +1248,7 @@ var bidMap = map[int]BidPositionConfig{
 	},
 	// round 9 starts here
 	70: {
+		Round:             9,
 		InitialAllocation: 36000,
 		Venues: []VenuePositionConfig{
 			DualityVenuePositionConfig{
@@ -1014,6 +1259,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	71: {
+		Round:             9,
 		InitialAllocation: 144000,
 		Venues: []VenuePositionConfig{
 			MarsVenuePositionConfig{
@@ -1023,6 +1269,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	72: {
+		Round:             9,
 		InitialAllocation: 13800,
 		Venues: []VenuePositionConfig{
 			NeptuneVenuePositionConfig{
@@ -1033,6 +1280,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	77: {
+		Round:             9,
 		InitialAllocation: 749, // 749 atom, 609302 arch
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -1063,6 +1311,7 @@ var bidMap = map[int]BidPositionConfig{
 	// 	},
 	// },
 	79: {
+		Round:             9,
 		InitialAllocation: 46900,
 		Venues: []VenuePositionConfig{
 			OsmosisVenuePositionConfig{
@@ -1073,6 +1322,7 @@ var bidMap = map[int]BidPositionConfig{
 		},
 	},
 	81: {
+		Round:             9,
 		InitialAllocation: 10000,
 		Venues: []VenuePositionConfig{
 			MissingVenuePositionConfig{Protocol: Pryzm},
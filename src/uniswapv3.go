@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Selectors of the Uniswap v3 functions that are called.
+const (
+	selectorPositions            = "99fbab88" // positions(uint256) of the NonfungiblePositionManager
+	selectorFactory              = "c45a0155" // factory()
+	selectorGetPool              = "1698ee82" // getPool(address,address,uint24)
+	selectorSlot0                = "3850c7bd" // slot0()
+	selectorFeeGrowthGlobal0X128 = "f3058399" // feeGrowthGlobal0X128()
+	selectorFeeGrowthGlobal1X128 = "46141319" // feeGrowthGlobal1X128()
+	selectorTicks                = "f30dba93" // ticks(int24)
+	selectorOwnerOf              = "6352211e" // ownerOf(uint256)
+)
+
+// uniswapV3Deployment is where Uniswap v3 is deployed on an EVM chain.
+type uniswapV3Deployment struct {
+	ChainID         string // EVM chain ID, as in the Skip asset list
+	PositionManager string // NonfungiblePositionManager
+}
+
+var uniswapV3Deployments = map[Protocol]uniswapV3Deployment{
+	UniswapV3:     {ChainID: "1", PositionManager: "0xC36442b4a4522E871399CD717aBDD847Ab11FE88"},
+	UniswapV3Base: {ChainID: "8453", PositionManager: "0x03a520b32C04BF3bEEf7BEb72E919cf822Ed34f1"},
+}
+
+// UniswapV3VenuePositionConfig is a Uniswap v3 liquidity position on an EVM chain, held as an NFT
+// of the NonfungiblePositionManager.
+type UniswapV3VenuePositionConfig struct {
+	VenueMetadata
+
+	Protocol Protocol // UniswapV3 for Ethereum, UniswapV3Base for Base
+	Address  string   // owner of the position, e.g. 0x...
+	TokenID  string   // ID of the position NFT
+	// PositionManager is the NonfungiblePositionManager of a fork of Uniswap v3 on the chain. If
+	// empty, it is the one of Uniswap.
+	PositionManager string
+	// CoingeckoIDs price the tokens of the pool that the Skip asset list doesn't, by token address.
+	CoingeckoIDs map[string]string
+}
+
+func (venueConfig UniswapV3VenuePositionConfig) GetProtocol() Protocol {
+	if venueConfig.Protocol == "" {
+		return UniswapV3
+	}
+	return venueConfig.Protocol
+}
+
+func (venueConfig UniswapV3VenuePositionConfig) GetPoolID() string {
+	return venueConfig.TokenID
+}
+
+func (venueConfig UniswapV3VenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+type UniswapV3Position struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig UniswapV3VenuePositionConfig
+}
+
+func NewUniswapV3Position(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*UniswapV3Position, error) {
+	uniswapV3VenuePositionConfig, ok := venuePositionConfig.(UniswapV3VenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of UniswapV3VenuePositionConfig type")
+	}
+
+	return &UniswapV3Position{protocolConfig: config, venuePositionConfig: uniswapV3VenuePositionConfig}, nil
+}
+
+func (p UniswapV3Position) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
+// uniswapV3PositionData is a position of the NonfungiblePositionManager and its pool.
+type uniswapV3PositionData struct {
+	token0, token1           string
+	fee                      *big.Int
+	tickLower, tickUpper     *big.Int
+	liquidity                *big.Int
+	feeGrowthInside0LastX128 *big.Int
+	feeGrowthInside1LastX128 *big.Int
+	tokensOwed0, tokensOwed1 *big.Int
+	pool                     string
+}
+
+// errBurnedPosition is returned for positions whose NFT was burned after they were closed, or
+// transferred away from the address of the venue.
+var errBurnedPosition = errors.New("the position NFT was burned or transferred")
+
+func (p UniswapV3Position) deployment() (uniswapV3Deployment, error) {
+	deployment, ok := uniswapV3Deployments[p.venuePositionConfig.GetProtocol()]
+	if !ok {
+		return deployment, fmt.Errorf("no Uniswap v3 deployment for %s", p.venuePositionConfig.GetProtocol())
+	}
+	if p.venuePositionConfig.PositionManager != "" {
+		deployment.PositionManager = p.venuePositionConfig.PositionManager
+	}
+	return deployment, nil
+}
+
+// fetchPosition reads the position from the NonfungiblePositionManager and looks up its pool.
+func (p UniswapV3Position) fetchPosition(ctx context.Context) (*uniswapV3PositionData, error) {
+	deployment, err := p.deployment()
+	if err != nil {
+		return nil, err
+	}
+	rpcUrl := p.protocolConfig.PoolInfoUrl
+
+	tokenID, ok := new(big.Int).SetString(p.venuePositionConfig.TokenID, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid position NFT ID %q", p.venuePositionConfig.TokenID)
+	}
+	result, err := evmCall(ctx, rpcUrl, deployment.PositionManager, selectorPositions+abiEncodeUint(tokenID))
+	if err != nil {
+		var revert *EVMRevertError
+		if errors.As(err, &revert) {
+			return nil, errBurnedPosition
+		}
+		return nil, fmt.Errorf("querying position %s: %v", p.venuePositionConfig.TokenID, err)
+	}
+
+	// nonce, operator, token0, token1, fee, tickLower, tickUpper, liquidity, feeGrowthInside0LastX128,
+	// feeGrowthInside1LastX128, tokensOwed0, tokensOwed1
+	position := &uniswapV3PositionData{}
+	if position.token0, err = abiAddress(result, 2); err != nil {
+		return nil, err
+	}
+	if position.token1, err = abiAddress(result, 3); err != nil {
+		return nil, err
+	}
+	fields := []struct {
+		value  **big.Int
+		signed bool
+	}{
+		{&position.fee, false}, {&position.tickLower, true}, {&position.tickUpper, true}, {&position.liquidity, false},
+		{&position.feeGrowthInside0LastX128, false}, {&position.feeGrowthInside1LastX128, false},
+		{&position.tokensOwed0, false}, {&position.tokensOwed1, false},
+	}
+	for i, field := range fields {
+		decode := abiUint
+		if field.signed {
+			decode = abiInt
+		}
+		if *field.value, err = decode(result, 4+i); err != nil {
+			return nil, err
+		}
+	}
+
+	// the factory and pool of a position never change
+	result, err = evmCallImmutable(ctx, rpcUrl, deployment.PositionManager, selectorFactory)
+	if err != nil {
+		return nil, fmt.Errorf("querying factory: %v", err)
+	}
+	factory, err := abiAddress(result, 0)
+	if err != nil {
+		return nil, err
+	}
+	result, err = evmCallImmutable(ctx, rpcUrl, factory, selectorGetPool+abiEncodeAddress(position.token0)+
+		abiEncodeAddress(position.token1)+abiEncodeUint(position.fee))
+	if err != nil {
+		return nil, fmt.Errorf("querying pool: %v", err)
+	}
+	if position.pool, err = abiAddress(result, 0); err != nil {
+		return nil, err
+	}
+
+	return position, nil
+}
+
+// fetchOwnedPosition is fetchPosition for the holdings of the address of the venue, which only
+// has the position as long as it owns its NFT.
+func (p UniswapV3Position) fetchOwnedPosition(ctx context.Context) (*uniswapV3PositionData, error) {
+	position, err := p.fetchPosition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := p.deployment()
+	if err != nil {
+		return nil, err
+	}
+	tokenID, _ := new(big.Int).SetString(p.venuePositionConfig.TokenID, 10)
+	result, err := evmCall(ctx, p.protocolConfig.PoolInfoUrl, deployment.PositionManager, selectorOwnerOf+abiEncodeUint(tokenID))
+	if err != nil {
+		return nil, fmt.Errorf("querying owner of position %s: %v", p.venuePositionConfig.TokenID, err)
+	}
+	owner, err := abiAddress(result, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(owner, p.venuePositionConfig.Address) {
+		debugLog("Uniswap v3 position is owned by another address", map[string]string{"tokenID": p.venuePositionConfig.TokenID, "owner": owner})
+		return nil, errBurnedPosition
+	}
+	return position, nil
+}
+
+// ComputeTVL returns the balances of the pool of the position.
+func (p UniswapV3Position) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	position, err := p.fetchPosition(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balance0, err := fetchERC20Balance(ctx, p.protocolConfig.PoolInfoUrl, position.token0, position.pool)
+	if err != nil {
+		return nil, err
+	}
+	balance1, err := fetchERC20Balance(ctx, p.protocolConfig.PoolInfoUrl, position.token1, position.pool)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.tokenHoldings(ctx, position, bigFloat(balance0), bigFloat(balance1))
+}
+
+// ComputeAddressPrincipalHoldings returns the tokens the liquidity of the position is worth at the
+// current price of the pool.
+func (p UniswapV3Position) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	position, err := p.fetchOwnedPosition(ctx)
+	if errors.Is(err, errBurnedPosition) {
+		return &Holdings{Balances: []Asset{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := evmCall(ctx, p.protocolConfig.PoolInfoUrl, position.pool, selectorSlot0)
+	if err != nil {
+		return nil, fmt.Errorf("querying pool price: %v", err)
+	}
+	sqrtPriceX96, err := abiUint(result, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	sqrtPrice := bigFloat(sqrtPriceX96) / math.Pow(2, 96)
+	amount0, amount1 := uniswapV3Amounts(bigFloat(position.liquidity), sqrtPrice,
+		tickSqrtPrice(position.tickLower.Int64()), tickSqrtPrice(position.tickUpper.Int64()))
+
+	return p.tokenHoldings(ctx, position, amount0, amount1)
+}
+
+// ComputeAddressRewardHoldings returns the uncollected fees of the position: the fees credited to
+// it so far, plus the ones accrued since from the fee growth inside its range.
+func (p UniswapV3Position) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	position, err := p.fetchOwnedPosition(ctx)
+	if errors.Is(err, errBurnedPosition) {
+		return &Holdings{Balances: []Asset{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rpcUrl := p.protocolConfig.PoolInfoUrl
+
+	result, err := evmCall(ctx, rpcUrl, position.pool, selectorSlot0)
+	if err != nil {
+		return nil, fmt.Errorf("querying pool tick: %v", err)
+	}
+	tick, err := abiInt(result, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// feeGrowthOutside0X128 and feeGrowthOutside1X128 are the 3rd and 4th fields of a tick
+	var global [2]*big.Int
+	var lower, upper []byte
+	for i, selector := range []string{selectorFeeGrowthGlobal0X128, selectorFeeGrowthGlobal1X128} {
+		if result, err = evmCall(ctx, rpcUrl, position.pool, selector); err != nil {
+			return nil, fmt.Errorf("querying fee growth: %v", err)
+		}
+		if global[i], err = abiUint(result, 0); err != nil {
+			return nil, err
+		}
+	}
+	if lower, err = evmCall(ctx, rpcUrl, position.pool, selectorTicks+abiEncodeUint(position.tickLower)); err != nil {
+		return nil, fmt.Errorf("querying lower tick: %v", err)
+	}
+	if upper, err = evmCall(ctx, rpcUrl, position.pool, selectorTicks+abiEncodeUint(position.tickUpper)); err != nil {
+		return nil, fmt.Errorf("querying upper tick: %v", err)
+	}
+
+	var fees [2]float64
+	owed := []*big.Int{position.tokensOwed0, position.tokensOwed1}
+	last := []*big.Int{position.feeGrowthInside0LastX128, position.feeGrowthInside1LastX128}
+	for i := range fees {
+		outsideLower, err := abiUint(lower, 2+i)
+		if err != nil {
+			return nil, err
+		}
+		outsideUpper, err := abiUint(upper, 2+i)
+		if err != nil {
+			return nil, err
+		}
+		inside := feeGrowthInside(tick, position.tickLower, position.tickUpper, global[i], outsideLower, outsideUpper)
+
+		accrued := new(big.Int).Sub(inside, last[i])
+		accrued.Mod(accrued, uint256Modulus)
+		accrued.Mul(accrued, position.liquidity)
+		accrued.Rsh(accrued, 128)
+		fees[i] = bigFloat(new(big.Int).Add(owed[i], accrued))
+	}
+
+	return p.tokenHoldings(ctx, position, fees[0], fees[1])
+}
+
+// uint256Modulus is 2^256, which fee growth values wrap around at.
+var uint256Modulus = new(big.Int).Lsh(big.NewInt(1), 256)
+
+// feeGrowthInside computes the fee growth per unit of liquidity inside the range of a position,
+// like the Uniswap v3 Tick library, modulo 2^256.
+func feeGrowthInside(tick, tickLower, tickUpper, global, outsideLower, outsideUpper *big.Int) *big.Int {
+	below := outsideLower
+	if tick.Cmp(tickLower) < 0 {
+		below = new(big.Int).Sub(global, outsideLower)
+	}
+	above := outsideUpper
+	if tick.Cmp(tickUpper) >= 0 {
+		above = new(big.Int).Sub(global, outsideUpper)
+	}
+	inside := new(big.Int).Sub(global, below)
+	inside.Sub(inside, above)
+	return inside.Mod(inside, uint256Modulus)
+}
+
+// tickSqrtPrice returns the square root of the price at a tick.
+func tickSqrtPrice(tick int64) float64 {
+	return math.Pow(1.0001, float64(tick)/2)
+}
+
+// uniswapV3Amounts returns the amounts of token0 and token1, in their base units, that liquidity
+// in the range between the square root prices sqrtLower and sqrtUpper is worth at sqrtPrice.
+func uniswapV3Amounts(liquidity float64, sqrtPrice float64, sqrtLower float64, sqrtUpper float64) (float64, float64) {
+	switch {
+	case sqrtPrice <= sqrtLower:
+		// all in token0 below the range
+		return liquidity * (sqrtUpper - sqrtLower) / (sqrtLower * sqrtUpper), 0
+	case sqrtPrice >= sqrtUpper:
+		return 0, liquidity * (sqrtUpper - sqrtLower)
+	default:
+		return liquidity * (sqrtUpper - sqrtPrice) / (sqrtPrice * sqrtUpper), liquidity * (sqrtPrice - sqrtLower)
+	}
+}
+
+func bigFloat(value *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(value).Float64()
+	return f
+}
+
+// tokenHoldings values amounts of the tokens of the pool, in their base units.
+func (p UniswapV3Position) tokenHoldings(ctx context.Context, position *uniswapV3PositionData, amount0 float64, amount1 float64) (*Holdings, error) {
+	deployment, err := p.deployment()
+	if err != nil {
+		return nil, err
+	}
+
+	holdings := &Holdings{Balances: []Asset{}}
+	for i, token := range []string{position.token0, position.token1} {
+		amount := []float64{amount0, amount1}[i]
+		if amount == 0 {
+			continue
+		}
+
+		tokenInfo, err := fetchERC20Token(ctx, p.protocolConfig.PoolInfoUrl, deployment.ChainID, token, p.venuePositionConfig.CoingeckoIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		adjustedAmount := amount / math.Pow(10, float64(tokenInfo.Decimals))
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, tokenInfo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute token values: %s", err)
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       strings.ToLower(token),
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+
+	return holdings, nil
+}
+
+// validateUniswapV3Venue checks the hex addresses and the token ID of a Uniswap v3 venue, which
+// validateVenueAddresses leaves out as they aren't bech32.
+func validateUniswapV3Venue(bidId int, venueConfig VenuePositionConfig) []error {
+	uniswapConfig, ok := venueConfig.(UniswapV3VenuePositionConfig)
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	id := venueID(bidId, venueConfig)
+	if _, ok := uniswapV3Deployments[uniswapConfig.GetProtocol()]; !ok {
+		errs = append(errs, fmt.Errorf("venue %s has protocol %s, which is not a Uniswap v3 deployment", id, uniswapConfig.GetProtocol()))
+	}
+	if !isEVMAddress(uniswapConfig.Address) {
+		errs = append(errs, fmt.Errorf("venue %s has a malformed Address %q", id, uniswapConfig.Address))
+	}
+	if uniswapConfig.PositionManager != "" && !isEVMAddress(uniswapConfig.PositionManager) {
+		errs = append(errs, fmt.Errorf("venue %s has a malformed PositionManager %q", id, uniswapConfig.PositionManager))
+	}
+	if tokenID, ok := new(big.Int).SetString(uniswapConfig.TokenID, 10); !ok || tokenID.Sign() < 0 {
+		errs = append(errs, fmt.Errorf("venue %s has a malformed TokenID %q", id, uniswapConfig.TokenID))
+	}
+	addresses := make([]string, 0, len(uniswapConfig.CoingeckoIDs))
+	for address := range uniswapConfig.CoingeckoIDs {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if !isEVMAddress(address) {
+			errs = append(errs, fmt.Errorf("venue %s has a malformed token address %q in CoingeckoIDs", id, address))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UnhealthyConsecutiveFailures is the number of failed requests in a row after which an upstream host
+// is reported as unhealthy.
+const UnhealthyConsecutiveFailures = 3
+
+var (
+	upstreamRequestsMetric = newCounter("upstream_requests_total",
+		"Number of requests to upstream APIs by host and outcome.")
+	upstreamLatencyMetric = newGauge("upstream_last_request_duration_seconds",
+		"Duration of the last request to an upstream API by host.")
+)
+
+// DefaultUserAgent identifies the service to upstream providers, some of which grant higher limits
+// to clients that can be contacted.
+const DefaultUserAgent = "hydro-deployment-tracking/1.0 (+https://github.com/informalsystems/deployment-tracking)"
+
+// upstreamClient is used for all requests to chain nodes and price APIs,
+// so that their health can be tracked per host.
+var upstreamClient = &http.Client{Transport: &upstreamTransport{base: http.DefaultTransport, userAgent: DefaultUserAgent}}
+
+// configureUpstreamClient routes upstream requests through an HTTP(S) proxy, if set,
+// and sets the User-Agent sent with them.
+// Without a proxy URL, the standard HTTP_PROXY/HTTPS_PROXY variables still apply.
+func configureUpstreamClient(proxyURL string, userAgent string) error {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL: %s", proxyURL)
+		}
+		base.Proxy = http.ProxyURL(u)
+	}
+
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	upstreamClient.Transport = &upstreamTransport{base: base, userAgent: userAgent}
+	return nil
+}
+
+// UpstreamHealth summarizes the recent requests to an upstream host.
+type UpstreamHealth struct {
+	Host                string    `json:"host"`
+	Healthy             bool      `json:"healthy"`
+	Requests            int       `json:"requests"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastFailure         time.Time `json:"last_failure"`
+	FailingSince        time.Time `json:"failing_since"` // start of the current streak of failures
+	LastError           string    `json:"last_error,omitempty"`
+	AvgLatencyMs        float64   `json:"avg_latency_ms"`
+}
+
+var (
+	upstreamHealthMu sync.Mutex
+	upstreamHealth   = make(map[string]*UpstreamHealth)
+)
+
+// upstreamTransport applies the rate limits and records the outcome of every request per host.
+type upstreamTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *upstreamTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+
+	// the replacements are third parties, they don't get the credentials for the dead host
+	if rerouted, ok := failoverURL(req.URL); ok {
+		if rerouted.Host != req.URL.Host {
+			stripCredentials(req.Header)
+		}
+		req.URL = rerouted
+		req.Host = ""
+	}
+
+	if err := waitForRateLimit(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		if err = guardResponse(req.URL.Host, resp); err != nil {
+			resp.Body.Close()
+			resp = nil
+		}
+	}
+	duration := time.Since(start)
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	} else if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		// 4xx responses other than rate limits are caused by our queries, not by the upstream
+		errMsg = resp.Status
+	}
+
+	recordUpstreamRequest(req.URL.Host, duration, errMsg)
+
+	if trace := traceFromContext(req.Context()); trace != nil {
+		call := TraceCall{URL: req.URL.String(), DurationMs: float64(duration.Microseconds()) / 1000}
+		if err != nil {
+			call.Error = err.Error()
+		} else {
+			call.Status = resp.StatusCode
+		}
+		trace.addCall(call)
+	}
+
+	return resp, err
+}
+
+// credentialHeaders are the request headers that carry credentials for an upstream.
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// stripCredentials removes the credentials of a request that is sent to another host.
+func stripCredentials(header http.Header) {
+	for _, name := range credentialHeaders {
+		header.Del(name)
+	}
+}
+
+func recordUpstreamRequest(host string, duration time.Duration, errMsg string) {
+	outcome := "success"
+	if errMsg != "" {
+		outcome = "failure"
+	}
+	upstreamRequestsMetric.Add(1, "host", host, "outcome", outcome)
+	upstreamLatencyMetric.Set(duration.Seconds(), "host", host)
+
+	upstreamHealthMu.Lock()
+	defer upstreamHealthMu.Unlock()
+
+	health, ok := upstreamHealth[host]
+	if !ok {
+		health = &UpstreamHealth{Host: host}
+		upstreamHealth[host] = health
+	}
+
+	latencyMs := float64(duration.Milliseconds())
+	if health.Requests == 0 {
+		health.AvgLatencyMs = latencyMs
+	} else {
+		// exponentially weighted, so that the average follows recent changes
+		health.AvgLatencyMs = 0.8*health.AvgLatencyMs + 0.2*latencyMs
+	}
+	health.Requests++
+
+	now := time.Now()
+	if errMsg != "" {
+		health.Failures++
+		if health.ConsecutiveFailures == 0 {
+			health.FailingSince = now
+		}
+		health.ConsecutiveFailures++
+		health.LastFailure = now
+		health.LastError = errMsg
+	} else {
+		health.ConsecutiveFailures = 0
+		health.FailingSince = time.Time{}
+		health.LastSuccess = now
+	}
+	health.Healthy = health.ConsecutiveFailures < UnhealthyConsecutiveFailures
+}
+
+// getUpstreamHealth returns the health of all upstream hosts contacted so far, sorted by host.
+func getUpstreamHealth() []UpstreamHealth {
+	upstreamHealthMu.Lock()
+	defer upstreamHealthMu.Unlock()
+
+	result := make([]UpstreamHealth, 0, len(upstreamHealth))
+	for _, health := range upstreamHealth {
+		result = append(result, *health)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Host < result[j].Host })
+
+	return result
+}
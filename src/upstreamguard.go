@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// DefaultMaxUpstreamResponseMB is the default limit of the size of upstream responses, in MiB.
+// The largest legitimate responses are asset lists and pool lists of a few MiB.
+const DefaultMaxUpstreamResponseMB = 16
+
+// maxUpstreamResponseBytes is the limit of the size of upstream responses, 0 for no limit.
+var maxUpstreamResponseBytes int64 = DefaultMaxUpstreamResponseMB << 20
+
+var (
+	errResponseTooLarge = errors.New("upstream response too large")
+	errNotJSON          = errors.New("upstream response is not JSON")
+)
+
+var upstreamRejectedMetric = newCounter("upstream_responses_rejected_total",
+	"Number of upstream responses rejected by host and reason, too_large or not_json.")
+
+// guardResponse checks an upstream response before anyone parses it: its body is limited to
+// maxUpstreamResponseBytes, and a successful response must be a JSON object or array, so that
+// an endpoint returning an HTML error page or megabytes of junk fails the request rather than
+// exhausting the memory or ending up in a cache. Only the first bytes are read here, the size is
+// checked as the body is read.
+func guardResponse(host string, resp *http.Response) error {
+	if maxUpstreamResponseBytes > 0 {
+		if resp.ContentLength > maxUpstreamResponseBytes {
+			upstreamRejectedMetric.Add(1, "host", host, "reason", "too_large")
+			return fmt.Errorf("%w: %d bytes", errResponseTooLarge, resp.ContentLength)
+		}
+		resp.Body = &limitedBody{body: resp.Body, remaining: maxUpstreamResponseBytes, host: host}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// error responses are only read to be logged
+		return nil
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == "text/html" {
+		upstreamRejectedMetric.Add(1, "host", host, "reason", "not_json")
+		return fmt.Errorf("%w: content type %s", errNotJSON, mediaType)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	first, err := firstNonSpace(reader)
+	if err != nil {
+		if errors.Is(err, errResponseTooLarge) {
+			return err
+		}
+		upstreamRejectedMetric.Add(1, "host", host, "reason", "not_json")
+		return fmt.Errorf("%w: %v", errNotJSON, err)
+	}
+	if first != '{' && first != '[' {
+		upstreamRejectedMetric.Add(1, "host", host, "reason", "not_json")
+		return fmt.Errorf("%w: starts with %q", errNotJSON, first)
+	}
+	resp.Body = bufferedBody{Reader: reader, Closer: resp.Body}
+
+	return nil
+}
+
+// firstNonSpace peeks at the first byte of a response that isn't whitespace, leaving it unread.
+func firstNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return 0, fmt.Errorf("empty response")
+			}
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, reader.UnreadByte()
+	}
+}
+
+// limitedBody fails the read of a response body once it exceeds its limit, rather than
+// truncating it like io.LimitReader, which would turn junk into a confusing decoding error.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	host      string
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	// read one byte past the limit, to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		upstreamRejectedMetric.Add(1, "host", b.host, "reason", "too_large")
+		return 0, errResponseTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
+
+// bufferedBody is a response body with the bytes peeked at by guardResponse put back.
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
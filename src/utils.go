@@ -1,16 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 // Helper functions
 func debugLog(message string, data interface{}) {
-	if Debug {
+	if verboseLogging {
 		fmt.Printf("[DEBUG] %s\n", message)
 		if data != nil {
 			jsonData, _ := json.MarshalIndent(data, "", "  ")
@@ -19,71 +21,38 @@ func debugLog(message string, data interface{}) {
 	}
 }
 
-func fetchAssetList(assetListUrl string) (*ChainInfo, error) {
-	debugLog("Fetching asset list", map[string]string{"url": assetListUrl})
-
-	resp, err := http.Get(assetListUrl)
+// httpGet issues a GET request that is cancelled together with ctx.
+func httpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
-	}
 
-	chain, ok := result["chain"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid asset data structure")
-	}
+	return upstreamClient.Do(req)
+}
 
-	chainID, ok := chain["chain_id"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing chain_id")
-	}
+func fetchAssetList(ctx context.Context, assetListUrl string) (*ChainInfo, error) {
+	debugLog("Fetching asset list", map[string]string{"url": assetListUrl})
 
-	assets, ok := chain["assets"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid assets structure")
+	chainID, listed, err := getAssetList(ctx, assetListUrl)
+	if err != nil {
+		return nil, err
 	}
 
-	tokens := make(map[string]ChainTokenInfo)
-	for _, asset := range assets {
-		assetMap := asset.(map[string]interface{})
-
-		denom, ok := assetMap["denom"].(string)
-		if !ok {
-			continue
-		}
-
-		token := ChainTokenInfo{
-			Denom: denom,
-		}
-
-		if symbol, ok := assetMap["symbol"].(string); ok {
-			token.Display = symbol
-		}
-
-		if decimals, ok := assetMap["decimals"].(float64); ok {
-			token.Decimals = int(decimals)
-		}
-
-		if coingeckoID, ok := assetMap["coingecko_id"].(string); ok {
-			token.CoingeckoID = coingeckoID
-		}
-
+	// the listed tokens are shared with the cache
+	tokens := make(map[string]ChainTokenInfo, len(listed))
+	for denom, token := range listed {
 		tokens[denom] = token
 	}
 
 	// supplement with the skip token list
-	err = fetchSkipAssets()
+	err = fetchSkipAssets(ctx)
 	if err != nil {
 		// if the skip assets couldn't be fetched, log an error, but continue
 		debugLog("Failed to fetch skip assets", map[string]string{"error": err.Error()})
 	}
 
-	skipAssets := skipCache.Assets[chainID]
+	skipAssets := getSkipAssets()[chainID]
 	for denom, asset := range skipAssets {
 		debugLog("Adding skip asset", map[string]string{"denom": denom})
 		if _, ok := tokens[denom]; !ok {
@@ -102,6 +71,25 @@ func fetchAssetList(assetListUrl string) (*ChainInfo, error) {
 	}, nil
 }
 
+const (
+	// ContractQueryCacheSize is the number of contract query responses kept in memory,
+	// and ContractQueryCacheBytes bounds their total size.
+	ContractQueryCacheSize  = 1024
+	ContractQueryCacheBytes = 64 << 20
+	// ContractQueryCacheTTL is short, so that only repeated queries within a computation are served from memory.
+	ContractQueryCacheTTL = time.Minute
+)
+
+// contractQueryCache holds recent smart contract query responses, keyed by query URL.
+var contractQueryCache = newLRUCache("contract_queries", ContractQueryCacheSize, ContractQueryCacheBytes, ContractQueryCacheTTL)
+
+var (
+	contractQueryCacheHitsMetric = newCounter("contract_query_cache_hits_total",
+		"Number of smart contract queries served from memory.")
+	contractQueryCacheMissesMetric = newCounter("contract_query_cache_misses_total",
+		"Number of smart contract queries sent to a node.")
+)
+
 // A type to parse error responses
 type WasmError struct {
 	Code    int      `json:"code"`
@@ -109,7 +97,7 @@ type WasmError struct {
 	Details []string `json:"details"`
 }
 
-func QuerySmartContractData(nodeUrl string, contractAddress string,
+func QuerySmartContractData(ctx context.Context, nodeUrl string, contractAddress string,
 	query map[string]interface{},
 ) (interface{}, error) {
 	debugLog("Querying smart contract data", query)
@@ -123,8 +111,15 @@ func QuerySmartContractData(nodeUrl string, contractAddress string,
 		nodeUrl, contractAddress, string(queryEncoded))
 	debugLog("Fetching data from smart contract", map[string]string{"url": url})
 
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
+	// the URL contains the node, contract and encoded query, so it identifies the response
+	if raw, ok := contractQueryCache.Get(url); ok {
+		contractQueryCacheHitsMetric.Add(1)
+		traceFromContext(ctx).addCall(TraceCall{URL: url, CacheHit: true})
+		return decodeContractData(raw.(json.RawMessage))
+	}
+	contractQueryCacheMissesMetric.Add(1)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request failed: %v", err)
 	}
@@ -133,9 +128,9 @@ func QuerySmartContractData(nodeUrl string, contractAddress string,
 	// we could change this in the future to have different headers,
 	// maybe bundled with the node
 	req.Header.Add("Accept", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", NumiaAuthToken))
+	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", numiaAuthToken()))
 
-	resp, err := client.Do(req)
+	resp, err := upstreamClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetching data failed: %v", err)
 	}
@@ -157,25 +152,45 @@ func QuerySmartContractData(nodeUrl string, contractAddress string,
 	}
 
 	var response struct {
-		Data interface{} `json:"data"`
+		Data json.RawMessage `json:"data"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("decoding smart contract data: %v", err)
 	}
 
-	debugLog("contract response", response)
+	data, err := decodeContractData(response.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	debugLog("contract response", data)
+
+	contractQueryCache.Set(url, response.Data, int64(len(response.Data)))
+
+	return data, nil
+}
+
+// decodeContractData decodes the data of a contract query response.
+// Every caller gets its own copy, so that cached responses can't be modified.
+func decodeContractData(raw json.RawMessage) (interface{}, error) {
+	var data interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, fmt.Errorf("decoding smart contract data: %v", err)
+		}
+	}
 
-	if response.Data == nil {
+	if data == nil {
 		return nil, fmt.Errorf("smart contract returned no data")
 	}
 
-	return response.Data, nil
+	return data, nil
 }
 
-func getJSON(url string, target interface{}) error {
+func getJSON(ctx context.Context, url string, target interface{}) error {
 	debugLog("Fetching JSON data", map[string]string{"url": url})
 
-	resp, err := http.Get(url)
+	resp, err := httpGet(ctx, url)
 	if err != nil {
 		return fmt.Errorf("making HTTP request: %v", err)
 	}
@@ -1,16 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"math"
+	"math/big"
 	"net/http"
-	"strconv"
 )
 
 const UX_ATOM = "ibc/C4CFF46FD6DE35CA4CF4CE031E643C8FDC9BA4B99AE598E9B0ED98FE3A2319F9"
 
 type UxVenuePositionConfig struct {
+	VenueMetadata
+
 	Denom   string
 	Address string
 }
@@ -44,9 +46,9 @@ func NewUxPosition(config ProtocolConfig, venuePositionConfig VenuePositionConfi
 	}, nil
 }
 
-func (p UxPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
+func (p UxPosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
 	// Fetch market summary
-	marketSummary, err := p.getMarketSummary()
+	marketSummary, err := p.getMarketSummary(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching market summary: %v", err)
 	}
@@ -57,10 +59,9 @@ func (p UxPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		return nil, fmt.Errorf("missing or invalid 'supplied' field in market summary")
 	}
 
-	// Parse supplyAmount as an integer
-	supplyAmount, err := strconv.ParseInt(supplyAmountStr, 10, 64)
+	supplyAmount, err := parseAmount(supplyAmountStr)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing 'supplied' field: %v", err)
+		return nil, fmt.Errorf("error parsing 'supplied' field: %w", err)
 	}
 
 	tokenInfo, err := assetData.GetTokenInfo(p.venuePositionConfig.Denom)
@@ -68,9 +69,9 @@ func (p UxPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		return nil, fmt.Errorf("error getting token info: %v", err)
 	}
 
-	adjustedAmount := float64(supplyAmount) / math.Pow(10, float64(tokenInfo.Decimals))
+	adjustedAmount := tokenAmount(supplyAmount, tokenInfo.Decimals)
 
-	usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("error calculating token values: %v", err)
 	}
@@ -79,6 +80,7 @@ func (p UxPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 		{
 			Denom:       p.venuePositionConfig.Denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(supplyAmount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		},
@@ -92,12 +94,12 @@ func (p UxPosition) ComputeTVL(assetData *ChainInfo) (*Holdings, error) {
 	}, nil
 }
 
-func (p UxPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p UxPosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	// Construct the query URL
 	queryURL := fmt.Sprintf("%s/leverage/v1/account_balances?address=%s", p.protocolConfig.PoolInfoUrl, address)
 
 	// Fetch account balances
-	resp, err := http.Get(queryURL)
+	resp, err := httpGet(ctx, queryURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetching account balances: %v", err)
 	}
@@ -120,7 +122,7 @@ func (p UxPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, addres
 	}
 
 	// Find the supplied amount for the matching denom
-	var suppliedAmount int64
+	var suppliedAmount *big.Int
 	for _, entry := range supplied {
 		entryMap, ok := entry.(map[string]interface{})
 		if !ok {
@@ -137,14 +139,14 @@ func (p UxPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, addres
 			return nil, fmt.Errorf("missing or invalid 'amount' field for denom %s", denom)
 		}
 
-		suppliedAmount, err = strconv.ParseInt(amountStr, 10, 64)
+		suppliedAmount, err = parseAmount(amountStr)
 		if err != nil {
-			return nil, fmt.Errorf("parsing supplied amount: %v", err)
+			return nil, fmt.Errorf("parsing supplied amount: %w", err)
 		}
 		break
 	}
 
-	if suppliedAmount == 0 {
+	if suppliedAmount == nil || suppliedAmount.Sign() == 0 {
 		return nil, fmt.Errorf("no matching supplied amount found for denom %s", p.venuePositionConfig.Denom)
 	}
 
@@ -153,9 +155,9 @@ func (p UxPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, addres
 		return nil, fmt.Errorf("getting token info: %v", err)
 	}
 
-	adjustedAmount := float64(suppliedAmount) / math.Pow(10, float64(tokenInfo.Decimals))
+	adjustedAmount := tokenAmount(suppliedAmount, tokenInfo.Decimals)
 
-	usdValue, atomValue, err := getTokenValues(adjustedAmount, *tokenInfo)
+	usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
 	if err != nil {
 		return nil, fmt.Errorf("calculating token values: %v", err)
 	}
@@ -164,6 +166,7 @@ func (p UxPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, addres
 		{
 			Denom:       p.venuePositionConfig.Denom,
 			Amount:      adjustedAmount,
+			ExactAmount: exactTokenAmount(suppliedAmount, tokenInfo.Decimals),
 			USDValue:    usdValue,
 			DisplayName: tokenInfo.Display,
 		},
@@ -176,15 +179,19 @@ func (p UxPosition) ComputeAddressPrincipalHoldings(assetData *ChainInfo, addres
 	}, nil
 }
 
-func (p UxPosition) ComputeAddressRewardHoldings(assetData *ChainInfo, address string) (*Holdings, error) {
+func (p UxPosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, RewardsIncludedInPrincipal: true}
+}
+
+func (p UxPosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
 	// Ux does not have separate reward holdings
 	return &Holdings{}, nil
 }
 
-func (p UxPosition) getMarketSummary() (map[string]interface{}, error) {
+func (p UxPosition) getMarketSummary(ctx context.Context) (map[string]interface{}, error) {
 	queryURL := fmt.Sprintf("%s/leverage/v1/market_summary?denom=%s", p.protocolConfig.PoolInfoUrl, p.venuePositionConfig.Denom)
 
-	resp, err := http.Get(queryURL)
+	resp, err := httpGet(ctx, queryURL)
 	if err != nil {
 		return nil, fmt.Errorf("fetching market summary: %v", err)
 	}
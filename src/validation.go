@@ -0,0 +1,382 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var bidConfigWarningsMetric = newGauge("bid_config_warnings",
+	"Number of warnings found by the last validation of the bid configs.")
+
+// FirstBidRequiringDeployedAt is the first bid whose venues must set VenueMetadata.DeployedAt.
+// Bid 81 was the last one in the code when DeployedAt was added, so every bid added since has to
+// set it. The older bids fall back to the start of their round.
+const FirstBidRequiringDeployedAt = 82
+
+// validateBidConfigs checks bid configs for inconsistencies that would only
+// surface as wrong numbers at request time.
+func validateBidConfigs(bids map[int]BidPositionConfig) []error {
+	var errs []error
+
+	// duplicate bid IDs can't make it into the map, they are rejected when loading a config store
+	errs = append(errs, validateUniqueVenues(bids)...)
+
+	for _, bidId := range sortedBidIdsOf(bids) {
+		for _, venueConfig := range bids[bidId].Venues {
+			errs = append(errs, validateVenueLinks(bids, bidId, venueConfig)...)
+			errs = append(errs, validateDeploymentDate(bidId, bids[bidId], venueConfig)...)
+			errs = append(errs, validateTestnetVenue(bidId, venueConfig)...)
+			errs = append(errs, validateVenueEndpoints(bidId, venueConfig)...)
+			errs = append(errs, validateVenueAddresses(bidId, venueConfig)...)
+			errs = append(errs, validatePoolType(bidId, venueConfig)...)
+			errs = append(errs, validateGenericCosmWasmQueries(bidId, venueConfig)...)
+			errs = append(errs, validateUniswapV3Venue(bidId, venueConfig)...)
+		}
+
+		for _, withdrawal := range bids[bidId].Withdrawals {
+			errs = append(errs, validateCompoundingTargets(bidId, withdrawal)...)
+		}
+
+		errs = append(errs, validateAllocations(bidId, bids[bidId])...)
+		errs = append(errs, validateRewardClaims(bids, bidId, bids[bidId])...)
+		errs = append(errs, validateTransfers(bids, bidId, bids[bidId])...)
+	}
+
+	return errs
+}
+
+// bidConfigWarnings checks bid configs for suspicious values that don't prevent computing them,
+// like funds compounded into a bid that isn't tracked (yet) or a position tracked by two bids.
+func bidConfigWarnings(bids map[int]BidPositionConfig) []error {
+	warnings := validateDuplicatePositions(bids)
+	for _, bidId := range sortedBidIdsOf(bids) {
+		for _, withdrawal := range bids[bidId].Withdrawals {
+			warnings = append(warnings, validateCompoundedBids(bids, bidId, withdrawal)...)
+		}
+		warnings = append(warnings, validateBidRound(bidId, bids[bidId])...)
+	}
+	return warnings
+}
+
+// logBidConfigWarnings logs the warnings about the bid configs and exports their number.
+func logBidConfigWarnings(bids map[int]BidPositionConfig) []error {
+	warnings := bidConfigWarnings(bids)
+	for _, warning := range warnings {
+		log.Printf("Bid config warning: %v", warning)
+	}
+	bidConfigWarningsMetric.Set(float64(len(warnings)))
+	return warnings
+}
+
+// validateVenueLinks checks that migration links point to existing venues that link back.
+func validateVenueLinks(bids map[int]BidPositionConfig, bidId int, venueConfig VenuePositionConfig) []error {
+	var errs []error
+
+	id := venueID(bidId, venueConfig)
+	metadata := venueConfig.GetMetadata()
+
+	if metadata.Supersedes != "" {
+		_, previous, ok := findVenueIn(bids, metadata.Supersedes)
+		if !ok {
+			errs = append(errs, fmt.Errorf("venue %s supersedes unknown venue %s", id, metadata.Supersedes))
+		} else if previous.GetMetadata().SupersededBy != id {
+			errs = append(errs, fmt.Errorf("venue %s supersedes %s, but %s is not superseded by it", id, metadata.Supersedes, metadata.Supersedes))
+		}
+	}
+
+	if metadata.SupersededBy != "" {
+		_, next, ok := findVenueIn(bids, metadata.SupersededBy)
+		if !ok {
+			errs = append(errs, fmt.Errorf("venue %s is superseded by unknown venue %s", id, metadata.SupersededBy))
+		} else if next.GetMetadata().Supersedes != id {
+			errs = append(errs, fmt.Errorf("venue %s is superseded by %s, which doesn't supersede it", id, metadata.SupersededBy))
+		}
+	}
+
+	return errs
+}
+
+// validateCompoundingTargets checks that a withdrawal split across several bids is consistent.
+func validateCompoundingTargets(bidId int, withdrawal Withdrawal) []error {
+	if len(withdrawal.CompoundedInto) == 0 {
+		return nil
+	}
+
+	var errs []error
+	date := withdrawal.Date.Format("2006-01-02")
+
+	if withdrawal.CompoundedBidId != 0 {
+		errs = append(errs, fmt.Errorf("bid %d: withdrawal on %s sets both compounded_bid_id and compounded_into", bidId, date))
+	}
+
+	total := 0.0
+	for _, target := range withdrawal.CompoundedInto {
+		if target.BidId == bidId {
+			errs = append(errs, fmt.Errorf("bid %d: withdrawal on %s is compounded into its own bid", bidId, date))
+		}
+		if target.Amount < 0 {
+			errs = append(errs, fmt.Errorf("bid %d: withdrawal on %s compounds a negative amount into bid %d", bidId, date, target.BidId))
+		}
+		total += target.Amount
+	}
+
+	if withdrawal.WithdrawnAmount > 0 && total > withdrawal.WithdrawnAmount {
+		errs = append(errs, fmt.Errorf("bid %d: withdrawal on %s compounds %.2f, more than the withdrawn %.2f", bidId, date, total, withdrawal.WithdrawnAmount))
+	}
+
+	return errs
+}
+
+// validateDeploymentDate checks that the venue's deployment date is set for new bids
+// and precedes the bid's withdrawals.
+func validateDeploymentDate(bidId int, bidConfig BidPositionConfig, venueConfig VenuePositionConfig) []error {
+	if _, ok := venueConfig.(MissingVenuePositionConfig); ok || venueConfig.GetMetadata().Testnet {
+		return nil
+	}
+
+	id := venueID(bidId, venueConfig)
+	deployedAt := venueConfig.GetMetadata().DeployedAt
+
+	if deployedAt.IsZero() {
+		if bidId >= FirstBidRequiringDeployedAt {
+			return []error{fmt.Errorf("venue %s has no deployment date", id)}
+		}
+		return nil
+	}
+
+	var errs []error
+	for _, withdrawal := range bidConfig.Withdrawals {
+		if !deployedAt.Before(withdrawal.Date) {
+			errs = append(errs, fmt.Errorf("venue %s is deployed on %s, not before the withdrawal on %s",
+				id, deployedAt.Format("2006-01-02"), withdrawal.Date.Format("2006-01-02")))
+		}
+	}
+
+	return errs
+}
+
+// validateUniqueVenues checks that no two venues share an ID, which would mix up their cached
+// results, and that no Osmosis position is tracked twice.
+func validateUniqueVenues(bids map[int]BidPositionConfig) []error {
+	var errs []error
+
+	venues := make(map[string]int)
+	positions := make(map[string]string)
+	for _, bidId := range sortedBidIdsOf(bids) {
+		for _, venueConfig := range bids[bidId].Venues {
+			id := venueID(bidId, venueConfig)
+			if otherBidId, ok := venues[id]; ok {
+				errs = append(errs, fmt.Errorf("venue %s of bid %d is also a venue of bid %d", id, bidId, otherBidId))
+			}
+			venues[id] = bidId
+
+			osmosisConfig, ok := venueConfig.(OsmosisVenuePositionConfig)
+			if !ok || osmosisConfig.PositionID == "" {
+				continue
+			}
+			key := osmosisConfig.PositionID
+			if osmosisConfig.Testnet {
+				key += ":testnet"
+			}
+			if other, ok := positions[key]; ok {
+				errs = append(errs, fmt.Errorf("venue %s tracks Osmosis position %s, like venue %s", id, osmosisConfig.PositionID, other))
+			}
+			positions[key] = id
+		}
+	}
+
+	return errs
+}
+
+// positionKey identifies the position a venue tracks, regardless of its bid: its protocol, pool,
+// address, position ID and active shares. It returns false for venues that can't be counted
+// twice: the ones without an integration, and the ones whose active shares are withdrawn.
+func positionKey(venueConfig VenuePositionConfig) (string, bool) {
+	if _, ok := venueConfig.(MissingVenuePositionConfig); ok {
+		return "", false
+	}
+
+	parts := []string{string(venueConfig.GetProtocol()), venueConfig.GetPoolID(), venueConfig.GetAddress()}
+	if positionConfig, ok := venueConfig.(interface{ GetPositionID() string }); ok {
+		parts = append(parts, positionConfig.GetPositionID())
+	}
+
+	switch venueConfig := venueConfig.(type) {
+	case interface{ GetActiveShares() float64 }:
+		if venueConfig.GetActiveShares() == 0 {
+			return "", false
+		}
+		parts = append(parts, strconv.FormatFloat(venueConfig.GetActiveShares(), 'f', -1, 64))
+	// for these, no active shares means all the shares of the address
+	case DemexVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case DropVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case MagmaVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case MarginedVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case PryzmVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case QuasarVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case MitoVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	case ShadeVenuePositionConfig:
+		parts = append(parts, strconv.FormatFloat(venueConfig.ActiveShares, 'f', -1, 64))
+	}
+
+	if venueConfig.GetMetadata().Testnet {
+		parts = append(parts, "testnet")
+	}
+	return strings.Join(parts, "|"), true
+}
+
+// duplicatePositions returns the groups of venues of different bids that track the same position,
+// e.g. after copying the config of a bid, so that its holdings would be counted twice.
+func duplicatePositions(bids map[int]BidPositionConfig) [][]string {
+	venues := make(map[string][]string)
+	bidsOfKey := make(map[string]map[int]bool)
+	var keys []string
+	for _, bidId := range sortedBidIdsOf(bids) {
+		for _, venueConfig := range bids[bidId].Venues {
+			key, ok := positionKey(venueConfig)
+			if !ok {
+				continue
+			}
+			if _, seen := venues[key]; !seen {
+				keys = append(keys, key)
+				bidsOfKey[key] = make(map[int]bool)
+			}
+			venues[key] = append(venues[key], venueID(bidId, venueConfig))
+			bidsOfKey[key][bidId] = true
+		}
+	}
+
+	duplicates := [][]string{}
+	for _, key := range keys {
+		if len(bidsOfKey[key]) > 1 {
+			duplicates = append(duplicates, venues[key])
+		}
+	}
+	return duplicates
+}
+
+// validateDuplicatePositions warns about venues of different bids that track the same position.
+func validateDuplicatePositions(bids map[int]BidPositionConfig) []error {
+	var warnings []error
+	for _, ids := range duplicatePositions(bids) {
+		warnings = append(warnings, fmt.Errorf("venues %s track the same position", strings.Join(ids, ", ")))
+	}
+	return warnings
+}
+
+// validateVenueEndpoints checks that the protocol of a venue with an integration has a pool info URL.
+func validateVenueEndpoints(bidId int, venueConfig VenuePositionConfig) []error {
+	if _, ok := venueConfig.(MissingVenuePositionConfig); ok {
+		return nil
+	}
+
+	id := venueID(bidId, venueConfig)
+	if _, ok := protocolConfigMap[venueConfig.GetProtocol()]; !ok {
+		return []error{fmt.Errorf("venue %s has unknown protocol %s", id, venueConfig.GetProtocol())}
+	}
+	protocolConfig, err := venueProtocolConfig(venueConfig)
+	if err != nil {
+		// reported by validateTestnetVenue
+		return nil
+	}
+	if protocolConfig.PoolInfoUrl == "" {
+		return []error{fmt.Errorf("venue %s is on %s, which has no pool info URL", id, venueConfig.GetProtocol())}
+	}
+	return nil
+}
+
+// venueAddresses returns the bech32 addresses of a venue config by field name.
+func venueAddresses(venueConfig VenuePositionConfig) map[string]string {
+	switch venueConfig := venueConfig.(type) {
+	case AstroportVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "PoolAddress": venueConfig.PoolAddress, "IncentiveAddress": venueConfig.IncentiveAddress}
+	case DualityVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "PoolAddress": venueConfig.PoolAddress}
+	case DropVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "CoreAddress": venueConfig.CoreAddress}
+	case NolusVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "PoolContractAddress": venueConfig.PoolContractAddress}
+	case MarginedVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "VaultAddress": venueConfig.VaultAddress}
+	case MagmaVenuePositionConfig, MitoVenuePositionConfig, QuasarVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.GetAddress(), "VaultAddress": venueConfig.GetPoolID()}
+	case LevanaVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "MarketAddress": venueConfig.MarketAddress}
+	case GenericCosmWasmVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "ContractAddress": venueConfig.ContractAddress}
+	case StakingVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "ValidatorAddress": venueConfig.ValidatorAddress}
+	case ShadeVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "PairAddress": venueConfig.PairAddress}
+	case WhiteWhaleVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.Address, "PoolAddress": venueConfig.PoolAddress, "IncentiveAddress": venueConfig.IncentiveAddress}
+	case DemexVenuePositionConfig, ElysVenuePositionConfig, InterVenuePositionConfig, NeptuneVenuePositionConfig, OsmosisVenuePositionConfig, PryzmVenuePositionConfig, UxVenuePositionConfig:
+		return map[string]string{"Address": venueConfig.GetAddress()}
+	}
+	// Mars venues are identified by a credit account ID, and EVM addresses are checked by
+	// validateUniswapV3Venue
+	return nil
+}
+
+// validateVenueAddresses checks that the addresses of a venue are valid bech32, so that a typo
+// fails the startup rather than every request for the venue.
+func validateVenueAddresses(bidId int, venueConfig VenuePositionConfig) []error {
+	var errs []error
+
+	addresses := venueAddresses(venueConfig)
+	fields := make([]string, 0, len(addresses))
+	for field := range addresses {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		address := addresses[field]
+		if address == "" {
+			// optional addresses, like the incentive contract, may be empty
+			if field == "Address" {
+				errs = append(errs, fmt.Errorf("venue %s has no Address", venueID(bidId, venueConfig)))
+			}
+			continue
+		}
+		if _, err := decodeBech32(address); err != nil {
+			errs = append(errs, fmt.Errorf("venue %s has a malformed %s %q: %v", venueID(bidId, venueConfig), field, address, err))
+		}
+	}
+
+	return errs
+}
+
+// validateCompoundedBids checks that a withdrawal is only compounded into known bids.
+func validateCompoundedBids(bids map[int]BidPositionConfig, bidId int, withdrawal Withdrawal) []error {
+	var errs []error
+	for _, target := range withdrawal.CompoundingTargets() {
+		if _, ok := bids[target.BidId]; !ok {
+			errs = append(errs, fmt.Errorf("bid %d: withdrawal on %s is compounded into unknown bid %d", bidId, withdrawal.Date.Format("2006-01-02"), target.BidId))
+		}
+	}
+	return errs
+}
+
+// validateBidRound checks that the configured round of a bid is in the round calendar.
+func validateBidRound(bidId int, bidConfig BidPositionConfig) []error {
+	if bidConfig.Round == 0 {
+		return nil
+	}
+	for _, round := range roundCalendar {
+		if round.Round == bidConfig.Round {
+			return nil
+		}
+	}
+	return []error{fmt.Errorf("bid %d: round %d is not in the round calendar", bidId, bidConfig.Round)}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"time"
+)
+
+// managedVault is implemented by the queriers of experimental deployments in vaults whose manager
+// charges fees, e.g. Magma.
+type managedVault interface {
+	// FeeRates returns the annual management fee, on the value of the vault, and the performance
+	// fee, on its earnings, in percent.
+	FeeRates() (managementPercent float64, performancePercent float64)
+}
+
+// VaultFees are the fees charged by the manager of a vault between the start of a deployment and
+// its current holdings, with the return of the deployment before (gross) and after them (net), in
+// ATOM. The fees are charged inside the vault, so the holdings are net of them.
+type VaultFees struct {
+	ManagementFeePercent  float64 `json:"management_fee_percent"`
+	PerformanceFeePercent float64 `json:"performance_fee_percent"`
+	DurationDays          float64 `json:"duration_days"`
+	InitialValueAtom      float64 `json:"initial_value_atom"`
+	CurrentValueAtom      float64 `json:"current_value_atom"`
+	ManagementFeesAtom    float64 `json:"management_fees_atom"`
+	PerformanceFeesAtom   float64 `json:"performance_fees_atom"`
+	FeesAtom              float64 `json:"fees_atom"`
+	GrossReturnPercent    float64 `json:"gross_return_percent"`
+	NetReturnPercent      float64 `json:"net_return_percent"`
+}
+
+// computeVaultFees estimates the fees of a managed vault from the return of a deployment. The
+// management fee accrues on the average of the value put into the vault, net of withdrawals, and
+// its current value over the period, and the performance fee is the share of the earnings before
+// it that the net earnings lack. It returns nil if the deployment isn't in a managed vault or its
+// return isn't known.
+func computeVaultFees(deployment *ExperimentalDeployment, ret *ExperimentalReturn, now time.Time) *VaultFees {
+	vault, ok := deployment.Querier.(managedVault)
+	if !ok || ret == nil {
+		return nil
+	}
+	invested := ret.InitialValueAtom + ret.DepositedAtom
+	if invested <= 0 {
+		return nil
+	}
+
+	end := now
+	if deployment.EndTimestamp != 0 && deployment.EndTimestamp < now.Unix() {
+		end = time.Unix(deployment.EndTimestamp, 0)
+	}
+	duration := end.Sub(time.Unix(deployment.StartTimestamp, 0))
+	if duration < 0 {
+		duration = 0
+	}
+	years := duration.Hours() / 24 / 365
+
+	managementPercent, performancePercent := vault.FeeRates()
+	fees := &VaultFees{
+		ManagementFeePercent:  managementPercent,
+		PerformanceFeePercent: performancePercent,
+		DurationDays:          duration.Hours() / 24,
+		InitialValueAtom:      ret.InitialValueAtom,
+		CurrentValueAtom:      ret.CurrentValueAtom,
+	}
+
+	fees.ManagementFeesAtom = managementPercent / 100 * years * (invested - ret.WithdrawnAtom + ret.CurrentValueAtom) / 2
+
+	// the performance fee is only charged on earnings
+	earnings := ret.ReturnAtom + fees.ManagementFeesAtom
+	if earnings > 0 && performancePercent > 0 && performancePercent < 100 {
+		fees.PerformanceFeesAtom = earnings/(1-performancePercent/100) - earnings
+	}
+
+	fees.FeesAtom = fees.ManagementFeesAtom + fees.PerformanceFeesAtom
+	fees.NetReturnPercent = ret.ReturnAtom / invested * 100
+	fees.GrossReturnPercent = (ret.ReturnAtom + fees.FeesAtom) / invested * 100
+	return fees
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultVenueTTL is how long the computation of a venue is served before it expires, unless
+// --venue-ttl says otherwise.
+const DefaultVenueTTL = 30 * time.Minute
+
+// venueTTL is how long the computation of a venue is served before it expires.
+var venueTTL = DefaultVenueTTL
+
+const (
+	// MinBidCacheTTL keeps bids with stale venues cached for a while,
+	// so that requests don't recompute venues whose upstreams are known to be slow.
+	MinBidCacheTTL = time.Minute
+)
+
+// cachedVenue is the last successful computation of a venue.
+type cachedVenue struct {
+	Holdings   VenueHoldings
+	ComputedAt time.Time
+	// Stale is set if a later computation timed out.
+	Stale bool
+}
+
+func (c cachedVenue) expiresAt() time.Time {
+	return c.ComputedAt.Add(venueTTL)
+}
+
+var (
+	venueCacheMu sync.RWMutex
+	// venueCache holds the last successful computation of every venue, by venue ID.
+	venueCache = make(map[string]cachedVenue)
+
+	venueInflightMu sync.Mutex
+	// venueInflight holds the computations that are running right now, by venue ID.
+	venueInflight = make(map[string]*venueCall)
+)
+
+// venueCall is a running computation of a venue that concurrent callers can wait for.
+type venueCall struct {
+	done     chan struct{}
+	holdings *VenueHoldings
+	err      error
+}
+
+// getCachedVenue returns the last computation of a venue. If the local one is missing or expired,
+// a newer one computed by another replica is taken from the shared cache.
+func getCachedVenue(id string) (cachedVenue, bool) {
+	venueCacheMu.RLock()
+	cached, ok := venueCache[id]
+	venueCacheMu.RUnlock()
+
+	if sharedRedis == nil || (ok && time.Now().Before(cached.expiresAt())) {
+		return cached, ok
+	}
+
+	shared, found := loadSharedVenue(id)
+	if !found || (ok && !shared.ComputedAt.After(cached.ComputedAt)) {
+		return cached, ok
+	}
+
+	venueCacheMu.Lock()
+	defer venueCacheMu.Unlock()
+
+	// another goroutine may have stored a newer computation in the meantime
+	if current, ok := venueCache[id]; ok && current.ComputedAt.After(shared.ComputedAt) {
+		return current, true
+	}
+	venueCache[id] = shared
+	return shared, true
+}
+
+func storeVenue(id string, holdings VenueHoldings, computedAt time.Time) {
+	cached := cachedVenue{Holdings: holdings, ComputedAt: computedAt}
+
+	venueCacheMu.Lock()
+	venueCache[id] = cached
+	venueCacheMu.Unlock()
+
+	if sharedRedis != nil {
+		storeSharedVenue(id, cached)
+	}
+}
+
+// markVenueStale flags the cached computation of a venue as stale and returns it.
+func markVenueStale(id string) (cachedVenue, bool) {
+	venueCacheMu.Lock()
+	defer venueCacheMu.Unlock()
+
+	cached, ok := venueCache[id]
+	if !ok {
+		return cached, false
+	}
+
+	cached.Stale = true
+	cached.Holdings.Stale = true
+	venueCache[id] = cached
+	return cached, true
+}
+
+// markVenueDeprecated caches the last known holdings of a venue whose pool is deprecated, as
+// stale so that the refresher keeps retrying it.
+func markVenueDeprecated(id string, holdings VenueHoldings, computedAt time.Time) {
+	venueCacheMu.Lock()
+	venueCache[id] = cachedVenue{Holdings: holdings, ComputedAt: computedAt, Stale: true}
+	venueCacheMu.Unlock()
+}
+
+// refreshVenue recomputes a venue and records the outcome for the status.
+// Concurrent refreshes of the same venue share a single computation.
+func refreshVenue(ctx context.Context, bidId int, venueConfig VenuePositionConfig) (*VenueHoldings, error) {
+	id := venueID(bidId, venueConfig)
+
+	venueInflightMu.Lock()
+	if call, ok := venueInflight[id]; ok {
+		venueInflightMu.Unlock()
+		select {
+		case <-call.done:
+			return call.holdings, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &venueCall{done: make(chan struct{})}
+	venueInflight[id] = call
+	venueInflightMu.Unlock()
+
+	call.holdings, call.err = computeVenueHoldingsWithBudget(ctx, bidId, venueConfig)
+	if call.err == nil && call.holdings.PoolDeprecated {
+		recordVenueResult(id, fmt.Errorf("pool deprecated, serving the last result"))
+	} else if call.err == nil && call.holdings.Stale {
+		recordVenueResult(id, fmt.Errorf("timed out, serving the last result"))
+	} else {
+		recordVenueResult(id, call.err)
+	}
+
+	venueInflightMu.Lock()
+	delete(venueInflight, id)
+	venueInflightMu.Unlock()
+	close(call.done)
+
+	return call.holdings, call.err
+}
+
+// assembleBidHoldings collects the holdings of a bid from the venue cache, marking expired venues as stale,
+// and returns them with the time the oldest of them was computed.
+func assembleBidHoldings(bidId int, now time.Time) ([]VenueHoldings, time.Time, error) {
+	bidConfig, ok := activeBids()[bidId]
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("bid not found: %d", bidId)
+	}
+
+	bidHoldings := make([]VenueHoldings, 0, len(bidConfig.Venues))
+	var oldest time.Time
+	for _, venueConfig := range productionVenues(bidConfig) {
+		id := venueID(bidId, venueConfig)
+		cached, ok := getCachedVenue(id)
+		if !ok {
+			return nil, time.Time{}, fmt.Errorf("venue %s has not been computed", id)
+		}
+
+		holdings := cached.Holdings
+		holdings.Stale = cached.Stale || !now.Before(cached.expiresAt())
+		bidHoldings = append(bidHoldings, holdings)
+
+		if oldest.IsZero() || cached.ComputedAt.Before(oldest) {
+			oldest = cached.ComputedAt
+		}
+	}
+
+	return revalueAtom(bidHoldings, currentPrices.Load()), oldest, nil
+}
+
+// publishBidHoldings assembles the holdings of a bid from the venue cache,
+// caches them until the first of its venues expires and records a snapshot.
+func publishBidHoldings(bidId int) ([]VenueHoldings, error) {
+	now := time.Now()
+	bidHoldings, oldest, err := assembleBidHoldings(bidId, now)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := oldest.Add(venueTTL).Sub(now)
+	if ttl < MinBidCacheTTL {
+		ttl = MinBidCacheTTL
+	}
+	resultCache.SetWithTTL(strconv.Itoa(bidId), bidHoldings, jsonSize(bidHoldings), ttl)
+	recordBidComputed(bidId, oldest)
+	checkBidAllocation(bidId, bidHoldings)
+
+	recordSnapshot(bidId, bidHoldings)
+
+	return bidHoldings, nil
+}
+
+// dueVenue is a venue the refresher has to recompute in the current cycle.
+type dueVenue struct {
+	bidId       int
+	venueConfig VenuePositionConfig
+	terminal    bool // not queried, see venueTerminal
+}
+
+// dueVenues lists the venues that haven't been computed yet or will expire before the end of the window.
+func dueVenues(now time.Time, window time.Duration) []dueVenue {
+	deadline := now.Add(window)
+
+	var due []dueVenue
+	for _, bidId := range sortedBidIds() {
+		if activeBids()[bidId].Frozen != nil {
+			continue
+		}
+		for _, venueConfig := range productionVenues(activeBids()[bidId]) {
+			cached, ok := getCachedVenue(venueID(bidId, venueConfig))
+			if !ok || cached.Stale || cached.expiresAt().Before(deadline) {
+				due = append(due, dueVenue{bidId: bidId, venueConfig: venueConfig, terminal: venueTerminal(activeBids()[bidId], venueConfig)})
+			}
+		}
+	}
+
+	return due
+}
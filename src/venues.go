@@ -0,0 +1,206 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+// VenueMetadata holds protocol-independent information about a venue position.
+// It is embedded into every venue position config.
+type VenueMetadata struct {
+	// Name and Description label the venue for clients, e.g. "ATOM/stATOM on Osmosis".
+	Name        string
+	Description string
+	// PoolType (cl, xyk, stableswap, lending or perp) and PoolAssets (denoms) describe the pool, where
+	// the protocol config doesn't, e.g. the pair of an Osmosis position or a stableswap on Astroport.
+	PoolType   string
+	PoolAssets []string
+	// ID identifies the venue position. If empty, it is derived from the bid, protocol, pool and address.
+	ID string
+	// Supersedes is the ID of the venue position this one was migrated from,
+	// e.g. when liquidity was moved to a new position ID or a new pool.
+	Supersedes string
+	// SupersededBy is the ID of the venue position this one was migrated to.
+	SupersededBy string
+	// DeployedAt is when the funds were deployed to the venue. Required for new bids.
+	DeployedAt time.Time
+	// Testnet venues are computed against the testnet config of their protocol, for rehearsals.
+	// They are left out of the holdings of their bid and served under /testnet/holdings instead.
+	Testnet bool
+}
+
+func (m VenueMetadata) GetMetadata() VenueMetadata {
+	return m
+}
+
+// venueID returns the identifier of a venue position of the given bid.
+func venueID(bidId int, venueConfig VenuePositionConfig) string {
+	if id := venueConfig.GetMetadata().ID; id != "" {
+		return id
+	}
+
+	parts := []string{
+		strconv.Itoa(bidId),
+		protocolSlug(venueConfig.GetProtocol()),
+		venueConfig.GetPoolID(),
+		venueConfig.GetAddress(),
+	}
+
+	// several positions can be opened in the same pool by the same address
+	if positionConfig, ok := venueConfig.(interface{ GetPositionID() string }); ok {
+		parts = append(parts, positionConfig.GetPositionID())
+	}
+
+	if venueConfig.GetMetadata().Testnet {
+		parts = append(parts, "testnet")
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// protocolSlug turns a protocol name like "Astroport (Neutron)" into "astroport-neutron".
+func protocolSlug(protocol Protocol) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(string(protocol)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+			dash = false
+		} else if !dash && sb.Len() > 0 {
+			sb.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(sb.String(), "-")
+}
+
+// parseProtocol looks up a protocol by its name or slug, e.g. "Astroport (Neutron)" or
+// "astroport-neutron".
+func parseProtocol(s string) (Protocol, bool) {
+	slug := protocolSlug(Protocol(s))
+	for p := range protocolConfigMap {
+		if protocolSlug(p) == slug {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// findVenue looks up a venue position by its ID across all bids.
+func findVenue(id string) (int, VenuePositionConfig, bool) {
+	return findVenueIn(activeBids(), id)
+}
+
+func findVenueIn(bids map[int]BidPositionConfig, id string) (int, VenuePositionConfig, bool) {
+	for bidId, bidConfig := range bids {
+		for _, venueConfig := range bidConfig.Venues {
+			if venueID(bidId, venueConfig) == id {
+				return bidId, venueConfig, true
+			}
+		}
+	}
+	return 0, nil, false
+}
+
+// venueLineage returns the IDs of the given venue and all venues it superseded, oldest first.
+func venueLineage(id string) []string {
+	lineage := []string{id}
+	seen := map[string]bool{id: true}
+
+	current := id
+	for {
+		_, venueConfig, ok := findVenue(current)
+		if !ok {
+			break
+		}
+
+		previous := venueConfig.GetMetadata().Supersedes
+		if previous == "" || seen[previous] {
+			break
+		}
+
+		lineage = append([]string{previous}, lineage...)
+		seen[previous] = true
+		current = previous
+	}
+
+	return lineage
+}
+
+// VenueHistoryEntry is the state of a venue position at a point in time.
+type VenueHistoryEntry struct {
+	Timestamp time.Time     `json:"timestamp"`
+	BidId     int           `json:"bid_id"`
+	VenueID   string        `json:"venue_id"`
+	Holdings  VenueHoldings `json:"holdings"`
+	// the prices the snapshot was valued at, which its values are served at rather than today's
+	Prices *SnapshotPrices `json:"prices,omitempty"`
+}
+
+// venueHistory collects the snapshots of a venue, including the ones of the venues it superseded,
+// so that the history is continuous across migrations.
+func venueHistory(id string, from time.Time, to time.Time) ([]VenueHistoryEntry, error) {
+	lineage := make(map[string]bool)
+	for _, lineageID := range venueLineage(id) {
+		lineage[lineageID] = true
+	}
+
+	history := []VenueHistoryEntry{}
+	err := snapshotStore.Range(from, to, func(snapshot BidSnapshot) error {
+		for _, venueHoldings := range snapshot.Holdings {
+			if lineage[venueHoldings.VenueID] {
+				history = append(history, VenueHistoryEntry{
+					Timestamp: snapshot.Timestamp,
+					BidId:     snapshot.BidId,
+					VenueID:   venueHoldings.VenueID,
+					Holdings:  venueHoldings,
+					Prices:    snapshot.Prices,
+				})
+			}
+		}
+		return nil
+	})
+
+	return history, err
+}
+
+// venueHistoryHandler serves the snapshot history of a venue.
+// The time window can be restricted with the RFC 3339 from and to query parameters.
+func venueHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["venue_id"]
+	if _, _, ok := findVenue(id); !ok {
+		http.Error(w, "venue not found: "+id, http.StatusNotFound)
+		return
+	}
+
+	if snapshotStore == nil {
+		http.Error(w, "snapshots are disabled", http.StatusNotFound)
+		return
+	}
+
+	from, to, err := parseTimeWindow(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	history, err := venueHistory(id, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonData, err := marshalResponse(r, history)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonData)
+}
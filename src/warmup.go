@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ready is set once the instance can serve requests from a warm cache.
+	ready atomic.Bool
+
+	warmUpDone  atomic.Int64
+	warmUpTotal atomic.Int64
+)
+
+// warmUp computes all bids with at most parallelism bids at a time, and marks the instance as ready
+// once they are done or the timeout expired. Bids that fail don't hold up the readiness, as they
+// would fail behind the load balancer as well.
+func warmUp(parallelism int, timeout time.Duration) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	bidIds := sortedBidIds()
+	warmUpTotal.Store(int64(len(bidIds)))
+
+	if _, err := takePriceSnapshot(ctx); err != nil {
+		log.Printf("Taking a price snapshot for the warm-up failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var failed atomic.Int64
+	slots := make(chan struct{}, parallelism)
+	for _, bidId := range bidIds {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(bidId int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if _, err := refreshHoldings(ctx, bidId); err != nil {
+				log.Printf("Warm-up of bid %d failed: %v", bidId, err)
+				failed.Add(1)
+			}
+			warmUpDone.Add(1)
+		}(bidId)
+	}
+	wg.Wait()
+
+	log.Printf("Warm-up of %d bids finished in %s, %d failed", len(bidIds), time.Since(start).Round(time.Second), failed.Load())
+	ready.Store(true)
+}
+
+// readyzHandler reports whether the instance is ready to serve requests, for load balancer health checks.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if !ready.Load() {
+		http.Error(w, fmt.Sprintf("warming up: %d of %d bids computed", warmUpDone.Load(), warmUpTotal.Load()), http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ready\n"))
+}
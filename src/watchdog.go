@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRefreshStallTimeout is how long a refresh cycle may go without progress, i.e. without
+// finishing a venue past its scheduled start, before the watchdog restarts it.
+const DefaultRefreshStallTimeout = 5 * time.Minute
+
+// RefreshWatchdogInterval is how often the watchdog checks the heartbeat of a running cycle.
+const RefreshWatchdogInterval = 15 * time.Second
+
+// refreshStallTimeout is set on startup, 0 disables the watchdog.
+var refreshStallTimeout = DefaultRefreshStallTimeout
+
+var (
+	refreshStallsMetric = newCounter("refresh_stalls_total",
+		"Number of refresh cycles cancelled and restarted by the watchdog because they made no progress.")
+	lastRefreshHeartbeatMetric = newGauge("last_refresh_heartbeat_timestamp",
+		"Unix time of the last progress of a refresh cycle.")
+)
+
+// refreshDeadline is the Unix time in nanoseconds by which the running cycle must make progress.
+var refreshDeadline atomic.Int64
+
+// refreshHeartbeat records progress of the cycle of ctx, which expects its next progress once it
+// starts its next venue at next. Cycles cancelled by the watchdog don't beat anymore.
+func refreshHeartbeat(ctx context.Context, next time.Time) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	refreshDeadline.Store(next.Add(refreshStallTimeout).UnixNano())
+	lastRefreshHeartbeatMetric.Set(float64(now.Unix()))
+
+	refreshStateMu.Lock()
+	refreshState.LastHeartbeat = now
+	refreshStateMu.Unlock()
+}
+
+// runWatchedRefreshCycle runs a refresh cycle and cancels it if it misses its heartbeat, e.g. on an
+// upstream call that ignores its timeout. A cycle that doesn't return once cancelled is abandoned.
+// It returns false if the cycle stalled.
+func runWatchedRefreshCycle(interval time.Duration) bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	refreshHeartbeat(ctx, time.Now())
+	done := make(chan struct{})
+	go func() {
+		refreshExpiredVenues(ctx, interval)
+		close(done)
+	}()
+
+	if refreshStallTimeout <= 0 {
+		<-done
+		return true
+	}
+
+	checkInterval := RefreshWatchdogInterval
+	if refreshStallTimeout/2 < checkInterval {
+		checkInterval = refreshStallTimeout / 2
+	}
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return true
+		case now := <-ticker.C:
+			if now.UnixNano() < refreshDeadline.Load() {
+				continue
+			}
+
+			cancel()
+			refreshStallsMetric.Add(1)
+			lastHeartbeat := getRefreshState().LastHeartbeat
+			fireAlert(AlertRefreshStall, "refresher", fmt.Sprintf("the refresh cycle made no progress since %s, restarting it",
+				lastHeartbeat.UTC().Format(time.RFC3339)))
+			return false
+		}
+	}
+}
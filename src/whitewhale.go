@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WhiteWhaleVenuePositionConfig is a liquidity position in a White Whale pool on Migaloo.
+type WhiteWhaleVenuePositionConfig struct {
+	VenueMetadata
+
+	PoolAddress string // Contract address of the pool
+	Address     string
+	// IncentiveAddress is the incentive contract of the pool's LP token, if the LP tokens are
+	// bonded for rewards. Without it, rewards aren't tracked.
+	IncentiveAddress string
+	ActiveShares     int64 // LP token amount, this is a way to track the funds deployed per bid
+}
+
+func (venueConfig WhiteWhaleVenuePositionConfig) GetProtocol() Protocol {
+	return WhiteWhale
+}
+
+func (venueConfig WhiteWhaleVenuePositionConfig) GetPoolID() string {
+	return venueConfig.PoolAddress
+}
+
+func (venueConfig WhiteWhaleVenuePositionConfig) GetAddress() string {
+	return venueConfig.Address
+}
+
+func (venueConfig WhiteWhaleVenuePositionConfig) GetActiveShares() float64 {
+	return float64(venueConfig.ActiveShares)
+}
+
+type WhiteWhalePosition struct {
+	protocolConfig      ProtocolConfig
+	venuePositionConfig WhiteWhaleVenuePositionConfig
+}
+
+func NewWhiteWhalePosition(config ProtocolConfig, venuePositionConfig VenuePositionConfig) (*WhiteWhalePosition, error) {
+	whiteWhaleVenuePositionConfig, ok := venuePositionConfig.(WhiteWhaleVenuePositionConfig)
+	if !ok {
+		return nil, fmt.Errorf("venuePositionConfig must be of WhiteWhaleVenuePositionConfig type")
+	}
+
+	return &WhiteWhalePosition{protocolConfig: config, venuePositionConfig: whiteWhaleVenuePositionConfig}, nil
+}
+
+// Like Astroport, rewards are per address, not per bid.
+func (p WhiteWhalePosition) Capabilities() ProtocolCapabilities {
+	return ProtocolCapabilities{SupportsTVL: true, SupportsPrincipal: true, SupportsRewards: true}
+}
+
+func (p WhiteWhalePosition) ComputeTVL(ctx context.Context, assetData *ChainInfo) (*Holdings, error) {
+	poolData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.PoolAddress, map[string]interface{}{
+			"pool": map[string]interface{}{},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("querying pool data: %s", err)
+	}
+
+	pool, ok := poolData.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid pool structure")
+	}
+	assets, ok := pool["assets"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid pool assets structure")
+	}
+
+	return whiteWhaleAssetHoldings(ctx, assetData, assets)
+}
+
+func (p WhiteWhalePosition) ComputeAddressPrincipalHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	if p.venuePositionConfig.ActiveShares == 0 {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	// Simulate withdrawing the LP shares
+	withdrawData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.PoolAddress, map[string]interface{}{
+			"share": map[string]interface{}{
+				"amount": strconv.FormatInt(p.venuePositionConfig.ActiveShares, 10),
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("simulating withdrawal: %s", err)
+	}
+
+	assets, ok := withdrawData.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid withdrawal structure")
+	}
+
+	return whiteWhaleAssetHoldings(ctx, assetData, assets)
+}
+
+func (p WhiteWhalePosition) ComputeAddressRewardHoldings(ctx context.Context, assetData *ChainInfo, address string) (*Holdings, error) {
+	if p.venuePositionConfig.ActiveShares == 0 || p.venuePositionConfig.IncentiveAddress == "" {
+		return &Holdings{
+			Balances:  []Asset{},
+			TotalUSDC: 0,
+			TotalAtom: 0,
+		}, nil
+	}
+
+	rewardsData, err := QuerySmartContractData(ctx, p.protocolConfig.PoolInfoUrl,
+		p.venuePositionConfig.IncentiveAddress, map[string]interface{}{
+			"rewards": map[string]interface{}{
+				"address": address,
+			},
+		})
+	if err != nil {
+		// addresses without a bonded position have no rewards
+		if strings.Contains(err.Error(), "no position") {
+			return &Holdings{
+				Balances:  []Asset{},
+				TotalUSDC: 0,
+				TotalAtom: 0,
+			}, nil
+		}
+		return nil, fmt.Errorf("querying rewards: %s", err)
+	}
+
+	rewards, ok := rewardsData.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid rewards structure")
+	}
+	assets, ok := rewards["rewards"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid rewards assets structure")
+	}
+
+	return whiteWhaleAssetHoldings(ctx, assetData, assets)
+}
+
+// whiteWhaleAssetHoldings values a list of White Whale assets, each an amount with the info of a
+// native or CW20 token. Tokens without token info or price are left out.
+func whiteWhaleAssetHoldings(ctx context.Context, assetData *ChainInfo, assets []interface{}) (*Holdings, error) {
+	holdings := &Holdings{Balances: []Asset{}}
+
+	for _, asset := range assets {
+		assetMap, ok := asset.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid asset structure")
+		}
+		denom, err := whiteWhaleAssetDenom(assetMap)
+		if err != nil {
+			return nil, err
+		}
+		amountStr, _ := assetMap["amount"].(string)
+		amount, err := strconv.ParseFloat(amountStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse amount of %s into float64: %s", denom, err)
+		}
+
+		tokenInfo, err := assetData.GetTokenInfo(denom)
+		if err != nil {
+			debugLog("Token info not found", map[string]string{"denom": denom})
+			continue
+		}
+
+		adjustedAmount := amount / math.Pow(10, float64(tokenInfo.Decimals))
+		usdValue, atomValue, err := getTokenValues(ctx, adjustedAmount, *tokenInfo)
+		if err != nil {
+			debugLog("Error getting token values", map[string]string{"denom": denom})
+			continue
+		}
+
+		holdings.TotalUSDC += usdValue
+		holdings.TotalAtom += atomValue
+		holdings.Balances = append(holdings.Balances, Asset{
+			Denom:       denom,
+			Amount:      adjustedAmount,
+			USDValue:    usdValue,
+			DisplayName: tokenInfo.Display,
+		})
+	}
+
+	return holdings, nil
+}
+
+// whiteWhaleAssetDenom returns the denom of a native token, or the address of a CW20 token.
+func whiteWhaleAssetDenom(asset map[string]interface{}) (string, error) {
+	info, ok := asset["info"].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("invalid asset info structure")
+	}
+	if nativeToken, ok := info["native_token"].(map[string]interface{}); ok {
+		if denom, ok := nativeToken["denom"].(string); ok {
+			return denom, nil
+		}
+	}
+	if token, ok := info["token"].(map[string]interface{}); ok {
+		if contractAddr, ok := token["contract_addr"].(string); ok {
+			return contractAddr, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported asset info: %v", info)
+}
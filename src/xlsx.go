@@ -0,0 +1,189 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// A minimal XLSX writer: inline strings, numbers and a few fixed cell styles.
+
+// Cell styles, indexes into cellXfs of xlsxStyles.
+const (
+	xlsxStyleDefault = iota
+	xlsxStyleHeader
+	xlsxStyleNumber
+	xlsxStylePercent
+	xlsxStyleDate
+)
+
+// xlsxStyles is the stylesheet, with the format code of dates to fill in.
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="%s"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="5">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+// xlsxCell is a cell value: a string, a number (int or float64), a time.Time or nil for an empty cell.
+// NaN and infinite numbers are written as empty cells.
+type xlsxCell struct {
+	Value interface{}
+	Style int
+}
+
+func xlsxNumber(v float64) xlsxCell { return xlsxCell{Value: v, Style: xlsxStyleNumber} }
+
+// xlsxPercent formats a fraction, e.g. 0.05 as 5.00%.
+func xlsxPercent(v float64) xlsxCell { return xlsxCell{Value: v, Style: xlsxStylePercent} }
+
+func xlsxDate(t time.Time) xlsxCell { return xlsxCell{Value: t, Style: xlsxStyleDate} }
+
+func xlsxHeaderRow(titles ...string) []xlsxCell {
+	row := make([]xlsxCell, len(titles))
+	for i, title := range titles {
+		row[i] = xlsxCell{Value: title, Style: xlsxStyleHeader}
+	}
+	return row
+}
+
+type xlsxSheet struct {
+	Name      string
+	ColWidths []float64
+	Rows      [][]xlsxCell
+}
+
+// xlsxEpoch is day 0 of the spreadsheet date system.
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+func xlsxEscape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// xlsxColumnName turns a zero-based column index into A, B, ..., Z, AA, ...
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+func (s *xlsxSheet) xml() string {
+	var sb strings.Builder
+	sb.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+
+	if len(s.ColWidths) > 0 {
+		sb.WriteString("<cols>")
+		for i, width := range s.ColWidths {
+			fmt.Fprintf(&sb, `<col min="%d" max="%d" width="%g" customWidth="1"/>`, i+1, i+1, width)
+		}
+		sb.WriteString("</cols>")
+	}
+
+	sb.WriteString("<sheetData>")
+	for r, row := range s.Rows {
+		fmt.Fprintf(&sb, `<row r="%d">`, r+1)
+		for c, cell := range row {
+			ref := fmt.Sprintf("%s%d", xlsxColumnName(c), r+1)
+			switch v := cell.Value.(type) {
+			case nil:
+				continue
+			case string:
+				fmt.Fprintf(&sb, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, cell.Style, xlsxEscape(v))
+			case int:
+				fmt.Fprintf(&sb, `<c r="%s" s="%d"><v>%d</v></c>`, ref, cell.Style, v)
+			case float64:
+				if math.IsNaN(v) || math.IsInf(v, 0) {
+					// a spreadsheet has no NaN or infinity, leave the cell empty
+					continue
+				}
+				fmt.Fprintf(&sb, `<c r="%s" s="%d"><v>%g</v></c>`, ref, cell.Style, v)
+			case time.Time:
+				days := v.Sub(xlsxEpoch).Hours() / 24
+				fmt.Fprintf(&sb, `<c r="%s" s="%d"><v>%g</v></c>`, ref, cell.Style, days)
+			}
+		}
+		sb.WriteString("</row>")
+	}
+	sb.WriteString("</sheetData></worksheet>")
+
+	return sb.String()
+}
+
+// writeXLSX writes the sheets as an Excel workbook, with dates in the format of the locale.
+func writeXLSX(w io.Writer, sheets []xlsxSheet, locale ExportLocale) error {
+	zw := zip.NewWriter(w)
+
+	var contentTypes, workbook, workbookRels strings.Builder
+	contentTypes.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+`)
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rIdStyles" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+`)
+
+	for i, sheet := range sheets {
+		fmt.Fprintf(&contentTypes, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", i+1)
+		fmt.Fprintf(&workbook, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, xlsxEscape(sheet.Name), i+1, i+1)
+		fmt.Fprintf(&workbookRels, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`+"\n", i+1, i+1)
+	}
+	contentTypes.WriteString("</Types>")
+	workbook.WriteString("</sheets></workbook>")
+	workbookRels.WriteString("</Relationships>")
+
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypes.String()},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+		{"xl/styles.xml", fmt.Sprintf(xlsxStyles, xlsxEscape(locale.xlsxDateFormat()))},
+	}
+	for i := range sheets {
+		files = append(files, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheets[i].xml()})
+	}
+
+	for _, file := range files {
+		f, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, file.content); err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
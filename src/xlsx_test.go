@@ -0,0 +1,184 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+// readXLSX unzips a workbook into its parts by name.
+func readXLSX(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("not a zip file: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[f.Name] = string(content)
+	}
+	return parts
+}
+
+type xlsxTestSheet struct {
+	Cols []struct {
+		Min   int     `xml:"min,attr"`
+		Width float64 `xml:"width,attr"`
+	} `xml:"cols>col"`
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Style  int    `xml:"s,attr"`
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func TestWriteXLSX(t *testing.T) {
+	sheets := []xlsxSheet{{
+		Name:      "Bids & <venues>",
+		ColWidths: []float64{12, 30.5},
+		Rows: [][]xlsxCell{
+			xlsxHeaderRow("Bid", `Name "quoted" & <tagged>`),
+			{{Value: 42}, {Value: "ATOM/USDC\tpool\x01 ünïcode"}},
+			{xlsxNumber(6976.354), xlsxPercent(0.05)},
+			{xlsxDate(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)), {Value: nil}, {Value: "after a gap"}},
+			{xlsxNumber(math.NaN()), xlsxNumber(math.Inf(1))},
+		},
+	}, {Name: "Empty"}}
+
+	var buf bytes.Buffer
+	if err := writeXLSX(&buf, sheets, ExportLocale{DecimalSeparator: ",", DateFormat: DateFormatDMY}); err != nil {
+		t.Fatal(err)
+	}
+	parts := readXLSX(t, buf.Bytes())
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Errorf("missing part %s", name)
+			continue
+		}
+		// every part must be well-formed XML
+		dec := xml.NewDecoder(bytes.NewReader([]byte(content)))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s is not well-formed: %v", name, err)
+				break
+			}
+		}
+	}
+	// strings are inline, so there is no shared strings part to keep in sync
+	if _, ok := parts["xl/sharedStrings.xml"]; ok {
+		t.Errorf("unexpected shared strings part")
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/workbook.xml"]), &workbook); err != nil {
+		t.Fatal(err)
+	}
+	if len(workbook.Sheets) != 2 || workbook.Sheets[0].Name != "Bids & <venues>" || workbook.Sheets[1].Name != "Empty" {
+		t.Errorf("sheets: %+v", workbook.Sheets)
+	}
+
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+			FontID   int `xml:"fontId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := xml.Unmarshal([]byte(parts["xl/styles.xml"]), &styles); err != nil {
+		t.Fatal(err)
+	}
+	if len(styles.NumFmts) != 1 || styles.NumFmts[0].ID != 164 || styles.NumFmts[0].Code != "dd.mm.yyyy" {
+		t.Errorf("number formats: %+v", styles.NumFmts)
+	}
+	// number formats by style: 4 is #,##0.00, 10 is 0.00%, 164 the date format
+	wantNumFmts := map[int]int{xlsxStyleDefault: 0, xlsxStyleHeader: 0, xlsxStyleNumber: 4, xlsxStylePercent: 10, xlsxStyleDate: 164}
+	if len(styles.CellXfs) != len(wantNumFmts) {
+		t.Fatalf("got %d cell styles", len(styles.CellXfs))
+	}
+	for style, numFmt := range wantNumFmts {
+		if styles.CellXfs[style].NumFmtID != numFmt {
+			t.Errorf("style %d has number format %d, want %d", style, styles.CellXfs[style].NumFmtID, numFmt)
+		}
+	}
+	if styles.CellXfs[xlsxStyleHeader].FontID != 1 {
+		t.Errorf("header style isn't bold")
+	}
+
+	var sheet xlsxTestSheet
+	if err := xml.Unmarshal([]byte(parts["xl/worksheets/sheet1.xml"]), &sheet); err != nil {
+		t.Fatal(err)
+	}
+	if len(sheet.Cols) != 2 || sheet.Cols[1].Min != 2 || sheet.Cols[1].Width != 30.5 {
+		t.Errorf("columns: %+v", sheet.Cols)
+	}
+
+	type cell struct {
+		ref, typ, value string
+		style           int
+	}
+	want := [][]cell{
+		{{"A1", "inlineStr", "Bid", xlsxStyleHeader}, {"B1", "inlineStr", `Name "quoted" & <tagged>`, xlsxStyleHeader}},
+		{{"A2", "", "42", xlsxStyleDefault}, {"B2", "inlineStr", "ATOM/USDC\tpool� ünïcode", xlsxStyleDefault}},
+		{{"A3", "", "6976.354", xlsxStyleNumber}, {"B3", "", "0.05", xlsxStylePercent}},
+		{{"A4", "", "45717.5", xlsxStyleDate}, {"C4", "inlineStr", "after a gap", xlsxStyleDefault}},
+		{},
+	}
+	if len(sheet.Rows) != len(want) {
+		t.Fatalf("got %d rows, want %d", len(sheet.Rows), len(want))
+	}
+	for r, row := range sheet.Rows {
+		if row.R != r+1 {
+			t.Errorf("row %d has number %d", r+1, row.R)
+		}
+		if len(row.Cells) != len(want[r]) {
+			t.Errorf("row %d: got %d cells, want %d", r+1, len(row.Cells), len(want[r]))
+			continue
+		}
+		for c, got := range row.Cells {
+			value := got.Value
+			if got.Type == "inlineStr" {
+				value = got.Inline
+			}
+			if g := (cell{got.Ref, got.Type, value, got.Style}); g != want[r][c] {
+				t.Errorf("cell %s: got %+v, want %+v", want[r][c].ref, g, want[r][c])
+			}
+		}
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := xlsxColumnName(i); got != want {
+			t.Errorf("column %d: got %s, want %s", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// yamlToJSON converts a YAML document to JSON, so that it can be decoded like a JSON config. It
+// supports the subset of YAML that configs are written in: block mappings and sequences, flow
+// sequences and mappings on a single line, plain and quoted scalars, and comments. Anchors, tags,
+// block scalars and multiple documents are rejected. Keys are kept as written, as JSON keys are
+// strings anyway.
+func yamlToJSON(data []byte) ([]byte, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(stripYAMLComment(line), " \t\r")
+		trimmed := strings.TrimLeft(line, " ")
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		if trimmed == "---" && len(p.lines) == 0 {
+			continue
+		}
+		if trimmed == "---" || trimmed == "..." {
+			return nil, fmt.Errorf("line %d: multiple documents are not supported", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(line) - len(trimmed), text: trimmed})
+	}
+
+	var value interface{}
+	if len(p.lines) > 0 {
+		var err error
+		if value, err = p.parseNode(p.lines[0].indent); err != nil {
+			return nil, err
+		}
+		if p.pos < len(p.lines) {
+			return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+		}
+	}
+	return json.Marshal(value)
+}
+
+type yamlLine struct {
+	num    int // 1-based, for errors
+	indent int
+	text   string // without the indentation
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseNode parses the block mapping or sequence starting at the current line.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	if isYAMLSequenceItem(p.lines[p.pos].text) {
+		return p.parseSequence(indent)
+	}
+	return p.parseMapping(indent)
+}
+
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(line.text[1:], " ")
+
+		if rest == "" {
+			// the item is the block below
+			p.pos++
+			item, err := p.parseNested(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		if _, _, ok := splitYAMLKey(rest); ok || isYAMLSequenceItem(rest) {
+			// a block that starts on the line of the dash: parse it as if it started on its own line
+			itemIndent := indent + len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: itemIndent, text: rest}
+			item, err := p.parseNode(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		item, err := parseYAMLScalar(rest, line.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		p.pos++
+	}
+
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	mapping := map[string]interface{}{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if isYAMLSequenceItem(line.text) {
+			return nil, fmt.Errorf("line %d: expected a key, got a sequence item", line.num)
+		}
+
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line.num)
+		}
+		keyString, err := parseYAMLKey(key, line.num)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := mapping[keyString]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, keyString)
+		}
+		p.pos++
+
+		if value != "" {
+			if mapping[keyString], err = parseYAMLScalar(value, line.num); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// a sequence below a key may be indented as much as the key
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSequenceItem(p.lines[p.pos].text) {
+			if mapping[keyString], err = p.parseSequence(indent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if mapping[keyString], err = p.parseNested(indent); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return mapping, nil
+}
+
+// parseNested parses the block indented deeper than the parent, or null if there is none.
+func (p *yamlParser) parseNested(parentIndent int) (interface{}, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= parentIndent {
+		return nil, nil
+	}
+	return p.parseNode(p.lines[p.pos].indent)
+}
+
+func isYAMLSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey splits "key: value" at the first colon outside of quotes and flow collections
+// that is followed by a space or ends the line.
+func splitYAMLKey(text string) (string, string, bool) {
+	for i := range text {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') && !insideYAMLQuotes(text[:i]) {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), i > 0
+		}
+	}
+	return "", "", false
+}
+
+// stripYAMLComment removes a comment, i.e. a # at the start or after a space, outside of quotes.
+func stripYAMLComment(line string) string {
+	for i := range line {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') && !insideYAMLQuotes(line[:i]) {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// insideYAMLQuotes reports whether the end of the text is inside a quoted string or a flow collection.
+func insideYAMLQuotes(text string) bool {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" [{,:", text[i-1]) >= 0):
+			// quotes only start at the beginning of a scalar, unlike apostrophes in plain text
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case (c == ']' || c == '}') && depth > 0:
+			depth--
+		}
+	}
+	return quote != 0 || depth > 0
+}
+
+// yamlFloat matches the floats of the YAML core schema, apart from .inf and .nan.
+var yamlFloat = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+
+// parseYAMLScalar parses a scalar or a flow collection.
+func parseYAMLScalar(text string, lineNum int) (interface{}, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	switch text[0] {
+	case '"', '\'':
+		return parseYAMLQuoted(text, lineNum)
+	case '[':
+		if text[len(text)-1] != ']' {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence %s", lineNum, text)
+		}
+		items := []interface{}{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLScalar(part, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case '{':
+		if text[len(text)-1] != '}' {
+			return nil, fmt.Errorf("line %d: unterminated flow mapping %s", lineNum, text)
+		}
+		mapping := map[string]interface{}{}
+		for _, part := range splitYAMLFlow(text[1 : len(text)-1]) {
+			key, value, ok := splitYAMLKey(part)
+			if !ok {
+				return nil, fmt.Errorf("line %d: expected \"key: value\" in flow mapping, got %q", lineNum, part)
+			}
+			keyString, err := parseYAMLKey(key, lineNum)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := mapping[keyString]; ok {
+				return nil, fmt.Errorf("line %d: duplicate key %q", lineNum, keyString)
+			}
+			if mapping[keyString], err = parseYAMLScalar(value, lineNum); err != nil {
+				return nil, err
+			}
+		}
+		return mapping, nil
+	case '&', '*', '!', '|', '>', '%', '@', '`':
+		return nil, fmt.Errorf("line %d: unsupported YAML syntax %q", lineNum, text)
+	}
+
+	switch text {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return n, nil
+	}
+	// ParseFloat also takes e.g. 1_000, 0x1p3 and Inf, which are strings in YAML
+	if yamlFloat.MatchString(text) {
+		if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) {
+			return f, nil
+		}
+	}
+	return text, nil
+}
+
+// parseYAMLKey parses the key of a mapping. Plain keys are kept as written, so that e.g. 007 or
+// 1e3 aren't turned into numbers; quoted ones are unquoted.
+func parseYAMLKey(text string, lineNum int) (string, error) {
+	switch text[0] {
+	case '"', '\'':
+		return parseYAMLQuoted(text, lineNum)
+	case '[', '{', '&', '*', '!', '|', '>', '%', '@', '`', '?':
+		return "", fmt.Errorf("line %d: unsupported key %q", lineNum, text)
+	}
+	return text, nil
+}
+
+// parseYAMLQuoted parses a single- or double-quoted scalar.
+func parseYAMLQuoted(text string, lineNum int) (string, error) {
+	quote := text[0]
+	if len(text) < 2 || text[len(text)-1] != quote {
+		return "", fmt.Errorf("line %d: unterminated quoted string %s", lineNum, text)
+	}
+	inner := text[1 : len(text)-1]
+
+	if quote == '\'' {
+		// the only escape is a doubled quote
+		if strings.Contains(strings.ReplaceAll(inner, "''", ""), "'") {
+			return "", fmt.Errorf("line %d: invalid single-quoted string %s", lineNum, text)
+		}
+		return strings.ReplaceAll(inner, "''", "'"), nil
+	}
+
+	s, err := unescapeYAMLDoubleQuoted(inner)
+	if err != nil {
+		return "", fmt.Errorf("line %d: invalid double-quoted string %s: %v", lineNum, text, err)
+	}
+	return s, nil
+}
+
+// yamlEscapes are the single-character escapes of double-quoted YAML scalars.
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// unescapeYAMLDoubleQuoted resolves the escapes of the inside of a double-quoted YAML scalar, which
+// differ from Go's: e.g. \e and \/ are valid, \' and octal escapes aren't.
+func unescapeYAMLDoubleQuoted(inner string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		if c == '"' {
+			return "", fmt.Errorf("unescaped quote")
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+1 == len(inner) {
+			return "", fmt.Errorf("trailing backslash")
+		}
+		i++
+		if escaped, ok := yamlEscapes[inner[i]]; ok {
+			b.WriteString(escaped)
+			continue
+		}
+
+		digits := 0
+		switch inner[i] {
+		case 'x':
+			digits = 2
+		case 'u':
+			digits = 4
+		case 'U':
+			digits = 8
+		}
+		if digits == 0 || i+digits >= len(inner) {
+			return "", fmt.Errorf("invalid escape \\%c", inner[i])
+		}
+		code, err := strconv.ParseUint(inner[i+1:i+1+digits], 16, 32)
+		if err != nil || code > utf8.MaxRune || (code >= 0xD800 && code <= 0xDFFF) {
+			return "", fmt.Errorf("invalid escape \\%s", inner[i:i+1+digits])
+		}
+		b.WriteRune(rune(code))
+		i += digits
+	}
+	return b.String(), nil
+}
+
+// splitYAMLFlow splits the inside of a flow collection at the commas outside of nested collections
+// and quotes.
+func splitYAMLFlow(text string) []string {
+	var parts []string
+	start := 0
+	for i := range text {
+		if text[i] == ',' && !insideYAMLQuotes(text[start:i]) {
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" || len(parts) > 0 {
+		parts = append(parts, last)
+	}
+	return parts
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		json string
+	}{
+		{"empty document", "", `null`},
+		{"comments and document marker", "---\n# bids\nversion: 1 # current\n", `{"version":1}`},
+		{"block mapping", "a: 1\nb:\n  c: true\n  d: null\n", `{"a":1,"b":{"c":true,"d":null}}`},
+		{"missing value", "a:\nb: 2\n", `{"a":null,"b":2}`},
+		{"block sequence", "- 1\n- two\n- 3.5\n", `[1,"two",3.5]`},
+		{"sequence as indented as its key", "venues:\n- kind: osmosis\n  config:\n    PoolId: \"1283\"\n- kind: mars\n", `{"venues":[{"config":{"PoolId":"1283"},"kind":"osmosis"},{"kind":"mars"}]}`},
+		{"nested sequences", "- - 1\n  - 2\n- []\n", `[[1,2],[]]`},
+		{"flow collections", "a: [1, \"b, c\", {d: e}]\nf: {}\n", `{"a":[1,"b, c",{"d":"e"}],"f":{}}`},
+		{"plain scalars", "a: hello world\nb: it's\nc: -7\nd: 1e3\ne: ~\nf: FALSE\ng: 1_000\n", `{"a":"hello world","b":"it's","c":-7,"d":1000,"e":null,"f":false,"g":"1_000"}`},
+		{"colon and hash inside scalars", "url: https://rest.cosmos.directory/osmosis#x\nq: \"a: # b\"\n", `{"q":"a: # b","url":"https://rest.cosmos.directory/osmosis#x"}`},
+		{"single-quoted", "a: 'it''s \\n'\n", `{"a":"it's \\n"}`},
+		{"double-quoted escapes", `a: "tab\there \"q\" \\ \/ \e \x41 \u00e9 \U0001F600 \N \_ \0"`, "{\"a\":\"tab\\there \\\"q\\\" \\\\ / \\u001b A é 😀 \u0085 \u00a0 \\u0000\"}"},
+		{"keys are literal", "007: a\n1e3: b\ntrue: c\nnull: d\n\"quoted key\": e\n'x': f\n", `{"007":"a","1e3":"b","null":"d","quoted key":"e","true":"c","x":"f"}`},
+		{"flow keys are literal", "a: {007: x, 1e3: y}", `{"a":{"007":"x","1e3":"y"}}`},
+		{"windows line endings", "a: 1\r\nb: 2\r\n", `{"a":1,"b":2}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := yamlToJSON([]byte(test.yaml))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != test.json {
+				t.Errorf("got %s, want %s", got, test.json)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"tab indentation", "a:\n\tb: 1\n", "line 2: tabs are not allowed"},
+		{"multiple documents", "a: 1\n---\nb: 2\n", "line 2: multiple documents"},
+		{"document end", "a: 1\n...\n", "line 2: multiple documents"},
+		{"anchor", "a: &x 1\n", "unsupported YAML syntax"},
+		{"alias", "a: *x\n", "unsupported YAML syntax"},
+		{"tag", "a: !!str 1\n", "unsupported YAML syntax"},
+		{"literal block scalar", "a: |\n  text\n", "unsupported YAML syntax"},
+		{"folded block scalar", "a: >\n  text\n", "unsupported YAML syntax"},
+		{"complex key", "? a\n: b\n", "expected \"key: value\""},
+		{"flow key", "[a]: b\n", "unsupported key"},
+		{"duplicate key", "a: 1\na: 2\n", "duplicate key \"a\""},
+		{"duplicate flow key", "a: {b: 1, b: 2}\n", "duplicate key \"b\""},
+		{"duplicate key after unquoting", "a: 1\n\"a\": 2\n", "duplicate key \"a\""},
+		{"sequence in mapping", "a: 1\n- b\n", "expected a key"},
+		{"unexpected indentation", "a: 1\n  b: 2\n", "line 2: unexpected indentation"},
+		{"not a mapping", "a: 1\nb\n", "expected \"key: value\""},
+		{"go escape", `a: "\'"`, "invalid escape"},
+		{"octal escape", `a: "\101"`, "invalid escape"},
+		{"short unicode escape", `a: "\u00"`, "invalid escape"},
+		{"surrogate escape", `a: "\uD800"`, "invalid escape"},
+		{"escaped closing quote", `a: "\"`, "invalid double-quoted string"},
+		{"unterminated double quote", `a: "abc`, "unterminated"},
+		{"stray single quote", `a: 'it's'`, "invalid single-quoted string"},
+		{"unterminated flow sequence", "a: [1, 2\n", "unterminated flow sequence"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := yamlToJSON([]byte(test.yaml))
+			if err == nil {
+				t.Fatalf("expected an error, got %s", got)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Errorf("error %q doesn't contain %q", err, test.err)
+			}
+		})
+	}
+}